@@ -2,6 +2,7 @@ package client
 
 import (
 	"encoding/csv"
+	"fmt"
 	"net/http"
 )
 
@@ -12,6 +13,10 @@ func GetCSVData(url string) ([][]string, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch dataset, status %d", resp.StatusCode)
+	}
+
 	r := csv.NewReader(resp.Body)
 
 	records, err := r.ReadAll()
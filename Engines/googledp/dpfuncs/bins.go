@@ -0,0 +1,178 @@
+package dpfuncs
+
+import (
+	"fmt"
+	"googledp/entities"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+type bin struct {
+	Label string
+	// bounds of numeric bins, infinite for the underflow and overflow bins
+	Lower       float64
+	Upper       float64
+	Categorical bool
+	Rows        [][]string
+}
+
+// Binz holds the bins of a column in output order
+type Binz struct {
+	Column string
+	Bins   []bin
+}
+
+// rows outside every bin are dropped, how many is not released
+func makeBins(step entities.BinStep, schema []entities.Column, data [][]string) (Binz, error) {
+	index, colType, err := getIndexAndTypeFromSchema(schema, step.Column)
+
+	if err != nil {
+		return Binz{}, err
+	}
+
+	switch colType.GetName() {
+	case "Enum", "Bool":
+		return makeLabelBins(step, schema[index], index, data)
+	case "Int", "Double":
+		return makeEdgeBins(step, schema[index], index, data)
+	default:
+		return Binz{}, fmt.Errorf("can only do bin on Int, Double, Enum or Bool columns")
+	}
+}
+
+func makeLabelBins(step entities.BinStep, col entities.Column, index int, data [][]string) (Binz, error) {
+	if len(step.Edges) > 0 || step.Underflow || step.Overflow {
+		return Binz{}, fmt.Errorf("bins not well formatted, %s columns are binned by labels", col.Type.GetName())
+	}
+
+	known := col.Type.GetLabels()
+	labels := step.Labels
+	if len(labels) == 0 {
+		labels = known
+	}
+
+	if len(labels) == 0 {
+		return Binz{}, fmt.Errorf("bins not well formatted, column %s has no labels", col.Name)
+	}
+
+	binz := Binz{Column: col.Name}
+	positions := make(map[string]int)
+
+	for i, label := range labels {
+		if !slices.Contains(known, label) {
+			return Binz{}, fmt.Errorf("bins not well formatted, %s is not a label of column %s", label, col.Name)
+		}
+		if _, ok := positions[label]; ok {
+			return Binz{}, fmt.Errorf("bins not well formatted, label %s occurs multiple times", label)
+		}
+		positions[label] = i
+		binz.Bins = append(binz.Bins, bin{Label: label, Categorical: true, Rows: make([][]string, 0)})
+	}
+
+	for _, row := range data {
+		cell := row[index]
+		if col.Type.GetName() == "Bool" {
+			b, ok, err := boolValue(col, cell)
+			if err != nil {
+				return Binz{}, err
+			}
+			if !ok {
+				continue
+			}
+			cell = strconv.FormatBool(b)
+		}
+
+		if i, ok := positions[cell]; ok {
+			binz.Bins[i].Rows = append(binz.Bins[i].Rows, row)
+		}
+	}
+
+	return binz, nil
+}
+
+func makeEdgeBins(step entities.BinStep, col entities.Column, index int, data [][]string) (Binz, error) {
+	if len(step.Labels) > 0 {
+		return Binz{}, fmt.Errorf("bins not well formatted, %s columns are binned by edges", col.Type.GetName())
+	}
+
+	edges := step.Edges
+
+	if !isUniqueAndSorted(edges) {
+		return Binz{}, fmt.Errorf("bins not well formatted, bins should be unique and in ascending order")
+	}
+
+	if len(edges) == 0 || (len(edges) < 2 && !step.Underflow && !step.Overflow) {
+		return Binz{}, fmt.Errorf("bins not well formatted, minimum 2 bins")
+	}
+
+	binz := Binz{Column: col.Name}
+
+	if step.Underflow {
+		binz.Bins = append(binz.Bins, newEdgeBin(math.Inf(-1), edges[0]))
+	}
+	for i := range edges[:len(edges)-1] {
+		binz.Bins = append(binz.Bins, newEdgeBin(edges[i], edges[i+1]))
+	}
+	if step.Overflow {
+		binz.Bins = append(binz.Bins, newEdgeBin(edges[len(edges)-1], math.Inf(1)))
+	}
+
+	for _, row := range data {
+		f, ok, err := numericValue(col, row[index])
+		if err != nil {
+			return Binz{}, err
+		}
+		if !ok {
+			continue
+		}
+
+		// index of the first bin with an upper bound above f
+		i := sort.Search(len(binz.Bins), func(i int) bool { return f < binz.Bins[i].Upper })
+		if i < len(binz.Bins) && f >= binz.Bins[i].Lower {
+			binz.Bins[i].Rows = append(binz.Bins[i].Rows, row)
+		}
+	}
+
+	return binz, nil
+}
+
+func newEdgeBin(lower, upper float64) bin {
+	return bin{
+		Label: fmt.Sprintf("[%s, %s)", formatEdge(lower), formatEdge(upper)),
+		Lower: lower,
+		Upper: upper,
+		Rows:  make([][]string, 0),
+	}
+}
+
+func formatEdge(edge float64) string {
+	if math.IsInf(edge, -1) {
+		return "-inf"
+	}
+	if math.IsInf(edge, 1) {
+		return "inf"
+	}
+	return strconv.FormatFloat(edge, 'f', -1, 64)
+}
+
+// the columns describing a bin in a result row, infinite bounds are null
+func binColumns(binz Binz, b bin) map[string]interface{} {
+	row := make(map[string]interface{})
+	row[fmt.Sprintf("%s_binned", binz.Column)] = b.Label
+
+	if !b.Categorical {
+		var lower, upper interface{}
+		if !math.IsInf(b.Lower, 0) {
+			lower = b.Lower
+		}
+		if !math.IsInf(b.Upper, 0) {
+			upper = b.Upper
+		}
+		row[fmt.Sprintf("%s_lower", binz.Column)] = lower
+		row[fmt.Sprintf("%s_upper", binz.Column)] = upper
+	}
+
+	return row
+}
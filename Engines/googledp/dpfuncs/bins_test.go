@@ -0,0 +1,162 @@
+package dpfuncs
+
+import (
+	"encoding/json"
+	"googledp/entities"
+	"googledp/requests"
+	"testing"
+)
+
+func getTestBinSchema() []entities.Column {
+	return []entities.Column{
+		{Name: "score", Type: entities.DoubleType{Name: "Double", Low: 0, High: 10}},
+		{Name: "color", Type: entities.EnumType{Name: "Enum", Labels: []string{"red", "green", "blue"}}},
+		{Name: "member", Type: entities.BoolType{Name: "Bool"}},
+	}
+}
+
+func getTestBinData() [][]string {
+	return [][]string{
+		{"0.5", "red", "true"},
+		{"2.5", "green", "False"},
+		{"2.75", "blue", "1"},
+		{"9.5", "red", "0"},
+		{"10", "purple", "maybe"},
+	}
+}
+
+func parseBinStep(t *testing.T, raw string) entities.BinStep {
+	var query entities.Query
+	if err := json.Unmarshal([]byte(`[{"bin": `+raw+`}]`), &query); err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+	return query[0].(entities.BinStep)
+}
+
+func binSizes(binz Binz) []int {
+	sizes := make([]int, 0, len(binz.Bins))
+	for _, b := range binz.Bins {
+		sizes = append(sizes, len(b.Rows))
+	}
+	return sizes
+}
+
+func TestParseBinStep(t *testing.T) {
+	step := parseBinStep(t, `{"score": ["-inf", 1.5, 2.75, "inf"]}`)
+	if !step.Underflow || !step.Overflow || len(step.Edges) != 2 || step.Edges[1] != 2.75 {
+		t.Fatalf("test failed, got %+v", step)
+	}
+
+	step = parseBinStep(t, `{"member": [true, false]}`)
+	if len(step.Labels) != 2 || step.Labels[0] != "true" {
+		t.Fatalf("test failed, got %+v", step)
+	}
+
+	for _, raw := range []string{`{"score": [1, "red"]}`, `{"score": [1, "inf", 2]}`, `{"score": ["inf", 1]}`, `{"score": [{}]}`} {
+		var query entities.Query
+		if err := json.Unmarshal([]byte(`[{"bin": `+raw+`}]`), &query); err == nil {
+			t.Fatalf("test failed, expected error on %s", raw)
+		}
+	}
+}
+
+func TestEdgeBins(t *testing.T) {
+	binz, err := makeBins(parseBinStep(t, `{"score": ["-inf", 1, 2.75, "inf"]}`), getTestBinSchema(), getTestBinData())
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	expected := []string{"[-inf, 1)", "[1, 2.75)", "[2.75, inf)"}
+	sizes := binSizes(binz)
+	for i, b := range binz.Bins {
+		if b.Label != expected[i] {
+			t.Fatalf("test failed, expected bin %s but got %s", expected[i], b.Label)
+		}
+	}
+	if sizes[0] != 1 || sizes[1] != 1 || sizes[2] != 3 {
+		t.Fatalf("test failed, got bin sizes %v", sizes)
+	}
+
+	// without the open ends the values outside [1, 2.75) are dropped
+	binz, err = makeBins(parseBinStep(t, `{"score": [1, 2.75]}`), getTestBinSchema(), getTestBinData())
+	if err != nil || len(binz.Bins) != 1 || len(binz.Bins[0].Rows) != 1 {
+		t.Fatalf("test failed, got %v %v", binz, err)
+	}
+
+	for _, raw := range []string{`{"score": [2, 1]}`, `{"score": [1]}`, `{"score": ["red"]}`} {
+		if _, err := makeBins(parseBinStep(t, raw), getTestBinSchema(), getTestBinData()); err == nil {
+			t.Fatalf("test failed, expected error on %s", raw)
+		}
+	}
+}
+
+func TestLabelBins(t *testing.T) {
+	binz, err := makeBins(parseBinStep(t, `{"color": []}`), getTestBinSchema(), getTestBinData())
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+	sizes := binSizes(binz)
+	if len(sizes) != 3 || binz.Bins[0].Label != "red" || sizes[0] != 2 || sizes[1] != 1 || sizes[2] != 1 {
+		t.Fatalf("test failed, got %v with sizes %v", binz.Bins, sizes)
+	}
+
+	// the cell "maybe" is not a Bool, which fails the query unless the policy drops or imputes it
+	if _, err := makeBins(parseBinStep(t, `{"member": []}`), getTestBinSchema(), getTestBinData()); err == nil {
+		t.Fatalf("test failed, expected error on an invalid Bool cell")
+	}
+
+	impute := 1.0
+	for policy, expected := range map[entities.ValuePolicy][]int{
+		{Null: entities.FAIL, Invalid: entities.DROP}:                    {2, 2},
+		{Null: entities.FAIL, Invalid: entities.IMPUTE, Impute: &impute}: {2, 3},
+	} {
+		schema, err := withValuePolicies(getTestBinSchema(), map[string]entities.ValuePolicy{"member": policy})
+		if err != nil {
+			t.Fatalf("test failed due to error: %s", err.Error())
+		}
+		binz, err = makeBins(parseBinStep(t, `{"member": []}`), schema, getTestBinData())
+		if err != nil {
+			t.Fatalf("test failed due to error: %s", err.Error())
+		}
+		sizes = binSizes(binz)
+		if len(sizes) != 2 || binz.Bins[0].Label != "false" || sizes[0] != expected[0] || sizes[1] != expected[1] {
+			t.Fatalf("test failed with invalid %s, got %v with sizes %v", policy.Invalid, binz.Bins, sizes)
+		}
+	}
+
+	half := 0.5
+	if _, err := withValuePolicies(getTestBinSchema(), map[string]entities.ValuePolicy{"member": {Null: entities.IMPUTE, Invalid: entities.FAIL, Impute: &half}}); err == nil {
+		t.Fatalf("test failed, expected error on impute value 0.5 of a Bool column")
+	}
+
+	for _, raw := range []string{`{"color": ["purple"]}`, `{"color": ["red", "red"]}`, `{"color": [1, 2]}`} {
+		if _, err := makeBins(parseBinStep(t, raw), getTestBinSchema(), getTestBinData()); err == nil {
+			t.Fatalf("test failed, expected error on %s", raw)
+		}
+	}
+}
+
+func TestBinnedEvalOrder(t *testing.T) {
+	req := requests.Evaluate{
+		Budget: entities.Budget{Epsilon: 1},
+		Query: entities.Query{
+			parseBinStep(t, `{"score": [0, 5, "inf"]}`),
+			entities.CountStep{Column: "score", Mech: "Laplace"},
+		},
+		Schema:        getTestBinSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, getTestBinData())
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(res.Rows) != 2 || res.Rows[0]["score_binned"] != "[0, 5)" || res.Rows[1]["score_binned"] != "[5, inf)" {
+		t.Fatalf("test failed, got rows %v", res.Rows)
+	}
+
+	if res.Rows[1]["score_lower"] != 5.0 || res.Rows[1]["score_upper"] != nil {
+		t.Fatalf("test failed, got bounds %v", res.Rows[1])
+	}
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func count(step entities.QueryStep, schema []entities.Column, data [][]string, budget entities.Budget) (float64, error) {
-	_, colType, err := getIndexAndTypeFromSchema(schema, step.GetColumn())
+	index, colType, err := getIndexAndTypeFromSchema(schema, step.GetColumn())
 
 	if err != nil {
 		return 0, err
@@ -20,13 +20,20 @@ func count(step entities.QueryStep, schema []entities.Column, data [][]string, b
 		return 0, err
 	}
 
+	// rows the policy of the column drops are not counted
+	values, err := numericValues(schema, index, data)
+
+	if err != nil {
+		return 0, err
+	}
+
 	dp_count, err := dpagg.NewCount(opts)
 
 	if err != nil {
 		return 0, fmt.Errorf("something went wrong with initilizing the dp mean")
 	}
 
-	err = dp_count.IncrementBy(int64(len(data)))
+	err = dp_count.IncrementBy(int64(len(values)))
 
 	if err != nil {
 		return 0, fmt.Errorf("error in data")
@@ -0,0 +1,203 @@
+package dpfuncs
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// Restricted arithmetic expressions used by the map transformation.
+//
+//	expr   := term   (('+' | '-') term)*
+//	term   := factor (('*' | '/') factor)*
+//	factor := number | column | '-' factor | '(' expr ')'
+//
+// Columns must be numeric. Division by zero evaluates to 0 so that the
+// outcome of a query never depends on whether such a row exists.
+
+type expression interface {
+	eval(row []float64) float64
+	// adds the indices of the referenced columns
+	columns(used map[int]bool)
+}
+
+type constExpr float64
+
+type columnExpr int
+
+type negExpr struct {
+	inner expression
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right expression
+}
+
+func (c constExpr) eval(_ []float64) float64 {
+	return float64(c)
+}
+
+func (c columnExpr) eval(row []float64) float64 {
+	return row[c]
+}
+
+func (n negExpr) eval(row []float64) float64 {
+	return -n.inner.eval(row)
+}
+
+func (c constExpr) columns(_ map[int]bool) {}
+
+func (c columnExpr) columns(used map[int]bool) {
+	used[int(c)] = true
+}
+
+func (n negExpr) columns(used map[int]bool) {
+	n.inner.columns(used)
+}
+
+func (b binaryExpr) columns(used map[int]bool) {
+	b.left.columns(used)
+	b.right.columns(used)
+}
+
+func (b binaryExpr) eval(row []float64) float64 {
+	l := b.left.eval(row)
+	r := b.right.eval(row)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	}
+}
+
+type exprParser struct {
+	input   string
+	pos     int
+	columns map[string]int
+}
+
+// parses fun, columns maps the names that may be referenced to their index in a row
+func parseExpression(fun string, columns map[string]int) (expression, error) {
+	p := exprParser{input: fun, columns: columns}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d in expression", p.input[p.pos], p.pos)
+	}
+
+	return expr, nil
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseExpr() (expression, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseTerm() (expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseFactor() (expression, error) {
+	c := p.peek()
+
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		inner, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{inner: inner}, nil
+	case c == '(':
+		p.pos++
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis in expression")
+		}
+		p.pos++
+		return inner, nil
+	case c == '.' || unicode.IsDigit(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '.' || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s in expression", p.input[start:p.pos])
+		}
+		return constExpr(f), nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '_' || unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+		index, ok := p.columns[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s can not be used in expression, only numeric columns are allowed", name)
+		}
+		return columnExpr(index), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d in expression", c, p.pos)
+	}
+}
@@ -72,6 +72,7 @@ func splitIntoSubQueries(query entities.Query) ([][]entities.QueryStep, error) {
 	// Filter -> Measurement -> Result
 	// Bins -> Measurement -> Result
 	// Measurement -> Result
+	// select, rename and map may appear anywhere before Bins
 	stepsOps := make([]string, 0)
 	blablaValidation := make([]string, 0)
 	for _, step := range query {
@@ -138,7 +139,7 @@ func getNoiseGenerator(name string) noise.Noise {
 	return noise.Laplace()
 }
 
-func isUniqueAndSorted(arr []int64) bool {
+func isUniqueAndSorted(arr []float64) bool {
 	if len(arr) <= 1 {
 		return true
 	}
@@ -3,7 +3,6 @@ package dpfuncs
 import (
 	"fmt"
 	"googledp/entities"
-	"strconv"
 
 	"github.com/google/differential-privacy/go/v3/dpagg"
 )
@@ -27,13 +26,14 @@ func mean(step entities.QueryStep, schema []entities.Column, data [][]string, bu
 		return 0, fmt.Errorf("something went wrong with initilizing the dp mean")
 	}
 
-	for _, value := range data {
-		if f, err := strconv.ParseFloat(value[index], 64); err == nil {
-			dp_mean.Add(f)
-		} else {
-			fmt.Println(value)
-			return 0, fmt.Errorf("error in data")
-		}
+	values, err := numericValues(schema, index, data)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range values {
+		dp_mean.Add(f)
 	}
 
 	return dp_mean.Result()
@@ -51,8 +51,8 @@ func getMeanOpts(step entities.QueryStep, typeSpec entities.ColType, allocatedBu
 	opts := dpagg.BoundedMeanOptions{
 		MaxPartitionsContributed:     1,
 		MaxContributionsPerPartition: 1,
-		Lower:                        float64(lowBound),
-		Upper:                        float64(highBound),
+		Lower:                        lowBound,
+		Upper:                        highBound,
 	}
 	mech := step.GetMechanism()
 
@@ -0,0 +1,40 @@
+package dpfuncs
+
+import (
+	"googledp/entities"
+	"testing"
+)
+
+func TestMeanOptsFractionalBounds(t *testing.T) {
+	del := 0.0
+	budget := entities.Budget{Epsilon: 1, Delta: &del}
+	step := entities.MeanStep{Column: "ratio", Mech: "Laplace"}
+	colType := entities.DoubleType{Name: "Double", Low: 0, High: 0.5}
+
+	opts, err := getMeanOpts(step, colType, budget)
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if opts.Lower != 0 || opts.Upper != 0.5 {
+		t.Fatalf("test failed, expected bounds [0, 0.5] but got [%f, %f]", opts.Lower, opts.Upper)
+	}
+}
+
+func TestSumOptsIntBounds(t *testing.T) {
+	del := 0.0
+	budget := entities.Budget{Epsilon: 1, Delta: &del}
+	step := entities.SumStep{Column: "age", Mech: "Laplace"}
+	colType := entities.IntType{Name: "Int", Low: 0, High: 100}
+
+	opts, err := getSumOpts(step, colType, budget)
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if opts.Lower != 0 || opts.Upper != 100 {
+		t.Fatalf("test failed, expected bounds [0, 100] but got [%f, %f]", opts.Lower, opts.Upper)
+	}
+}
@@ -8,7 +8,14 @@ import (
 	"github.com/google/differential-privacy/go/v3/dpagg"
 )
 
-func quantile(step entities.QueryStep, schema []entities.Column, data [][]string, budget entities.Budget) ([]float64, error) {
+// computes all requested ranks of a step from a single BoundedQuantiles instance,
+// the budget is only spent once no matter how many ranks that are requested
+func quantiles(step entities.QueryStep, schema []entities.Column, data [][]string, budget entities.Budget) ([]float64, error) {
+	ranks := step.GetRanks()
+
+	if err := validRanks(ranks); err != nil {
+		return nil, err
+	}
 
 	index, colType, err := getIndexAndTypeFromSchema(schema, step.GetColumn())
 
@@ -25,28 +32,23 @@ func quantile(step entities.QueryStep, schema []entities.Column, data [][]string
 	dp_quan, err := dpagg.NewBoundedQuantiles(opts)
 
 	if err != nil {
-		return nil, fmt.Errorf("something went wrong with initilizing the dp mean")
+		return nil, fmt.Errorf("something went wrong with initilizing the dp %s", step.GetOperation())
 	}
 
-	for _, value := range data {
-		if f, err := strconv.ParseFloat(value[index], 64); err == nil {
-			dp_quan.Add(f)
-		} else {
-			fmt.Println(value)
-			return nil, fmt.Errorf("error in data")
-		}
+	values, err := numericValues(schema, index, data)
 
+	if err != nil {
+		return nil, err
 	}
 
-	result := []float64{}
-
-	sthep := 1.0 / 100
-	value := 0.0
+	for _, f := range values {
+		dp_quan.Add(f)
+	}
 
-	for i := 0; i < 99; i++ {
-		value += sthep
+	result := make([]float64, 0, len(ranks))
 
-		intermediateRes, err := dp_quan.Result(value)
+	for _, rank := range ranks {
+		intermediateRes, err := dp_quan.Result(rank)
 
 		if err != nil {
 			return nil, err
@@ -58,9 +60,48 @@ func quantile(step entities.QueryStep, schema []entities.Column, data [][]string
 	return result, nil
 }
 
+// used for min, max and median which all are backed by a single rank
+func quantile(step entities.QueryStep, schema []entities.Column, data [][]string, budget entities.Budget) (float64, error) {
+	if len(step.GetRanks()) != 1 {
+		return 0, fmt.Errorf("%s measurement expects exactly one rank", step.GetOperation())
+	}
+
+	result, err := quantiles(step, schema, data, budget)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result[0], nil
+}
+
+func validRanks(ranks []float64) error {
+	if len(ranks) == 0 {
+		return fmt.Errorf("quantile measurement needs at least one rank")
+	}
+
+	seen := make(map[float64]bool)
+	for _, rank := range ranks {
+		if rank < 0 || rank > 1 {
+			return fmt.Errorf("rank %v is out of range, ranks should be in [0, 1]", rank)
+		}
+		if seen[rank] {
+			return fmt.Errorf("rank %v occurs multiple times", rank)
+		}
+		seen[rank] = true
+	}
+
+	return nil
+}
+
+// result key naming the rank, e.g. quantile_0.25
+func rankKey(rank float64) string {
+	return fmt.Sprintf("%s_%s", entities.QUANTILE, strconv.FormatFloat(rank, 'f', -1, 64))
+}
+
 func getQuantilesOpts(step entities.QueryStep, typeSpec entities.ColType, allocatedBudget entities.Budget) (*dpagg.BoundedQuantilesOptions, error) {
 	if !checkIfNumber(typeSpec.GetName()) {
-		return nil, fmt.Errorf("can't do mean measurment as column type is not a number")
+		return nil, fmt.Errorf("can't do %s measurment as column type is not a number", step.GetOperation())
 	}
 
 	lowBound := typeSpec.GetLow()
@@ -69,8 +110,8 @@ func getQuantilesOpts(step entities.QueryStep, typeSpec entities.ColType, alloca
 	opts := dpagg.BoundedQuantilesOptions{
 		MaxPartitionsContributed:     1,
 		MaxContributionsPerPartition: 1,
-		Lower:                        float64(lowBound),
-		Upper:                        float64(highBound),
+		Lower:                        lowBound,
+		Upper:                        highBound,
 	}
 	mech := step.GetMechanism()
 
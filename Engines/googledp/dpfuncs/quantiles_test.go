@@ -0,0 +1,122 @@
+package dpfuncs
+
+import (
+	"googledp/entities"
+	"googledp/requests"
+	"testing"
+)
+
+func TestValidRanks(t *testing.T) {
+	if err := validRanks([]float64{0, 0.25, 0.5, 1}); err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if validRanks(nil) == nil {
+		t.Fatalf("test failed, expected error on empty ranks")
+	}
+
+	if validRanks([]float64{0.5, 1.5}) == nil {
+		t.Fatalf("test failed, expected error on rank out of range")
+	}
+
+	if validRanks([]float64{0.5, 0.5}) == nil {
+		t.Fatalf("test failed, expected error on duplicate ranks")
+	}
+}
+
+func TestRankKey(t *testing.T) {
+	if rankKey(0.25) != "quantile_0.25" {
+		t.Fatalf("test failed, got %s", rankKey(0.25))
+	}
+
+	if rankKey(1) != "quantile_1" {
+		t.Fatalf("test failed, got %s", rankKey(1))
+	}
+}
+
+func TestQuantileEvalKeys(t *testing.T) {
+	req := requests.Evaluate{
+		Budget: entities.Budget{Epsilon: 1},
+		Query: entities.Query{
+			entities.QuantileStep{Column: "age", Mech: "Laplace", Ranks: []float64{0.25, 0.75}},
+		},
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(res.Rows) != 1 {
+		t.Fatalf("test failed, expected one row but got %d", len(res.Rows))
+	}
+
+	for _, key := range []string{"age_quantile_0.25", "age_quantile_0.75"} {
+		if _, ok := res.Rows[0][key]; !ok {
+			t.Fatalf("test failed, missing key %s in %v", key, res.Rows[0])
+		}
+	}
+}
+
+func TestBinnedQuantileEvalKeys(t *testing.T) {
+	req := requests.Evaluate{
+		Budget: entities.Budget{Epsilon: 1},
+		Query: entities.Query{
+			entities.BinStep{Column: "age", Edges: []float64{0, 50, 100}},
+			entities.QuantileStep{Column: "age", Mech: "Laplace", Ranks: []float64{0.5}},
+		},
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(res.Rows) != 2 {
+		t.Fatalf("test failed, expected one row per bin but got %d", len(res.Rows))
+	}
+
+	for _, row := range res.Rows {
+		if _, ok := row["quantile_0.5"]; !ok {
+			t.Fatalf("test failed, missing rank key in %v", row)
+		}
+		if _, ok := row["age_binned"]; !ok {
+			t.Fatalf("test failed, missing bin key in %v", row)
+		}
+	}
+}
+
+func TestMinMaxMedianEval(t *testing.T) {
+	req := requests.Evaluate{
+		Budget: entities.Budget{Epsilon: 3},
+		Query: entities.Query{
+			entities.MinStep{Column: "age", Mech: "Laplace"},
+			entities.MaxStep{Column: "age", Mech: "Laplace"},
+			entities.MedianStep{Column: "age", Mech: "Laplace"},
+		},
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(res.Rows) != 3 {
+		t.Fatalf("test failed, expected three rows but got %d", len(res.Rows))
+	}
+
+	for i, key := range []string{"age_min", "age_max", "age_median"} {
+		if _, ok := res.Rows[i][key]; !ok {
+			t.Fatalf("test failed, missing key %s in %v", key, res.Rows[i])
+		}
+	}
+}
@@ -4,16 +4,10 @@ import (
 	"fmt"
 	"googledp/entities"
 	"googledp/requests"
-	"strconv"
 )
 
 type ResultType struct {
-	Rows []map[string]float64 `json:"rows"`
-}
-
-type binKey struct {
-	FromInclude  int64
-	ToNotInclude int64
+	Rows []map[string]interface{} `json:"rows"`
 }
 
 func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
@@ -31,7 +25,11 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 	}
 
 	topLevelBudget := req.Budget
-	schema := req.Schema
+	schema, err := withValuePolicies(req.Schema, req.ValuePolicies)
+
+	if err != nil {
+		return nil, err
+	}
 
 	// incase querystep budgets not set
 
@@ -51,12 +49,18 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 	}
 
 	results := ResultType{
-		Rows: make([]map[string]float64, 0),
+		Rows: make([]map[string]interface{}, 0),
 	}
 
+	// select, rename and map change the columns of the whole query, the measurements
+	// after them see the transformed columns. Filters and bins hold for their own
+	// measurement only.
+	workData, workSchema := data, schema
+
 	for _, subQ := range subQueries {
-		subQData := data
-		bins := make(map[binKey][][]string)
+		subQData := workData
+		subQSchema := workSchema
+		var bins Binz
 		doBins := false
 		for _, step := range subQ {
 			var budget entities.Budget
@@ -74,9 +78,9 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 				var err error
 				if doBins {
 					budgetBins := getBudgetBins(budget, bins)
-					_, err = doBinnedEval(bins, step, schema, budgetBins, mean, &results)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, mean, &results)
 				} else {
-					_, err = doEval(subQData, step, schema, budget, mean, &results)
+					_, err = doEval(subQData, step, subQSchema, budget, mean, &results)
 				}
 				if err != nil {
 					return nil, err
@@ -85,9 +89,9 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 				var err error
 				if doBins {
 					budgetBins := getBudgetBins(budget, bins)
-					_, err = doBinnedEval(bins, step, schema, budgetBins, sum, &results)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, sum, &results)
 				} else {
-					_, err = doEval(subQData, step, schema, budget, sum, &results)
+					_, err = doEval(subQData, step, subQSchema, budget, sum, &results)
 				}
 				if err != nil {
 					return nil, err
@@ -96,9 +100,9 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 				var err error
 				if doBins {
 					budgetBins := getBudgetBins(budget, bins)
-					_, err = doBinnedEval(bins, step, schema, budgetBins, stDev, &results)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, stDev, &results)
 				} else {
-					_, err = doEval(subQData, step, schema, *step.GetBudget(), stDev, &results)
+					_, err = doEval(subQData, step, subQSchema, *step.GetBudget(), stDev, &results)
 				}
 				if err != nil {
 					return nil, err
@@ -107,9 +111,9 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 				var err error
 				if doBins {
 					budgetBins := getBudgetBins(budget, bins)
-					_, err = doBinnedEval(bins, step, schema, budgetBins, variance, &results)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, variance, &results)
 				} else {
-					_, err = doEval(subQData, step, schema, budget, variance, &results)
+					_, err = doEval(subQData, step, subQSchema, budget, variance, &results)
 				}
 				if err != nil {
 					return nil, err
@@ -118,98 +122,96 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 				var err error
 				if doBins {
 					budgetBins := getBudgetBins(budget, bins)
-					_, err = doBinnedEval(bins, step, schema, budgetBins, count, &results)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, count, &results)
 				} else {
-					_, err = doEval(subQData, step, schema, budget, count, &results)
+					_, err = doEval(subQData, step, subQSchema, budget, count, &results)
 				}
 				if err != nil {
 					return nil, err
 				}
 			case entities.QUANTILE:
+				var err error
 				if doBins {
-					tempMap := make(map[string]float64)
 					budgetBins := getBudgetBins(budget, bins)
-					for key, binnedData := range bins {
-						result, err := quantile(step, schema, binnedData, budgetBins)
-
-						if err != nil {
-							return nil, err
-						}
-
-						tempMap := make(map[string]float64)
-
-						for i, ires := range result {
-							tempMap[fmt.Sprintf("bin_%d_%d_%s_%s", key, i, step.GetOperation(), step.GetColumn())] = ires
-						}
-
-					}
-					results.Rows = append(results.Rows, tempMap)
+					_, err = doBinnedQuantileEval(bins, step, subQSchema, budgetBins, &results)
 				} else {
-					result, err := quantile(step, schema, subQData, budget)
-
-					if err != nil {
-						return nil, err
-					}
-
-					tempMap := make(map[string]float64)
-
-					for i, ires := range result {
-						tempMap[fmt.Sprintf("%d_%s_%s", i, step.GetOperation(), step.GetColumn())] = ires
-					}
-
-					results.Rows = append(results.Rows, tempMap)
+					_, err = doQuantileEval(subQData, step, subQSchema, budget, &results)
 				}
-			case entities.BIN:
-				index, colType, err := getIndexAndTypeFromSchema(schema, step.GetColumn())
-
 				if err != nil {
 					return nil, err
 				}
-
-				if !checkIfNumber(colType.GetName()) {
-					return nil, fmt.Errorf("can only do bin on columns with numbers")
+			case entities.MIN, entities.MAX, entities.MEDIAN:
+				var err error
+				if doBins {
+					budgetBins := getBudgetBins(budget, bins)
+					_, err = doBinnedEval(bins, step, subQSchema, budgetBins, quantile, &results)
+				} else {
+					_, err = doEval(subQData, step, subQSchema, budget, quantile, &results)
 				}
-
-				biinz := step.GetBins()
-
-				if !isUniqueAndSorted(biinz) {
-					return nil, fmt.Errorf("bins not well formatted, bins should be unique and in ascending order")
+				if err != nil {
+					return nil, err
 				}
-
-				if len(biinz) < 2 {
-					return nil, fmt.Errorf("bins not well formatted, minimum 2 bins")
+			case entities.BIN:
+				binStep, ok := step.(entities.BinStep)
+				if !ok {
+					return nil, fmt.Errorf("oops something went wrong")
 				}
 
-				for i := range biinz[:len(biinz)-1] {
-					bk := binKey{
-						FromInclude:  biinz[i],
-						ToNotInclude: biinz[i+1],
-					}
-					bins[bk] = make([][]string, 0)
-				}
+				binz, err := makeBins(binStep, subQSchema, subQData)
 
-				for _, row := range subQData {
-					for key := range bins {
-						if f, err := strconv.ParseFloat(row[index], 64); err == nil {
-							if f >= float64(key.FromInclude) && f < float64(key.ToNotInclude) {
-								bins[key] = append(bins[key], row)
-								break
-							}
-						} else {
-							return nil, fmt.Errorf("type mismatch, error in data")
-						}
-					}
+				if err != nil {
+					return nil, err
 				}
 
+				bins = binz
 				doBins = true
 
 			case entities.FILTER:
 				filters := step.GetFilters()
-				filteredData, err := filterData(data, filters, schema)
+				filteredData, err := filterData(subQData, filters, subQSchema)
 				if err != nil {
 					return nil, err
 				}
 				subQData = filteredData
+			case entities.SELECT:
+				selectStep, ok := step.(entities.SelectStep)
+				if !ok {
+					return nil, fmt.Errorf("oops something went wrong")
+				}
+				newSchema, newData, err := selectColumns(selectStep, subQSchema, subQData)
+				if err != nil {
+					return nil, err
+				}
+				subQSchema, subQData = newSchema, newData
+				if workSchema, workData, err = selectColumns(selectStep, workSchema, workData); err != nil {
+					return nil, err
+				}
+			case entities.RENAME:
+				renameStep, ok := step.(entities.RenameStep)
+				if !ok {
+					return nil, fmt.Errorf("oops something went wrong")
+				}
+				newSchema, err := renameColumns(renameStep, subQSchema)
+				if err != nil {
+					return nil, err
+				}
+				subQSchema = newSchema
+				if workSchema, err = renameColumns(renameStep, workSchema); err != nil {
+					return nil, err
+				}
+			case entities.MAP:
+				mapStep, ok := step.(entities.MapStep)
+				if !ok {
+					return nil, fmt.Errorf("oops something went wrong")
+				}
+				newSchema, newData, err := mapColumn(mapStep, subQSchema, subQData)
+				if err != nil {
+					return nil, err
+				}
+				subQSchema, subQData = newSchema, newData
+				if workSchema, workData, err = mapColumn(mapStep, workSchema, workData); err != nil {
+					return nil, err
+				}
 			default:
 				return nil, fmt.Errorf("oops something went wrong")
 			}
@@ -219,7 +221,6 @@ func NewEvalQuery(req requests.Evaluate, data [][]string) (*ResultType, error) {
 	return &results, nil
 }
 
-type Binz map[binKey][][]string
 type Data [][]string
 type EvalFunc func(entities.QueryStep, []entities.Column, [][]string, entities.Budget) (float64, error)
 type QS entities.QueryStep
@@ -227,14 +228,13 @@ type CS []entities.Column
 
 func doBinnedEval(bins Binz, step QS, schema CS, budget entities.Budget, operation EvalFunc, res *ResultType) (*ResultType, error) {
 
-	for key, binnedData := range bins {
-		tempMap := make(map[string]float64)
-		result, err := operation(step, schema, binnedData, budget)
+	for _, b := range bins.Bins {
+		result, err := operation(step, schema, b.Rows, budget)
 		if err != nil {
 			return nil, err
 		}
 
-		tempMap[fmt.Sprintf("%s_binned", step.GetColumn())] = float64(key.ToNotInclude)
+		tempMap := binColumns(bins, b)
 		tempMap[step.GetOperation()] = result
 
 		res.Rows = append(res.Rows, tempMap)
@@ -250,7 +250,7 @@ func doEval(data Data, step QS, schema CS, budget entities.Budget, operation Eva
 		return nil, err
 	}
 
-	tempMap := make(map[string]float64)
+	tempMap := make(map[string]interface{})
 
 	tempMap[fmt.Sprintf("%s_%s", step.GetColumn(), step.GetOperation())] = result
 
@@ -259,8 +259,45 @@ func doEval(data Data, step QS, schema CS, budget entities.Budget, operation Eva
 	return res, nil
 }
 
+func doBinnedQuantileEval(bins Binz, step QS, schema CS, budget entities.Budget, res *ResultType) (*ResultType, error) {
+
+	for _, b := range bins.Bins {
+		result, err := quantiles(step, schema, b.Rows, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		tempMap := binColumns(bins, b)
+		for i, rank := range step.GetRanks() {
+			tempMap[rankKey(rank)] = result[i]
+		}
+
+		res.Rows = append(res.Rows, tempMap)
+	}
+
+	return res, nil
+}
+
+func doQuantileEval(data Data, step QS, schema CS, budget entities.Budget, res *ResultType) (*ResultType, error) {
+
+	result, err := quantiles(step, schema, data, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	tempMap := make(map[string]interface{})
+
+	for i, rank := range step.GetRanks() {
+		tempMap[fmt.Sprintf("%s_%s", step.GetColumn(), rankKey(rank))] = result[i]
+	}
+
+	res.Rows = append(res.Rows, tempMap)
+
+	return res, nil
+}
+
 func getBudgetBins(budget entities.Budget, bins Binz) entities.Budget {
-	nBins := len(bins)
+	nBins := len(bins.Bins)
 
 	budgetBins := entities.Budget{
 		Epsilon: budget.Epsilon / float64(nBins),
@@ -3,7 +3,6 @@ package dpfuncs
 import (
 	"fmt"
 	"googledp/entities"
-	"strconv"
 
 	"github.com/google/differential-privacy/go/v3/dpagg"
 )
@@ -27,14 +26,14 @@ func sum(step entities.QueryStep, schema []entities.Column, data [][]string, bud
 		return 0, fmt.Errorf("something went wrong with initilizing the dp mean")
 	}
 
-	for _, value := range data {
-		if f, err := strconv.ParseFloat(value[index], 64); err == nil {
-			dp_sum.Add(f)
-		} else {
-			fmt.Println(value)
-			return 0, fmt.Errorf("error in data")
-		}
+	values, err := numericValues(schema, index, data)
 
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range values {
+		dp_sum.Add(f)
 	}
 
 	return dp_sum.Result()
@@ -50,8 +49,8 @@ func getSumOpts(step entities.QueryStep, typeSpec entities.ColType, allocatedBud
 
 	opts := dpagg.BoundedSumFloat64Options{
 		MaxPartitionsContributed: 1,
-		Lower:                    float64(lowBound),
-		Upper:                    float64(highBound),
+		Lower:                    lowBound,
+		Upper:                    highBound,
 	}
 	mech := step.GetMechanism()
 
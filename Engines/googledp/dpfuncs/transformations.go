@@ -0,0 +1,195 @@
+package dpfuncs
+
+import (
+	"fmt"
+	"googledp/entities"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Transformations take the working schema and data of a sub query and return
+// new ones. Rows are always copied, the input data may be shared with the cache.
+
+func selectColumns(step entities.SelectStep, schema []entities.Column, data [][]string) ([]entities.Column, [][]string, error) {
+	if len(step.Columns) == 0 {
+		return nil, nil, fmt.Errorf("select needs at least one column")
+	}
+
+	indices := make([]int, 0, len(step.Columns))
+	newSchema := make([]entities.Column, 0, len(step.Columns))
+	seen := make(map[string]bool)
+
+	for _, name := range step.Columns {
+		if seen[name] {
+			return nil, nil, fmt.Errorf("column %s selected multiple times", name)
+		}
+		seen[name] = true
+
+		index, _, err := getIndexAndTypeFromSchema(schema, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		indices = append(indices, index)
+		newSchema = append(newSchema, schema[index])
+	}
+
+	newData := make([][]string, 0, len(data))
+	for _, row := range data {
+		newRow := make([]string, 0, len(indices))
+		for _, index := range indices {
+			newRow = append(newRow, row[index])
+		}
+		newData = append(newData, newRow)
+	}
+
+	return newSchema, newData, nil
+}
+
+// all renames are applied at once, so {"a": "b", "b": "a"} swaps the columns.
+// only the schema changes, the data is left as is
+func renameColumns(step entities.RenameStep, schema []entities.Column) ([]entities.Column, error) {
+	if len(step.Mapping) == 0 {
+		return nil, fmt.Errorf("rename needs at least one column")
+	}
+
+	for from := range step.Mapping {
+		if _, _, err := getIndexAndTypeFromSchema(schema, from); err != nil {
+			return nil, err
+		}
+	}
+
+	newSchema := make([]entities.Column, 0, len(schema))
+	seen := make(map[string]bool)
+
+	for _, col := range schema {
+		name := col.Name
+		if to, ok := step.Mapping[col.Name]; ok {
+			name = to
+		}
+		if name == "" {
+			return nil, fmt.Errorf("can not rename column %s to an empty name", col.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rename results in duplicate column %s", name)
+		}
+		seen[name] = true
+		col.Name = name
+		newSchema = append(newSchema, col)
+	}
+
+	return newSchema, nil
+}
+
+// the schema of a map step is the working schema after the step. Columns already
+// in the working schema are carried over and must keep their type. Exactly one new
+// numeric column is allowed, it is computed by the expression and clamped to its
+// declared bounds. Referenced columns go through their value policies.
+func mapColumn(step entities.MapStep, schema []entities.Column, data [][]string) ([]entities.Column, [][]string, error) {
+	if len(step.Schema) == 0 {
+		return nil, nil, fmt.Errorf("map needs a schema")
+	}
+
+	indices := make([]int, 0, len(step.Schema))
+	newSchema := make([]entities.Column, 0, len(step.Schema))
+	newIndex := -1
+	seen := make(map[string]bool)
+
+	for i, col := range step.Schema {
+		if seen[col.Name] {
+			return nil, nil, fmt.Errorf("column %s occurs multiple times in map schema", col.Name)
+		}
+		seen[col.Name] = true
+
+		index, colType, err := getIndexAndTypeFromSchema(schema, col.Name)
+		if err != nil {
+			if newIndex != -1 {
+				return nil, nil, fmt.Errorf("map can only produce one new column")
+			}
+			if col.Type == nil || !checkIfNumber(col.Type.GetName()) {
+				return nil, nil, fmt.Errorf("column %s produced by map must be Int or Double", col.Name)
+			}
+			if col.Type.GetLow() > col.Type.GetHigh() {
+				return nil, nil, fmt.Errorf("column %s produced by map has low bound above high bound", col.Name)
+			}
+			newIndex = i
+			indices = append(indices, -1)
+			newSchema = append(newSchema, entities.Column{Name: col.Name, Type: col.Type})
+			continue
+		}
+		if col.Type == nil || !sameColType(colType, col.Type) {
+			return nil, nil, fmt.Errorf("map can not change the type of column %s", col.Name)
+		}
+		indices = append(indices, index)
+		newSchema = append(newSchema, schema[index])
+	}
+
+	if newIndex == -1 {
+		return nil, nil, fmt.Errorf("map schema must declare the new column")
+	}
+
+	numeric := make(map[string]int)
+	for i, col := range schema {
+		if checkIfNumber(col.Type.GetName()) {
+			numeric[col.Name] = i
+		}
+	}
+
+	expr, err := parseExpression(step.Fun, numeric)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make(map[int]bool)
+	expr.columns(used)
+
+	newType := step.Schema[newIndex].Type
+	values := make([]float64, len(schema))
+	newData := make([][]string, 0, len(data))
+
+	// rows where a referenced column is dropped by its value policy are left out
+rows:
+	for _, row := range data {
+		for i := range used {
+			f, ok, err := numericValue(schema[i], row[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				continue rows
+			}
+			values[i] = f
+		}
+
+		newRow := make([]string, 0, len(indices))
+		for _, index := range indices {
+			if index == -1 {
+				newRow = append(newRow, formatMapped(expr.eval(values), newType))
+			} else {
+				newRow = append(newRow, row[index])
+			}
+		}
+		newData = append(newData, newRow)
+	}
+
+	return newSchema, newData, nil
+}
+
+func formatMapped(value float64, colType entities.ColType) string {
+	if math.IsNaN(value) {
+		value = colType.GetLow()
+	}
+	value = math.Max(colType.GetLow(), math.Min(colType.GetHigh(), value))
+
+	if colType.GetName() == "Int" {
+		return strconv.FormatInt(int64(math.Round(value)), 10)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func sameColType(a, b entities.ColType) bool {
+	return a.GetName() == b.GetName() &&
+		a.GetLow() == b.GetLow() &&
+		a.GetHigh() == b.GetHigh() &&
+		slices.Equal(a.GetLabels(), b.GetLabels())
+}
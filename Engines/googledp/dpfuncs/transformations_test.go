@@ -0,0 +1,192 @@
+package dpfuncs
+
+import (
+	"encoding/json"
+	"googledp/entities"
+	"googledp/requests"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	columns := map[string]int{"a": 0, "b": 1}
+	row := []float64{6, 3}
+
+	tests := map[string]float64{
+		"a + b":         9,
+		"a - b * 2":     0,
+		"(a - b) * 2":   6,
+		"-a / b":        -2,
+		"a / (b - 3)":   0,
+		"1.5 * a + 0.5": 9.5,
+	}
+
+	for fun, expected := range tests {
+		expr, err := parseExpression(fun, columns)
+		if err != nil {
+			t.Fatalf("test failed on %s due to error: %s", fun, err.Error())
+		}
+		if got := expr.eval(row); got != expected {
+			t.Fatalf("test failed on %s, expected %v but got %v", fun, expected, got)
+		}
+	}
+
+	for _, fun := range []string{"", "a +", "(a", "a b", "name * 2", "a % b"} {
+		if _, err := parseExpression(fun, columns); err == nil {
+			t.Fatalf("test failed, expected error on %q", fun)
+		}
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	schema, data, err := selectColumns(entities.SelectStep{Columns: []string{"age"}}, getTestSchema(), genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(schema) != 1 || schema[0].Name != "age" || len(data[5]) != 1 || data[5][0] != "5" {
+		t.Fatalf("test failed, got schema %v and row %v", schema, data[5])
+	}
+
+	if _, _, err := selectColumns(entities.SelectStep{Columns: []string{"salary"}}, getTestSchema(), genTestData()); err == nil {
+		t.Fatalf("test failed, expected error on unknown column")
+	}
+
+	if _, _, err := selectColumns(entities.SelectStep{Columns: []string{"age", "age"}}, getTestSchema(), genTestData()); err == nil {
+		t.Fatalf("test failed, expected error on duplicate column")
+	}
+}
+
+func TestRenameColumns(t *testing.T) {
+	schema, err := renameColumns(entities.RenameStep{Mapping: map[string]string{"age": "name", "name": "age"}}, getTestSchema())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if schema[0].Name != "age" || schema[1].Name != "name" || schema[1].Type.GetName() != "Int" {
+		t.Fatalf("test failed, got schema %v", schema)
+	}
+
+	if _, err := renameColumns(entities.RenameStep{Mapping: map[string]string{"age": "name"}}, getTestSchema()); err == nil {
+		t.Fatalf("test failed, expected error on duplicate column")
+	}
+
+	if _, err := renameColumns(entities.RenameStep{Mapping: map[string]string{"salary": "x"}}, getTestSchema()); err == nil {
+		t.Fatalf("test failed, expected error on unknown column")
+	}
+}
+
+func TestMapColumn(t *testing.T) {
+	step := entities.MapStep{
+		Fun: "age * 2 - 10",
+		Schema: []entities.Column{
+			getTestSchema()[1],
+			{Name: "doubled", Type: entities.IntType{Name: "Int", Low: 0, High: 150}},
+		},
+	}
+
+	data := genTestData()
+	schema, mapped, err := mapColumn(step, getTestSchema(), data)
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if len(schema) != 2 || schema[1].Name != "doubled" {
+		t.Fatalf("test failed, got schema %v", schema)
+	}
+
+	// 2 * 2 - 10 is clamped to 0, 50 * 2 - 10 = 90, 99 * 2 - 10 is clamped to 150
+	if mapped[2][1] != "0" || mapped[50][1] != "90" || mapped[99][1] != "150" || mapped[50][0] != "50" {
+		t.Fatalf("test failed, got rows %v %v %v", mapped[2], mapped[50], mapped[99])
+	}
+
+	if len(data[0]) != 2 || data[0][0] != "david0" {
+		t.Fatalf("test failed, input data was modified")
+	}
+}
+
+func TestMapColumnInvalid(t *testing.T) {
+	newCol := entities.Column{Name: "x", Type: entities.DoubleType{Name: "Double", Low: 0, High: 1}}
+
+	tests := []entities.MapStep{
+		{Fun: "age", Schema: []entities.Column{getTestSchema()[1]}},
+		{Fun: "age", Schema: []entities.Column{newCol, {Name: "y", Type: newCol.Type}}},
+		{Fun: "age", Schema: []entities.Column{{Name: "x", Type: entities.StringType{Name: "Text"}}}},
+		{Fun: "age", Schema: []entities.Column{newCol, {Name: "age", Type: entities.IntType{Name: "Int", Low: 0, High: 10}}}},
+		{Fun: "name + 1", Schema: []entities.Column{newCol}},
+	}
+
+	for i, step := range tests {
+		if _, _, err := mapColumn(step, getTestSchema(), genTestData()); err == nil {
+			t.Fatalf("test %d failed, expected error", i)
+		}
+	}
+}
+
+func TestTransformationsInQuery(t *testing.T) {
+	var query entities.Query
+	raw := `[
+		{"rename": {"age": "years"}},
+		{"map": {"fun": "years / 10", "schema": [{"name": "decade", "type": {"name": "Double", "low": 0, "high": 10}}]}},
+		{"filter": ["decade >= 5"]},
+		{"count": {"column": "decade", "mech": "Laplace"}}
+	]`
+
+	if err := json.Unmarshal([]byte(raw), &query); err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	req := requests.Evaluate{
+		Budget:        entities.Budget{Epsilon: 1},
+		Query:         query,
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	if _, ok := res.Rows[0]["decade_count"]; !ok {
+		t.Fatalf("test failed, missing key decade_count in %v", res.Rows[0])
+	}
+}
+
+func TestTransformationsForAllMeasurements(t *testing.T) {
+	var query entities.Query
+	raw := `[
+		{"rename": {"age": "years"}},
+		{"map": {"fun": "years / 10", "schema": [{"name": "years", "type": {"name": "Int", "low": 0, "high": 100}}, {"name": "decade", "type": {"name": "Double", "low": 0, "high": 10}}]}},
+		{"filter": ["decade >= 5"]},
+		{"count": {"column": "decade", "mech": "Laplace"}},
+		{"sum": {"column": "decade", "mech": "Laplace"}},
+		{"mean": {"column": "years", "mech": "Laplace"}}
+	]`
+
+	if err := json.Unmarshal([]byte(raw), &query); err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	req := requests.Evaluate{
+		Budget:        entities.Budget{Epsilon: 3},
+		Query:         query,
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	res, err := NewEvalQuery(req, genTestData())
+
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	for i, key := range []string{"decade_count", "decade_sum", "years_mean"} {
+		if _, ok := res.Rows[i][key]; !ok {
+			t.Fatalf("test failed, missing key %s in %v", key, res.Rows[i])
+		}
+	}
+}
@@ -15,22 +15,37 @@ func NewSMValidator() *StateMachine {
 	sm.AddTransition(0, entities.FILTER, 1)
 	sm.AddTransition(0, entities.BIN, 2)
 	sm.AddTransition(0, entities.MEASUREMENT, 3)
+	sm.AddTransition(0, entities.SELECT, 0)
+	sm.AddTransition(0, entities.RENAME, 0)
+	sm.AddTransition(0, entities.MAP, 0)
 
 	sm.AddTransition(1, entities.FILTER, 4)
 	sm.AddTransition(1, entities.BIN, 2)
 	sm.AddTransition(1, entities.MEASUREMENT, 3)
+	sm.AddTransition(1, entities.SELECT, 1)
+	sm.AddTransition(1, entities.RENAME, 1)
+	sm.AddTransition(1, entities.MAP, 1)
 
 	sm.AddTransition(2, entities.FILTER, 4)
 	sm.AddTransition(2, entities.BIN, 4)
 	sm.AddTransition(2, entities.MEASUREMENT, 3)
+	sm.AddTransition(2, entities.SELECT, 4)
+	sm.AddTransition(2, entities.RENAME, 4)
+	sm.AddTransition(2, entities.MAP, 4)
 
 	sm.AddTransition(3, entities.FILTER, 4)
 	sm.AddTransition(3, entities.BIN, 4)
 	sm.AddTransition(3, entities.MEASUREMENT, 4)
+	sm.AddTransition(3, entities.SELECT, 4)
+	sm.AddTransition(3, entities.RENAME, 4)
+	sm.AddTransition(3, entities.MAP, 4)
 
 	sm.AddTransition(4, entities.FILTER, 4)
 	sm.AddTransition(4, entities.BIN, 4)
 	sm.AddTransition(4, entities.MEASUREMENT, 4)
+	sm.AddTransition(4, entities.SELECT, 4)
+	sm.AddTransition(4, entities.RENAME, 4)
+	sm.AddTransition(4, entities.MAP, 4)
 
 	return sm
 }
@@ -76,3 +76,27 @@ func TestValidator9(t *testing.T) {
 		t.Fatalf("test failed")
 	}
 }
+
+func TestValidator10(t *testing.T) {
+	testArr := []string{entities.SELECT, entities.RENAME, entities.FILTER, entities.MAP, entities.BIN, entities.MEASUREMENT}
+	validator := NewSMValidator()
+	if validator.VerifyInputs(testArr) != true {
+		t.Fatalf("test failed")
+	}
+}
+
+func TestValidator11(t *testing.T) {
+	testArr := []string{entities.BIN, entities.MAP, entities.MEASUREMENT}
+	validator := NewSMValidator()
+	if validator.VerifyInputs(testArr) != false {
+		t.Fatalf("test failed")
+	}
+}
+
+func TestValidator12(t *testing.T) {
+	testArr := []string{entities.MAP}
+	validator := NewSMValidator()
+	if validator.VerifyInputs(testArr) != false {
+		t.Fatalf("test failed")
+	}
+}
@@ -0,0 +1,123 @@
+package dpfuncs
+
+import (
+	"fmt"
+	"googledp/entities"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Numeric cells go through the value policy of their column before they reach
+// the library. Null and invalid cells are dropped, imputed or fail the query,
+// which is what happens without a policy, and every value is clamped to the
+// declared bounds of the column. The number of dropped rows is never part of a
+// result or an error message.
+
+// attaches the policies of the request to the columns of the schema
+func withValuePolicies(schema []entities.Column, policies map[string]entities.ValuePolicy) ([]entities.Column, error) {
+	newSchema := make([]entities.Column, len(schema))
+	copy(newSchema, schema)
+
+	for name, policy := range policies {
+		index, colType, err := getIndexAndTypeFromSchema(newSchema, name)
+		if err != nil {
+			return nil, err
+		}
+		if !checkIfNumber(colType.GetName()) && colType.GetName() != "Bool" {
+			return nil, fmt.Errorf("value policy for column %s, but policies only apply to Int, Double and Bool columns", name)
+		}
+		if err := policy.Valid(); err != nil {
+			return nil, fmt.Errorf("value policy for column %s: %w", name, err)
+		}
+		if colType.GetName() == "Bool" && policy.Impute != nil && *policy.Impute != 0 && *policy.Impute != 1 {
+			return nil, fmt.Errorf("value policy for column %s: the impute value of a Bool column should be 0 or 1", name)
+		}
+		policy := policy
+		newSchema[index].Policy = &policy
+	}
+
+	return newSchema, nil
+}
+
+func isNull(cell string) bool {
+	trimmed := strings.TrimSpace(cell)
+	return trimmed == "" || strings.EqualFold(trimmed, "null")
+}
+
+// the policy of the column, the default one if the request gave none
+func columnPolicy(col entities.Column) entities.ValuePolicy {
+	if col.Policy != nil {
+		return *col.Policy
+	}
+	return entities.DefaultValuePolicy()
+}
+
+// the action of the policy for a null or invalid cell, empty for a valid cell
+func cellAction(policy entities.ValuePolicy, cell string, invalid bool) string {
+	if isNull(cell) {
+		return policy.Null
+	}
+	if invalid {
+		return policy.Invalid
+	}
+	return ""
+}
+
+// returns the clamped value of a cell, ok is false if the row should be dropped
+func numericValue(col entities.Column, cell string) (float64, bool, error) {
+	policy := columnPolicy(col)
+	f, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+
+	switch cellAction(policy, cell, err != nil || math.IsNaN(f) || math.IsInf(f, 0)) {
+	case "":
+	case entities.DROP:
+		return 0, false, nil
+	case entities.IMPUTE:
+		f = *policy.Impute
+	default:
+		return 0, false, fmt.Errorf("column %s contains null or invalid values", col.Name)
+	}
+
+	return clamp(f, col.Type), true, nil
+}
+
+// returns the value of a Bool cell, ok is false if the row should be dropped.
+// An imputed 1 is true and 0 is false.
+func boolValue(col entities.Column, cell string) (bool, bool, error) {
+	policy := columnPolicy(col)
+	b, err := strconv.ParseBool(strings.TrimSpace(cell))
+
+	switch cellAction(policy, cell, err != nil) {
+	case "":
+	case entities.DROP:
+		return false, false, nil
+	case entities.IMPUTE:
+		b = *policy.Impute == 1
+	default:
+		return false, false, fmt.Errorf("column %s contains null or invalid values", col.Name)
+	}
+
+	return b, true, nil
+}
+
+func clamp(f float64, colType entities.ColType) float64 {
+	return math.Max(colType.GetLow(), math.Min(colType.GetHigh(), f))
+}
+
+// all values of a numeric column that survive the value policy
+func numericValues(schema []entities.Column, index int, data [][]string) ([]float64, error) {
+	values := make([]float64, 0, len(data))
+
+	for _, row := range data {
+		f, ok, err := numericValue(schema[index], row[index])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values = append(values, f)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,142 @@
+package dpfuncs
+
+import (
+	"googledp/entities"
+	"googledp/requests"
+	"testing"
+)
+
+func testPolicyColumn(policy *entities.ValuePolicy) entities.Column {
+	return entities.Column{
+		Name:   "age",
+		Type:   entities.IntType{Name: "Int", Low: 0, High: 100},
+		Policy: policy,
+	}
+}
+
+func TestNumericValueDefaultPolicy(t *testing.T) {
+	col := testPolicyColumn(nil)
+
+	for _, cell := range []string{"", " ", "null", "NULL", "abc", "NaN", "Inf"} {
+		if _, ok, err := numericValue(col, cell); ok || err == nil {
+			t.Fatalf("test failed, expected %q to fail the query", cell)
+		}
+	}
+
+	tests := map[string]float64{"50": 50, " 7 ": 7, "-5": 0, "250": 100}
+	for cell, expected := range tests {
+		f, ok, err := numericValue(col, cell)
+		if err != nil || !ok || f != expected {
+			t.Fatalf("test failed on %q, expected %v but got %v", cell, expected, f)
+		}
+	}
+}
+
+func TestNumericValueImputeAndFail(t *testing.T) {
+	impute := 500.0
+	col := testPolicyColumn(&entities.ValuePolicy{Null: entities.IMPUTE, Invalid: entities.FAIL, Impute: &impute})
+
+	// the imputed value is clamped as well
+	if f, ok, err := numericValue(col, ""); err != nil || !ok || f != 100 {
+		t.Fatalf("test failed, expected imputed 100 but got %v", f)
+	}
+
+	if _, _, err := numericValue(col, "abc"); err == nil {
+		t.Fatalf("test failed, expected error on invalid value")
+	}
+}
+
+func TestWithValuePolicies(t *testing.T) {
+	impute := 1.0
+
+	schema, err := withValuePolicies(getTestSchema(), map[string]entities.ValuePolicy{
+		"age": {Null: entities.IMPUTE, Invalid: entities.DROP, Impute: &impute},
+	})
+	if err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+	if schema[1].Policy == nil || getTestSchema()[1].Policy != nil {
+		t.Fatalf("test failed, policy not attached to a copy of the schema")
+	}
+
+	bad := []map[string]entities.ValuePolicy{
+		{"salary": entities.DefaultValuePolicy()},
+		{"name": entities.DefaultValuePolicy()},
+		{"age": {Null: entities.IMPUTE, Invalid: entities.DROP}},
+		{"age": {Null: "skip", Invalid: entities.DROP}},
+	}
+	for i, policies := range bad {
+		if _, err := withValuePolicies(getTestSchema(), policies); err == nil {
+			t.Fatalf("test %d failed, expected error", i)
+		}
+	}
+}
+
+func TestEvalWithInvalidValues(t *testing.T) {
+	data := genTestData()
+	data[3][1] = ""
+	data[4][1] = "n/a"
+
+	req := requests.Evaluate{
+		Budget: entities.Budget{Epsilon: 1},
+		Query: entities.Query{
+			entities.BinStep{Column: "age", Edges: []float64{0, 50, 100}},
+			entities.SumStep{Column: "age", Mech: "Laplace"},
+		},
+		Schema:        getTestSchema(),
+		PrivacyNotion: "PureDP",
+	}
+
+	if _, err := NewEvalQuery(req, data); err == nil {
+		t.Fatalf("test failed, expected error without a policy")
+	}
+
+	req.ValuePolicies = map[string]entities.ValuePolicy{
+		"age": {Null: entities.DROP, Invalid: entities.DROP},
+	}
+
+	if _, err := NewEvalQuery(req, data); err != nil {
+		t.Fatalf("test failed due to error: %s", err.Error())
+	}
+
+	req.ValuePolicies = map[string]entities.ValuePolicy{
+		"age": {Null: entities.DROP, Invalid: entities.FAIL},
+	}
+
+	if _, err := NewEvalQuery(req, data); err == nil {
+		t.Fatalf("test failed, expected error with fail policy")
+	}
+}
+
+func TestCountWithValuePolicy(t *testing.T) {
+	data := genTestData()
+	data[3][1] = ""
+	data[4][1] = "n/a"
+	step := entities.CountStep{Column: "age", Mech: "Laplace"}
+	// noise small enough to round to the count
+	delta := 0.0
+	budget := entities.Budget{Epsilon: 1e9, Delta: &delta}
+
+	if _, err := count(step, getTestSchema(), data, budget); err == nil {
+		t.Fatalf("test failed, expected error without a policy")
+	}
+
+	impute := 1.0
+	tests := []struct {
+		policy   entities.ValuePolicy
+		expected float64
+	}{
+		{entities.ValuePolicy{Null: entities.DROP, Invalid: entities.DROP}, 98},
+		{entities.ValuePolicy{Null: entities.IMPUTE, Invalid: entities.DROP, Impute: &impute}, 99},
+	}
+	for _, tc := range tests {
+		schema, err := withValuePolicies(getTestSchema(), map[string]entities.ValuePolicy{"age": tc.policy})
+		if err != nil {
+			t.Fatalf("test failed due to error: %s", err.Error())
+		}
+		result, err := count(step, schema, data, budget)
+		if err != nil || result != tc.expected {
+			t.Fatalf("test failed with %+v, expected %v but got %v %v", tc.policy, tc.expected, result, err)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package dpfuncs
 import (
 	"fmt"
 	"googledp/entities"
-	"strconv"
 
 	"github.com/google/differential-privacy/go/v3/dpagg"
 )
@@ -28,14 +27,14 @@ func variance(step entities.QueryStep, schema []entities.Column, data [][]string
 		return 0, fmt.Errorf("something went wrong with initilizing the dp mean")
 	}
 
-	for _, value := range data {
-		if f, err := strconv.ParseFloat(value[index], 64); err == nil {
-			dp_var.Add(f)
-		} else {
-			fmt.Println(value)
-			return 0, fmt.Errorf("error in data")
-		}
+	values, err := numericValues(schema, index, data)
 
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range values {
+		dp_var.Add(f)
 	}
 
 	return dp_var.Result()
@@ -52,8 +51,8 @@ func getVarianceOpts(step entities.QueryStep, typeSpec entities.ColType, allocat
 	opts := dpagg.BoundedVarianceOptions{
 		MaxPartitionsContributed:     1,
 		MaxContributionsPerPartition: 1,
-		Lower:                        float64(lowBound),
-		Upper:                        float64(highBound),
+		Lower:                        lowBound,
+		Upper:                        highBound,
 	}
 	mech := step.GetMechanism()
 
@@ -8,6 +8,8 @@ import (
 type Column struct {
 	Name string  `json:"name"`
 	Type ColType `json:"type"`
+	// set from the value policies of the request, nil means the default policy
+	Policy *ValuePolicy `json:"-"`
 }
 
 func (c *Column) UnmarshalJSON(data []byte) error {
@@ -48,6 +50,8 @@ func (c *Column) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		c.Type = &enumType
+	case "Bool":
+		c.Type = &BoolType{Name: "Bool"}
 	case "Text":
 		var textType StringType
 		if err := json.Unmarshal(tmp.TypeData, &textType); err != nil {
@@ -63,8 +67,8 @@ func (c *Column) UnmarshalJSON(data []byte) error {
 
 type ColType interface {
 	GetName() string
-	GetLow() int64
-	GetHigh() int64
+	GetLow() float64
+	GetHigh() float64
 	GetLabels() []string
 }
 
@@ -78,12 +82,12 @@ func (i IntType) GetName() string {
 	return i.Name
 }
 
-func (i IntType) GetLow() int64 {
-	return i.Low
+func (i IntType) GetLow() float64 {
+	return float64(i.Low)
 }
 
-func (i IntType) GetHigh() int64 {
-	return i.High
+func (i IntType) GetHigh() float64 {
+	return float64(i.High)
 }
 
 func (i IntType) GetLabels() []string {
@@ -91,20 +95,20 @@ func (i IntType) GetLabels() []string {
 }
 
 type DoubleType struct {
-	Name string `json:"name"`
-	Low  int64  `json:"low"`
-	High int64  `json:"high"`
+	Name string  `json:"name"`
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
 }
 
 func (i DoubleType) GetName() string {
 	return i.Name
 }
 
-func (i DoubleType) GetLow() int64 {
+func (i DoubleType) GetLow() float64 {
 	return i.Low
 }
 
-func (i DoubleType) GetHigh() int64 {
+func (i DoubleType) GetHigh() float64 {
 	return i.High
 }
 
@@ -121,16 +125,16 @@ func (i EnumType) GetName() string {
 	return i.Name
 }
 
-func (i EnumType) GetLow() int64 {
+func (i EnumType) GetLow() float64 {
 	return 0
 }
 
-func (i EnumType) GetHigh() int64 {
+func (i EnumType) GetHigh() float64 {
 	return 0
 }
 
 func (i EnumType) GetLabels() []string {
-	return nil
+	return i.Labels
 }
 
 type StringType struct {
@@ -141,14 +145,35 @@ func (i StringType) GetName() string {
 	return i.Name
 }
 
-func (i StringType) GetLow() int64 {
+func (i StringType) GetLow() float64 {
 	return 0
 }
 
-func (i StringType) GetHigh() int64 {
+func (i StringType) GetHigh() float64 {
 	return 0
 }
 
 func (i StringType) GetLabels() []string {
 	return nil
 }
+
+type BoolType struct {
+	Name string `json:"name"`
+}
+
+func (i BoolType) GetName() string {
+	return i.Name
+}
+
+func (i BoolType) GetLow() float64 {
+	return 0
+}
+
+func (i BoolType) GetHigh() float64 {
+	return 0
+}
+
+// bool cells are binned by these labels, see strconv.ParseBool for accepted values
+func (i BoolType) GetLabels() []string {
+	return []string{"false", "true"}
+}
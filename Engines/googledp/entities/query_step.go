@@ -16,8 +16,14 @@ const (
 	VARIANCE       = "variance"
 	COUNT          = "count"
 	QUANTILE       = "quantile"
+	MIN            = "min"
+	MAX            = "max"
+	MEDIAN         = "median"
 	BIN            = "bin"
 	FILTER         = "filter"
+	SELECT         = "select"
+	RENAME         = "rename"
+	MAP            = "map"
 	TRANSFORMATION = "transformation"
 	MEASUREMENT    = "measurement"
 )
@@ -68,6 +74,24 @@ func (q *Query) UnmarshalJSON(data []byte) error {
 					return err
 				}
 				step = quantileStep
+			case MIN:
+				var minStep MinStep
+				if err := json.Unmarshal(value, &minStep); err != nil {
+					return err
+				}
+				step = minStep
+			case MAX:
+				var maxStep MaxStep
+				if err := json.Unmarshal(value, &maxStep); err != nil {
+					return err
+				}
+				step = maxStep
+			case MEDIAN:
+				var medianStep MedianStep
+				if err := json.Unmarshal(value, &medianStep); err != nil {
+					return err
+				}
+				step = medianStep
 			case BIN:
 				var temp map[string]json.RawMessage
 				if err := json.Unmarshal(value, &temp); err != nil {
@@ -78,12 +102,9 @@ func (q *Query) UnmarshalJSON(data []byte) error {
 
 				for k, v := range temp {
 					binStep.Column = k
-					var bins []int64
-
-					if err := json.Unmarshal(v, &bins); err != nil {
+					if err := binStep.parseBins(v); err != nil {
 						return err
 					}
-					binStep.Bins = bins
 					break
 				}
 
@@ -115,6 +136,24 @@ func (q *Query) UnmarshalJSON(data []byte) error {
 				}
 
 				step = filterStep
+			case SELECT:
+				var selectStep SelectStep
+				if err := json.Unmarshal(value, &selectStep.Columns); err != nil {
+					return err
+				}
+				step = selectStep
+			case RENAME:
+				var renameStep RenameStep
+				if err := json.Unmarshal(value, &renameStep.Mapping); err != nil {
+					return err
+				}
+				step = renameStep
+			case MAP:
+				var mapStep MapStep
+				if err := json.Unmarshal(value, &mapStep); err != nil {
+					return err
+				}
+				step = mapStep
 			// Add cases for other types as needed
 			default:
 				return fmt.Errorf("unknown query step type: %s", key)
@@ -131,8 +170,9 @@ type QueryStep interface {
 	GetColumn() string
 	GetMechanism() string
 	GetBudget() *Budget
-	GetBins() []int64
+	GetBins() []float64
 	GetFilters() []Filter
+	GetRanks() []float64
 	GetType() string
 }
 
@@ -158,7 +198,7 @@ func (s MeanStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s MeanStep) GetBins() []int64 {
+func (s MeanStep) GetBins() []float64 {
 	return nil
 }
 
@@ -166,6 +206,10 @@ func (s MeanStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s MeanStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s MeanStep) GetType() string {
 	return MEASUREMENT
 }
@@ -192,7 +236,7 @@ func (s SumStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s SumStep) GetBins() []int64 {
+func (s SumStep) GetBins() []float64 {
 	return nil
 }
 
@@ -200,6 +244,10 @@ func (s SumStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s SumStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s SumStep) GetType() string {
 	return MEASUREMENT
 }
@@ -226,7 +274,7 @@ func (s StdevStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s StdevStep) GetBins() []int64 {
+func (s StdevStep) GetBins() []float64 {
 	return nil
 }
 
@@ -234,6 +282,10 @@ func (s StdevStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s StdevStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s StdevStep) GetType() string {
 	return MEASUREMENT
 }
@@ -260,7 +312,7 @@ func (s VarianceStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s VarianceStep) GetBins() []int64 {
+func (s VarianceStep) GetBins() []float64 {
 	return nil
 }
 
@@ -268,6 +320,10 @@ func (s VarianceStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s VarianceStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s VarianceStep) GetType() string {
 	return MEASUREMENT
 }
@@ -294,7 +350,7 @@ func (s CountStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s CountStep) GetBins() []int64 {
+func (s CountStep) GetBins() []float64 {
 	return nil
 }
 
@@ -302,14 +358,19 @@ func (s CountStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s CountStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s CountStep) GetType() string {
 	return MEASUREMENT
 }
 
 type QuantileStep struct {
-	Column string  `json:"column"`
-	Mech   string  `json:"mech"`
-	Budget *Budget `json:"budget"`
+	Column string    `json:"column"`
+	Mech   string    `json:"mech"`
+	Budget *Budget   `json:"budget"`
+	Ranks  []float64 `json:"ranks"`
 }
 
 func (s QuantileStep) GetOperation() string {
@@ -328,7 +389,7 @@ func (s QuantileStep) GetBudget() *Budget {
 	return s.Budget
 }
 
-func (s QuantileStep) GetBins() []int64 {
+func (s QuantileStep) GetBins() []float64 {
 	return nil
 }
 
@@ -336,13 +397,177 @@ func (s QuantileStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s QuantileStep) GetRanks() []float64 {
+	return s.Ranks
+}
+
 func (s QuantileStep) GetType() string {
 	return MEASUREMENT
 }
 
-type BinStep struct {
+// MinStep is computed as the quantile of rank 0
+type MinStep struct {
+	Column string  `json:"column"`
+	Mech   string  `json:"mech"`
+	Budget *Budget `json:"budget"`
+}
+
+func (s MinStep) GetOperation() string {
+	return MIN
+}
+
+func (s MinStep) GetColumn() string {
+	return s.Column
+}
+
+func (s MinStep) GetMechanism() string {
+	return s.Mech
+}
+
+func (s MinStep) GetBudget() *Budget {
+	return s.Budget
+}
+
+func (s MinStep) GetBins() []float64 {
+	return nil
+}
+
+func (s MinStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s MinStep) GetRanks() []float64 {
+	return []float64{0}
+}
+
+func (s MinStep) GetType() string {
+	return MEASUREMENT
+}
+
+// MaxStep is computed as the quantile of rank 1
+type MaxStep struct {
+	Column string  `json:"column"`
+	Mech   string  `json:"mech"`
+	Budget *Budget `json:"budget"`
+}
+
+func (s MaxStep) GetOperation() string {
+	return MAX
+}
+
+func (s MaxStep) GetColumn() string {
+	return s.Column
+}
+
+func (s MaxStep) GetMechanism() string {
+	return s.Mech
+}
+
+func (s MaxStep) GetBudget() *Budget {
+	return s.Budget
+}
+
+func (s MaxStep) GetBins() []float64 {
+	return nil
+}
+
+func (s MaxStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s MaxStep) GetRanks() []float64 {
+	return []float64{1}
+}
+
+func (s MaxStep) GetType() string {
+	return MEASUREMENT
+}
+
+// MedianStep is computed as the quantile of rank 0.5
+type MedianStep struct {
 	Column string  `json:"column"`
-	Bins   []int64 `json:"bins"`
+	Mech   string  `json:"mech"`
+	Budget *Budget `json:"budget"`
+}
+
+func (s MedianStep) GetOperation() string {
+	return MEDIAN
+}
+
+func (s MedianStep) GetColumn() string {
+	return s.Column
+}
+
+func (s MedianStep) GetMechanism() string {
+	return s.Mech
+}
+
+func (s MedianStep) GetBudget() *Budget {
+	return s.Budget
+}
+
+func (s MedianStep) GetBins() []float64 {
+	return nil
+}
+
+func (s MedianStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s MedianStep) GetRanks() []float64 {
+	return []float64{0.5}
+}
+
+func (s MedianStep) GetType() string {
+	return MEASUREMENT
+}
+
+// BinStep partitions the rows of a column. Numeric columns are binned by edges,
+// each bin is [lower, upper), where "-inf" as first and "inf" as last edge add an
+// underflow and an overflow bin. Enum and Bool columns get one bin per label,
+// or per listed label if labels are given.
+type BinStep struct {
+	Column    string    `json:"column"`
+	Edges     []float64 `json:"edges"`
+	Labels    []string  `json:"labels"`
+	Underflow bool      `json:"underflow"`
+	Overflow  bool      `json:"overflow"`
+}
+
+func (s *BinStep) parseBins(data json.RawMessage) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for i, value := range raw {
+		switch v := value.(type) {
+		case float64:
+			if len(s.Labels) > 0 || s.Overflow {
+				return fmt.Errorf("bins can not mix edges and labels")
+			}
+			s.Edges = append(s.Edges, v)
+		case string:
+			if v == "-inf" && i == 0 {
+				s.Underflow = true
+			} else if v == "inf" && i == len(raw)-1 && len(s.Edges) > 0 {
+				s.Overflow = true
+			} else if len(s.Edges) > 0 || s.Underflow {
+				return fmt.Errorf("bins can not mix edges and labels")
+			} else {
+				s.Labels = append(s.Labels, v)
+			}
+		case bool:
+			if len(s.Edges) > 0 || s.Underflow {
+				return fmt.Errorf("bins can not mix edges and labels")
+			}
+			s.Labels = append(s.Labels, strconv.FormatBool(v))
+		default:
+			return fmt.Errorf("bins should be numbers, labels or \"-inf\" and \"inf\"")
+		}
+	}
+
+	return nil
 }
 
 func (s BinStep) GetOperation() string {
@@ -361,14 +586,18 @@ func (s BinStep) GetBudget() *Budget {
 	return nil
 }
 
-func (s BinStep) GetBins() []int64 {
-	return s.Bins
+func (s BinStep) GetBins() []float64 {
+	return s.Edges
 }
 
 func (s BinStep) GetFilters() []Filter {
 	return nil
 }
 
+func (s BinStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s BinStep) GetType() string {
 	return TRANSFORMATION
 }
@@ -400,7 +629,7 @@ func (s FilterStep) GetBudget() *Budget {
 	return nil
 }
 
-func (s FilterStep) GetBins() []int64 {
+func (s FilterStep) GetBins() []float64 {
 	return nil
 }
 
@@ -408,6 +637,123 @@ func (s FilterStep) GetFilters() []Filter {
 	return s.Filters
 }
 
+func (s FilterStep) GetRanks() []float64 {
+	return nil
+}
+
 func (s FilterStep) GetType() string {
 	return TRANSFORMATION
 }
+
+// SelectStep projects the working schema onto the given columns, in the given order
+type SelectStep struct {
+	Columns []string
+}
+
+func (s SelectStep) GetOperation() string {
+	return SELECT
+}
+
+func (s SelectStep) GetColumn() string {
+	return ""
+}
+
+func (s SelectStep) GetMechanism() string {
+	return ""
+}
+
+func (s SelectStep) GetBudget() *Budget {
+	return nil
+}
+
+func (s SelectStep) GetBins() []float64 {
+	return nil
+}
+
+func (s SelectStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s SelectStep) GetRanks() []float64 {
+	return nil
+}
+
+func (s SelectStep) GetType() string {
+	return TRANSFORMATION
+}
+
+// RenameStep relabels columns, old name -> new name. All renames are applied at once
+type RenameStep struct {
+	Mapping map[string]string
+}
+
+func (s RenameStep) GetOperation() string {
+	return RENAME
+}
+
+func (s RenameStep) GetColumn() string {
+	return ""
+}
+
+func (s RenameStep) GetMechanism() string {
+	return ""
+}
+
+func (s RenameStep) GetBudget() *Budget {
+	return nil
+}
+
+func (s RenameStep) GetBins() []float64 {
+	return nil
+}
+
+func (s RenameStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s RenameStep) GetRanks() []float64 {
+	return nil
+}
+
+func (s RenameStep) GetType() string {
+	return TRANSFORMATION
+}
+
+// MapStep computes a new column from an arithmetic expression over numeric columns.
+// The schema declares the name, type and bounds of the produced column
+type MapStep struct {
+	Fun    string   `json:"fun"`
+	Schema []Column `json:"schema"`
+}
+
+func (s MapStep) GetOperation() string {
+	return MAP
+}
+
+func (s MapStep) GetColumn() string {
+	return ""
+}
+
+func (s MapStep) GetMechanism() string {
+	return ""
+}
+
+func (s MapStep) GetBudget() *Budget {
+	return nil
+}
+
+func (s MapStep) GetBins() []float64 {
+	return nil
+}
+
+func (s MapStep) GetFilters() []Filter {
+	return nil
+}
+
+func (s MapStep) GetRanks() []float64 {
+	return nil
+}
+
+func (s MapStep) GetType() string {
+	return TRANSFORMATION
+}
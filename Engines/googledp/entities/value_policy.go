@@ -0,0 +1,42 @@
+package entities
+
+import "fmt"
+
+const (
+	DROP   = "drop"
+	IMPUTE = "impute"
+	FAIL   = "fail"
+)
+
+// ValuePolicy decides what happens to a numeric cell that is missing (null)
+// or can not be parsed as a finite number (invalid)
+type ValuePolicy struct {
+	Null    string   `json:"null"`
+	Invalid string   `json:"invalid"`
+	Impute  *float64 `json:"impute,omitempty"`
+}
+
+// policy used for columns that have none configured, a query on a column with
+// null or invalid cells fails unless the request chooses to drop or impute them
+func DefaultValuePolicy() ValuePolicy {
+	return ValuePolicy{
+		Null:    FAIL,
+		Invalid: FAIL,
+	}
+}
+
+func (p ValuePolicy) Valid() error {
+	for _, action := range []string{p.Null, p.Invalid} {
+		switch action {
+		case DROP, FAIL:
+		case IMPUTE:
+			if p.Impute == nil {
+				return fmt.Errorf("impute policy needs an impute value")
+			}
+		default:
+			return fmt.Errorf("unknown value policy %q, should be one of drop, impute or fail", action)
+		}
+	}
+
+	return nil
+}
@@ -8,7 +8,10 @@ type Evaluate struct {
 	Budget        entities.Budget   `json:"budget"`
 	Query         entities.Query    `json:"query"`
 	Dataset       int64             `json:"dataset"`
+	Version       int64             `json:"version"`
 	Schema        []entities.Column `json:"schema"`
 	PrivacyNotion string            `json:"privacy_notion"`
 	CallbackUrl   string            `json:"url"`
+	// per column policies for null and invalid numeric values
+	ValuePolicies map[string]entities.ValuePolicy `json:"value_policies"`
 }
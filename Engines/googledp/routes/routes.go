@@ -10,18 +10,28 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 
 	"github.com/gorilla/mux"
 )
 
+// data is cached per version, a pinned version and the latest may both be in use
+type cacheKey struct {
+	dataset int64
+	version int64
+}
+
 type handlers struct {
-	Cache map[int64][][]string
+	mu    *sync.Mutex
+	Cache map[cacheKey][][]string
 }
 
 func RegisterRoutes(r *mux.Router) {
 
 	handlers := &handlers{
-		Cache: make(map[int64][][]string),
+		mu:    &sync.Mutex{},
+		Cache: make(map[cacheKey][][]string),
 	}
 
 	r.HandleFunc("/evaluate", wrapperHttp(handlers.evaluate)).Methods("POST")
@@ -39,15 +49,11 @@ func (h handlers) evaluate(w http.ResponseWriter, r *http.Request) error {
 		return WriteJSON(w, http.StatusBadRequest, err.Error())
 	}
 
-	records, ok := h.Cache[eval.Dataset]
+	records, err := h.getData(eval)
 
-	if !ok {
-		data, err := client.GetCSVData(eval.CallbackUrl)
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-		h.Cache[eval.Dataset] = data
-		records = data
+	if err != nil {
+		fmt.Println(err.Error())
+		return WriteJSON(w, http.StatusInternalServerError, err.Error())
 	}
 
 	result, err := dpfuncs.NewEvalQuery(eval, records[1:])
@@ -72,19 +78,14 @@ func (h handlers) validate(w http.ResponseWriter, r *http.Request) error {
 		return WriteJSON(w, http.StatusOK, ValidateResponse{Valid: false, Status: err.Error()})
 	}
 
-	records, ok := h.Cache[eval.Dataset]
+	records, err := h.getData(eval)
 
-	if !ok {
-		data, err := client.GetCSVData(eval.CallbackUrl)
-		if err != nil {
-			fmt.Println(err.Error())
-			return WriteJSON(w, http.StatusInternalServerError, ValidateResponse{Valid: false, Status: err.Error()})
-		}
-		h.Cache[eval.Dataset] = data
-		records = data
+	if err != nil {
+		fmt.Println(err.Error())
+		return WriteJSON(w, http.StatusInternalServerError, ValidateResponse{Valid: false, Status: err.Error()})
 	}
 
-	_, err := dpfuncs.NewEvalQuery(eval, records[1:])
+	_, err = dpfuncs.NewEvalQuery(eval, records[1:])
 
 	if err != nil {
 		fmt.Printf("%s", err.Error())
@@ -121,8 +122,49 @@ func (h handlers) functions(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// drops every cached version of a dataset
 func (h handlers) cache(w http.ResponseWriter, r *http.Request) error {
-	return WriteJSON(w, http.StatusNotImplemented, "not implemented")
+	dataset, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, "invalid dataset id")
+	}
+
+	h.mu.Lock()
+	for key := range h.Cache {
+		if key.dataset == dataset {
+			delete(h.Cache, key)
+		}
+	}
+	h.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// the cached data of the version of the query, fetched from the callback url if missing
+func (h handlers) getData(eval requests.Evaluate) ([][]string, error) {
+	key := cacheKey{dataset: eval.Dataset, version: eval.Version}
+
+	h.mu.Lock()
+	records, ok := h.Cache[key]
+	h.mu.Unlock()
+	if ok {
+		return records, nil
+	}
+
+	records, err := client.GetCSVData(eval.CallbackUrl)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("dataset %d has no header", eval.Dataset)
+	}
+
+	h.mu.Lock()
+	h.Cache[key] = records
+	h.mu.Unlock()
+
+	return records, nil
 }
 
 // HELPER FUNCTIONS
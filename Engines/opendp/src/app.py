@@ -21,7 +21,8 @@ import pandas as pd
 app = Flask(__name__)
 
 class Cache:
-    _cache: Dict[int, Tuple[int, str]]
+    # by dataset and version of the data
+    _cache: Dict[Tuple[int, int], Tuple[int, str]]
     _lock: threading.Lock
     
     def __init__(self) -> None:
@@ -32,17 +33,19 @@ class Cache:
     def delete_dataset(self, did: int):
         self._lock.acquire()
         try:
-            self._cache.pop(did)
-        except:
-            print(f"{did} not in cache")
+            keys = [key for key in self._cache if key[0] == did]
+            if not keys:
+                print(f"{did} not in cache")
+            for key in keys:
+                self._cache.pop(key)
         finally:
             self._lock.release()
 
     
-    def update_cache(self, did: int, url: str):
+    def update_cache(self, did: int, version: int, url: str):
         self._lock.acquire()
         try:
-            if not self._cache.__contains__(did):
+            if not self._cache.__contains__((did, version)):
                 # fetch
                 resp = requests.get(url)
                 if resp.status_code != 200:
@@ -50,14 +53,14 @@ class Cache:
                 
                 resp.encoding = 'utf-8'
                 recieved_at = int(time.time())
-                self._cache[did] = (recieved_at, resp.text)
+                self._cache[(did, version)] = (recieved_at, resp.text)
         finally:
             self._lock.release()
     
-    def get_csv(self, did: int) -> str:
+    def get_csv(self, did: int, version: int) -> str:
         self._lock.acquire()
         try:
-            return self._cache.get(did)[1]
+            return self._cache.get((did, version))[1]
         finally:
             self._lock.release()
 
@@ -78,7 +81,7 @@ class QHandler:
                 budget=req.budget,
                 privacy_notion=req.privacy_notion
             )
-            data = self.cache.get_csv(req.datasetId)
+            data = self.cache.get_csv(req.datasetId, req.version)
             app.logger.debug(req.query)
             
             dummy = pd.read_csv(StringIO(data))
@@ -111,7 +114,7 @@ class QHandler:
             )
             
 
-            data = self.cache.get_csv(req.datasetId)
+            data = self.cache.get_csv(req.datasetId, req.version)
             
             result = self.service.build_query_from_sequence(
                 query_steps=req.query,
@@ -162,7 +165,7 @@ def evaluate_query():
     data = request.get_json()
     try:
         qr = QueryRequest.fromJson(**data)
-        _cache.update_cache(qr.datasetId, qr.dataLoc)
+        _cache.update_cache(qr.datasetId, qr.version, qr.dataLoc)
         return _handler.evaluate(qr)
     except Exception as e:
         return str(e), 500
@@ -174,7 +177,7 @@ def accuracy():
         ar = AccuracyRequest.fromJson(**data)
         url = ar.qr.dataLoc
         did = ar.qr.datasetId
-        _cache.update_cache(did, url)
+        _cache.update_cache(did, ar.qr.version, url)
         return _handler.accuracy(req=ar.qr, confidence=ar.confidence)
     except:
         return "", 500
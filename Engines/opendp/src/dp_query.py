@@ -225,6 +225,8 @@ class QueryRequest:
     schema: List[ColumnSchema]
     dataLoc: str
     datasetId: int
+    # version of the data, 0 for the latest
+    version: int = 0
 
     def fromJson(**kwargs):
         fields = list(filter(
@@ -264,7 +266,8 @@ class QueryRequest:
             dataLoc=kwargs["url"],
             data = "",
             csv_header= "",
-            datasetId=datasetId
+            datasetId=datasetId,
+            version=int(kwargs.get("version") or 0)
         )
         
 
@@ -11,8 +11,8 @@ class IntType:
 
 @dataclass 
 class DoubleType:
-    low: int
-    high: int 
+    low: float
+    high: float 
     name: str = "Double"
 
 
@@ -45,7 +45,7 @@ class DpType:
             return DpType(dptype=IntType(low=l, high=h))
            
         elif n == "Double":
-            l, h = int(kwargs["low"]), int(kwargs["high"])
+            l, h = float(kwargs["low"]), float(kwargs["high"])
             return DpType(dptype=DoubleType(low=l, high=h))
             
         elif n == "Enum":
@@ -8,6 +8,7 @@ from dataframe.to_pyspark_session import from_csv
 
 app = FastAPI()
 
+# tumult sessions by dataset and version of the data
 app.cache = {}
 
 @app.middleware("http")
@@ -18,20 +19,20 @@ async def log_requests(request: Request, call_next):
 
 @app.post("/evaluate")
 def post_evaluate_v2(query: EvalRequestWithCallBack, response: Response):
-    if query.dataset not in app.cache:
+    if (query.dataset, query.version) not in app.cache:
         try:
             resp = requests.get(query.url, timeout=30)
             if resp.status_code == 200:
                 pyspark_sess = from_csv(resp.text, query.schema)
                 tmlt_sess = create_tmlt_session(query.dataset, pyspark_sess, query.privacy_notion, Budget(epsilon=float('inf'), delta=float('inf')))
-                app.cache[query.dataset] = tmlt_sess
+                app.cache[(query.dataset, query.version)] = tmlt_sess
             else:
                 response.status_code = 400
                 return {"error": "failed to retrieve data"}
         except requests.exceptions.Timeout:
             response.status_code = 500
             return {"error": "failed to retrieve data"}
-    resp, status_code = query_evaluate_with_data(query, app.cache[query.dataset])
+    resp, status_code = query_evaluate_with_data(query, app.cache[(query.dataset, query.version)])
     response.status_code = status_code
     return resp
 
@@ -61,8 +62,10 @@ def get_docs():
 
 @app.delete("/cache/{dataset_id}")
 def delete_cached_dataset(dataset_id: int, response: Response):
-    if dataset_id in app.cache:
-        del app.cache[dataset_id]
+    keys = [key for key in app.cache if key[0] == dataset_id]
+    if keys:
+        for key in keys:
+            del app.cache[key]
         response.status_code = 204
         return {"status": f"dataset with id {dataset_id} deleted from cache"}
     response.status_code = 404
@@ -70,20 +73,20 @@ def delete_cached_dataset(dataset_id: int, response: Response):
 
 @app.post("/validate")
 def post_validate(query: EvalRequestWithCallBack, response: Response):
-    if query.dataset not in app.cache:
+    if (query.dataset, query.version) not in app.cache:
         try:
             resp = requests.get(query.url, timeout=30)
             if resp.status_code == 200:
                 pyspark_sess = from_csv(resp.text, query.schema)
                 tmlt_sess = create_tmlt_session(query.dataset, pyspark_sess, query.privacy_notion, Budget(epsilon=float('inf'), delta=float('inf')))
-                app.cache[query.dataset] = tmlt_sess
+                app.cache[(query.dataset, query.version)] = tmlt_sess
             else:
                 response.status_code = 400
                 return {"valid": False, "status": "failed to validate query"}
         except requests.exceptions.Timeout:
             response.status_code = 500
             return {"valid": False, "status": "failed to validate query"}
-    resp, status_code = query_evaluate_with_data(query, app.cache[query.dataset])
+    resp, status_code = query_evaluate_with_data(query, app.cache[(query.dataset, query.version)])
     response.status_code = status_code
     if status_code == 200:
         return {"valid": True, "status": "query is valid in tumult"}
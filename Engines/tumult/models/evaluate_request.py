@@ -5,8 +5,8 @@ from pydantic import BaseModel
 
 class DataType(BaseModel):
     name: str
-    low: int | None = None
-    high: int | None = None
+    low: int | float | None = None
+    high: int | float | None = None
     labels: List[str] | None = None
 
 class ColumnSchema(BaseModel):
@@ -74,4 +74,6 @@ class EvalRequestWithCallBack(BaseModel):
     privacy_notion: PrivacyNotion
     dataset: int
     schema: List[ColumnSchema]
-    url: str
\ No newline at end of file
+    url: str
+    # version of the data, 0 for the latest
+    version: int = 0
\ No newline at end of file
@@ -2,25 +2,97 @@ CREATE TYPE WebDPType AS ENUM ('Int', 'Double', 'Bool', 'Text', 'Enum');
 CREATE TYPE PrivacyNotion AS ENUM ('PureDP', 'ApproxDP');
 
 
+-- roles are sets of permissions, the built-in roles cannot be changed
 CREATE TABLE Roles (
-    role TEXT PRIMARY KEY
+    role TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE
 );
 
+CREATE TABLE Permissions (
+    permission TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE RolePermissions (
+    role TEXT,
+    permission TEXT,
+    PRIMARY KEY (role, permission),
+    FOREIGN KEY (role) REFERENCES Roles(role) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES Permissions(permission) ON DELETE CASCADE
+);
+
+-- tenants, every user and dataset belongs to one. The total budgets of the
+-- datasets of an organization are at most its budget cap, if it has one
+CREATE TABLE Organizations (
+    handle TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    epsilon_cap DOUBLE PRECISION,
+    delta_cap DOUBLE PRECISION,
+    created_time TIMESTAMPTZ NOT NULL,
+    CHECK(LENGTH(handle) > 0),
+    CHECK(LENGTH(name) > 0),
+    CHECK(COALESCE(epsilon_cap, 0.0) >= 0.0),
+    CHECK(COALESCE(delta_cap, 0.0) >= 0.0)
+);
+
+-- the organization of the platform, its admins manage the other organizations
+INSERT INTO Organizations VALUES ('default', 'Default', NULL, NULL, NOW());
+
 CREATE TABLE Users (
     handle TEXT PRIMARY KEY,
     name TEXT NOT NULL,
     pwd TEXT NOT NULL,
+    organization TEXT NOT NULL DEFAULT 'default' REFERENCES Organizations(handle),
     created_time TIMESTAMPTZ NOT NULL,
     updated_time TIMESTAMPTZ NOT NULL,
+    -- failed logins since the last login, the account refuses logins until locked_until
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
     CHECK(LENGTH(handle) > 0),
     CHECK(LENGTH(pwd) > 0),
     CHECK(LENGTH(name) > 0)
 );
 
-CREATE TABLE UserTokens (
-    username TEXT,
-    token TEXT NOT NULL,
-    PRIMARY KEY (username),
+-- every login is a session, the refresh tokens are stored hashed
+CREATE TABLE UserSessions (
+    id TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    device TEXT NOT NULL DEFAULT '',
+    refresh_hash TEXT NOT NULL,
+    previous_hash TEXT,
+    created_time TIMESTAMPTZ NOT NULL,
+    refreshed_time TIMESTAMPTZ NOT NULL,
+    expires_time TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (username) REFERENCES Users(handle) ON DELETE CASCADE
+);
+
+CREATE INDEX UserSessionsUser ON UserSessions (username);
+
+-- keys of service accounts, an empty list of datasets gives access to all datasets of the owner
+CREATE TABLE ApiKeys (
+    id TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    roles TEXT[] NOT NULL,
+    datasets BIGINT[] NOT NULL DEFAULT '{}',
+    created_by TEXT NOT NULL,
+    created_time TIMESTAMPTZ NOT NULL,
+    expires_time TIMESTAMPTZ NOT NULL,
+    last_used_time TIMESTAMPTZ,
+    FOREIGN KEY (owner) REFERENCES Users(handle) ON DELETE CASCADE
+);
+
+CREATE INDEX ApiKeysOwner ON ApiKeys (owner);
+
+-- users provisioned on their first login with the identity provider
+CREATE TABLE ExternalIdentities (
+    issuer TEXT,
+    subject TEXT,
+    username TEXT NOT NULL UNIQUE,
+    created_time TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer, subject),
     FOREIGN KEY (username) REFERENCES Users(handle) ON DELETE CASCADE
 );
 
@@ -36,11 +108,18 @@ CREATE TABLE Dataset (
     id SERIAL PRIMARY KEY,
     name TEXT NOT NULL,
     owner TEXT, 
+    organization TEXT NOT NULL REFERENCES Organizations(handle),
     privacy_notion PrivacyNotion NOT NULL,
     total_epsilon DOUBLE PRECISION NOT NULL, 
     total_delta DOUBLE PRECISION,
+    description TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    source TEXT NOT NULL DEFAULT '',
+    contact TEXT NOT NULL DEFAULT '',
     created_time TIMESTAMPTZ NOT NULL,
     updated_time TIMESTAMPTZ NOT NULL,
+    deleted_time TIMESTAMPTZ,
+    deleted_by TEXT,
     FOREIGN KEY (owner) REFERENCES Users(handle) ON DELETE CASCADE,
     CHECK(privacy_notion != 'PureDP' OR (COALESCE(total_delta, 0.0) = 0.0)),
     CHECK(privacy_notion != 'ApproxDP' OR total_delta IS NOT NULL),
@@ -48,6 +127,10 @@ CREATE TABLE Dataset (
     CHECK(COALESCE(total_delta, 0.0) >= 0.0)
 );
 
+-- datasets are filtered by tag when they are listed
+CREATE INDEX DatasetTags ON Dataset USING GIN (tags);
+CREATE INDEX DatasetOrganization ON Dataset (organization);
+
 /*
 ,
     CHECK(privacy_notion != 'PureDP' OR (total_delta IS NULL or total_delta = 0.0)),
@@ -56,19 +139,71 @@ CREATE TABLE Dataset (
 
 */
 
+-- every upload creates a version, an append version also holds the rows of the versions before it.
+-- a schema version holds the rows of the version before it rewritten to a changed schema
 CREATE TABLE DataUpload (
-    dataset SERIAL PRIMARY KEY,
+    dataset INTEGER,
+    version INTEGER,
+    mode TEXT NOT NULL,
     loaded_time TIMESTAMPTZ NOT NULL,
-    loaded_data BYTEA NOT NULL,
+    header TEXT NOT NULL,
+    row_count BIGINT NOT NULL,
+    total_rows BIGINT NOT NULL,
+    budget_reset BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (dataset, version),
+    FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
+    CHECK (mode IN ('replace', 'append', 'schema')),
+    CHECK (version > 0)
+);
+
+-- one CSV encoded line per uploaded row, in upload order, encrypted with the data key of the dataset
+CREATE TABLE DataRows (
+    dataset INTEGER,
+    version INTEGER,
+    row_number BIGINT,
+    line BYTEA NOT NULL,
+    PRIMARY KEY (dataset, version, row_number),
+    FOREIGN KEY (dataset, version) REFERENCES DataUpload(dataset, version) ON DELETE CASCADE
+);
+
+-- resumable uploads, chunks are appended at the current size of the session by its owner
+CREATE TABLE UploadSessions (
+    id SERIAL PRIMARY KEY,
+    dataset INTEGER NOT NULL,
+    owner TEXT NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_time TIMESTAMPTZ NOT NULL,
+    updated_time TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
+    FOREIGN KEY (owner) REFERENCES Users(handle) ON DELETE CASCADE
+);
+
+-- chunks are encrypted with the data key of the dataset
+CREATE TABLE UploadChunks (
+    session INTEGER,
+    chunk_offset BIGINT,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (session, chunk_offset),
+    FOREIGN KEY (session) REFERENCES UploadSessions(id) ON DELETE CASCADE
+);
+
+-- the data key of a dataset, wrapped by the master key with id master_key
+CREATE TABLE DataKeys (
+    dataset INTEGER PRIMARY KEY,
+    master_key TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_time TIMESTAMPTZ NOT NULL,
+    rotated_time TIMESTAMPTZ,
     FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE
 );
 
 CREATE TABLE ColumnSchemas (
     dataset SERIAL, 
     column_name TEXT,
+    position INTEGER NOT NULL,
     data_type WebDPType NOT NULL, 
-    low INT,
-    high INT,
+    low DOUBLE PRECISION,
+    high DOUBLE PRECISION,
     labels TEXT[],
     PRIMARY KEY (dataset, column_name),
     FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
@@ -79,6 +214,95 @@ CREATE TABLE ColumnSchemas (
         )
 );
 
+-- audit trail of schema changes, the version is the data rewritten by the change
+CREATE TABLE SchemaChanges (
+    id SERIAL PRIMARY KEY,
+    dataset INTEGER NOT NULL,
+    changed_by TEXT NOT NULL,
+    changed_time TIMESTAMPTZ NOT NULL,
+    patch JSONB NOT NULL,
+    old_schema JSONB NOT NULL,
+    new_schema JSONB NOT NULL,
+    version INTEGER,
+    report JSONB,
+    FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE
+);
+
+-- members of a dataset and their role, the owner is also Dataset.owner
+CREATE TABLE DatasetMembers (
+    dataset INTEGER,
+    userid TEXT,
+    role TEXT NOT NULL,
+    added_by TEXT NOT NULL,
+    added_time TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (dataset, userid),
+    FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
+    FOREIGN KEY (userid) REFERENCES Users(handle) ON DELETE CASCADE,
+    CHECK (role IN ('owner', 'co-curator', 'viewer'))
+);
+
+-- a dataset has a single owner
+CREATE UNIQUE INDEX DatasetOwner ON DatasetMembers (dataset) WHERE role = 'owner';
+
+-- a proposed owner, who becomes the owner by accepting the transfer
+CREATE TABLE OwnershipTransfers (
+    dataset INTEGER PRIMARY KEY,
+    from_user TEXT NOT NULL,
+    to_user TEXT NOT NULL,
+    created_time TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
+    FOREIGN KEY (to_user) REFERENCES Users(handle) ON DELETE CASCADE
+);
+
+-- the budgets of purged datasets, kept after the dataset and its data are dropped
+CREATE TABLE PurgedDatasets (
+    dataset INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    organization TEXT NOT NULL,
+    privacy_notion PrivacyNotion NOT NULL,
+    total_epsilon DOUBLE PRECISION NOT NULL,
+    total_delta DOUBLE PRECISION NOT NULL,
+    allocation JSONB NOT NULL,
+    deleted_by TEXT,
+    deleted_time TIMESTAMPTZ NOT NULL,
+    purged_time TIMESTAMPTZ NOT NULL
+);
+
+CREATE FUNCTION RejectChange() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is immutable', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER PurgedDatasetsImmutable BEFORE UPDATE OR DELETE ON PurgedDatasets
+    FOR EACH ROW EXECUTE FUNCTION RejectChange();
+
+-- administrative and privacy-relevant actions. Every entry has the hash of the
+-- previous entry and its own, so that a changed or removed entry breaks the chain.
+-- The values are JSON rather than JSONB to keep the text that was hashed
+CREATE TABLE AuditLog (
+    id BIGSERIAL PRIMARY KEY,
+    time TIMESTAMPTZ NOT NULL,
+    actor TEXT NOT NULL,
+    organization TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL,
+    before JSON,
+    after JSON,
+    request_id TEXT NOT NULL DEFAULT '',
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX AuditLogTime ON AuditLog (time);
+
+CREATE TRIGGER AuditLogImmutable BEFORE UPDATE OR DELETE ON AuditLog
+    FOR EACH ROW EXECUTE FUNCTION RejectChange();
+
+CREATE TRIGGER AuditLogNoTruncate BEFORE TRUNCATE ON AuditLog
+    FOR EACH STATEMENT EXECUTE FUNCTION RejectChange();
+
 CREATE TABLE DPEngines (
     name TEXT PRIMARY KEY, 
     eval_url TEXT,
@@ -92,31 +316,106 @@ CREATE TABLE UserBudgetAllocation (
     all_delta DOUBLE PRECISION,
     con_epsilon DOUBLE PRECISION,
     con_delta DOUBLE PRECISION,
+    -- the queries per day on the allocation, the configured default if NULL and no quota if 0
+    daily_queries INT,
     PRIMARY KEY (dataset, userid),
     FOREIGN KEY (dataset) REFERENCES Dataset(id) ON DELETE CASCADE,
     FOREIGN KEY (userid) REFERENCES Users(handle) ON DELETE CASCADE,
     CHECK (all_epsilon >= COALESCE(con_epsilon, 0)),
-    CHECK (COALESCE(all_delta, 0) >= COALESCE(con_delta, 0))
+    CHECK (COALESCE(all_delta, 0) >= COALESCE(con_delta, 0)),
+    CHECK (COALESCE(daily_queries, 0) >= 0)
+);
+
+-- the queries made on each allocation per day, in UTC
+CREATE TABLE QueryCounts (
+    dataset INT,
+    userid TEXT,
+    day DATE,
+    queries INT NOT NULL,
+    PRIMARY KEY (dataset, userid, day),
+    FOREIGN KEY (dataset, userid) REFERENCES UserBudgetAllocation(dataset, userid) ON DELETE CASCADE
+);
+
+-- the requests of each user per window of the rate limits, shared by the
+-- replicas of Webdp. Unlogged as the counts are not worth keeping after a crash
+CREATE UNLOGGED TABLE RateLimitCounts (
+    username TEXT,
+    class TEXT,
+    window_start TIMESTAMPTZ,
+    requests INT NOT NULL,
+    PRIMARY KEY (username, class, window_start)
 );
 
 
-INSERT INTO Roles VALUES ('Analyst');
-INSERT INTO Roles VALUES ('Admin');
-INSERT INTO Roles VALUES ('Curator');
+INSERT INTO Roles VALUES ('Analyst', 'Queries the datasets it has a budget on', TRUE);
+INSERT INTO Roles VALUES ('Admin', 'Manages users and roles', TRUE);
+INSERT INTO Roles VALUES ('Curator', 'Creates datasets and allocates budgets on them', TRUE);
 
+INSERT INTO Permissions VALUES
+    ('user.read', 'Read the users of the organization'),
+    ('user.manage', 'Create, update and delete users and manage their API keys'),
+    ('role.manage', 'Create, update and delete custom roles, only in the default organization'),
+    ('org.manage', 'Create, update and delete organizations, only in the default organization'),
+    ('dataset.read', 'Read the datasets of the organization, their versions, schema changes and members'),
+    ('dataset.create', 'Create datasets, and own them'),
+    ('dataset.update', 'Update datasets and schemas that one owns or co-curates'),
+    ('dataset.upload', 'Upload data to datasets that one owns or co-curates'),
+    ('dataset.restore', 'Restore any deleted dataset of the organization'),
+    ('budget.read', 'Read the budgets of the users of the organization'),
+    ('budget.allocate', 'Allocate budgets on datasets that one owns or co-curates'),
+    ('budget.history', 'Read the budgets spent on purged datasets'),
+    ('query.run', 'Evaluate queries on datasets that one has access to'),
+    ('audit.read', 'Read the audit log of the organization, of all organizations in the default organization');
+
+INSERT INTO RolePermissions VALUES
+    ('Admin', 'user.read'),
+    ('Admin', 'user.manage'),
+    ('Admin', 'role.manage'),
+    ('Admin', 'org.manage'),
+    ('Admin', 'dataset.read'),
+    ('Admin', 'dataset.upload'),
+    ('Admin', 'dataset.restore'),
+    ('Admin', 'budget.history'),
+    ('Admin', 'audit.read'),
+    ('Curator', 'user.read'),
+    ('Curator', 'dataset.read'),
+    ('Curator', 'dataset.create'),
+    ('Curator', 'dataset.update'),
+    ('Curator', 'dataset.upload'),
+    ('Curator', 'budget.read'),
+    ('Curator', 'budget.allocate'),
+    ('Curator', 'budget.history'),
+    ('Curator', 'query.run'),
+    ('Analyst', 'dataset.upload'),
+    ('Analyst', 'budget.allocate'),
+    ('Analyst', 'query.run');
+
+
+CREATE VIEW LatestDataUpload AS (
+    SELECT DISTINCT ON (dataset) dataset, version, loaded_time
+    FROM DataUpload
+    ORDER BY dataset, version DESC
+);
 
 CREATE VIEW LoadedDatasets AS (
     SELECT D.id, 
     D.name, 
     D.owner, 
+    D.organization,
     D.privacy_notion, 
     D.total_epsilon, 
     COALESCE(D.total_delta, 0) AS total_delta, 
+    D.description,
+    D.tags,
+    D.source,
+    D.contact,
     CASE WHEN L.loaded_time IS NULL THEN false ELSE true END AS loaded, 
     D.created_time,
     D.updated_time,
-    L.loaded_time
-    FROM Dataset AS D LEFT OUTER JOIN DataUpload AS L ON D.id = L.dataset
+    L.loaded_time,
+    L.version,
+    D.deleted_time
+    FROM Dataset AS D LEFT OUTER JOIN LatestDataUpload AS L ON D.id = L.dataset
 );
 
 CREATE VIEW DatasetAllocatedConsumed AS (
@@ -143,6 +442,7 @@ CREATE VIEW DatasetAllocatedConsumed AS (
     COALESCE(C.con_epsilon, 0) AS con_epsilon, 
     COALESCE(C.con_delta, 0) AS con_delta
     FROM Dataset as D LEFT OUTER JOIN Consumed as C ON D.id = C.dataset
+    WHERE D.deleted_time IS NULL
 );
 
 CREATE VIEW GetUsers AS (
@@ -150,7 +450,7 @@ CREATE VIEW GetUsers AS (
         SELECT username, ARRAY_AGG(role) AS roles FROM UserRoles
         GROUP BY username
     )
-    SELECT handle, name, roles, created_time, updated_time 
+    SELECT handle, name, organization, roles, created_time, updated_time 
     FROM Users LEFT JOIN Uroles ON handle = username
 );
 
@@ -384,7 +384,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role admin or curator, or needs granted access via budget allocation.",
+                "description": "Requester needs permission dataset.read, or needs granted access via budget allocation.",
                 "consumes": [
                     "application/json"
                 ],
@@ -431,7 +431,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs to be the owner of the dataset.",
+                "description": "Requester needs to be the owner of the dataset. The dataset can no longer be read or queried, but can be\nrestored until the retention period has passed. The dataset and its data are then purged, only a record\nof the budgets spent on it is kept.",
                 "consumes": [
                     "application/json"
                 ],
@@ -475,7 +475,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Update name, owner or total budget of a dataset.\nRequester needs to be curator and owner of the dataset.",
+                "description": "Update name, owner, total budget or metadata of a dataset. Metadata that is left out is kept.\nRequester needs permission dataset.update, and to be owner or co-curator of the dataset.\nA different owner is proposed as an ownership transfer, which only the owner can do and which the new owner has to accept.\nThe total budgets of the datasets of the organization cannot exceed its budget cap.",
                 "consumes": [
                     "application/json"
                 ],
@@ -500,7 +500,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.DatasetCreate"
+                            "$ref": "#/definitions/entity.DatasetPatch"
                         }
                     }
                 ],
@@ -514,6 +514,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -530,9 +536,11 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs to be the owner of the dataset.",
+                "description": "Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset.\nEvery cell is checked against the schema. Invalid uploads are rejected with the first invalid cells,\nunless invalid=clamp or invalid=drop is given, in which case a report of the upload is returned.\nThe data can be CSV with a header, JSON Lines or Parquet, chosen by the format parameter or the\ncontent type (CSV by default), and may be gzip compressed. Columns are matched to the schema by name.\nEvery upload creates a new version of the data which replaces the data or is appended to it.\nConsumed budgets are kept unless budget=reset is given with mode=replace, which drops the older versions.",
                 "consumes": [
-                    "application/json"
+                    "text/plain",
+                    "application/json",
+                    "application/octet-stream"
                 ],
                 "produces": [
                     "application/json"
@@ -543,8 +551,8 @@ const docTemplate = `{
                 "summary": "Upload a dataset.",
                 "parameters": [
                     {
-                        "description": "CSV Data",
-                        "name": "csvData",
+                        "description": "CSV, JSON Lines or Parquet data",
+                        "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
@@ -557,12 +565,48 @@ const docTemplate = `{
                         "name": "datasetId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv, jsonl or parquet, overrides the content type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "replace (default) or append to the latest version",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "keep (default) or reset the consumed budgets of the dataset, reset needs mode=replace",
+                        "name": "budget",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "what to do with cells that do not fit the schema: reject (default), clamp or drop",
+                        "name": "invalid",
+                        "in": "query"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadReport"
+                        }
+                    },
                     "204": {
                         "description": "No Content"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "403": {
                         "description": "Forbidden",
                         "schema": {
@@ -585,7 +629,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role admin or curator, or needs granted access via budget allocation.",
+                "description": "Only the datasets of the organization of the requester are listed.\nRequester needs permission dataset.read, or only gets the datasets with granted access via budget allocation.\nThe number of datasets that match the filters is returned in the X-Total-Count header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -595,7 +639,69 @@ const docTemplate = `{
                 "tags": [
                     "datasets"
                 ],
-                "summary": "Gets all datasets which requester has access to.",
+                "summary": "Gets a page of the datasets which requester has access to.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "only datasets of this owner",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only datasets with or without data",
+                        "name": "loaded",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this privacy notion",
+                        "name": "privacy_notion",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this in their name, regardless of case",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id (default), name, created_time, updated_time or loaded_time",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "max number of datasets, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of datasets to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only deleted datasets that can still be restored, which requesters without permission dataset.read only get for their own datasets",
+                        "name": "deleted",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -604,6 +710,18 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/entity.DatasetInfo"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "number of datasets that match the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
                         }
                     },
                     "403": {
@@ -626,7 +744,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role curator. New owner of dataset needs role curator.",
+                "description": "Requester and new owner of dataset need permission dataset.create, and the owner has to be in the organization of the requester.\nThe total budgets of the datasets of the organization cannot exceed its budget cap.",
                 "consumes": [
                     "application/json"
                 ],
@@ -661,6 +779,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -672,7 +796,7 @@ const docTemplate = `{
         },
         "/v1/login": {
             "post": {
-                "description": "Login user with user/password credentials.",
+                "description": "Login user with user/password credentials. Every login starts a new session, labelled with the optional device.\nThe access token is used until it expires at expires_at, the refresh token then gets a new pair of tokens.\nAfter too many failed logins the account, or the address the logins come from, is locked for a while.\nLogins are then refused with 429 and a Retry-After header, without checking the password.",
                 "consumes": [
                     "application/json"
                 ],
@@ -713,6 +837,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -729,7 +859,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Logout user from session.",
+                "description": "Logout user from the current session, other sessions of the user stay logged in.",
                 "consumes": [
                     "application/json"
                 ],
@@ -766,7 +896,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Request query accuracy on a specific dataset.\nRequester must be curator or analyst.",
+                "description": "Request query accuracy on a specific dataset.\nRequester needs permission query.run.",
                 "consumes": [
                     "application/json"
                 ],
@@ -871,7 +1001,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Request a query evaluation on a specific dataset.\nRequester must be curator or analyst.",
+                "description": "Request a query evaluation on a specific dataset.\nRequester needs permission query.run.\nThe query counts against the daily quota of queries of the allocation, see X-Query-Quota-* headers.",
                 "consumes": [
                     "application/json"
                 ],
@@ -918,6 +1048,64 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/refresh": {
+            "post": {
+                "description": "Gets a new access token and a new refresh token for the session of the refresh token. A refresh token can\nonly be used once, the session is revoked if it is used again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh Request",
+                        "name": "refreshRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -934,7 +1122,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin or curator roles.",
+                "description": "Requester needs permission user.read.",
                 "consumes": [
                     "application/json"
                 ],
@@ -981,7 +1169,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin role.",
+                "description": "Requester needs permission user.manage.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1031,7 +1219,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Update name, password and roles of a user.\nRequester needs admin role.",
+                "description": "Update name, password and roles of a user.\nRequester needs permission user.manage.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1083,7 +1271,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin or curator roles.",
+                "description": "Requester needs permission user.read. Only the users of the organization of the requester are listed,\nplatform admins list the users of all organizations.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1124,7 +1312,7 @@ const docTemplate = `{
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin role.",
+                "description": "Requester needs permission user.manage. The user is created in the organization of the requester\nunless another organization is given, which only platform admins can do.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1171,45 +1359,105 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/budgets/allocations/{userHandle}/{datasetId}": {
+        "/v2/.well-known/jwks.json": {
+            "get": {
+                "description": "The public keys that verify the access tokens, as a JSON Web Key Set. The kid in the header of a token\nnames its key. Keys that are rotated in are published before they sign, keys that are rotated out until\nthey are removed. Tokens signed with AUTH_SIGN_KEY are verified by Webdp only, its key is secret.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get the keys of the access tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/signing.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/audit": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Gets the specified users budget on a specific dataset",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs permission audit.read, and gets the entries of its organization. In the default organization\nthe entries of all organizations are read, or of the one organization given.\nThe entries record the administrative and privacy-relevant actions, with the values of the target before and after them.\nThe number of entries that match the filters is returned in the X-Total-Count header.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "budgets"
+                    "audit"
                 ],
-                "summary": "Gets user budget on a dataset",
+                "summary": "Gets a page of the audit log, newest entries first.",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User Handle",
-                        "name": "userHandle",
-                        "in": "path",
-                        "required": true
+                        "description": "only entries of this actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries of this action, or of this category of actions such as user",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries of this target or what is under it, such as dataset/3",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries at or after this time, in RFC 3339 format",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries before this time, in RFC 3339 format",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries of this organization, in the default organization",
+                        "name": "organization",
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Dataset Id",
-                        "name": "datasetId",
-                        "in": "path",
-                        "required": true
+                        "description": "max number of entries, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/entity.Budget"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.AuditEntry"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "number of entries that match the filters"
+                            }
                         }
                     },
                     "400": {
@@ -1231,52 +1479,67 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/v2/audit/export": {
+            "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Adds a user budget on a dataset",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs permission audit.read. Takes the filters of GET /v2/audit except limit and offset, all entries that\nmatch are exported. The hashes are exported too, the chain of an export of all entries can be checked without Webdp.",
                 "produces": [
-                    "application/json"
+                    "text/csv"
                 ],
                 "tags": [
-                    "budgets"
+                    "audit"
                 ],
-                "summary": "Adds a user budget on a dataset",
+                "summary": "Exports the audit log as CSV, oldest entries first.",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User Handle",
-                        "name": "userHandle",
-                        "in": "path",
-                        "required": true
+                        "description": "only entries of this actor",
+                        "name": "actor",
+                        "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Dataset Id",
-                        "name": "datasetId",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "description": "only entries of this action, or of this category of actions such as user",
+                        "name": "action",
+                        "in": "query"
                     },
                     {
-                        "description": "request body",
-                        "name": "requestBody",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entity.Budget"
-                        }
+                        "type": "string",
+                        "description": "only entries of this target or what is under it, such as dataset/3",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries at or after this time, in RFC 3339 format",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries before this time, in RFC 3339 format",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only entries of this organization, in the default organization",
+                        "name": "organization",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "200": {
+                        "description": "the entries, with a header row",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -1297,14 +1560,53 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "security": [
-                    {
+            }
+        },
+        "/v2/audit/verify": {
+            "get": {
+                "security": [
+                    {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Deletes budget for user and dataset.",
+                "description": "Requester needs permission audit.read in the default organization. The whole audit log is checked,\nbroken_at is the id of the first entry that was changed or follows an entry that was removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Checks the hash chain of the audit log.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.AuditVerification"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/budgets/allocations/{userHandle}/{datasetId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Gets the specified users budget on a specific dataset",
                 "consumes": [
                     "application/json"
                 ],
@@ -1314,7 +1616,7 @@ const docTemplate = `{
                 "tags": [
                     "budgets"
                 ],
-                "summary": "Deletes budget for user and dataset.",
+                "summary": "Gets user budget on a dataset",
                 "parameters": [
                     {
                         "type": "string",
@@ -1332,8 +1634,11 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Budget"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -1355,13 +1660,13 @@ const docTemplate = `{
                     }
                 }
             },
-            "patch": {
+            "post": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Update a user budget on a dataset",
+                "description": "Adds a user budget on a dataset",
                 "consumes": [
                     "application/json"
                 ],
@@ -1371,7 +1676,7 @@ const docTemplate = `{
                 "tags": [
                     "budgets"
                 ],
-                "summary": "Update a user budget on a dataset",
+                "summary": "Adds a user budget on a dataset",
                 "parameters": [
                     {
                         "type": "string",
@@ -1398,8 +1703,8 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "201": {
+                        "description": "Created"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -1420,16 +1725,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/budgets/datasets/{datasetId}": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Gets the dataset budget allocation",
+                "description": "Deletes budget for user and dataset.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1439,8 +1742,15 @@ const docTemplate = `{
                 "tags": [
                     "budgets"
                 ],
-                "summary": "Gets dataset budget for a dataset",
+                "summary": "Deletes budget for user and dataset.",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "type": "integer",
                         "description": "Dataset Id",
@@ -1450,11 +1760,8 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/entity.DatasetBudgetAllocationResponse"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -1475,16 +1782,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/budgets/users/{userHandle}": {
-            "get": {
+            },
+            "patch": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Gets all budgets that are allocated to the user",
+                "description": "Update a user budget on a dataset",
                 "consumes": [
                     "application/json"
                 ],
@@ -1494,7 +1799,7 @@ const docTemplate = `{
                 "tags": [
                     "budgets"
                 ],
-                "summary": "Get budgets for user",
+                "summary": "Update a user budget on a dataset",
                 "parameters": [
                     {
                         "type": "string",
@@ -1502,16 +1807,32 @@ const docTemplate = `{
                         "name": "userHandle",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.Budget"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.UserBudgetsResponse"
-                            }
+                            "$ref": "#/definitions/response.Error"
                         }
                     },
                     "403": {
@@ -1529,32 +1850,48 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/datasets": {
+        "/v2/budgets/allocations/{userHandle}/{datasetId}/quota": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role admin or curator, or needs granted access via budget allocation.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Gets the queries per day the user can make on the dataset, and the queries made today.\ndaily_queries is the quota set on the allocation, null if it has the default quota; limit is the quota that applies, 0 is no quota.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "datasets"
+                    "budgets"
+                ],
+                "summary": "Gets the query quota of a user on a dataset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Gets all datasets which requester has access to.",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.DatasetInfo"
-                            }
+                            "$ref": "#/definitions/entity.QueryQuota"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
                         }
                     },
                     "403": {
@@ -1563,6 +1900,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1571,39 +1914,53 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
+            "put": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role curator. New owner of dataset needs role curator.",
+                "description": "Sets the queries per day the user can make on the dataset it has a budget on. Evaluated and validated queries count.\nA daily_queries of 0 is no quota, null gives the allocation the default quota of the configuration.",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "datasets"
+                    "budgets"
                 ],
-                "summary": "Creates a dataset.",
+                "summary": "Sets the query quota of a user on a dataset",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "description": "request body",
                         "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.DatasetCreate"
+                            "$ref": "#/definitions/entity.QueryQuotaPut"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/response.Id"
+                            "$ref": "#/definitions/response.Error"
                         }
                     },
                     "403": {
@@ -1612,6 +1969,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1621,14 +1984,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/datasets/{datasetId}": {
+        "/v2/budgets/datasets/{datasetId}": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs role admin or curator, or needs granted access via budget allocation.",
+                "description": "Gets the dataset budget allocation",
                 "consumes": [
                     "application/json"
                 ],
@@ -1636,9 +1999,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "datasets"
+                    "budgets"
                 ],
-                "summary": "Gets all datasets which requester has access to.",
+                "summary": "Gets dataset budget for a dataset",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1652,7 +2015,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/entity.DatasetInfo"
+                            "$ref": "#/definitions/entity.DatasetBudgetAllocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
                         }
                     },
                     "403": {
@@ -1668,36 +2037,32 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/v2/budgets/purged": {
+            "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs to be the owner of the dataset.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Gets the budgets that were allocated and consumed on the datasets of the organization of the requester\nthat were deleted and purged after the retention period",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "datasets"
-                ],
-                "summary": "Delete a dataset.",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Dataset Id",
-                        "name": "datasetId",
-                        "in": "path",
-                        "required": true
-                    }
+                    "budgets"
                 ],
+                "summary": "Gets the budgets of purged datasets",
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.PurgedDatasetBudget"
+                            }
+                        }
                     },
                     "403": {
                         "description": "Forbidden",
@@ -1712,14 +2077,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/v2/budgets/users/{userHandle}": {
+            "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Update name, owner or total budget of a dataset.\nRequester needs to be curator and owner of the dataset.",
+                "description": "Gets all budgets that are allocated to the user",
                 "consumes": [
                     "application/json"
                 ],
@@ -1727,30 +2094,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "datasets"
+                    "budgets"
                 ],
-                "summary": "Update a dataset.",
+                "summary": "Get budgets for user",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Dataset Id",
-                        "name": "datasetId",
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "request body",
-                        "name": "requestBody",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entity.DatasetCreate"
-                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.UserBudgetsResponse"
+                            }
+                        }
                     },
                     "403": {
                         "description": "Forbidden",
@@ -1767,14 +2131,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/datasets/{datasetId}/upload": {
-            "post": {
+        "/v2/datasets": {
+            "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs to be the owner of the dataset.",
+                "description": "Only the datasets of the organization of the requester are listed.\nRequester needs permission dataset.read, or only gets the datasets with granted access via budget allocation.\nThe number of datasets that match the filters is returned in the X-Total-Count header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1784,73 +2148,83 @@ const docTemplate = `{
                 "tags": [
                     "datasets"
                 ],
-                "summary": "Upload a dataset.",
+                "summary": "Gets a page of the datasets which requester has access to.",
                 "parameters": [
                     {
-                        "description": "CSV Data",
-                        "name": "csvData",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "string"
-                        }
+                        "type": "string",
+                        "description": "only datasets of this owner",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only datasets with or without data",
+                        "name": "loaded",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this privacy notion",
+                        "name": "privacy_notion",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only datasets with this in their name, regardless of case",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id (default), name, created_time, updated_time or loaded_time",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Dataset Id",
-                        "name": "datasetId",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "204": {
-                        "description": "No Content"
+                        "description": "max number of datasets, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
                     },
-                    "403": {
-                        "description": "Forbidden",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
-                        }
+                    {
+                        "type": "integer",
+                        "description": "number of datasets to skip",
+                        "name": "offset",
+                        "in": "query"
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
-                        }
-                    }
-                }
-            }
-        },
-        "/v2/login": {
-            "post": {
-                "description": "Login user with user/password credentials.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "auth"
-                ],
-                "summary": "Login User",
-                "parameters": [
                     {
-                        "description": "Login Request",
-                        "name": "loginRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entity.LoginRequest"
-                        }
+                        "type": "boolean",
+                        "description": "only deleted datasets that can still be restored, which requesters without permission dataset.read only get for their own datasets",
+                        "name": "deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/response.Token"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DatasetInfo"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "number of datasets that match the filters"
+                            }
                         }
                     },
                     "400": {
@@ -1859,8 +2233,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
@@ -1872,16 +2246,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/logout": {
+            },
             "post": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Logout user from session.",
+                "description": "Requester and new owner of dataset need permission dataset.create, and the owner has to be in the organization of the requester.\nThe total budgets of the datasets of the organization cannot exceed its budget cap.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1889,15 +2261,35 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "datasets"
+                ],
+                "summary": "Creates a dataset.",
+                "parameters": [
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.DatasetCreate"
+                        }
+                    }
                 ],
-                "summary": "Logout User",
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/response.Id"
+                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
@@ -1911,38 +2303,69 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/queries/accuracy": {
+        "/v2/datasets/infer-schema": {
             "post": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Request query accuracy on a specific dataset.\nRequester must be curator or analyst.",
+                "description": "Requester needs permission dataset.create. Reads a CSV (with a header) or Parquet sample, which may be gzip compressed,\nand proposes Int, Double, Bool, Enum or Text for every column. The response has the fields of the body of\nPOST /v2/datasets. Bounds taken from the sample are widened to round numbers and marked with confirm_bounds,\nsince they tell something about the rows of the sample; the curator should confirm or replace them.\nEnum labels are marked the same way, labels that occur fewer than 5 times are left out and counted in rare_labels.",
                 "consumes": [
-                    "application/json"
+                    "text/plain",
+                    "application/octet-stream"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
                 ],
-                "summary": "Check a query's accuracy",
+                "summary": "Proposes a schema from a sample of the data.",
                 "parameters": [
                     {
-                        "description": "Query Accuracy Request",
-                        "name": "queryAccuracy",
+                        "description": "CSV or Parquet sample",
+                        "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.QueryAccuracy"
+                            "type": "string"
                         }
                     },
                     {
                         "type": "string",
-                        "description": "engine name",
-                        "name": "engine",
+                        "description": "csv or parquet, overrides the content type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "max number of rows read from the sample, 10000 by default",
+                        "name": "rows",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "name of the dataset",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PureDP (default) or ApproxDP",
+                        "name": "privacy_notion",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "epsilon of the total budget",
+                        "name": "epsilon",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "delta of the total budget",
+                        "name": "delta",
                         "in": "query"
                     }
                 ],
@@ -1950,10 +2373,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "number"
-                            }
+                            "$ref": "#/definitions/entity.SchemaProposal"
                         }
                     },
                     "400": {
@@ -1967,56 +2387,42 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
-                        }
                     }
                 }
             }
         },
-        "/v2/queries/docs": {
+        "/v2/datasets/{datasetId}": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Returns a markdown file with features for each engine\nor single engine if specified in query param",
+                "description": "Requester needs permission dataset.read, or needs granted access via budget allocation.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/markdown"
+                    "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
                 ],
-                "summary": "Get engine query documentation",
+                "summary": "Gets all datasets which requester has access to.",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "engine name",
-                        "name": "engine",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "integer"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
+                            "$ref": "#/definitions/entity.DatasetInfo"
                         }
                     },
                     "403": {
@@ -2032,16 +2438,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/queries/engines": {
-            "get": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Returns a list of available engines.",
+                "description": "Requester needs to be the owner of the dataset. The dataset can no longer be read or queried, but can be\nrestored until the retention period has passed. The dataset and its data are then purged, only a record\nof the budgets spent on it is kept.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2049,24 +2453,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
+                ],
+                "summary": "Delete a dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List available engines",
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "403": {
                         "description": "Forbidden",
@@ -2081,16 +2482,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/queries/evaluate": {
-            "post": {
+            },
+            "patch": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Request a query evaluation on a specific dataset.\nRequester must be curator or analyst.",
+                "description": "Update name, owner, total budget or metadata of a dataset. Metadata that is left out is kept.\nRequester needs permission dataset.update, and to be owner or co-curator of the dataset.\nA different owner is proposed as an ownership transfer, which only the owner can do and which the new owner has to accept.\nThe total budgets of the datasets of the organization cannot exceed its budget cap.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2098,41 +2497,39 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
                 ],
-                "summary": "Do a query evaluation",
+                "summary": "Update a dataset.",
                 "parameters": [
                     {
-                        "description": "Query Evaluation Request",
-                        "name": "queryEvaluate",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.QueryEvaluate"
+                            "$ref": "#/definitions/entity.DatasetPatch"
                         }
-                    },
-                    {
-                        "type": "string",
-                        "description": "engine name",
-                        "name": "engine",
-                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/entity.QueryResult"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
@@ -2146,43 +2543,38 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/queries/functions": {
+        "/v2/datasets/{datasetId}/members": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Returns a json with supported functions for each engine\nor single engine if specified in query param",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs permission dataset.read, or needs granted access via budget allocation or membership.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
                 ],
-                "summary": "List engine functions",
+                "summary": "Gets the members of a dataset.",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "engine name",
-                        "name": "engine",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/response.EngineFunctions"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/response.Error"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DatasetMember"
+                            }
                         }
                     },
                     "403": {
@@ -2191,8 +2583,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
@@ -2200,14 +2592,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/queries/validate": {
-            "post": {
+        "/v2/datasets/{datasetId}/members/{userHandle}": {
+            "put": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Validate a query's syntax.\nRequester must be curator or analyst.",
+                "description": "Requester needs to be the owner of the dataset. The role is co-curator or viewer, the member can have any role.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2215,35 +2607,37 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "queries"
+                    "datasets"
                 ],
-                "summary": "Validate a query",
+                "summary": "Adds a member to a dataset or changes its role.",
                 "parameters": [
                     {
-                        "description": "Query Evaluation Request",
-                        "name": "queryEvaluate",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.QueryEvaluate"
+                            "$ref": "#/definitions/entity.MemberPut"
                         }
-                    },
-                    {
-                        "type": "string",
-                        "description": "engine name",
-                        "name": "engine",
-                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/client.ValidateResponse"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -2257,59 +2651,104 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
                     }
                 }
-            }
-        },
-        "/v2/spec": {
-            "get": {
-                "description": "Returns a html of the API specification, using a Swagger UI.\nSee http://localhost:8080/v2/spec/index.html",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the owner of the dataset, or the member itself. The owner cannot be removed.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "spec"
+                    "datasets"
+                ],
+                "summary": "Removes a member from a dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get OpenAPI Specification",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
                         }
                     }
                 }
             }
         },
-        "/v2/users": {
-            "get": {
+        "/v2/datasets/{datasetId}/restore": {
+            "post": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin or curator roles.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs to be the owner of the dataset or have permission dataset.restore. The dataset, its data, members and\nbudgets are as they were before it was deleted.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "datasets"
+                ],
+                "summary": "Restores a deleted dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all users.",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/entity.UserResponse"
-                            }
+                            "$ref": "#/definitions/entity.DatasetInfo"
                         }
                     },
                     "403": {
@@ -2318,6 +2757,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2325,14 +2770,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/v2/datasets/{datasetId}/schema": {
+            "patch": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin role.",
+                "description": "Requester needs permission dataset.update, and to be owner or co-curator of the dataset. Columns are dropped, then updated and then added\nafter the remaining columns. An update changes bounds or labels but not the type of a column.\nIf data is loaded, its latest version is rewritten to the new schema as a new version with mode schema;\nadded columns need a default value and every value is checked against the new schema, invalid values are\nhandled like in uploads. Queries cannot pin versions from before the change. Consumed budgets are kept.\nThe change is recorded in the audit trail of the schema.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2340,23 +2787,39 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "datasets"
                 ],
-                "summary": "Create new user.",
+                "summary": "Changes the schema of a dataset.",
                 "parameters": [
                     {
-                        "description": "User Request",
-                        "name": "userRequest",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entity.UserPost"
+                            "$ref": "#/definitions/entity.SchemaPatch"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "what to do with loaded values that do not fit the new schema: reject (default), clamp or drop",
+                        "name": "invalid",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.SchemaChange"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -2370,8 +2833,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
@@ -2379,29 +2842,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/users/{userHandle}": {
+        "/v2/datasets/{datasetId}/schema/changes": {
             "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin or curator roles.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs permission dataset.read, or needs granted access via budget allocation.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "datasets"
                 ],
-                "summary": "Get a user.",
+                "summary": "Gets the audit trail of the schema of a dataset.",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User Handle",
-                        "name": "userHandle",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
                         "in": "path",
                         "required": true
                     }
@@ -2410,7 +2870,10 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/entity.UserResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.SchemaChange"
+                            }
                         }
                     },
                     "403": {
@@ -2419,43 +2882,101 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/v2/datasets/{datasetId}/transfer": {
+            "get": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Requester needs admin role.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Requester needs to be the owner of the dataset or the proposed owner.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "datasets"
                 ],
-                "summary": "Delete a user.",
+                "summary": "Gets the proposed ownership transfer of a dataset.",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User Handle",
-                        "name": "userHandle",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.OwnershipTransfer"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the owner of the dataset. The new owner can have any role and becomes the owner\nwhen it accepts the transfer, the previous owner is then no longer a member. A new proposal replaces an earlier one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Proposes a new owner of a dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.TransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/entity.OwnershipTransfer"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -2469,41 +2990,168 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Error"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/response.Error"
                         }
                     }
                 }
             },
-            "patch": {
+            "delete": {
                 "security": [
                     {
                         "BearerTokenAuth": []
                     }
                 ],
-                "description": "Update name, password and roles of a user.\nRequester needs admin role.",
-                "consumes": [
+                "description": "Requester needs to be the owner of the dataset or the proposed owner.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Cancels or declines the proposed ownership transfer of a dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/datasets/{datasetId}/transfer/accept": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the proposed owner of the dataset.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "datasets"
                 ],
-                "summary": "Update a user.",
+                "summary": "Accepts the ownership of a dataset.",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User Handle",
-                        "name": "userHandle",
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/datasets/{datasetId}/upload": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset.\nEvery cell is checked against the schema. Invalid uploads are rejected with the first invalid cells,\nunless invalid=clamp or invalid=drop is given, in which case a report of the upload is returned.\nThe data can be CSV with a header, JSON Lines or Parquet, chosen by the format parameter or the\ncontent type (CSV by default), and may be gzip compressed. Columns are matched to the schema by name.\nEvery upload creates a new version of the data which replaces the data or is appended to it.\nConsumed budgets are kept unless budget=reset is given with mode=replace, which drops the older versions.",
+                "consumes": [
+                    "text/plain",
+                    "application/json",
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Upload a dataset.",
+                "parameters": [
+                    {
+                        "description": "CSV, JSON Lines or Parquet data",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv, jsonl or parquet, overrides the content type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "replace (default) or append to the latest version",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "keep (default) or reset the consumed budgets of the dataset, reset needs mode=replace",
+                        "name": "budget",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "what to do with cells that do not fit the schema: reject (default), clamp or drop",
+                        "name": "invalid",
+                        "in": "query"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadReport"
+                        }
+                    },
                     "204": {
                         "description": "No Content"
                     },
@@ -2527,106 +3175,2942 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "client.ValidateResponse": {
+        },
+        "/v2/datasets/{datasetId}/uploads": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Start a resumable upload.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadSession"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/datasets/{datasetId}/uploads/{uploadId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the owner or a co-curator of the dataset. The size is the offset of the next chunk.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Get a resumable upload.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Upload Id",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadSession"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the owner or a co-curator of the dataset. The offset must be the current size of the upload,\notherwise 409 is returned and the upload can be resumed from its size.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Append a chunk to a resumable upload.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Upload Id",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset of the chunk",
+                        "name": "offset",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Data",
+                        "name": "chunk",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs to be the owner or a co-curator of the dataset.",
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Abort a resumable upload.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Upload Id",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/datasets/{datasetId}/uploads/{uploadId}/complete": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset. The uploaded chunks are validated and stored\nlike a single upload, and the upload session is removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Complete a resumable upload.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Upload Id",
+                        "name": "uploadId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "what to do with cells that do not fit the schema: reject (default), clamp or drop",
+                        "name": "invalid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv (default), jsonl or parquet",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "replace (default) or append to the latest version",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "keep (default) or reset the consumed budgets of the dataset, reset needs mode=replace",
+                        "name": "budget",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UploadReport"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/datasets/{datasetId}/versions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Every upload creates a version. Queries use the latest version unless they pin one.\nRequester needs permission dataset.read, or needs granted access via budget allocation.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasets"
+                ],
+                "summary": "Gets the versions of the data of a dataset.",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Dataset Id",
+                        "name": "datasetId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.DataVersion"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/login": {
+            "post": {
+                "description": "Login user with user/password credentials. Every login starts a new session, labelled with the optional device.\nThe access token is used until it expires at expires_at, the refresh token then gets a new pair of tokens.\nAfter too many failed logins the account, or the address the logins come from, is locked for a while.\nLogins are then refused with 429 and a Retry-After header, without checking the password.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login User",
+                "parameters": [
+                    {
+                        "description": "Login Request",
+                        "name": "loginRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/logout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Logout user from the current session, other sessions of the user stay logged in.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout User",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/oidc/callback": {
+            "get": {
+                "description": "Starts a session for the user that logged in at the identity provider. The user is created on its first login,\nits roles follow its groups at the identity provider.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete a login with the identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "code from the identity provider",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "state of the login",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.Token"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/oidc/login": {
+            "get": {
+                "description": "Redirects to the identity provider, which redirects back to the callback when the user has logged in.",
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login with the identity provider",
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/organizations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Platform admins get all organizations, other users their own organization.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Get the organizations.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Organization"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission org.manage in the default organization.\nWithout a budget cap the datasets of the organization can have any total budget.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Create an organization.",
+                "parameters": [
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.OrganizationCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/organizations/{orgHandle}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Users get their own organization, platform admins any organization.\nUsed is the total budget of the datasets of the organization, deleted datasets that can still be restored included.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Get an organization.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization Handle",
+                        "name": "orgHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Organization"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission org.manage in the default organization.\nThe organization cannot have users or datasets. The default organization cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Delete an organization.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization Handle",
+                        "name": "orgHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission org.manage in the default organization. Replaces the name and the budget cap,\nwhich cannot be lower than the total budget of the datasets of the organization.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "organizations"
+                ],
+                "summary": "Update an organization.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organization Handle",
+                        "name": "orgHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.OrganizationPatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "The permissions that roles can give.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get all permissions.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Permission"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/accuracy": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Request query accuracy on a specific dataset.\nRequester needs permission query.run.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "Check a query's accuracy",
+                "parameters": [
+                    {
+                        "description": "Query Accuracy Request",
+                        "name": "queryAccuracy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.QueryAccuracy"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "engine name",
+                        "name": "engine",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "number"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/docs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Returns a markdown file with features for each engine\nor single engine if specified in query param",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/markdown"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "Get engine query documentation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "engine name",
+                        "name": "engine",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/engines": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Returns a list of available engines.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "List available engines",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/evaluate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Request a query evaluation on a specific dataset.\nRequester needs permission query.run.\nThe query counts against the daily quota of queries of the allocation, see X-Query-Quota-* headers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "Do a query evaluation",
+                "parameters": [
+                    {
+                        "description": "Query Evaluation Request",
+                        "name": "queryEvaluate",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.QueryEvaluate"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "engine name",
+                        "name": "engine",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.QueryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/functions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Returns a json with supported functions for each engine\nor single engine if specified in query param",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "List engine functions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "engine name",
+                        "name": "engine",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.EngineFunctions"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/queries/validate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Validate a query's syntax.\nRequester needs permission query.run.\nThe query counts against the daily quota of queries of the allocation, see X-Query-Quota-* headers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queries"
+                ],
+                "summary": "Validate a query",
+                "parameters": [
+                    {
+                        "description": "Query Evaluation Request",
+                        "name": "queryEvaluate",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.QueryEvaluate"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "engine name",
+                        "name": "engine",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/client.ValidateResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/refresh": {
+            "post": {
+                "description": "Gets a new access token and a new refresh token for the session of the refresh token. A refresh token can\nonly be used once, the session is revoked if it is used again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh Request",
+                        "name": "refreshRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/roles": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "The roles and their permissions, the built-in roles first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get all roles.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Role"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission role.manage in the default organization. The role can be given to users like the built-in roles.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Create a custom role.",
+                "parameters": [
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.RoleCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/roles/{role}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Get a role.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Role"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission role.manage in the default organization. Replaces the description and the permissions of the role,\nwhich apply to the users with the role at once. The built-in roles cannot be changed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Update a custom role.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.RolePut"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission role.manage in the default organization. The role cannot be given to any user. The built-in roles cannot be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Delete a custom role.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Gets the sessions the requester is logged in with, the session of the request is marked as current.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get own sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.Session"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/sessions/{sessionId}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Revokes a session of the requester, its access and refresh tokens can no longer be used.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke own session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session Id",
+                        "name": "sessionId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/spec": {
+            "get": {
+                "description": "Returns a html of the API specification, using a Swagger UI.\nSee http://localhost:8080/v2/spec/index.html",
+                "tags": [
+                    "spec"
+                ],
+                "summary": "Get OpenAPI Specification",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.read. Only the users of the organization of the requester are listed,\nplatform admins list the users of all organizations.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get all users.",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.UserResponse"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage. The user is created in the organization of the requester\nunless another organization is given, which only platform admins can do.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create new user.",
+                "parameters": [
+                    {
+                        "description": "User Request",
+                        "name": "userRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.UserPost"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users/{userHandle}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.read.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/entity.UserResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Update name, password and roles of a user.\nRequester needs permission user.manage.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users/{userHandle}/keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage or be the user, and must be logged in rather than use a key. The keys themselves are not returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Gets the API keys of a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entity.ApiKey"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage or be the user, and must be logged in rather than use a key. The roles of the key\nare some of the roles of the user, an empty list of datasets gives access to all datasets the user has access to.\nThe key is only returned in this response, it is sent as a bearer token like an access token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Creates an API key for a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "request body",
+                        "name": "requestBody",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.ApiKeyCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/entity.ApiKeyCreated"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users/{userHandle}/keys/{keyId}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage or be the user, and must be logged in rather than use a key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Revokes an API key of a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key Id",
+                        "name": "keyId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users/{userHandle}/lockout": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Requester needs permission user.manage. Forgets the failed logins of a user in the organization of the\nrequester, which unlocks its account. Platform admins unlock the users of all organizations.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Unlock a user.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/users/{userHandle}/password": {
+            "put": {
+                "security": [
+                    {
+                        "BearerTokenAuth": []
+                    }
+                ],
+                "description": "Users change their own password with the password they have now, admins set the passwords of others\nwith PATCH. The new password follows the password policy. The other sessions of the user are logged out.\nA wrong old password counts as a failed login. API keys and tokens of the identity provider cannot change passwords.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Change own password.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User Handle",
+                        "name": "userHandle",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Old and new password",
+                        "name": "passwordChange",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entity.PasswordChange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Error"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "client.ValidateResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "entity.AddedColumn": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/entity.DataType"
+                }
+            }
+        },
+        "entity.ApiKey": {
+            "type": "object",
+            "properties": {
+                "created_by": {
+                    "type": "string"
+                },
+                "created_time": {
+                    "type": "string"
+                },
+                "datasets": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "expires_time": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "entity.ApiKeyCreate": {
+            "type": "object",
+            "properties": {
+                "datasets": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "expires_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "entity.ApiKeyCreated": {
+            "type": "object",
+            "properties": {
+                "created_by": {
+                    "type": "string"
+                },
+                "created_time": {
+                    "type": "string"
+                },
+                "datasets": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "expires_time": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "last_used_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "entity.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "organization": {
+                    "type": "string"
+                },
+                "prev_hash": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.AuditVerification": {
+            "type": "object",
+            "properties": {
+                "broken_at": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "entity.Budget": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "number"
+                },
+                "epsilon": {
+                    "type": "number"
+                }
+            }
+        },
+        "entity.ColumnSchema": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/entity.DataType"
+                }
+            }
+        },
+        "entity.DataType": {
+            "type": "object",
+            "properties": {
+                "type": {}
+            }
+        },
+        "entity.DataVersion": {
+            "type": "object",
+            "properties": {
+                "budget_reset": {
+                    "type": "boolean"
+                },
+                "loaded_time": {
+                    "type": "string"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "total_rows": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entity.DatasetBudgetAllocationResponse": {
+            "type": "object",
+            "properties": {
+                "allocated": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "allocation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.UserBudgetModel"
+                    }
+                },
+                "consumed": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "total": {
+                    "$ref": "#/definitions/entity.Budget"
+                }
+            }
+        },
+        "entity.DatasetCreate": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "privacy_notion": {
+                    "type": "string"
+                },
+                "schema": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.ColumnSchema"
+                    }
+                },
+                "source": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "total_budget": {
+                    "$ref": "#/definitions/entity.Budget"
+                }
+            }
+        },
+        "entity.DatasetInfo": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "type": "string"
+                },
+                "created_time": {
+                    "type": "string"
+                },
+                "deleted_time": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "loaded": {
+                    "type": "boolean"
+                },
+                "loaded_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "organization": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "privacy_notion": {
+                    "type": "string"
+                },
+                "schema": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.ColumnSchema"
+                    }
+                },
+                "source": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "total_budget": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "updated_time": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entity.DatasetMember": {
+            "type": "object",
+            "properties": {
+                "added_by": {
+                    "type": "string"
+                },
+                "added_time": {
+                    "type": "string"
+                },
+                "handle": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.DatasetPatch": {
+            "type": "object",
+            "properties": {
+                "contact": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "total_budget": {
+                    "$ref": "#/definitions/entity.Budget"
+                }
+            }
+        },
+        "entity.InferredColumn": {
+            "type": "object",
+            "properties": {
+                "confirm_bounds": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rare_labels": {
+                    "type": "integer"
+                },
+                "type": {
+                    "$ref": "#/definitions/entity.DataType"
+                }
+            }
+        },
+        "entity.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "device": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.MemberPut": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.Organization": {
+            "type": "object",
+            "properties": {
+                "budget_cap": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "created_time": {
+                    "type": "string"
+                },
+                "handle": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "used": {
+                    "$ref": "#/definitions/entity.Budget"
+                }
+            }
+        },
+        "entity.OrganizationCreate": {
+            "type": "object",
+            "properties": {
+                "budget_cap": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "handle": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.OrganizationPatch": {
+            "type": "object",
+            "properties": {
+                "budget_cap": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.OwnershipTransfer": {
+            "type": "object",
+            "properties": {
+                "created_time": {
+                    "type": "string"
+                },
+                "dataset": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.PasswordChange": {
+            "type": "object",
+            "properties": {
+                "new_password": {
+                    "type": "string"
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.Permission": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.PurgedDatasetBudget": {
+            "type": "object",
+            "properties": {
+                "allocation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.UserBudgetModel"
+                    }
+                },
+                "consumed": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "dataset": {
+                    "type": "integer"
+                },
+                "deleted_by": {
+                    "type": "string"
+                },
+                "deleted_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "organization": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "privacy_notion": {
+                    "type": "string"
+                },
+                "purged_time": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/entity.Budget"
+                }
+            }
+        },
+        "entity.Query": {
+            "type": "object",
+            "properties": {
+                "querySteps": {
+                    "type": "array",
+                    "items": {}
+                }
+            }
+        },
+        "entity.QueryAccuracy": {
+            "type": "object",
+            "properties": {
+                "budget": {
+                    "$ref": "#/definitions/entity.Budget"
+                },
+                "confidence": {
+                    "type": "number"
+                },
+                "dataset": {
+                    "type": "integer"
+                },
+                "query": {
+                    "$ref": "#/definitions/entity.Query"
+                },
+                "version": {
+                    "description": "version of the data, the latest if left out",
+                    "type": "integer"
+                }
+            }
+        },
+        "entity.QueryCustom": {
             "type": "object",
             "properties": {
-                "status": {
-                    "type": "string"
+                "budget": {
+                    "$ref": "#/definitions/entity.Budget"
                 },
-                "valid": {
-                    "type": "boolean"
+                "dataset": {
+                    "type": "integer"
+                },
+                "query": {
+                    "type": "string"
                 }
             }
         },
-        "entity.Budget": {
+        "entity.QueryEvaluate": {
             "type": "object",
             "properties": {
-                "delta": {
-                    "type": "number"
+                "budget": {
+                    "$ref": "#/definitions/entity.Budget"
                 },
-                "epsilon": {
-                    "type": "number"
+                "dataset": {
+                    "type": "integer"
+                },
+                "query": {
+                    "$ref": "#/definitions/entity.Query"
+                },
+                "value_policies": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/entity.ValuePolicy"
+                    }
+                },
+                "version": {
+                    "description": "version of the data, the latest if left out",
+                    "type": "integer"
                 }
             }
         },
-        "entity.ColumnSchema": {
+        "entity.QueryQuota": {
             "type": "object",
             "properties": {
-                "name": {
+                "daily_queries": {
+                    "type": "integer"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "resets": {
                     "type": "string"
                 },
-                "type": {
-                    "$ref": "#/definitions/entity.DataType"
+                "used": {
+                    "type": "integer"
                 }
             }
         },
-        "entity.DataType": {
+        "entity.QueryQuotaPut": {
             "type": "object",
             "properties": {
-                "type": {}
+                "daily_queries": {
+                    "type": "integer"
+                }
             }
         },
-        "entity.DatasetBudgetAllocationResponse": {
+        "entity.QueryResult": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "entity.RefreshRequest": {
             "type": "object",
             "properties": {
-                "allocated": {
-                    "$ref": "#/definitions/entity.Budget"
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "entity.Role": {
+            "type": "object",
+            "properties": {
+                "builtin": {
+                    "type": "boolean"
                 },
-                "allocation": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/entity.UserBudgetModel"
+                        "type": "string"
                     }
-                },
-                "consumed": {
-                    "$ref": "#/definitions/entity.Budget"
-                },
-                "total": {
-                    "$ref": "#/definitions/entity.Budget"
                 }
             }
         },
-        "entity.DatasetCreate": {
+        "entity.RoleCreate": {
             "type": "object",
             "properties": {
-                "name": {
+                "description": {
                     "type": "string"
                 },
-                "owner": {
+                "name": {
                     "type": "string"
                 },
-                "privacy_notion": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "entity.RolePut": {
+            "type": "object",
+            "properties": {
+                "description": {
                     "type": "string"
                 },
-                "schema": {
+                "permissions": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/entity.ColumnSchema"
+                        "type": "string"
                     }
-                },
-                "total_budget": {
-                    "$ref": "#/definitions/entity.Budget"
                 }
             }
         },
-        "entity.DatasetInfo": {
+        "entity.SchemaChange": {
             "type": "object",
             "properties": {
-                "created_time": {
+                "changed_by": {
+                    "type": "string"
+                },
+                "changed_time": {
                     "type": "string"
                 },
+                "dataset": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "loaded": {
-                    "type": "boolean"
+                "new_schema": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.ColumnSchema"
+                    }
                 },
-                "loaded_time": {
-                    "type": "string"
+                "old_schema": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.ColumnSchema"
+                    }
+                },
+                "patch": {
+                    "$ref": "#/definitions/entity.SchemaPatch"
+                },
+                "report": {
+                    "$ref": "#/definitions/entity.UploadReport"
+                },
+                "version": {
+                    "description": "the version of the data rewritten to the new schema, if data was loaded",
+                    "type": "integer"
+                }
+            }
+        },
+        "entity.SchemaPatch": {
+            "type": "object",
+            "properties": {
+                "add": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.AddedColumn"
+                    }
+                },
+                "drop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
+                "update": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entity.ColumnSchema"
+                    }
+                }
+            }
+        },
+        "entity.SchemaProposal": {
+            "type": "object",
+            "properties": {
                 "name": {
                     "type": "string"
                 },
@@ -2636,89 +6120,97 @@ const docTemplate = `{
                 "privacy_notion": {
                     "type": "string"
                 },
+                "sample_rows": {
+                    "type": "integer"
+                },
                 "schema": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/entity.ColumnSchema"
+                        "$ref": "#/definitions/entity.InferredColumn"
                     }
                 },
                 "total_budget": {
                     "$ref": "#/definitions/entity.Budget"
-                },
-                "updated_time": {
-                    "type": "string"
                 }
             }
         },
-        "entity.LoginRequest": {
+        "entity.Session": {
             "type": "object",
             "properties": {
-                "password": {
+                "created_time": {
                     "type": "string"
                 },
-                "username": {
+                "current": {
+                    "type": "boolean"
+                },
+                "device": {
+                    "type": "string"
+                },
+                "expires_time": {
+                    "type": "string"
+                },
+                "handle": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "refreshed_time": {
                     "type": "string"
                 }
             }
         },
-        "entity.Query": {
+        "entity.TransferRequest": {
             "type": "object",
             "properties": {
-                "querySteps": {
-                    "type": "array",
-                    "items": {}
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "entity.QueryAccuracy": {
+        "entity.UploadReport": {
             "type": "object",
             "properties": {
-                "budget": {
-                    "$ref": "#/definitions/entity.Budget"
+                "clamped_cells": {
+                    "type": "integer"
                 },
-                "confidence": {
-                    "type": "number"
+                "dropped_rows": {
+                    "type": "integer"
                 },
-                "dataset": {
+                "rows": {
                     "type": "integer"
                 },
-                "query": {
-                    "$ref": "#/definitions/entity.Query"
+                "version": {
+                    "type": "integer"
                 }
             }
         },
-        "entity.QueryCustom": {
+        "entity.UploadSession": {
             "type": "object",
             "properties": {
-                "budget": {
-                    "$ref": "#/definitions/entity.Budget"
+                "created_time": {
+                    "type": "string"
                 },
                 "dataset": {
                     "type": "integer"
                 },
-                "query": {
+                "expires_time": {
                     "type": "string"
-                }
-            }
-        },
-        "entity.QueryEvaluate": {
-            "type": "object",
-            "properties": {
-                "budget": {
-                    "$ref": "#/definitions/entity.Budget"
                 },
-                "dataset": {
+                "id": {
                     "type": "integer"
                 },
-                "query": {
-                    "$ref": "#/definitions/entity.Query"
+                "owner": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "updated_time": {
+                    "type": "string"
                 }
             }
         },
-        "entity.QueryResult": {
-            "type": "object",
-            "additionalProperties": true
-        },
         "entity.UserBudgetModel": {
             "type": "object",
             "properties": {
@@ -2756,6 +6248,9 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "organization": {
+                    "type": "string"
+                },
                 "password": {
                     "type": "string"
                 },
@@ -2779,6 +6274,9 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "organization": {
+                    "type": "string"
+                },
                 "roles": {
                     "type": "array",
                     "items": {
@@ -2790,6 +6288,20 @@ const docTemplate = `{
                 }
             }
         },
+        "entity.ValuePolicy": {
+            "type": "object",
+            "properties": {
+                "impute": {
+                    "type": "number"
+                },
+                "invalid": {
+                    "type": "string"
+                },
+                "null": {
+                    "type": "string"
+                }
+            }
+        },
         "response.AllFunctions": {
             "type": "object",
             "properties": {
@@ -2867,8 +6379,57 @@ const docTemplate = `{
         "response.Token": {
             "type": "object",
             "properties": {
+                "expires_at": {
+                    "type": "integer"
+                },
                 "jwt": {
                     "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "signing.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "signing.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/signing.JWK"
+                    }
                 }
             }
         }
@@ -194,8 +194,8 @@ func (cl *DPClient) IsAvailable(engine string) bool {
 	return ok
 }
 
-func (cl DPClient) makeUrl(dataset int64) string {
-	s := fmt.Sprintf("%s/%d", cl.datasetURL, dataset)
+func (cl DPClient) makeUrl(dataset int64, version int64) string {
+	s := fmt.Sprintf("%s/%d?version=%d", cl.datasetURL, dataset, version)
 	return s
 }
 
@@ -208,7 +208,7 @@ Sends a http request to the engine's evaluation endpoint. Will fail if:
 func (cl *DPClient) EvaluateQuery(engine string, query entity.QueryFromClientEvaluate) (entity.QueryResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
 	defer cancel()
-	query.CallbackUrl = cl.makeUrl(query.Data)
+	query.CallbackUrl = cl.makeUrl(query.Data, query.Version)
 	var res entity.QueryResult
 	js, err := json.Marshal(query)
 	if err != nil {
@@ -263,7 +263,7 @@ Sends a Validate Query to all Engines with a Validate endpoint.
 Returns a map with the result for each Engine.
 */
 func (cl *DPClient) ValidateQueryAll(query entity.QueryFromClientEvaluate) (map[string]interface{}, error) {
-	query.CallbackUrl = cl.makeUrl(query.Data)
+	query.CallbackUrl = cl.makeUrl(query.Data, query.Version)
 
 	validateResult := make(map[string]interface{})
 	var wg sync.WaitGroup
@@ -292,7 +292,7 @@ Will fail if:
 */
 func (cl *DPClient) ValidateQuery(engine string, query entity.QueryFromClientEvaluate) (interface{}, error) {
 	// Send the engine the URL to retrieve the data
-	query.CallbackUrl = cl.makeUrl(query.Data)
+	query.CallbackUrl = cl.makeUrl(query.Data, query.Version)
 
 	// Check the format of the query to send the engine
 	js, err := json.Marshal(query)
@@ -338,7 +338,7 @@ Sends a http request to the engine's accuracy endpoint. Will fail if:
 */
 func (cl *DPClient) GetQueryAccuracy(engine string, query entity.QueryFromClientAccuracy) ([]float64, error) {
 
-	query.CallbackUrl = cl.makeUrl(query.Data)
+	query.CallbackUrl = cl.makeUrl(query.Data, query.Version)
 
 	// marshaling
 	js, err := json.Marshal(query)
@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/utils"
+)
+
+// the prefix of API keys, which tells them apart from access tokens
+const API_KEY_PREFIX = "wdpk_"
+
+// the longest time an API key can be valid
+const MAX_API_KEY_LIFETIME = 365 * 24 * time.Hour
+
+/*
+A key that a service account, or any user, authenticates with instead of a
+password. A key has some of the roles of its owner and, when Datasets is not
+empty, only gives access to those datasets. Only the hash of the key is
+stored, the key itself is shown once when it is created.
+*/
+type ApiKey struct {
+	Id         string     `json:"id"`
+	Owner      string     `json:"owner"`
+	Name       string     `json:"name"`
+	Roles      []string   `json:"roles"`
+	Datasets   []int64    `json:"datasets"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedOn  time.Time  `json:"created_time"`
+	ExpiresOn  time.Time  `json:"expires_time"`
+	LastUsedOn *time.Time `json:"last_used_time,omitempty"`
+}
+
+type ApiKeyCreate struct {
+	Name      string    `json:"name" dpvalidation:"non-empty-string"`
+	Roles     []string  `json:"roles"`
+	Datasets  []int64   `json:"datasets"`
+	ExpiresOn time.Time `json:"expires_time"`
+}
+
+// a new key, the only time the key is returned
+type ApiKeyCreated struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+func (k ApiKeyCreate) Valid(now time.Time) error {
+	if err := utils.ValidateNonEmptyString(k); err != nil {
+		return err
+	}
+	if err := validateRoles(k.Roles); err != nil {
+		return err
+	}
+	if !k.ExpiresOn.After(now) || k.ExpiresOn.Sub(now) > MAX_API_KEY_LIFETIME {
+		return fmt.Errorf("%w: expires_time should be in the future and at most %d days ahead", errors.ErrBadInput, MAX_API_KEY_LIFETIME/(24*time.Hour))
+	}
+	for _, id := range k.Datasets {
+		if id <= 0 {
+			return fmt.Errorf("%w: unexpected dataset id: %d", errors.ErrBadInput, id)
+		}
+	}
+	return nil
+}
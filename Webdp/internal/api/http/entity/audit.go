@@ -0,0 +1,197 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	errors "webdp/internal/api/http"
+)
+
+// the actions recorded in the audit log, an action is filtered on by its category before the dot too
+const (
+	AUDIT_LOGIN            = "auth.login"
+	AUDIT_LOGIN_FAILED     = "auth.login_failed"
+	AUDIT_LOGIN_LOCKOUT    = "auth.lockout"
+	AUDIT_USER_CREATE      = "user.create"
+	AUDIT_USER_UPDATE      = "user.update"
+	AUDIT_USER_DELETE      = "user.delete"
+	AUDIT_USER_PASSWORD    = "user.password_change"
+	AUDIT_USER_UNLOCK      = "user.unlock"
+	AUDIT_APIKEY_CREATE    = "apikey.create"
+	AUDIT_APIKEY_DELETE    = "apikey.delete"
+	AUDIT_ROLE_CREATE      = "role.create"
+	AUDIT_ROLE_UPDATE      = "role.update"
+	AUDIT_ROLE_DELETE      = "role.delete"
+	AUDIT_ORG_CREATE       = "org.create"
+	AUDIT_ORG_UPDATE       = "org.update"
+	AUDIT_ORG_DELETE       = "org.delete"
+	AUDIT_DATASET_CREATE   = "dataset.create"
+	AUDIT_DATASET_UPDATE   = "dataset.update"
+	AUDIT_DATASET_DELETE   = "dataset.delete"
+	AUDIT_DATASET_RESTORE  = "dataset.restore"
+	AUDIT_DATASET_PURGE    = "dataset.purge"
+	AUDIT_DATASET_TRANSFER = "dataset.transfer"
+	AUDIT_MEMBER_PUT       = "member.put"
+	AUDIT_MEMBER_DELETE    = "member.delete"
+	AUDIT_BUDGET_ALLOCATE  = "budget.allocate"
+	AUDIT_BUDGET_UPDATE    = "budget.update"
+	AUDIT_BUDGET_REVOKE    = "budget.revoke"
+	AUDIT_BUDGET_QUOTA     = "budget.quota"
+	AUDIT_QUERY_RELEASE    = "query.release"
+)
+
+// the handle of the actor of the actions of Webdp itself
+const AUDIT_SYSTEM_ACTOR = "system"
+
+/*
+Who does an action, and in which request. The entries of an actor are in the
+audit log of the organization of the actor. Actions of Webdp itself, such as
+purging datasets, are done by the system actor.
+*/
+type Actor struct {
+	Handle       string
+	Organization string
+	RequestId    string
+}
+
+// the actor of the actions of Webdp itself
+func SystemActor(requestId string) Actor {
+	return Actor{Handle: AUDIT_SYSTEM_ACTOR, Organization: DEFAULT_ORGANIZATION, RequestId: requestId}
+}
+
+/*
+An action in the audit log. Before and After are the values of the target
+before and after the action, as JSON, null for what did not exist. Hash is
+the hash of the entry together with PrevHash, the hash of the entry before it,
+so that changing or removing an entry breaks the chain of the entries after it.
+*/
+type AuditEntry struct {
+	Id           int64           `json:"id"`
+	Time         time.Time       `json:"time"`
+	Actor        string          `json:"actor"`
+	Organization string          `json:"organization"`
+	Action       string          `json:"action"`
+	Target       string          `json:"target"`
+	Before       json.RawMessage `json:"before" swaggertype:"object"`
+	After        json.RawMessage `json:"after" swaggertype:"object"`
+	RequestId    string          `json:"request_id"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// the fields that are hashed, in this order
+type hashedAuditEntry struct {
+	PrevHash     string          `json:"prev_hash"`
+	Time         string          `json:"time"`
+	Actor        string          `json:"actor"`
+	Organization string          `json:"organization"`
+	Action       string          `json:"action"`
+	Target       string          `json:"target"`
+	Before       json.RawMessage `json:"before" swaggertype:"object"`
+	After        json.RawMessage `json:"after" swaggertype:"object"`
+	RequestId    string          `json:"request_id"`
+}
+
+/*
+The hash of the entry, a hex encoded SHA-256 of its fields and PrevHash. The
+time is hashed in UTC to the microsecond, as precise as the database stores it.
+*/
+func (e AuditEntry) ComputeHash() (string, error) {
+	bs, err := json.Marshal(hashedAuditEntry{
+		PrevHash:     e.PrevHash,
+		Time:         e.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		Actor:        e.Actor,
+		Organization: e.Organization,
+		Action:       e.Action,
+		Target:       e.Target,
+		Before:       e.Before,
+		After:        e.After,
+		RequestId:    e.RequestId,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// the outcome of checking the hash chain of the whole audit log
+type AuditVerification struct {
+	Valid   bool   `json:"valid"`
+	Entries int64  `json:"entries"`
+	Broken  *int64 `json:"broken_at,omitempty"`
+}
+
+/*
+Selects a page of the audit log, newest entries first. Empty fields do not
+filter. Action matches the action or its category, "user" matches all user
+actions. Target matches the target or what is under it, "dataset/3" matches
+the budgets and members of dataset 3 too. From is inclusive and To exclusive.
+Organization is set by the handler, empty selects all organizations.
+*/
+type AuditFilter struct {
+	Organization string
+	Actor        string
+	Action       string
+	Target       string
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// a page of the audit log and the number of entries that match the filter
+type AuditPage struct {
+	Entries []AuditEntry
+	Total   int64
+}
+
+// reads the filter from the query parameters of a request for the audit log
+func NewAuditFilter(query url.Values) (AuditFilter, error) {
+	f := AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  DEFAULT_PAGE_SIZE,
+	}
+
+	for _, bound := range []struct {
+		name string
+		time **time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if !query.Has(bound.name) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, query.Get(bound.name))
+		if err != nil {
+			return f, fmt.Errorf("%w: %s should be a time in RFC 3339 format", errors.ErrBadInput, bound.name)
+		}
+		*bound.time = &t
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return f, fmt.Errorf("%w: from should be before to", errors.ErrBadInput)
+	}
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > MAX_PAGE_SIZE {
+			return f, fmt.Errorf("%w: limit should be a number between 1 and %d", errors.ErrBadInput, MAX_PAGE_SIZE)
+		}
+		f.Limit = limit
+	}
+
+	if query.Has("offset") {
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			return f, fmt.Errorf("%w: offset should be a number of at least 0", errors.ErrBadInput)
+		}
+		f.Offset = offset
+	}
+
+	return f, nil
+}
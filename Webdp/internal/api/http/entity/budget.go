@@ -2,6 +2,7 @@ package entity
 
 import (
 	"fmt"
+	"time"
 	errors "webdp/internal/api/http"
 )
 
@@ -51,3 +52,18 @@ type UserBudgetModel struct {
 	Allocated Budget `json:"allocated"`
 	Consumed  Budget `json:"consumed"`
 }
+
+// the budget of a purged dataset, which is kept when the dataset and its data are dropped
+type PurgedDatasetBudget struct {
+	Dataset       int64             `json:"dataset"`
+	Name          string            `json:"name"`
+	Owner         string            `json:"owner"`
+	Organization  string            `json:"organization"`
+	PrivacyNotion string            `json:"privacy_notion"`
+	Total         Budget            `json:"total"`
+	Consumed      Budget            `json:"consumed"`
+	Allocation    []UserBudgetModel `json:"allocation"`
+	DeletedBy     string            `json:"deleted_by"`
+	DeletedOn     time.Time         `json:"deleted_time"`
+	PurgedOn      time.Time         `json:"purged_time"`
+}
@@ -3,6 +3,9 @@ package entity
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
 
 	errors "webdp/internal/api/http"
 )
@@ -11,6 +14,9 @@ type DpDataType interface {
 	dataType()
 	GetName() string
 	Valid() error
+	// CheckValue checks an uploaded cell against the type. It returns the value
+	// to store and whether it was clamped, which only happens if clamp is set.
+	CheckValue(value string, clamp bool) (string, bool, error)
 }
 
 type DataType struct {
@@ -37,10 +43,12 @@ func (d DataType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Type)
 }
 
+// bounds are decoded as floats so that Double columns can have fractional
+// bounds. Int bounds are checked to be whole numbers when converted.
 type rawType struct {
 	Name   string    `json:"name"`
-	Low    *int32    `json:"low,omitempty"`
-	High   *int32    `json:"high,omitempty"`
+	Low    *float64  `json:"low,omitempty"`
+	High   *float64  `json:"high,omitempty"`
 	Labels *[]string `json:"labels,omitempty"`
 }
 
@@ -51,7 +59,15 @@ func UnmarshalDataType(data []byte, marhalfunc func([]byte, any) error) (DpDataT
 		return nil, err
 	}
 	if temp.Name == "Int" && temp.Labels == nil && temp.Low != nil && temp.High != nil {
-		it := &IntType{Low: *(temp.Low), High: *(temp.High)}
+		low, err := toInt32Bound(*temp.Low)
+		if err != nil {
+			return nil, err
+		}
+		high, err := toInt32Bound(*temp.High)
+		if err != nil {
+			return nil, err
+		}
+		it := &IntType{Low: low, High: high}
 		return it, nil
 	}
 
@@ -76,6 +92,13 @@ func UnmarshalDataType(data []byte, marhalfunc func([]byte, any) error) (DpDataT
 	return nil, fmt.Errorf("%w: unrecognized type", errors.ErrBadType)
 }
 
+func toInt32Bound(f float64) (int32, error) {
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: bounds of an Int column must be 32 bit integers, got: %g", errors.ErrBadType, f)
+	}
+	return int32(f), nil
+}
+
 type BoolType struct{}
 
 func (b *BoolType) GetName() string {
@@ -133,13 +156,16 @@ func (b IntType) MarshalJSON() ([]byte, error) {
 }
 
 type DoubleType struct {
-	Low  int32
-	High int32
+	Low  float64
+	High float64
 }
 
 func (i DoubleType) Valid() error {
+	if math.IsNaN(i.Low) || math.IsNaN(i.High) || math.IsInf(i.Low, 0) || math.IsInf(i.High, 0) {
+		return fmt.Errorf("%w: bounds must be finite numbers. low: %g   high: %g", errors.ErrBadInput, i.Low, i.High)
+	}
 	if i.Low > i.High {
-		return fmt.Errorf("%w: lower bound is larger than the higher bound. low: %d   high: %d", errors.ErrBadInput, i.Low, i.High)
+		return fmt.Errorf("%w: lower bound is larger than the higher bound. low: %g   high: %g", errors.ErrBadInput, i.Low, i.High)
 	}
 	return nil
 }
@@ -183,6 +209,54 @@ func (b EnumType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&temp)
 }
 
+func (b BoolType) CheckValue(value string, _ bool) (string, bool, error) {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return "", false, fmt.Errorf("%q is not a Bool", value)
+	}
+	return value, false, nil
+}
+
+func (t TextType) CheckValue(value string, _ bool) (string, bool, error) {
+	return value, false, nil
+}
+
+func (i IntType) CheckValue(value string, clamp bool) (string, bool, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "", false, fmt.Errorf("%q is not an Int", value)
+	}
+	if n >= int64(i.Low) && n <= int64(i.High) {
+		return value, false, nil
+	}
+	if !clamp {
+		return "", false, fmt.Errorf("%d is outside the bounds [%d, %d]", n, i.Low, i.High)
+	}
+	n = max(int64(i.Low), min(int64(i.High), n))
+	return strconv.FormatInt(n, 10), true, nil
+}
+
+func (d DoubleType) CheckValue(value string, clamp bool) (string, bool, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false, fmt.Errorf("%q is not a finite Double", value)
+	}
+	if f >= d.Low && f <= d.High {
+		return value, false, nil
+	}
+	if !clamp {
+		return "", false, fmt.Errorf("%g is outside the bounds [%g, %g]", f, d.Low, d.High)
+	}
+	f = math.Max(d.Low, math.Min(d.High, f))
+	return strconv.FormatFloat(f, 'f', -1, 64), true, nil
+}
+
+func (e EnumType) CheckValue(value string, _ bool) (string, bool, error) {
+	if !slices.Contains(e.Labels, value) {
+		return "", false, fmt.Errorf("%q is not one of the labels", value)
+	}
+	return value, false, nil
+}
+
 // func (r *rawType) dataType()    {}
 func (b *BoolType) dataType()   {}
 func (t *TextType) dataType()   {}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	errors "webdp/internal/api/http"
+)
+
+// how an upload or a schema change changes the data of a dataset
+const (
+	VERSION_REPLACE = "replace"
+	VERSION_APPEND  = "append"
+	VERSION_SCHEMA  = "schema"
+)
+
+// what happens to the consumed budgets of a dataset when its data changes
+const (
+	BUDGET_KEEP  = "keep"
+	BUDGET_RESET = "reset"
+)
+
+/*
+Every upload creates a new version of the data. A replace version holds only
+the uploaded rows, an append version holds the rows of the previous version
+followed by the uploaded rows. A schema change rewrites the rows of the latest
+version to the new schema as a schema version. The versions before a version
+that reset the budgets can no longer be queried, their rows are dropped.
+*/
+type DataVersion struct {
+	Version     int64     `json:"version"`
+	Mode        string    `json:"mode"`
+	Rows        int64     `json:"rows"`
+	TotalRows   int64     `json:"total_rows"`
+	LoadedOn    time.Time `json:"loaded_time"`
+	BudgetReset bool      `json:"budget_reset"`
+}
+
+type UploadOptions struct {
+	Mode        string
+	ResetBudget bool
+}
+
+/*
+Mode defaults to replace and budget to keep. The budgets are only reset when
+the data is replaced, appended rows still hold the rows the budgets were
+consumed on.
+*/
+func NewUploadOptions(mode string, budget string) (UploadOptions, error) {
+	switch mode {
+	case "":
+		mode = VERSION_REPLACE
+	case VERSION_REPLACE, VERSION_APPEND:
+	default:
+		return UploadOptions{}, fmt.Errorf("%w: mode should be either %s or %s", errors.ErrBadInput, VERSION_REPLACE, VERSION_APPEND)
+	}
+
+	switch budget {
+	case "", BUDGET_KEEP:
+	case BUDGET_RESET:
+	default:
+		return UploadOptions{}, fmt.Errorf("%w: budget should be either %s or %s", errors.ErrBadInput, BUDGET_KEEP, BUDGET_RESET)
+	}
+	if budget == BUDGET_RESET && mode != VERSION_REPLACE {
+		return UploadOptions{}, fmt.Errorf("%w: the budget can only be reset by a %s upload", errors.ErrBadInput, VERSION_REPLACE)
+	}
+
+	return UploadOptions{Mode: mode, ResetBudget: budget == BUDGET_RESET}, nil
+}
@@ -16,13 +16,20 @@ type DatasetInfo struct {
 	Id            int64          `json:"id"`
 	Name          string         `json:"name"`
 	Owner         string         `json:"owner"`
+	Organization  string         `json:"organization"`
 	Schema        []ColumnSchema `json:"schema"`
 	PrivacyNotion string         `json:"privacy_notion"`
 	TotalBudget   Budget         `json:"total_budget"`
+	Description   string         `json:"description"`
+	Tags          []string       `json:"tags"`
+	Source        string         `json:"source"`
+	Contact       string         `json:"contact"`
 	Loaded        bool           `json:"loaded"`
+	Version       int64          `json:"version,omitempty"`
 	CreatedOn     time.Time      `json:"created_time,omitempty"`
 	UpdatedOn     time.Time      `json:"updated_time,omitempty"`
 	LoadedOn      time.Time      `json:"loaded_time,omitempty"`
+	DeletedOn     *time.Time     `json:"deleted_time,omitempty"`
 }
 
 type DatasetCreate struct {
@@ -31,12 +38,44 @@ type DatasetCreate struct {
 	Schema        []ColumnSchema `json:"schema"`
 	PrivacyNotion string         `json:"privacy_notion" dpvalidation:"non-empty-string"`
 	TotalBudget   Budget         `json:"total_budget"`
+	Description   string         `json:"description,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	Source        string         `json:"source,omitempty"`
+	Contact       string         `json:"contact,omitempty"`
 }
 
+// metadata that is left out of a patch is kept
 type DatasetPatch struct {
-	Name        string `json:"name" dpvalidation:"non-empty-string"`
-	Owner       string `json:"owner" dpvalidation:"non-empty-string"`
-	TotalBudget Budget `json:"total_budget"`
+	Name        string    `json:"name" dpvalidation:"non-empty-string"`
+	Owner       string    `json:"owner" dpvalidation:"non-empty-string"`
+	TotalBudget Budget    `json:"total_budget"`
+	Description *string   `json:"description,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Source      *string   `json:"source,omitempty"`
+	Contact     *string   `json:"contact,omitempty"`
+}
+
+// max number of tags of a dataset and max length of a tag
+const (
+	maxTags      = 50
+	maxTagLength = 64
+)
+
+func validTags(tags []string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("%w: a dataset can have at most %d tags", errors.ErrBadInput, maxTags)
+	}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if tag == "" || len(tag) > maxTagLength {
+			return fmt.Errorf("%w: tags should be non-empty and at most %d characters", errors.ErrBadInput, maxTagLength)
+		}
+		if seen[tag] {
+			return fmt.Errorf("%w: the tag \"%s\" occurs multiple times", errors.ErrBadInput, tag)
+		}
+		seen[tag] = true
+	}
+	return nil
 }
 
 type ColumnSchema struct {
@@ -54,7 +93,7 @@ func (c ColumnSchema) Valid() error {
 }
 
 func (d DatasetCreate) Valid() error {
-	err := utils.ValidateNonEmptyString(d)
+	err := utils.ValidateNonEmptyTagged(d)
 	if err != nil {
 		return err
 	}
@@ -74,7 +113,7 @@ func (d DatasetCreate) Valid() error {
 			return err
 		}
 	}
-	return nil
+	return validTags(d.Tags)
 }
 
 func (d DatasetPatch) Valid() error {
@@ -82,5 +121,10 @@ func (d DatasetPatch) Valid() error {
 	if err != nil {
 		return err
 	}
+	if d.Tags != nil {
+		if err := validTags(*d.Tags); err != nil {
+			return err
+		}
+	}
 	return d.TotalBudget.Valid()
 }
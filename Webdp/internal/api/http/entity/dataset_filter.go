@@ -0,0 +1,158 @@
+package entity
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	errors "webdp/internal/api/http"
+)
+
+// default and max number of datasets in a page
+const (
+	DEFAULT_PAGE_SIZE = 100
+	MAX_PAGE_SIZE     = 1000
+)
+
+// fields that datasets can be sorted by
+const (
+	SORT_ID      = "id"
+	SORT_NAME    = "name"
+	SORT_CREATED = "created_time"
+	SORT_UPDATED = "updated_time"
+	SORT_LOADED  = "loaded_time"
+)
+
+/*
+Selects a page of datasets. Empty fields do not filter, Search matches a part
+of the name regardless of case. GrantedTo keeps the datasets on which the user
+has a budget or of which the user is a member. Deleted selects the deleted
+datasets that can still be restored instead of the others. Ids, unless it is
+nil, keeps only the datasets with those ids. Organization always filters,
+only the datasets of that organization are selected.
+*/
+type DatasetFilter struct {
+	Organization  string
+	Owner         string
+	Loaded        *bool
+	Deleted       bool
+	PrivacyNotion string
+	Tag           string
+	Search        string
+	GrantedTo     string
+	Ids           []int64
+	Sort          string
+	Descending    bool
+	Limit         int
+	Offset        int
+}
+
+// a page of datasets and the number of datasets that match the filter
+type DatasetPage struct {
+	Datasets []DatasetInfo
+	Total    int64
+}
+
+// reads the filter from the query parameters of a list request
+func NewDatasetFilter(query url.Values) (DatasetFilter, error) {
+	f := DatasetFilter{
+		Owner:         query.Get("owner"),
+		PrivacyNotion: query.Get("privacy_notion"),
+		Tag:           query.Get("tag"),
+		Search:        query.Get("search"),
+		Sort:          query.Get("sort"),
+		Limit:         DEFAULT_PAGE_SIZE,
+	}
+
+	if query.Has("loaded") {
+		loaded, err := strconv.ParseBool(query.Get("loaded"))
+		if err != nil {
+			return f, fmt.Errorf("%w: loaded should be true or false", errors.ErrBadInput)
+		}
+		f.Loaded = &loaded
+	}
+
+	if query.Has("deleted") {
+		deleted, err := strconv.ParseBool(query.Get("deleted"))
+		if err != nil {
+			return f, fmt.Errorf("%w: deleted should be true or false", errors.ErrBadInput)
+		}
+		f.Deleted = deleted
+	}
+
+	if f.PrivacyNotion != "" && f.PrivacyNotion != PURE && f.PrivacyNotion != APPROX {
+		return f, fmt.Errorf("%w: privacy notion should be either \"%s\" or \"%s\"", errors.ErrBadInput, PURE, APPROX)
+	}
+
+	switch f.Sort {
+	case "":
+		f.Sort = SORT_ID
+	case SORT_ID, SORT_NAME, SORT_CREATED, SORT_UPDATED, SORT_LOADED:
+	default:
+		return f, fmt.Errorf("%w: sort should be one of %s, %s, %s, %s or %s", errors.ErrBadInput, SORT_ID, SORT_NAME, SORT_CREATED, SORT_UPDATED, SORT_LOADED)
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		f.Descending = true
+	default:
+		return f, fmt.Errorf("%w: order should be asc or desc", errors.ErrBadInput)
+	}
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > MAX_PAGE_SIZE {
+			return f, fmt.Errorf("%w: limit should be a number between 1 and %d", errors.ErrBadInput, MAX_PAGE_SIZE)
+		}
+		f.Limit = limit
+	}
+
+	if query.Has("offset") {
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			return f, fmt.Errorf("%w: offset should be a number of at least 0", errors.ErrBadInput)
+		}
+		f.Offset = offset
+	}
+
+	return f, nil
+}
+
+/*
+The query parameters of a list request with the filter, the inverse of
+NewDatasetFilter. Organization, GrantedTo and Ids are set by the server and
+left out, as are a zero Limit and the default sort.
+*/
+func (f DatasetFilter) Query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{"owner": f.Owner, "privacy_notion": f.PrivacyNotion, "tag": f.Tag, "search": f.Search} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if f.Loaded != nil {
+		query.Set("loaded", strconv.FormatBool(*f.Loaded))
+	}
+	if f.Deleted {
+		query.Set("deleted", "true")
+	}
+	if f.Sort != "" && f.Sort != SORT_ID {
+		query.Set("sort", f.Sort)
+	}
+	if f.Descending {
+		query.Set("order", "desc")
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		query.Set("offset", strconv.Itoa(f.Offset))
+	}
+	return query
+}
+
+// whether the filter leaves out datasets beyond those of GrantedTo
+func (f DatasetFilter) Filters() bool {
+	return f.Owner != "" || f.Loaded != nil || f.Deleted || f.PrivacyNotion != "" || f.Tag != "" || f.Search != ""
+}
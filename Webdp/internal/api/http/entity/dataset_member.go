@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	errors "webdp/internal/api/http"
+)
+
+/*
+Roles of the members of a dataset. The owner manages the members and can delete
+the dataset, co-curators manage the dataset, its data and its budgets, viewers
+can read the dataset without a budget.
+*/
+const (
+	MEMBER_OWNER      = "owner"
+	MEMBER_CO_CURATOR = "co-curator"
+	MEMBER_VIEWER     = "viewer"
+)
+
+// the roles that can manage a dataset, its data and its budgets
+var MANAGING_MEMBERS = []string{MEMBER_OWNER, MEMBER_CO_CURATOR}
+
+type DatasetMember struct {
+	Handle  string    `json:"handle"`
+	Role    string    `json:"role"`
+	AddedBy string    `json:"added_by"`
+	AddedOn time.Time `json:"added_time"`
+}
+
+// the role of a member, the owner only changes through an ownership transfer
+type MemberPut struct {
+	Role string `json:"role" dpvalidation:"non-empty-string"`
+}
+
+func (m MemberPut) Valid() error {
+	if !slices.Contains([]string{MEMBER_CO_CURATOR, MEMBER_VIEWER}, m.Role) {
+		return fmt.Errorf("%w: role should be either \"%s\" or \"%s\", the owner changes through a transfer", errors.ErrBadInput, MEMBER_CO_CURATOR, MEMBER_VIEWER)
+	}
+	return nil
+}
+
+// a proposed change of owner, which takes effect when the new owner accepts it
+type OwnershipTransfer struct {
+	Dataset   int64     `json:"dataset"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	CreatedOn time.Time `json:"created_time"`
+}
+
+type TransferRequest struct {
+	To string `json:"to" dpvalidation:"non-empty-string"`
+}
@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/utils"
+)
+
+/*
+The organization of the platform. Users that are not given an organization
+belong to it, and its admins manage the other organizations.
+*/
+const DEFAULT_ORGANIZATION = "default"
+
+var organizationHandle = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+
+/*
+A tenant. Users only see the users and datasets of their own organization.
+The total budgets of the datasets of the organization, deleted datasets
+that can still be restored included, are at most BudgetCap when it is set;
+Used is their sum.
+*/
+type Organization struct {
+	Handle    string    `json:"handle"`
+	Name      string    `json:"name"`
+	BudgetCap *Budget   `json:"budget_cap,omitempty"`
+	Used      Budget    `json:"used"`
+	CreatedOn time.Time `json:"created_time"`
+}
+
+type OrganizationCreate struct {
+	Handle    string  `json:"handle" dpvalidation:"non-empty-string"`
+	Name      string  `json:"name" dpvalidation:"non-empty-string"`
+	BudgetCap *Budget `json:"budget_cap"`
+}
+
+// replaces the name and the budget cap, no budget cap removes the cap
+type OrganizationPatch struct {
+	Name      string  `json:"name" dpvalidation:"non-empty-string"`
+	BudgetCap *Budget `json:"budget_cap"`
+}
+
+func (o OrganizationCreate) Valid() error {
+	if err := utils.ValidateNonEmptyString(o); err != nil {
+		return err
+	}
+	if !organizationHandle.MatchString(o.Handle) {
+		return fmt.Errorf("%w: organization handle should start with a lowercase letter or digit and have at most 50 lowercase letters, digits or -", errors.ErrBadInput)
+	}
+	return validateBudgetCap(o.BudgetCap)
+}
+
+func (o OrganizationPatch) Valid() error {
+	if err := utils.ValidateNonEmptyString(o); err != nil {
+		return err
+	}
+	return validateBudgetCap(o.BudgetCap)
+}
+
+func validateBudgetCap(b *Budget) error {
+	if b == nil {
+		return nil
+	}
+	if b.Epsilon < 0 || (b.Delta != nil && *b.Delta < 0) {
+		return fmt.Errorf("%w: budget cap can't be negative: %s", errors.ErrBadInput, printBudget(*b))
+	}
+	return nil
+}
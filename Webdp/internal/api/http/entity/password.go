@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/utils"
+)
+
+// the password policy and the login limits, unless the PASSWORD_* and LOGIN_* environment variables are set
+const (
+	DEFAULT_PASSWORD_MIN_LENGTH   = 10
+	DEFAULT_PASSWORD_MIN_CLASSES  = 3
+	DEFAULT_LOGIN_MAX_FAILURES    = 5
+	DEFAULT_LOGIN_IP_MAX_FAILURES = 20
+	DEFAULT_LOGIN_LOCKOUT         = 15 * time.Minute
+)
+
+// bcrypt only hashes the first 72 bytes of a password
+const MAX_PASSWORD_BYTES = 72
+
+/*
+The passwords that users can set. A password has at least MinLength
+characters, of at least MinClasses of the classes lower case letters, upper
+case letters, digits and other characters, and does not contain the handle of
+the user.
+*/
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+}
+
+func (p PasswordPolicy) Check(handle string, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: password should be at least %d characters", errors.ErrBadInput, p.MinLength)
+	}
+	if len(password) > MAX_PASSWORD_BYTES {
+		return fmt.Errorf("%w: password should be at most %d bytes", errors.ErrBadInput, MAX_PASSWORD_BYTES)
+	}
+
+	var lower, upper, digit, other int
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	if lower+upper+digit+other < p.MinClasses {
+		return fmt.Errorf("%w: password should have at least %d of lower case letters, upper case letters, digits and other characters", errors.ErrBadInput, p.MinClasses)
+	}
+
+	if handle != "" && strings.Contains(strings.ToLower(password), strings.ToLower(handle)) {
+		return fmt.Errorf("%w: password should not contain the handle of the user", errors.ErrBadInput)
+	}
+	return nil
+}
+
+/*
+How many logins can fail before they are refused. After MaxFailures failed
+logins in a row an account is locked for Lockout, after MaxIpFailures failed
+logins from one address within Lockout the address is.
+*/
+type LoginPolicy struct {
+	MaxFailures   int
+	MaxIpFailures int
+	Lockout       time.Duration
+}
+
+// the stored password of a user and the failed logins since its last login
+type Credentials struct {
+	Handle       string
+	PWD          string
+	FailedLogins int
+	LockedUntil  *time.Time
+}
+
+// whether the account refuses logins at the time
+func (c Credentials) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// a user changing its own password, which takes the password it has now
+type PasswordChange struct {
+	OldPWD string `json:"old_password" dpvalidation:"non-empty-string"`
+	NewPWD string `json:"new_password" dpvalidation:"non-empty-string"`
+}
+
+func (p PasswordChange) Valid() error {
+	return utils.ValidateNonEmptyString(p)
+}
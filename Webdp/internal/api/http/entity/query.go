@@ -21,9 +21,20 @@ type QueryStep interface {
 type QueryResult = map[string]interface{}
 
 type QueryEvaluate struct {
-	Dataset int64  `json:"dataset"`
-	Budget  Budget `json:"budget"`
-	Query   Query  `json:"query"`
+	Dataset int64 `json:"dataset"`
+	// version of the data, the latest if left out
+	Version       int64                  `json:"version,omitempty"`
+	Budget        Budget                 `json:"budget"`
+	Query         Query                  `json:"query"`
+	ValuePolicies map[string]ValuePolicy `json:"value_policies,omitempty"`
+}
+
+// what an engine does with null or invalid numeric cells of a column,
+// one of drop, impute or fail. Impute is the value used by impute.
+type ValuePolicy struct {
+	Null    string   `json:"null"`
+	Invalid string   `json:"invalid"`
+	Impute  *float64 `json:"impute,omitempty"`
 }
 
 type QueryCustom struct {
@@ -33,7 +44,9 @@ type QueryCustom struct {
 }
 
 type QueryAccuracy struct {
-	Dataset    int64   `json:"dataset"`
+	Dataset int64 `json:"dataset"`
+	// version of the data, the latest if left out
+	Version    int64   `json:"version,omitempty"`
 	Budget     Budget  `json:"budget"`
 	Query      Query   `json:"query"`
 	Confidence float64 `json:"confidence"`
@@ -45,18 +58,21 @@ type QueryAccuracyResult struct {
 }
 
 type QueryFromClientEvaluate struct {
-	Budget        Budget         `json:"budget"`
-	Query         Query          `json:"query"`
-	Data          int64          `json:"dataset"`
-	Schema        []ColumnSchema `json:"schema"`
-	PrivacyNotion string         `json:"privacy_notion"`
-	CallbackUrl   string         `json:"url"`
+	Budget        Budget                 `json:"budget"`
+	Query         Query                  `json:"query"`
+	Data          int64                  `json:"dataset"`
+	Version       int64                  `json:"version"`
+	Schema        []ColumnSchema         `json:"schema"`
+	PrivacyNotion string                 `json:"privacy_notion"`
+	CallbackUrl   string                 `json:"url"`
+	ValuePolicies map[string]ValuePolicy `json:"value_policies,omitempty"`
 }
 
 type QueryFromClientAccuracy struct {
 	Budget        Budget         `json:"budget"`
 	Query         Query          `json:"query"`
 	Data          int64          `json:"dataset"`
+	Version       int64          `json:"version"`
 	Schema        []ColumnSchema `json:"schema"`
 	PrivacyNotion string         `json:"privacy_notion"`
 	CallbackUrl   string         `json:"url"`
@@ -73,6 +89,11 @@ type MeasurementParams struct {
 	Budget *Budget `json:"budget,omitempty"`
 }
 
+type QuantileParams struct {
+	MeasurementParams
+	Ranks []float64 `json:"ranks"`
+}
+
 type ColumnMapping struct {
 	Fun    string         `json:"fun"`
 	Schema []ColumnSchema `json:"schema"`
@@ -87,6 +108,26 @@ func (q QueryEvaluate) Valid() error {
 	if err != nil {
 		return err
 	}
+	for col, policy := range q.ValuePolicies {
+		if err := policy.Valid(); err != nil {
+			return fmt.Errorf("%w: value policy for column %s", err, col)
+		}
+	}
+	return nil
+}
+
+func (p ValuePolicy) Valid() error {
+	for _, action := range []string{p.Null, p.Invalid} {
+		switch action {
+		case "drop", "fail":
+		case "impute":
+			if p.Impute == nil {
+				return fmt.Errorf("%w: impute policy needs an impute value", errors.ErrBadInput)
+			}
+		default:
+			return fmt.Errorf("%w: unknown value policy %q, should be one of drop, impute or fail", errors.ErrBadInput, action)
+		}
+	}
 	return nil
 }
 
@@ -251,6 +292,12 @@ func getUnmarshalFunc(typ string) func([]byte) (QueryStep, error) {
 		case "sum":
 			var q SumMeasurement
 			return q, marshalHelper(&q)(data)
+		case "median":
+			var q MedianMeasurement
+			return q, marshalHelper(&q)(data)
+		case "quantile":
+			var q QuantileMeasurement
+			return q, marshalHelper(&q)(data)
 		case "groupby":
 			var q GroupByPartition
 			return q, marshalHelper(&q)(data)
@@ -310,6 +357,14 @@ type SumMeasurement struct {
 	Params MeasurementParams `json:"sum"`
 }
 
+type MedianMeasurement struct {
+	Params MeasurementParams `json:"median"`
+}
+
+type QuantileMeasurement struct {
+	Params QuantileParams `json:"quantile"`
+}
+
 func (s SumMeasurement) getParams() MeasurementParams {
 	return s.Params
 }
@@ -325,6 +380,12 @@ func (s MinMeasurement) getParams() MeasurementParams {
 func (s CountMeasurement) getParams() MeasurementParams {
 	return s.Params
 }
+func (m MedianMeasurement) getParams() MeasurementParams {
+	return m.Params
+}
+func (q QuantileMeasurement) getParams() MeasurementParams {
+	return q.Params.MeasurementParams
+}
 
 // dummy implementation of QueryStep
 
@@ -337,5 +398,7 @@ func (m MinMeasurement) isQuery()       {}
 func (m MaxMeasurement) isQuery()       {}
 func (m MeanMeasurement) isQuery()      {}
 func (s SumMeasurement) isQuery()       {}
+func (m MedianMeasurement) isQuery()    {}
+func (q QuantileMeasurement) isQuery()  {}
 func (g GroupByPartition) isQuery()     {}
 func (m MapTransformation) isQuery()    {}
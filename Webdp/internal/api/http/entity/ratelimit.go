@@ -0,0 +1,159 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	errors "webdp/internal/api/http"
+)
+
+// the classes of endpoints that are limited separately
+const (
+	// evaluating, validating and the accuracy of queries, which go to the engines
+	RATE_CLASS_QUERY = "query"
+	// the other requests that change something
+	RATE_CLASS_WRITE = "write"
+	RATE_CLASS_READ  = "read"
+)
+
+var RATE_CLASSES = []string{RATE_CLASS_QUERY, RATE_CLASS_WRITE, RATE_CLASS_READ}
+
+// the requests of a user are counted per window
+const RATE_LIMIT_WINDOW = time.Minute
+
+// the requests per window of each class, unless RATE_LIMITS sets them
+const (
+	DEFAULT_RATE_LIMIT_QUERY = 60
+	DEFAULT_RATE_LIMIT_WRITE = 300
+	DEFAULT_RATE_LIMIT_READ  = 1200
+)
+
+// the queries per day on an allocation, unless QUERY_DAILY_QUOTA is set; 0 is no quota
+const DEFAULT_QUERY_DAILY_QUOTA = 0
+
+/*
+How many requests of each class a user makes per window. The limit of a user
+is the one set for the user, else the highest one set for its roles, else the
+default. A limit of 0 is no limit.
+*/
+type RateLimits struct {
+	Default map[string]int
+	Roles   map[string]map[string]int
+	Users   map[string]map[string]int
+}
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Default: map[string]int{
+			RATE_CLASS_QUERY: DEFAULT_RATE_LIMIT_QUERY,
+			RATE_CLASS_WRITE: DEFAULT_RATE_LIMIT_WRITE,
+			RATE_CLASS_READ:  DEFAULT_RATE_LIMIT_READ,
+		},
+		Roles: make(map[string]map[string]int),
+		Users: make(map[string]map[string]int),
+	}
+}
+
+/*
+Reads the limits from a comma separated list of class=limit, role:<role>:class=limit
+and user:<handle>:class=limit, such as "query=30,role:Curator:query=120,user:root:query=0".
+The classes that are not in the list keep their default.
+*/
+func ParseRateLimits(spec string) (RateLimits, error) {
+	limits := DefaultRateLimits()
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		subject, value, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 {
+			return limits, fmt.Errorf("%w: rate limit %s should be a class and a number of requests", errors.ErrBadInput, entry)
+		}
+
+		parts := strings.Split(subject, ":")
+		class := strings.TrimSpace(parts[len(parts)-1])
+		if !slices.Contains(RATE_CLASSES, class) {
+			return limits, fmt.Errorf("%w: rate limit %s should be of one of the classes %s", errors.ErrBadInput, entry, strings.Join(RATE_CLASSES, ", "))
+		}
+		switch {
+		case len(parts) == 1:
+			limits.Default[class] = n
+		case len(parts) == 3 && parts[0] == "role" && parts[1] != "":
+			setLimit(limits.Roles, parts[1], class, n)
+		case len(parts) == 3 && parts[0] == "user" && parts[1] != "":
+			setLimit(limits.Users, parts[1], class, n)
+		default:
+			return limits, fmt.Errorf("%w: rate limit %s should be for role:<role> or user:<handle>", errors.ErrBadInput, entry)
+		}
+	}
+	return limits, nil
+}
+
+func setLimit(limits map[string]map[string]int, subject string, class string, n int) {
+	if _, ok := limits[subject]; !ok {
+		limits[subject] = make(map[string]int)
+	}
+	limits[subject][class] = n
+}
+
+// the requests of the class that the user with the roles makes per window, 0 is no limit
+func (l RateLimits) Limit(handle string, roles []string, class string) int {
+	if n, ok := l.Users[handle][class]; ok {
+		return n
+	}
+	limit, found := 0, false
+	for _, role := range roles {
+		n, ok := l.Roles[role][class]
+		if !ok {
+			continue
+		}
+		if n == 0 {
+			return 0
+		}
+		limit, found = max(limit, n), true
+	}
+	if found {
+		return limit
+	}
+	return l.Default[class]
+}
+
+// the requests a user has left of a limit in the current window
+type RateLimitState struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+/*
+The queries a user can make per day on a dataset it has a budget on. The
+quota of an allocation is DailyQueries if it is set, else the default of the
+configuration; a quota of 0 is no quota. The days are in UTC.
+*/
+type QueryQuota struct {
+	DailyQueries *int      `json:"daily_queries"`
+	Limit        int       `json:"limit"`
+	Used         int       `json:"used"`
+	Resets       time.Time `json:"resets"`
+}
+
+// sets the quota of an allocation, no daily_queries gives it the default quota
+type QueryQuotaPut struct {
+	DailyQueries *int `json:"daily_queries"`
+}
+
+func (q QueryQuotaPut) Valid() error {
+	if q.DailyQueries != nil && *q.DailyQueries < 0 {
+		return fmt.Errorf("%w: daily_queries can't be negative", errors.ErrBadInput)
+	}
+	return nil
+}
+
+// the start of the day of the time, and of the next day
+func QuotaDay(now time.Time) (time.Time, time.Time) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	return day, day.Add(24 * time.Hour)
+}
@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/utils"
+)
+
+/*
+Permissions are what a role allows. A request is authorized by a permission
+rather than by a role, so that roles can be made from any set of permissions.
+Some requests are also allowed without a permission, for instance to the
+owner of a dataset or to the user a request is about.
+*/
+const (
+	PERM_USER_READ       = "user.read"       // read the users of the organization
+	PERM_USER_MANAGE     = "user.manage"     // create, update and delete users and manage their api keys
+	PERM_ROLE_MANAGE     = "role.manage"     // create, update and delete custom roles, in the default organization only
+	PERM_ORG_MANAGE      = "org.manage"      // create, update and delete organizations, in the default organization only
+	PERM_DATASET_READ    = "dataset.read"    // read the datasets of the organization, their versions, schema changes and members
+	PERM_DATASET_CREATE  = "dataset.create"  // create datasets, and own them
+	PERM_DATASET_UPDATE  = "dataset.update"  // update datasets and schemas that one owns or co-curates
+	PERM_DATASET_UPLOAD  = "dataset.upload"  // upload data to datasets that one owns or co-curates
+	PERM_DATASET_RESTORE = "dataset.restore" // restore any deleted dataset of the organization
+	PERM_BUDGET_READ     = "budget.read"     // read the budgets of the users of the organization
+	PERM_BUDGET_ALLOCATE = "budget.allocate" // allocate budgets on datasets that one owns or co-curates
+	PERM_BUDGET_HISTORY  = "budget.history"  // read the budgets spent on purged datasets
+	PERM_QUERY_RUN       = "query.run"       // evaluate queries on datasets that one has access to
+	PERM_AUDIT_READ      = "audit.read"      // read the audit log of the organization, of all organizations in the default organization
+)
+
+// all permissions, in the order they are listed
+var PERMISSIONS = []string{
+	PERM_USER_READ, PERM_USER_MANAGE, PERM_ROLE_MANAGE, PERM_ORG_MANAGE,
+	PERM_DATASET_READ, PERM_DATASET_CREATE, PERM_DATASET_UPDATE, PERM_DATASET_UPLOAD, PERM_DATASET_RESTORE,
+	PERM_BUDGET_READ, PERM_BUDGET_ALLOCATE, PERM_BUDGET_HISTORY,
+	PERM_QUERY_RUN, PERM_AUDIT_READ,
+}
+
+// the most roles a user or an api key can have
+const MAX_USER_ROLES = 20
+
+var roleName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,49}$`)
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+/*
+A role and its permissions. The built-in roles Admin, Curator and Analyst
+cannot be changed or deleted.
+*/
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	Builtin     bool     `json:"builtin"`
+}
+
+type RoleCreate struct {
+	Name        string   `json:"name" dpvalidation:"non-empty-string"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// replaces the description and the permissions of a role
+type RolePut struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (c RoleCreate) Valid() error {
+	if err := utils.ValidateNonEmptyTagged(c); err != nil {
+		return err
+	}
+	if !roleName.MatchString(c.Name) {
+		return fmt.Errorf("%w: role name should start with a letter and have at most 50 letters, digits, - or _", errors.ErrBadInput)
+	}
+	return validatePermissions(c.Permissions)
+}
+
+func (p RolePut) Valid() error {
+	return validatePermissions(p.Permissions)
+}
+
+/*
+The permissions that the roles give, in the order of PERMISSIONS. Roles that
+are not among the defined roles give no permissions.
+*/
+func PermissionsOf(defined []Role, roles []string) []string {
+	out := make([]string, 0)
+	for _, p := range PERMISSIONS {
+		for _, r := range defined {
+			if slices.Contains(roles, r.Name) && slices.Contains(r.Permissions, p) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func validatePermissions(ps []string) error {
+	if len(ps) == 0 {
+		return fmt.Errorf("%w: a role needs at least one permission", errors.ErrBadInput)
+	}
+	for i, p := range ps {
+		if !slices.Contains(PERMISSIONS, p) {
+			return fmt.Errorf("%w: unrecognized permission: %s", errors.ErrBadInput, p)
+		}
+		if slices.Contains(ps[:i], p) {
+			return fmt.Errorf("%w: permission %s is given twice", errors.ErrBadInput, p)
+		}
+	}
+	return nil
+}
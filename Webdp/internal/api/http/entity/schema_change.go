@@ -0,0 +1,217 @@
+package entity
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+
+	errors "webdp/internal/api/http"
+)
+
+// a column added by a schema patch, loaded rows get the default value
+type AddedColumn struct {
+	ColumnSchema
+	Default *string `json:"default,omitempty"`
+}
+
+/*
+Changes the schema of a dataset. Columns are dropped first, then updated and
+then added after the remaining columns. An update changes the bounds or labels
+of a column but not its type. A column that is dropped and added again keeps
+its loaded values, which are checked against its new type.
+*/
+type SchemaPatch struct {
+	Add    []AddedColumn  `json:"add,omitempty"`
+	Drop   []string       `json:"drop,omitempty"`
+	Update []ColumnSchema `json:"update,omitempty"`
+}
+
+// an entry of the audit trail of the schema of a dataset
+type SchemaChange struct {
+	Id        int64          `json:"id"`
+	Dataset   int64          `json:"dataset"`
+	ChangedBy string         `json:"changed_by"`
+	ChangedOn time.Time      `json:"changed_time"`
+	Patch     SchemaPatch    `json:"patch"`
+	OldSchema []ColumnSchema `json:"old_schema"`
+	NewSchema []ColumnSchema `json:"new_schema"`
+	// the version of the data rewritten to the new schema, if data was loaded
+	Version int64         `json:"version,omitempty"`
+	Report  *UploadReport `json:"report,omitempty"`
+}
+
+func (p SchemaPatch) Valid() error {
+	if len(p.Add) == 0 && len(p.Drop) == 0 && len(p.Update) == 0 {
+		return fmt.Errorf("%w: the schema patch changes nothing", errors.ErrBadInput)
+	}
+	for _, col := range p.Add {
+		if err := col.Valid(); err != nil {
+			return err
+		}
+	}
+	for _, col := range p.Update {
+		if err := col.Valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the schema after the patch
+func (p SchemaPatch) Apply(schema []ColumnSchema) ([]ColumnSchema, error) {
+	out := append([]ColumnSchema(nil), schema...)
+	index := func(name string) int {
+		for i, col := range out {
+			if col.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, name := range p.Drop {
+		i := index(name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: cannot drop %s, the dataset has no such column", errors.ErrBadInput, name)
+		}
+		out = append(out[:i], out[i+1:]...)
+	}
+
+	for _, col := range p.Update {
+		i := index(col.Name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: cannot update %s, the dataset has no such column", errors.ErrBadInput, col.Name)
+		}
+		if out[i].Type.Type.GetName() != col.Type.Type.GetName() {
+			return nil, fmt.Errorf("%w: cannot change the type of %s from %s to %s, drop and add the column instead", errors.ErrBadInput, col.Name, out[i].Type.Type.GetName(), col.Type.Type.GetName())
+		}
+		out[i] = col
+	}
+
+	for _, col := range p.Add {
+		if index(col.Name) >= 0 {
+			return nil, fmt.Errorf("%w: cannot add %s, the dataset already has such a column", errors.ErrBadInput, col.Name)
+		}
+		out = append(out, col.ColumnSchema)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: a dataset needs at least one column", errors.ErrBadInput)
+	}
+	return out, nil
+}
+
+/*
+Rewrites stored rows to a changed schema. Rows keep the values of the columns
+that are left, added columns get their default value and every value is checked
+against the new schema like an upload, see UploadValidator.
+*/
+type SchemaRewriter struct {
+	validator *UploadValidator
+	// index in the stored row of every column of the new schema, -1 if added
+	index    []int
+	defaults []string
+	width    int
+	row      int
+}
+
+// header is the header of the stored rows
+func NewSchemaRewriter(header string, schema []ColumnSchema, patch SchemaPatch, invalid string) (*SchemaRewriter, error) {
+	oldHeader, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid header of the loaded data: %s", errors.ErrUnexpected, err.Error())
+	}
+
+	newHeader := make([]string, len(schema))
+	for i, col := range schema {
+		newHeader[i] = col.Name
+	}
+	validator, err := NewUploadValidator(schema, newHeader, invalid)
+	if err != nil {
+		return nil, err
+	}
+
+	r := SchemaRewriter{validator: validator, index: make([]int, len(schema)), defaults: make([]string, len(schema)), width: len(oldHeader)}
+	for i, col := range schema {
+		r.index[i] = -1
+		for j, name := range oldHeader {
+			if name == col.Name {
+				r.index[i] = j
+			}
+		}
+		if r.index[i] >= 0 {
+			continue
+		}
+		if r.defaults[i], err = addedDefault(patch, col.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+func addedDefault(patch SchemaPatch, name string) (string, error) {
+	for _, added := range patch.Add {
+		if added.Name == name && added.Default != nil {
+			return *added.Default, nil
+		}
+	}
+	return "", fmt.Errorf("%w: the dataset has loaded data, so the added column %s needs a default value", errors.ErrBadInput, name)
+}
+
+// the header of the rewritten rows
+func (r *SchemaRewriter) Header() (string, error) {
+	return EncodeCSVLine(r.validator.Header())
+}
+
+// returns the rewritten row and false if the row is dropped or invalid
+func (r *SchemaRewriter) Row(line string) (string, bool, error) {
+	r.row++
+	record, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil || len(record) != r.width {
+		return "", false, fmt.Errorf("%w: row %d of the loaded data is not valid CSV", errors.ErrUnexpected, r.row)
+	}
+
+	values := make([]string, len(r.index))
+	for i, j := range r.index {
+		if j < 0 {
+			values[i] = r.defaults[i]
+		} else {
+			values[i] = record[j]
+		}
+	}
+
+	row, ok := r.validator.Row(values)
+	if !ok {
+		return "", false, nil
+	}
+	out, err := EncodeCSVLine(row)
+	return out, err == nil, err
+}
+
+// the rows of the loaded data that do not fit the new schema
+func (r *SchemaRewriter) Err() error {
+	if r.validator.nErrors == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: loaded data does not fit the new schema: %s", errors.ErrBadInput, r.validator.invalidCells())
+}
+
+func (r *SchemaRewriter) Report() UploadReport {
+	return r.validator.Report()
+}
+
+// one CSV encoded line without the line break
+func EncodeCSVLine(record []string) (string, error) {
+	var sb strings.Builder
+	writer := csv.NewWriter(&sb)
+	if err := writer.Write(record); err != nil {
+		return "", err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
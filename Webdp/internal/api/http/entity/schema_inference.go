@@ -0,0 +1,196 @@
+package entity
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	errors "webdp/internal/api/http"
+)
+
+// text columns with at most this many distinct values are proposed as Enum
+const maxEnumLabels = 20
+
+// labels that occur fewer times in the sample are left out of a proposed Enum
+const minEnumCount = 5
+
+// default and max number of rows read from a sample
+const (
+	DEFAULT_SAMPLE_ROWS = 10000
+	MAX_SAMPLE_ROWS     = 1000000
+)
+
+/*
+A column of an inferred schema. Bounds and labels taken from the data tell
+something about the rows of the sample, so they are marked until the curator
+confirms or replaces them. Bounds are widened to round numbers, and labels that
+occur fewer than minEnumCount times are left out and only counted, since they
+single out a few rows.
+*/
+type InferredColumn struct {
+	ColumnSchema
+	ConfirmBounds bool `json:"confirm_bounds,omitempty"`
+	RareLabels    int  `json:"rare_labels,omitempty"`
+}
+
+// has the fields of DatasetCreate, so that it can be posted once filled in
+type SchemaProposal struct {
+	Name          string           `json:"name"`
+	Owner         string           `json:"owner"`
+	Schema        []InferredColumn `json:"schema"`
+	PrivacyNotion string           `json:"privacy_notion"`
+	TotalBudget   *Budget          `json:"total_budget,omitempty"`
+	SampleRows    int              `json:"sample_rows"`
+}
+
+// what has been seen of a column so far, empty cells are ignored
+type columnStats struct {
+	values   int
+	ints     bool
+	doubles  bool
+	bools    bool
+	low      float64
+	high     float64
+	distinct map[string]int
+}
+
+func newColumnStats() *columnStats {
+	return &columnStats{ints: true, doubles: true, bools: true, low: math.Inf(1), high: math.Inf(-1), distinct: make(map[string]int)}
+}
+
+func (c *columnStats) add(value string) {
+	if value == "" {
+		return
+	}
+	c.values++
+
+	if c.ints {
+		if n, err := strconv.ParseInt(value, 10, 64); err != nil || n < math.MinInt32 || n > math.MaxInt32 {
+			c.ints = false
+		}
+	}
+	if c.doubles {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			c.doubles = false
+		} else {
+			c.low, c.high = math.Min(c.low, f), math.Max(c.high, f)
+		}
+	}
+	if c.bools {
+		// the spellings of strconv.ParseBool, except for 1, 0, t and f
+		switch value {
+		case "true", "TRUE", "True", "false", "FALSE", "False":
+		default:
+			c.bools = false
+		}
+	}
+	if _, ok := c.distinct[value]; ok || len(c.distinct) <= maxEnumLabels {
+		c.distinct[value]++
+	}
+}
+
+// the type of the column, whether it should be confirmed and the number of rare labels left out
+func (c *columnStats) dataType() (DataType, bool, int) {
+	switch {
+	case c.values == 0:
+		return DataType{Type: &TextType{}}, false, 0
+	case c.bools:
+		return DataType{Type: &BoolType{}}, false, 0
+	case c.ints:
+		low, high := roundBounds(c.low, c.high)
+		return DataType{Type: &IntType{Low: int32(math.Max(low, math.MinInt32)), High: int32(math.Min(high, math.MaxInt32))}}, true, 0
+	case c.doubles:
+		low, high := roundBounds(c.low, c.high)
+		return DataType{Type: &DoubleType{Low: low, High: high}}, true, 0
+	case len(c.distinct) <= maxEnumLabels:
+		// most of the values should have a label that is not rare
+		labels, covered := make([]string, 0, len(c.distinct)), 0
+		for label, count := range c.distinct {
+			if count >= minEnumCount {
+				labels = append(labels, label)
+				covered += count
+			}
+		}
+		if covered*2 < c.values {
+			return DataType{Type: &TextType{}}, false, 0
+		}
+		sort.Strings(labels)
+		return DataType{Type: &EnumType{Labels: labels}}, true, len(c.distinct) - len(labels)
+	default:
+		return DataType{Type: &TextType{}}, false, 0
+	}
+}
+
+/*
+Widens the range of the sample to multiples of half the power of ten above its
+largest magnitude, e.g. [18, 93] becomes [0, 100], [-3, 7] becomes [-5, 10] and
+[0.13, 0.97] becomes [0, 1].
+*/
+func roundBounds(low float64, high float64) (float64, float64) {
+	magnitude := math.Max(math.Abs(low), math.Abs(high))
+	if magnitude == 0 {
+		return 0, 1
+	}
+	step := math.Pow(10, math.Ceil(math.Log10(magnitude))) / 2
+	low = math.Floor(low/step) * step
+	high = math.Ceil(high/step) * step
+	if low == high {
+		high += step
+	}
+	// undo the rounding errors of the division, e.g. 0.30000000000000004
+	low, _ = strconv.ParseFloat(strconv.FormatFloat(low, 'g', 12, 64), 64)
+	high, _ = strconv.ParseFloat(strconv.FormatFloat(high, 'g', 12, 64), 64)
+	return low, high
+}
+
+/*
+Proposes a schema from at most maxRows rows of a sample. Columns where every
+value is true or false are Bool, whole numbers are Int, other numbers Double,
+text with few distinct values that mostly occur often is Enum and everything
+else Text.
+*/
+func InferSchema(reader RecordReader, maxRows int) ([]InferredColumn, int, error) {
+	header := reader.Header()
+	if len(header) == 0 {
+		return nil, 0, fmt.Errorf("%w: the sample has no columns", errors.ErrBadInput)
+	}
+	seen := make(map[string]bool)
+	for _, name := range header {
+		if name == "" || seen[name] {
+			return nil, 0, fmt.Errorf("%w: column names of the sample must be unique and non-empty", errors.ErrBadInput)
+		}
+		seen[name] = true
+	}
+
+	stats := make([]*columnStats, len(header))
+	for i := range stats {
+		stats[i] = newColumnStats()
+	}
+
+	rows := 0
+	for ; rows < maxRows; rows++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(record) != len(header) {
+			return nil, 0, fmt.Errorf("%w: row %d has %d values, expected %d", errors.ErrBadFormatting, rows+1, len(record), len(header))
+		}
+		for i, value := range record {
+			stats[i].add(value)
+		}
+	}
+
+	schema := make([]InferredColumn, len(header))
+	for i, name := range header {
+		typ, confirm, rare := stats[i].dataType()
+		schema[i] = InferredColumn{ColumnSchema: ColumnSchema{Name: name, Type: typ}, ConfirmBounds: confirm, RareLabels: rare}
+	}
+	return schema, rows, nil
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+	errors "webdp/internal/api/http"
+)
+
+// the longest label of the device a session is logged in from
+const MAX_DEVICE_LENGTH = 100
+
+/*
+A login of a user. Every login gets its own session, so that a user can be
+logged in from several devices at once. The access tokens of a session are
+valid until they expire or the session is revoked, the refresh token of a
+session is replaced every time it is used.
+*/
+type Session struct {
+	Id          string    `json:"id"`
+	Handle      string    `json:"handle"`
+	Device      string    `json:"device"`
+	CreatedOn   time.Time `json:"created_time"`
+	RefreshedOn time.Time `json:"refreshed_time"`
+	ExpiresOn   time.Time `json:"expires_time"`
+	Current     bool      `json:"current"`
+}
+
+/*
+A session is extended by the refresh lifetime every time it is refreshed, but
+never beyond the max lifetime after the login that created it.
+*/
+func SessionExpiry(created time.Time, now time.Time, refreshLifetime time.Duration, maxLifetime time.Duration) time.Time {
+	expires := now.Add(refreshLifetime)
+	if end := created.Add(maxLifetime); end.Before(expires) {
+		return end
+	}
+	return expires
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" dpvalidation:"non-empty-string"`
+}
+
+func validateDevice(device string) error {
+	if len(device) > MAX_DEVICE_LENGTH {
+		return fmt.Errorf("%w: device should be at most %d characters", errors.ErrBadInput, MAX_DEVICE_LENGTH)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+
+	errors "webdp/internal/api/http"
+)
+
+// what to do with uploaded cells that do not fit the schema
+const (
+	UPLOAD_REJECT = "reject"
+	UPLOAD_CLAMP  = "clamp"
+	UPLOAD_DROP   = "drop"
+)
+
+// max number of invalid cells listed when an upload is rejected
+const maxReportedCells = 20
+
+type UploadReport struct {
+	Version      int64 `json:"version"`
+	Rows         int   `json:"rows"`
+	DroppedRows  int   `json:"dropped_rows"`
+	ClampedCells int   `json:"clamped_cells"`
+}
+
+type cellError struct {
+	row    int
+	column int
+	name   string
+	err    error
+}
+
+/*
+Validates uploaded rows one at a time against the dataset schema.
+With reject every invalid cell fails the upload, clamp clamps out of range
+numbers and rejects the rest, drop leaves out every row with an invalid cell.
+Accepted rows are returned in the column order of the schema.
+*/
+type UploadValidator struct {
+	mode    string
+	schema  []ColumnSchema
+	columns []ColumnSchema
+	// index in the schema of every uploaded column
+	order   []int
+	report  UploadReport
+	errors  []cellError
+	nErrors int
+}
+
+// header is the first line of the upload, its columns may be in any order
+func NewUploadValidator(schema []ColumnSchema, header []string, mode string) (*UploadValidator, error) {
+	if mode == "" {
+		mode = UPLOAD_REJECT
+	}
+	if mode != UPLOAD_REJECT && mode != UPLOAD_CLAMP && mode != UPLOAD_DROP {
+		return nil, fmt.Errorf("%w: invalid value handling should be one of %s, %s or %s", errors.ErrBadInput, UPLOAD_REJECT, UPLOAD_CLAMP, UPLOAD_DROP)
+	}
+
+	if len(header) != len(schema) {
+		return nil, fmt.Errorf("%w: uploaded data does not fit the dataset schema", errors.ErrBadInput)
+	}
+
+	columns := make([]ColumnSchema, len(header))
+	order := make([]int, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		found := false
+		for j, col := range schema {
+			if col.Name == name && !seen[name] {
+				columns[i] = col
+				order[i] = j
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: uploaded data does not fit the dataset schema", errors.ErrBadInput)
+		}
+		seen[name] = true
+	}
+
+	return &UploadValidator{mode: mode, schema: schema, columns: columns, order: order}, nil
+}
+
+// the column names of the accepted rows
+func (v *UploadValidator) Header() []string {
+	header := make([]string, len(v.schema))
+	for i, col := range v.schema {
+		header[i] = col.Name
+	}
+	return header
+}
+
+// returns the row to store and false if the row is dropped or invalid
+func (v *UploadValidator) Row(record []string) ([]string, bool) {
+	v.report.Rows++
+
+	row := make([]string, len(record))
+	clamped := 0
+	valid := true
+
+	for i, cell := range record {
+		value, wasClamped, err := v.columns[i].Type.Type.CheckValue(cell, v.mode == UPLOAD_CLAMP)
+		if err != nil {
+			valid = false
+			if v.mode == UPLOAD_DROP {
+				break
+			}
+			v.nErrors++
+			if len(v.errors) < maxReportedCells {
+				v.errors = append(v.errors, cellError{row: v.report.Rows, column: i + 1, name: v.columns[i].Name, err: err})
+			}
+			continue
+		}
+		if wasClamped {
+			clamped++
+		}
+		row[v.order[i]] = value
+	}
+
+	if !valid {
+		if v.mode == UPLOAD_DROP {
+			v.report.DroppedRows++
+		}
+		return nil, false
+	}
+
+	v.report.ClampedCells += clamped
+	return row, true
+}
+
+// the invalid cells found so far, rows and columns are counted from 1 excluding the header
+func (v *UploadValidator) Err() error {
+	if v.nErrors == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: uploaded data does not fit the dataset schema: %s", errors.ErrBadInput, v.invalidCells())
+}
+
+func (v *UploadValidator) invalidCells() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d invalid cells", v.nErrors)
+	if v.nErrors > len(v.errors) {
+		fmt.Fprintf(&sb, ", showing the first %d", len(v.errors))
+	}
+	for _, ce := range v.errors {
+		fmt.Fprintf(&sb, "; row %d, column %d (%s): %s", ce.row, ce.column, ce.name, ce.err)
+	}
+	return sb.String()
+}
+
+func (v *UploadValidator) Report() UploadReport {
+	return v.report
+}
@@ -0,0 +1,341 @@
+package entity
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// formats accepted for uploads, every format may be gzip compressed
+const (
+	FORMAT_CSV     = "csv"
+	FORMAT_JSONL   = "jsonl"
+	FORMAT_PARQUET = "parquet"
+)
+
+var formatContentTypes = map[string]string{
+	"text/csv":                       FORMAT_CSV,
+	"application/csv":                FORMAT_CSV,
+	"application/jsonl":              FORMAT_JSONL,
+	"application/x-jsonlines":        FORMAT_JSONL,
+	"application/x-ndjson":           FORMAT_JSONL,
+	"application/vnd.apache.parquet": FORMAT_PARQUET,
+	"application/x-parquet":          FORMAT_PARQUET,
+}
+
+/*
+The format of an upload. The format query parameter takes precedence over the
+content type, content types that do not name a format are read as CSV.
+*/
+func UploadFormat(param string, contentType string) (string, error) {
+	switch param {
+	case FORMAT_CSV, FORMAT_JSONL, FORMAT_PARQUET:
+		return param, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: format should be one of %s, %s or %s", errors.ErrBadInput, FORMAT_CSV, FORMAT_JSONL, FORMAT_PARQUET)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return FORMAT_CSV, nil
+	}
+	if format, ok := formatContentTypes[mediaType]; ok {
+		return format, nil
+	}
+	return FORMAT_CSV, nil
+}
+
+// reads the records of an upload
+type RecordReader interface {
+	// the column names, in the order of the values of a record
+	Header() []string
+	// the next record, io.EOF after the last one
+	Read() ([]string, error)
+	Close() error
+}
+
+/*
+Opens an upload in the given format. JSON Lines are mapped to the schema
+columns by name. Parquet files are read from their footer, so a Parquet body
+has to be a file that was already decompressed, see Decompress.
+*/
+func NewRecordReader(format string, body io.Reader, schema []ColumnSchema) (RecordReader, error) {
+	if format == FORMAT_PARQUET {
+		file, ok := body.(parquet.ReaderAtSeeker)
+		if !ok {
+			return nil, fmt.Errorf("%w: parquet uploads are read from a file", errors.ErrUnexpected)
+		}
+		return newParquetReader(file)
+	}
+
+	decompressed, err := Decompress(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader RecordReader
+	switch format {
+	case FORMAT_JSONL:
+		reader = newJsonlReader(decompressed, schema)
+	default:
+		if reader, err = newCsvReader(decompressed); err != nil {
+			decompressed.Close()
+			return nil, err
+		}
+	}
+
+	return closingReader{reader, decompressed}, nil
+}
+
+// gzip compressed uploads are recognized by their content
+func Decompress(body io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	if magic, _ := buffered.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return io.NopCloser(buffered), nil
+	}
+
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid gzip data: %s", errors.ErrBadFormatting, err.Error())
+	}
+	return zr, nil
+}
+
+// also closes the decompressor of the upload
+type closingReader struct {
+	RecordReader
+	closer io.Closer
+}
+
+func (c closingReader) Close() error {
+	err := c.RecordReader.Close()
+	if cerr := c.closer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCsvReader(body io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: uploaded data does not fit the dataset schema", errors.ErrBadInput)
+	}
+
+	return &csvReader{reader: reader, header: append([]string(nil), header...)}, nil
+}
+
+func (c *csvReader) Header() []string {
+	return c.header
+}
+
+func (c *csvReader) Read() ([]string, error) {
+	record, err := c.reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %s", errors.ErrBadFormatting, err.Error())
+	}
+	return record, err
+}
+
+func (c *csvReader) Close() error {
+	return nil
+}
+
+/*
+One JSON object per row. Missing and null fields are empty, fields that are not
+columns of the schema fail the upload.
+*/
+type jsonlReader struct {
+	decoder *json.Decoder
+	header  []string
+	index   map[string]int
+	row     int
+}
+
+func newJsonlReader(body io.Reader, schema []ColumnSchema) *jsonlReader {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	header := make([]string, len(schema))
+	index := make(map[string]int)
+	for i, col := range schema {
+		header[i] = col.Name
+		index[col.Name] = i
+	}
+
+	return &jsonlReader{decoder: decoder, header: header, index: index}
+}
+
+func (j *jsonlReader) Header() []string {
+	return j.header
+}
+
+func (j *jsonlReader) Read() ([]string, error) {
+	var object map[string]interface{}
+	if err := j.decoder.Decode(&object); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: row %d is not a JSON object: %s", errors.ErrBadFormatting, j.row+1, err.Error())
+	}
+	j.row++
+
+	record := make([]string, len(j.header))
+	for name, value := range object {
+		i, ok := j.index[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: row %d: %s is not a column of the dataset", errors.ErrBadInput, j.row, name)
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[i] = v
+		case json.Number:
+			record[i] = v.String()
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%w: row %d: column %s should not be an object or array", errors.ErrBadInput, j.row, name)
+		}
+	}
+
+	return record, nil
+}
+
+func (j *jsonlReader) Close() error {
+	return nil
+}
+
+// rows of a Parquet file that are decoded at a time
+const PARQUET_BATCH_ROWS = 1024
+
+/*
+Parquet files are read with the Arrow reader one batch of rows at a time.
+Only flat tables are supported, every column becomes text the way CSV uploads
+are stored.
+*/
+type parquetReader struct {
+	file    *file.Reader
+	header  []string
+	records pqarrow.RecordReader
+	record  arrow.Record
+	row     int
+}
+
+func newParquetReader(r parquet.ReaderAtSeeker) (*parquetReader, error) {
+	props := parquet.NewReaderProperties(memory.DefaultAllocator)
+	props.BufferedStreamEnabled = true
+
+	pf, err := file.NewParquetReader(r, file.WithReadProps(props))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid parquet file: %s", errors.ErrBadFormatting, err.Error())
+	}
+	p := parquetReader{file: pf}
+
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: PARQUET_BATCH_ROWS}, memory.DefaultAllocator)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("%w: invalid parquet file: %s", errors.ErrBadFormatting, err.Error())
+	}
+
+	schema, err := fr.Schema()
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("%w: invalid parquet file: %s", errors.ErrBadFormatting, err.Error())
+	}
+	for _, field := range schema.Fields() {
+		if _, nested := field.Type.(arrow.NestedType); nested {
+			p.Close()
+			return nil, fmt.Errorf("%w: column %s: nested columns are not supported", errors.ErrBadFormatting, field.Name)
+		}
+		p.header = append(p.header, field.Name)
+	}
+
+	if p.records, err = fr.GetRecordReader(context.Background(), nil, nil); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("%w: invalid parquet file: %s", errors.ErrBadFormatting, err.Error())
+	}
+
+	return &p, nil
+}
+
+func (p *parquetReader) Header() []string {
+	return p.header
+}
+
+func (p *parquetReader) Read() ([]string, error) {
+	for p.record == nil || p.row == int(p.record.NumRows()) {
+		if !p.records.Next() {
+			if err := p.records.Err(); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("%w: invalid parquet file: %s", errors.ErrBadFormatting, err.Error())
+			}
+			return nil, io.EOF
+		}
+		p.record, p.row = p.records.Record(), 0
+	}
+
+	record := make([]string, p.record.NumCols())
+	for i, col := range p.record.Columns() {
+		record[i] = parquetText(col, p.row)
+	}
+	p.row++
+
+	return record, nil
+}
+
+// the text of a cell, empty for nulls
+func parquetText(col arrow.Array, i int) string {
+	if col.IsNull(i) {
+		return ""
+	}
+
+	switch c := col.(type) {
+	case *array.Float32:
+		return strconv.FormatFloat(float64(c.Value(i)), 'f', -1, 32)
+	case *array.Float64:
+		return strconv.FormatFloat(c.Value(i), 'f', -1, 64)
+	case *array.Decimal128:
+		return c.Value(i).ToString(c.DataType().(*arrow.Decimal128Type).Scale)
+	case *array.Decimal256:
+		return c.Value(i).ToString(c.DataType().(*arrow.Decimal256Type).Scale)
+	case *array.Binary:
+		return string(c.Value(i))
+	case *array.LargeBinary:
+		return string(c.Value(i))
+	case *array.FixedSizeBinary:
+		return string(c.Value(i))
+	}
+	return col.ValueStr(i)
+}
+
+func (p *parquetReader) Close() error {
+	if p.records != nil {
+		p.records.Release()
+	}
+	return p.file.Close()
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// largest chunk accepted by a single request to a resumable upload
+const MAX_CHUNK_SIZE = 32 << 20
+
+// how long a resumable upload is kept after its last chunk
+const UPLOAD_SESSION_LIFETIME = 24 * time.Hour
+
+// a resumable upload of Owner, chunks are appended at Size until the upload is completed
+type UploadSession struct {
+	Id        int64     `json:"id"`
+	Dataset   int64     `json:"dataset"`
+	Owner     string    `json:"owner"`
+	Size      int64     `json:"size"`
+	CreatedOn time.Time `json:"created_time"`
+	UpdatedOn time.Time `json:"updated_time"`
+	ExpiresOn time.Time `json:"expires_time"`
+}
@@ -2,11 +2,13 @@ package entity
 
 import (
 	"fmt"
+	"slices"
 	"time"
 	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/utils"
 )
 
+// the built-in roles
 const (
 	ADMIN   = "Admin"
 	CURATOR = "Curator"
@@ -23,18 +25,21 @@ type User struct {
 }
 
 type UserResponse struct {
-	Handle    string    `json:"handle"`
-	Name      string    `json:"name"`
-	Roles     []string  `json:"roles"`
-	CreatedOn time.Time `json:"created_time"`
-	UpdatedOn time.Time `json:"updated_time"`
+	Handle       string    `json:"handle"`
+	Name         string    `json:"name"`
+	Organization string    `json:"organization"`
+	Roles        []string  `json:"roles"`
+	CreatedOn    time.Time `json:"created_time"`
+	UpdatedOn    time.Time `json:"updated_time"`
 }
 
+// a user in Organization, or in the organization of the requester if it is empty
 type UserPost struct {
-	Handle string   `json:"handle" dpvalidation:"non-empty-string"`
-	Name   string   `json:"name" dpvalidation:"non-empty-string"`
-	Roles  []string `json:"roles"`
-	PWD    string   `json:"password" dpvalidation:"non-empty-string"`
+	Handle       string   `json:"handle" dpvalidation:"non-empty-string"`
+	Name         string   `json:"name" dpvalidation:"non-empty-string"`
+	Organization string   `json:"organization"`
+	Roles        []string `json:"roles"`
+	PWD          string   `json:"password" dpvalidation:"non-empty-string"`
 }
 
 type UserPatch struct {
@@ -46,10 +51,11 @@ type UserPatch struct {
 type LoginRequest struct {
 	Username string `json:"username" dpvalidation:"non-empty-string"`
 	PWD      string `json:"password" dpvalidation:"non-empty-string"`
+	Device   string `json:"device"`
 }
 
 func (u UserPost) Valid() error {
-	err := utils.ValidateNonEmptyString(u)
+	err := utils.ValidateNonEmptyTagged(u)
 	if err != nil {
 		return err
 	}
@@ -65,18 +71,28 @@ func (u UserPatch) Valid() error {
 }
 
 func (l LoginRequest) Valid() error {
-	return utils.ValidateNonEmptyString(l)
+	if err := utils.ValidateNonEmptyTagged(l); err != nil {
+		return err
+	}
+	return validateDevice(l.Device)
 }
 
+/*
+Checks the number and the names of the roles, whether the roles exist is
+checked when they are stored.
+*/
 func validateRoles(rs []string) error {
-	if len(rs) == 0 || len(rs) > 3 {
+	if len(rs) == 0 || len(rs) > MAX_USER_ROLES {
 		return fmt.Errorf("%w: unexpected amount of roles: %d", errors.ErrBadInput, len(rs))
 	}
 
-	for _, role := range rs {
-		if role != ADMIN && role != CURATOR && role != ANALYST {
+	for i, role := range rs {
+		if !roleName.MatchString(role) {
 			return fmt.Errorf("%w: unrecognized role: %s", errors.ErrBadInput, role)
 		}
+		if slices.Contains(rs[:i], role) {
+			return fmt.Errorf("%w: role %s is given twice", errors.ErrBadInput, role)
+		}
 	}
 	return nil
 }
@@ -8,19 +8,21 @@ import (
 )
 
 var (
-	ErrBadFormatting  = errors.New("bad format")
-	ErrBadInput       = errors.New("bad input")
-	ErrBadRequest     = errors.New("bad request")
-	ErrBadType        = errors.New("type error")
-	ErrDatabase       = errors.New("database error")
-	ErrForbidden      = errors.New("you are not allowed to perform this action")
-	ErrInvalidToken   = errors.New("invalid authorization token")
-	ErrMissingEnv     = errors.New("missing environment variable")
-	ErrNotFound       = errors.New("could not find resource")
-	ErrNotImplemented = errors.New("not implemented")
-	ErrTimeout        = errors.New("timeout error")
-	ErrUnauthorized   = errors.New("you are not authorized to perform this action")
-	ErrUnexpected     = errors.New("something bad happened")
+	ErrBadFormatting   = errors.New("bad format")
+	ErrBadInput        = errors.New("bad input")
+	ErrBadRequest      = errors.New("bad request")
+	ErrBadType         = errors.New("type error")
+	ErrConflict        = errors.New("conflict")
+	ErrDatabase        = errors.New("database error")
+	ErrForbidden       = errors.New("you are not allowed to perform this action")
+	ErrInvalidToken    = errors.New("invalid authorization token")
+	ErrMissingEnv      = errors.New("missing environment variable")
+	ErrNotFound        = errors.New("could not find resource")
+	ErrNotImplemented  = errors.New("not implemented")
+	ErrTimeout         = errors.New("timeout error")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnauthorized    = errors.New("you are not authorized to perform this action")
+	ErrUnexpected      = errors.New("something bad happened")
 )
 
 func ExpandError(err error) response.HttpResponse[any] {
@@ -47,6 +49,10 @@ func ExpandError(err error) response.HttpResponse[any] {
 		status, desc = http.StatusForbidden, "Forbidden"
 	case ErrNotFound: // 404
 		status, desc = http.StatusNotFound, "Not Found"
+	case ErrConflict: // 409
+		status, desc = http.StatusConflict, "Conflict"
+	case ErrTooManyRequests: // 429
+		status, desc = http.StatusTooManyRequests, "Too Many Requests"
 	case ErrDatabase:
 		fallthrough
 	case ErrMissingEnv:
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/utils"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+API keys of a user, typically a service account that runs automated jobs.
+Keys are managed by admins or by the user itself, with a login and not with
+another key.
+*/
+
+// PostApiKey godoc
+// @Summary      Creates an API key for a user.
+// @Description  Requester needs permission user.manage or be the user, and must be logged in rather than use a key. The roles of the key
+// @Description  are some of the roles of the user, an empty list of datasets gives access to all datasets the user has access to.
+// @Description  The key is only returned in this response, it is sent as a bearer token like an access token.
+// @Tags         users
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param		 userHandle		path string 				true "User Handle"
+// @Param		 requestBody	body entity.ApiKeyCreate 	true "request body"
+// @Success      201  {object}  entity.ApiKeyCreated
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/users/{userHandle}/keys [post]
+func (h UserHandler) PostApiKey(w http.ResponseWriter, r *http.Request) error {
+	if err := validateKeyManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.ApiKeyCreate
+	if err := utils.ParseJsonRequestBody[entity.ApiKeyCreate](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := req.Valid(time.Now()); err != nil {
+		return RenderError(w, err)
+	}
+
+	owner := mux.Vars(r)["userHandle"]
+	roles, err := h.userService.GetUserRoles(owner)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	for _, role := range req.Roles {
+		if !slices.Contains(roles, role) {
+			return RenderError(w, fmt.Errorf("%w: %s does not have role %s", errors.ErrBadInput, owner, role))
+		}
+	}
+
+	key, err := h.tokenService.CreateApiKey(middlewares.RequestActor(r), owner, req)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusCreated, key))
+}
+
+// GetApiKeys godoc
+// @Summary      Gets the API keys of a user.
+// @Description  Requester needs permission user.manage or be the user, and must be logged in rather than use a key. The keys themselves are not returned.
+// @Tags         users
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 userHandle	path string true "User Handle"
+// @Success      200  {object}  []entity.ApiKey
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/users/{userHandle}/keys [get]
+func (h UserHandler) GetApiKeys(w http.ResponseWriter, r *http.Request) error {
+	if err := validateKeyManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	keys, err := h.tokenService.GetApiKeys(mux.Vars(r)["userHandle"])
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, keys))
+}
+
+// DeleteApiKey godoc
+// @Summary      Revokes an API key of a user.
+// @Description  Requester needs permission user.manage or be the user, and must be logged in rather than use a key.
+// @Tags         users
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 userHandle	path string true "User Handle"
+// @Param		 keyId		path string true "Key Id"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/users/{userHandle}/keys/{keyId} [delete]
+func (h UserHandler) DeleteApiKey(w http.ResponseWriter, r *http.Request) error {
+	if err := validateKeyManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	vars := mux.Vars(r)
+	if err := h.tokenService.DeleteApiKey(middlewares.RequestActor(r), vars["userHandle"], vars["keyId"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// checks that the requester can manage the keys of the requested user
+func validateKeyManagement(r *http.Request) error {
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return err
+	}
+	if userToken.ApiKey {
+		return fmt.Errorf("%w: api keys cannot manage api keys", errors.ErrForbidden)
+	}
+
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_MANAGE); err != nil {
+		if err := middlewares.ValidateSelfRequest(r); err != nil {
+			return err
+		}
+	}
+	if isRoot(mux.Vars(r)["userHandle"]) && !middlewares.IsRootRequestor(r) {
+		return errors.ErrForbidden
+	}
+	return nil
+}
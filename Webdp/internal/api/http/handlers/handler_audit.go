@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/services"
+
+	errors "webdp/internal/api/http"
+)
+
+// the columns of the CSV export of the audit log
+var auditCSVHeader = []string{"id", "time", "actor", "organization", "action", "target", "before", "after", "request_id", "prev_hash", "hash"}
+
+/*
+The audit log. Requesters with permission audit.read read the entries of their
+organization, in the default organization the entries of all organizations.
+*/
+type AuditHandler struct {
+	auditService services.AuditService
+}
+
+func NewAuditHandler(auditService services.AuditService) AuditHandler {
+	return AuditHandler{auditService: auditService}
+}
+
+// GetAuditLog godoc
+// @Summary      Gets a page of the audit log, newest entries first.
+// @Description  Requester needs permission audit.read, and gets the entries of its organization. In the default organization
+// @Description  the entries of all organizations are read, or of the one organization given.
+// @Description  The entries record the administrative and privacy-relevant actions, with the values of the target before and after them.
+// @Description  The number of entries that match the filters is returned in the X-Total-Count header.
+// @Tags         audit
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        actor     		query   string  false "only entries of this actor"
+// @Param        action    		query   string  false "only entries of this action, or of this category of actions such as user"
+// @Param        target    		query   string  false "only entries of this target or what is under it, such as dataset/3"
+// @Param        from      		query   string  false "only entries at or after this time, in RFC 3339 format"
+// @Param        to        		query   string  false "only entries before this time, in RFC 3339 format"
+// @Param        organization	query   string  false "only entries of this organization, in the default organization"
+// @Param        limit     		query   int     false "max number of entries, 100 by default and at most 1000"
+// @Param        offset    		query   int     false "number of entries to skip"
+// @Success      200  {object}  []entity.AuditEntry
+// @Header       200  {integer} X-Total-Count "number of entries that match the filters"
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/audit [get]
+func (h AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) error {
+	filter, err := auditFilter(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	page, err := h.auditService.ListAuditEntries(filter)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, page.Entries), "X-Total-Count", strconv.FormatInt(page.Total, 10))
+}
+
+// ExportAuditLog godoc
+// @Summary      Exports the audit log as CSV, oldest entries first.
+// @Description  Requester needs permission audit.read. Takes the filters of GET /v2/audit except limit and offset, all entries that
+// @Description  match are exported. The hashes are exported too, the chain of an export of all entries can be checked without Webdp.
+// @Tags         audit
+// @Security 	 BearerTokenAuth
+// @Produce      text/csv
+// @Param        actor     		query   string  false "only entries of this actor"
+// @Param        action    		query   string  false "only entries of this action, or of this category of actions such as user"
+// @Param        target    		query   string  false "only entries of this target or what is under it, such as dataset/3"
+// @Param        from      		query   string  false "only entries at or after this time, in RFC 3339 format"
+// @Param        to        		query   string  false "only entries before this time, in RFC 3339 format"
+// @Param        organization	query   string  false "only entries of this organization, in the default organization"
+// @Success      200  {string}  string "the entries, with a header row"
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/audit/export [get]
+func (h AuditHandler) ExportAuditLog(w http.ResponseWriter, r *http.Request) error {
+	filter, err := auditFilter(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	// the entries are streamed, the response starts with the first of them
+	writer := csv.NewWriter(w)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set(CONTENT_TYPE, TEXT_CSV)
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+		w.WriteHeader(http.StatusOK)
+		return writer.Write(auditCSVHeader)
+	}
+
+	err = h.auditService.EachAuditEntry(filter, func(e entity.AuditEntry) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write([]string{
+			strconv.FormatInt(e.Id, 10), e.Time.UTC().Format(time.RFC3339Nano), e.Actor, e.Organization, e.Action, e.Target,
+			string(e.Before), string(e.After), e.RequestId, e.PrevHash, e.Hash,
+		})
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil && !started {
+		return RenderError(w, err)
+	}
+	if err != nil {
+		// the status is sent, the export ends early
+		log.Printf("Exporting the audit log: %v", err)
+		return nil
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// VerifyAuditLog godoc
+// @Summary      Checks the hash chain of the audit log.
+// @Description  Requester needs permission audit.read in the default organization. The whole audit log is checked,
+// @Description  broken_at is the id of the first entry that was changed or follows an entry that was removed.
+// @Tags         audit
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  entity.AuditVerification
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/audit/verify [get]
+func (h AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_AUDIT_READ); err != nil {
+		return RenderError(w, err)
+	}
+	if middlewares.RequesterOrganization(r) != entity.DEFAULT_ORGANIZATION {
+		return RenderError(w, fmt.Errorf("%w: the audit log is verified in the default organization", errors.ErrForbidden))
+	}
+
+	verification, err := h.auditService.VerifyAuditLog()
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, verification))
+}
+
+// the filter of a request for the audit log, in the organization that the requester reads
+func auditFilter(r *http.Request) (entity.AuditFilter, error) {
+	if err := middlewares.ValidatePermission(r, entity.PERM_AUDIT_READ); err != nil {
+		return entity.AuditFilter{}, err
+	}
+	query := r.URL.Query()
+	filter, err := entity.NewAuditFilter(query)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Organization = middlewares.RequesterOrganization(r)
+	if filter.Organization == entity.DEFAULT_ORGANIZATION {
+		filter.Organization = query.Get("organization")
+	}
+	return filter, nil
+}
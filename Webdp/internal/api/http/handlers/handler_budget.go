@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/middlewares"
 	"webdp/internal/api/http/response"
@@ -25,7 +26,7 @@ func NewBudgetHandler(bs services.BudgetService, ds services.DatasetService) Bud
 Gets budgets for user.
 Request parameters: User handle.
 Response: List of user budgets (dataset id, allocated, consumed).
-Requester can get own budgets. For others, requester needs curator role.
+Requester can get own budgets. For others, requester needs permission budget.read.
 */
 // GetBudgets godoc
 // @Summary      Get budgets for user
@@ -41,7 +42,7 @@ Requester can get own budgets. For others, requester needs curator role.
 // @Router       /v1/budget/user/{userHandle} [get]
 // @Router       /v2/budgets/users/{userHandle} [get]
 func (h BudgetHandler) GetUserBudgets(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_READ); err != nil {
 		if err := middlewares.ValidateSelfRequest(r); err != nil {
 			return RenderError(w, err)
 		}
@@ -56,11 +57,40 @@ func (h BudgetHandler) GetUserBudgets(w http.ResponseWriter, r *http.Request) er
 	return RenderResponse(w, response.NewSuccess(http.StatusOK, budgets))
 }
 
+/*
+Gets the budgets recorded for purged datasets.
+Response: List of purged datasets with their total, consumed and allocated budgets.
+Requester needs permission budget.history.
+*/
+// GetPurgedBudgets godoc
+// @Summary      Gets the budgets of purged datasets
+// @Description  Gets the budgets that were allocated and consumed on the datasets of the organization of the requester
+// @Description  that were deleted and purged after the retention period
+// @Tags         budgets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  []entity.PurgedDatasetBudget
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/budgets/purged [get]
+func (h BudgetHandler) GetPurgedBudgets(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_HISTORY); err != nil {
+		return RenderError(w, err)
+	}
+
+	budgets, err := h.budgetService.GetPurgedBudgets(middlewares.RequesterOrganization(r))
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, budgets))
+}
+
 /*
 Gets dataset budget for a dataset.
 Request parameters: Dataset id.
 Response: Dataset budget allocations.
-Requester needs curator role, or granted access to a dataset.
+Requester needs permission budget.read, or granted access to a dataset.
 */
 // GetDatasetBudgets godoc
 // @Summary      Gets dataset budget for a dataset
@@ -77,8 +107,8 @@ Requester needs curator role, or granted access to a dataset.
 // @Router       /v1/budget/dataset/{datasetId} [get]
 // @Router       /v2/budgets/datasets/{datasetId} [get]
 func (h BudgetHandler) GetDatasetBudget(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR}); err != nil {
-		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_READ); err != nil {
+		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService, &h.datasetService); err != nil {
 			return RenderError(w, err)
 		}
 	}
@@ -102,7 +132,7 @@ func (h BudgetHandler) GetDatasetBudget(w http.ResponseWriter, r *http.Request)
 Gets budget for user and dataset.
 Request parameters: User handle, dataset id
 Response: Budget for user and dataset.
-Requester can get own budgets. For others, requester needs curator role.
+Requester can get own budgets. For others, requester needs permission budget.read.
 */
 // GetUserDatasetBudget godoc
 // @Summary      Gets user budget on a dataset
@@ -120,7 +150,7 @@ Requester can get own budgets. For others, requester needs curator role.
 // @Router       /v1/budget/allocation/{userHandle}/{datasetId} [get]
 // @Router       /v2/budgets/allocations/{userHandle}/{datasetId} [get]
 func (h BudgetHandler) GetUserDatasetBudget(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_READ); err != nil {
 		if err := middlewares.ValidateSelfRequest(r); err != nil {
 			return RenderError(w, err)
 		}
@@ -145,7 +175,7 @@ func (h BudgetHandler) GetUserDatasetBudget(w http.ResponseWriter, r *http.Reque
 Creates user budget for dataset.
 Request parameters: User handle, dataset id
 Request body: New allocation.
-Requester needs curator or analyst role, and needs to be the owner of the dataset.
+Requester needs permission budget.allocate, and needs to be the owner or a co-curator of the dataset.
 */
 // PostUserDatasetBudget godoc
 // @Summary      Adds a user budget on a dataset
@@ -164,10 +194,10 @@ Requester needs curator or analyst role, and needs to be the owner of the datase
 // @Router       /v1/budget/allocation/{userHandle}/{datasetId} [post]
 // @Router       /v2/budgets/allocations/{userHandle}/{datasetId} [post]
 func (h BudgetHandler) PostUserDatasetBudget(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR, entity.ANALYST}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_ALLOCATE); err != nil {
 		return RenderError(w, err)
 	}
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -182,7 +212,7 @@ func (h BudgetHandler) PostUserDatasetBudget(w http.ResponseWriter, r *http.Requ
 		return RenderError(w, err)
 	}
 
-	if err := h.budgetService.PostUserDatasetBudget(vars["userHandle"], id, addBudget); err != nil {
+	if err := h.budgetService.PostUserDatasetBudget(middlewares.RequestActor(r), vars["userHandle"], id, addBudget); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -193,7 +223,7 @@ func (h BudgetHandler) PostUserDatasetBudget(w http.ResponseWriter, r *http.Requ
 Updates budget for user and dataset.
 Request parameters: User handle, dataset id.
 Request body: New allocation.
-Requester needs to be the owner of the dataset.
+Requester needs to be the owner or a co-curator of the dataset.
 // Note: user needs to have allocated budget (otherwise use post?). TODO: Assess if this needs intervention.
 */
 // PatchUserDatasetBudget godoc
@@ -213,7 +243,7 @@ Requester needs to be the owner of the dataset.
 // @Router       /v1/budget/allocation/{userHandle}/{datasetId} [patch]
 // @Router       /v2/budgets/allocations/{userHandle}/{datasetId} [patch]
 func (h BudgetHandler) PatchUserDatasetBudget(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -228,7 +258,7 @@ func (h BudgetHandler) PatchUserDatasetBudget(w http.ResponseWriter, r *http.Req
 		return RenderError(w, err)
 	}
 
-	if err := h.budgetService.PatchUserDatasetBudget(vars["userHandle"], id, patchBudget); err != nil {
+	if err := h.budgetService.PatchUserDatasetBudget(middlewares.RequestActor(r), vars["userHandle"], id, patchBudget); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -238,7 +268,7 @@ func (h BudgetHandler) PatchUserDatasetBudget(w http.ResponseWriter, r *http.Req
 /*
 Deletes budget for user and dataset.
 Request parameters: User handle, dataset id.
-Requester needs to be the owner of the dataset.
+Requester needs to be the owner or a co-curator of the dataset.
 */
 // DeleteUserDatasetBudget godoc
 // @Summary      Deletes budget for user and dataset.
@@ -256,7 +286,97 @@ Requester needs to be the owner of the dataset.
 // @Router       /v1/budget/allocation/{userHandle}/{datasetId} [delete]
 // @Router       /v2/budgets/allocations/{userHandle}/{datasetId} [delete]
 func (h BudgetHandler) DeleteUserDatasetBudget(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.budgetService.DeleteUserDatasetBudget(middlewares.RequestActor(r), vars["userHandle"], id); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+/*
+Gets the daily quota of queries of a user on a dataset.
+Request parameters: User handle, dataset id.
+Response: The quota of the allocation, the queries made today and when they reset.
+Requester can get own quotas. For others, requester needs permission budget.read.
+*/
+// GetQueryQuota godoc
+// @Summary      Gets the query quota of a user on a dataset
+// @Description  Gets the queries per day the user can make on the dataset, and the queries made today.
+// @Description  daily_queries is the quota set on the allocation, null if it has the default quota; limit is the quota that applies, 0 is no quota.
+// @Tags         budgets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        userHandle   path      string  true  "User Handle"
+// @Param        datasetId   path      int  true  "Dataset Id"
+// @Success      200  {object}  entity.QueryQuota
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/budgets/allocations/{userHandle}/{datasetId}/quota [get]
+func (h BudgetHandler) GetQueryQuota(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_BUDGET_READ); err != nil {
+		if err := middlewares.ValidateSelfRequest(r); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	quota, err := h.budgetService.GetQueryQuota(vars["userHandle"], id, time.Now())
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, quota))
+}
+
+/*
+Sets the daily quota of queries of a user on a dataset.
+Request parameters: User handle, dataset id.
+Request body: The quota, null for the default quota.
+Requester needs to be the owner or a co-curator of the dataset.
+*/
+// PutQueryQuota godoc
+// @Summary      Sets the query quota of a user on a dataset
+// @Description  Sets the queries per day the user can make on the dataset it has a budget on. Evaluated and validated queries count.
+// @Description  A daily_queries of 0 is no quota, null gives the allocation the default quota of the configuration.
+// @Tags         budgets
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Param        userHandle   path      string  true  "User Handle"
+// @Param        datasetId   path      int  true  "Dataset Id"
+// @Param		 requestBody	body   entity.QueryQuotaPut  true  "request body"
+// @Success      204
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/budgets/allocations/{userHandle}/{datasetId}/quota [put]
+func (h BudgetHandler) PutQueryQuota(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	var put entity.QueryQuotaPut
+	if err := utils.ParseJsonRequestBody[entity.QueryQuotaPut](r, &put); err != nil {
+		return RenderError(w, err)
+	}
+	if err := put.Valid(); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -266,7 +386,7 @@ func (h BudgetHandler) DeleteUserDatasetBudget(w http.ResponseWriter, r *http.Re
 		return RenderError(w, err)
 	}
 
-	if err := h.budgetService.DeleteUserDatasetBudget(vars["userHandle"], id); err != nil {
+	if err := h.budgetService.SetQueryQuota(middlewares.RequestActor(r), vars["userHandle"], id, put); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -1,13 +1,12 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/csv"
 	"fmt"
 	"io"
 	"net/http"
-	"sort"
+	"os"
 	"strconv"
+	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/client"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/middlewares"
@@ -32,45 +31,76 @@ func NewDatasetHandler(ds services.DatasetService, us services.UserService, bs s
 }
 
 /*
-Gets all datasets which requester has access to.
-Requester needs role admin or curator, or needs granted access via budget allocation.
-* TODO: get only those to which the requester has granted access!
+Gets a page of the datasets which requester has access to.
+Requester needs permission dataset.read, or needs granted access via budget allocation.
 */
 // GetDatasets godoc
-// @Summary      Gets all datasets which requester has access to.
-// @Description  Requester needs role admin or curator, or needs granted access via budget allocation.
+// @Summary      Gets a page of the datasets which requester has access to.
+// @Description  Only the datasets of the organization of the requester are listed.
+// @Description  Requester needs permission dataset.read, or only gets the datasets with granted access via budget allocation.
+// @Description  The number of datasets that match the filters is returned in the X-Total-Count header.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
 // @Accept       json
 // @Produce      json
+// @Param        owner     		query   string  false "only datasets of this owner"
+// @Param        loaded    		query   bool    false "only datasets with or without data"
+// @Param        privacy_notion query   string  false "only datasets with this privacy notion"
+// @Param        tag       		query   string  false "only datasets with this tag"
+// @Param        search    		query   string  false "only datasets with this in their name, regardless of case"
+// @Param        sort      		query   string  false "id (default), name, created_time, updated_time or loaded_time"
+// @Param        order     		query   string  false "asc (default) or desc"
+// @Param        limit     		query   int     false "max number of datasets, 100 by default and at most 1000"
+// @Param        offset    		query   int     false "number of datasets to skip"
+// @Param        deleted   		query   bool    false "only deleted datasets that can still be restored, which requesters without permission dataset.read only get for their own datasets"
 // @Success      200  {object}  []entity.DatasetInfo
+// @Header       200  {integer} X-Total-Count "number of datasets that match the filters"
+// @Failure      400  {object}  response.Error
 // @Failure      403  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/datasets [get]
 // @Router       /v2/datasets [get]
 func (h DatasetHandler) GetDatasets(w http.ResponseWriter, r *http.Request) error {
-	datasets, err := h.datasetService.GetAllDatasets()
+	filter, err := entity.NewDatasetFilter(r.URL.Query())
 	if err != nil {
 		return RenderError(w, err)
 	}
 
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN, entity.CURATOR}); err != nil {
-		err := middlewares.FilterGrantedAccess(r, &h.budgetService, &datasets)
-		if err != nil || len(datasets) == 0 {
-			return RenderError(w, err)
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	filter.Ids = userToken.Datasets
+	filter.Organization = middlewares.RequesterOrganization(r)
+
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_READ); err != nil {
+		filter.GrantedTo = userToken.Handle
+		// members of a deleted dataset have no access to it, only its owner can restore it
+		if filter.Deleted {
+			filter.Owner = userToken.Handle
 		}
 	}
 
-	return RenderResponse(w, response.NewSuccess(http.StatusOK, datasets))
+	page, err := h.datasetService.ListDatasets(filter)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	// users without access to any dataset are not allowed to list them
+	if filter.GrantedTo != "" && page.Total == 0 && !filter.Filters() {
+		return RenderError(w, fmt.Errorf("%w: no access to datasets", errors.ErrForbidden))
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, page.Datasets), "X-Total-Count", strconv.FormatInt(page.Total, 10))
 }
 
 /*
 Gets a dataset.
-Requester needs role admin or curator, or needs granted access via budget allocation.
+Requester needs permission dataset.read, or needs granted access via budget allocation.
 */
 // GetDataset godoc
 // @Summary      Gets all datasets which requester has access to.
-// @Description  Requester needs role admin or curator, or needs granted access via budget allocation.
+// @Description  Requester needs permission dataset.read, or needs granted access via budget allocation.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -82,8 +112,8 @@ Requester needs role admin or curator, or needs granted access via budget alloca
 // @Router       /v1/dataset/{datasetId} [get]
 // @Router       /v2/datasets/{datasetId} [get]
 func (h DatasetHandler) GetDataset(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN, entity.CURATOR}); err != nil {
-		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_READ); err != nil {
+		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService, &h.datasetService); err != nil {
 			return RenderError(w, err)
 		}
 	}
@@ -101,12 +131,13 @@ func (h DatasetHandler) GetDataset(w http.ResponseWriter, r *http.Request) error
 }
 
 /*
-Creates a dataset.
-Requester needs role curator. New owner of dataset needs role curator.
+Creates a dataset in the organization of the requester.
+Requester and new owner of dataset need permission dataset.create.
 */
 // PostDataset godoc
 // @Summary      Creates a dataset.
-// @Description  Requester needs role curator. New owner of dataset needs role curator.
+// @Description  Requester and new owner of dataset need permission dataset.create, and the owner has to be in the organization of the requester.
+// @Description  The total budgets of the datasets of the organization cannot exceed its budget cap.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -114,14 +145,15 @@ Requester needs role curator. New owner of dataset needs role curator.
 // @Param		 requestBody	body   entity.DatasetCreate  true  "request body"
 // @Success      201  {object}  response.Id
 // @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/datasets [post]
 // @Router       /v2/datasets [post]
 func (h DatasetHandler) PostDataset(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_CREATE); err != nil {
 		return RenderError(w, err)
 	}
-	if err := middlewares.ValidateNewOwnerRole(r, &h.userService, entity.CURATOR); err != nil {
+	if err := middlewares.ValidateNewOwnerPermission(r, &h.userService, entity.PERM_DATASET_CREATE); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -129,8 +161,11 @@ func (h DatasetHandler) PostDataset(w http.ResponseWriter, r *http.Request) erro
 	if err := utils.ParseJsonRequestBody[entity.DatasetCreate](r, &createDataset); err != nil {
 		return RenderError(w, err)
 	}
+	if err := createDataset.Valid(); err != nil {
+		return RenderError(w, err)
+	}
 
-	res, err := h.datasetService.CreateDataset(createDataset)
+	res, err := h.datasetService.CreateDataset(middlewares.RequestActor(r), createDataset)
 	if err != nil {
 		return RenderError(w, err)
 	}
@@ -139,9 +174,107 @@ func (h DatasetHandler) PostDataset(w http.ResponseWriter, r *http.Request) erro
 	return RenderResponse(w, response.NewSuccess(http.StatusCreated, resp))
 }
 
+/*
+Proposes a schema for a new dataset from a sample of its data.
+Requester needs permission dataset.create.
+*/
+// InferSchema godoc
+// @Summary      Proposes a schema from a sample of the data.
+// @Description  Requester needs permission dataset.create. Reads a CSV (with a header) or Parquet sample, which may be gzip compressed,
+// @Description  and proposes Int, Double, Bool, Enum or Text for every column. The response has the fields of the body of
+// @Description  POST /v2/datasets. Bounds taken from the sample are widened to round numbers and marked with confirm_bounds,
+// @Description  since they tell something about the rows of the sample; the curator should confirm or replace them.
+// @Description  Enum labels are marked the same way, labels that occur fewer than 5 times are left out and counted in rare_labels.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Accept       plain
+// @Accept       octet-stream
+// @Produce      json
+// @Param		 data 			body	string	true  "CSV or Parquet sample"
+// @Param        format     	query   string  false "csv or parquet, overrides the content type"
+// @Param        rows       	query   int     false "max number of rows read from the sample, 10000 by default"
+// @Param        name       	query   string  false "name of the dataset"
+// @Param        privacy_notion query   string  false "PureDP (default) or ApproxDP"
+// @Param        epsilon    	query   number  false "epsilon of the total budget"
+// @Param        delta      	query   number  false "delta of the total budget"
+// @Success      200  {object}  entity.SchemaProposal
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Router       /v2/datasets/infer-schema [post]
+func (h DatasetHandler) InferSchema(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_CREATE); err != nil {
+		return RenderError(w, err)
+	}
+
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	query := r.URL.Query()
+	proposal := entity.SchemaProposal{Name: query.Get("name"), Owner: userToken.Handle, PrivacyNotion: query.Get("privacy_notion")}
+	if proposal.PrivacyNotion == "" {
+		proposal.PrivacyNotion = entity.PURE
+	}
+	if proposal.PrivacyNotion != entity.PURE && proposal.PrivacyNotion != entity.APPROX {
+		return RenderError(w, fmt.Errorf("%w: privacy notion should be either \"%s\" or \"%s\"", errors.ErrBadInput, entity.PURE, entity.APPROX))
+	}
+
+	if query.Has("epsilon") || query.Has("delta") {
+		var budget entity.Budget
+		var err error
+		if budget.Epsilon, err = strconv.ParseFloat(query.Get("epsilon"), 64); err != nil {
+			return RenderError(w, fmt.Errorf("%w: epsilon should be a number", errors.ErrBadInput))
+		}
+		if query.Has("delta") {
+			delta, err := strconv.ParseFloat(query.Get("delta"), 64)
+			if err != nil {
+				return RenderError(w, fmt.Errorf("%w: delta should be a number", errors.ErrBadInput))
+			}
+			budget.Delta = &delta
+		}
+		if err := budget.Valid(); err != nil {
+			return RenderError(w, err)
+		}
+		proposal.TotalBudget = &budget
+	}
+
+	rows := entity.DEFAULT_SAMPLE_ROWS
+	if query.Has("rows") {
+		n, err := strconv.Atoi(query.Get("rows"))
+		if err != nil || n <= 0 || n > entity.MAX_SAMPLE_ROWS {
+			return RenderError(w, fmt.Errorf("%w: rows should be a number between 1 and %d", errors.ErrBadInput, entity.MAX_SAMPLE_ROWS))
+		}
+		rows = n
+	}
+
+	format, err := entity.UploadFormat(query.Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		return RenderError(w, err)
+	}
+	// JSON Lines are read by the column names of a schema
+	if format == entity.FORMAT_JSONL {
+		return RenderError(w, fmt.Errorf("%w: schemas can only be inferred from CSV or Parquet", errors.ErrBadInput))
+	}
+
+	reader, err := openUpload(format, r.Body, nil)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	defer reader.Close()
+
+	if proposal.Schema, proposal.SampleRows, err = entity.InferSchema(reader, rows); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, proposal))
+}
+
 /*
 Update a dataset.
-Requester needs to be curator and owner of the dataset. New owner can have any role.
+Requester needs permission dataset.update, and to be owner or co-curator of the dataset. New owner can have any role.
+A new owner is only proposed, only the owner can propose one and the new owner has to accept the
+transfer before the ownership changes hands.
   - Allowing analysts to own datasets will disallow further patches and gets; only
   	dataset deletion, data uploads and budget allocation on the dataset is allowed
 	from that point on.
@@ -151,26 +284,29 @@ Requester needs to be curator and owner of the dataset. New owner can have any r
 */
 // PatchDataset godoc
 // @Summary      Update a dataset.
-// @Description  Update name, owner or total budget of a dataset.
-// @Description  Requester needs to be curator and owner of the dataset.
+// @Description  Update name, owner, total budget or metadata of a dataset. Metadata that is left out is kept.
+// @Description  Requester needs permission dataset.update, and to be owner or co-curator of the dataset.
+// @Description  A different owner is proposed as an ownership transfer, which only the owner can do and which the new owner has to accept.
+// @Description  The total budgets of the datasets of the organization cannot exceed its budget cap.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
 // @Accept       json
 // @Produce      json
 // @Param        datasetId   	path   int 			  true  "Dataset Id"
-// @Param		 requestBody	body   entity.DatasetCreate  true  "request body"
+// @Param		 requestBody	body   entity.DatasetPatch  true  "request body"
 // @Success      204
 // @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/dataset/{datasetId} [patch]
 // @Router       /v2/datasets/{datasetId} [patch]
 func (h DatasetHandler) PatchDataset(w http.ResponseWriter, r *http.Request) error {
 	// Checks are a bit different in the proof-of-concept.
 	// Compare: "is curator, is owner" vs "is owner, owner is curator"
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_UPDATE); err != nil {
 		return RenderError(w, err)
 	}
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -184,20 +320,43 @@ func (h DatasetHandler) PatchDataset(w http.ResponseWriter, r *http.Request) err
 	if err := utils.ParseJsonRequestBody[entity.DatasetPatch](r, &patch); err != nil {
 		return RenderError(w, err)
 	}
+	if err := patch.Valid(); err != nil {
+		return RenderError(w, err)
+	}
 
-	if err := h.datasetService.UpdateDataset(id, patch); err != nil {
+	dataset, err := h.datasetService.GetDataset(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	transfer := patch.Owner != dataset.Owner
+	if transfer {
+		if err := h.validateTransfer(r, patch.Owner); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	if err := h.datasetService.UpdateDataset(middlewares.RequestActor(r), id, patch); err != nil {
 		return RenderError(w, err)
 	}
 
+	if transfer {
+		if _, err := h.datasetService.ProposeTransfer(id, dataset.Owner, patch.Owner); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
 	return RenderResponse(w, response.NoContent())
 }
 
 /*
 Delete a dataset. Requester needs to be the owner of the dataset.
+The dataset is hidden and can be restored until it is purged after the retention period.
 */
 // DeleteDataset godoc
 // @Summary      Delete a dataset.
-// @Description  Requester needs to be the owner of the dataset.
+// @Description  Requester needs to be the owner of the dataset. The dataset can no longer be read or queried, but can be
+// @Description  restored until the retention period has passed. The dataset and its data are then purged, only a record
+// @Description  of the budgets spent on it is kept.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -209,7 +368,7 @@ Delete a dataset. Requester needs to be the owner of the dataset.
 // @Router       /v1/dataset/{datasetId} [delete]
 // @Router       /v2/datasets/{datasetId} [delete]
 func (h DatasetHandler) DeleteDataset(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -219,7 +378,7 @@ func (h DatasetHandler) DeleteDataset(w http.ResponseWriter, r *http.Request) er
 		return RenderError(w, err)
 	}
 
-	if err := h.datasetService.DeleteDataset(id); err != nil {
+	if err := h.datasetService.DeleteDataset(middlewares.RequestActor(r), id); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -229,34 +388,106 @@ func (h DatasetHandler) DeleteDataset(w http.ResponseWriter, r *http.Request) er
 }
 
 /*
-Upload a dataset. Requester needs to be the owner of the dataset.
+Restores a deleted dataset that is not purged yet.
+Requester needs to be the owner of the dataset or have permission dataset.restore.
+*/
+// RestoreDataset godoc
+// @Summary      Restores a deleted dataset.
+// @Description  Requester needs to be the owner of the dataset or have permission dataset.restore. The dataset, its data, members and
+// @Description  budgets are as they were before it was deleted.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId   	path   int 			  true  "Dataset Id"
+// @Success      200  {object}  entity.DatasetInfo
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/restore [post]
+func (h DatasetHandler) RestoreDataset(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	dataset, err := h.datasetService.GetDeletedDataset(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	if dataset.Owner != userToken.Handle {
+		if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_RESTORE); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	if err := h.datasetService.RestoreDataset(middlewares.RequestActor(r), id); err != nil {
+		return RenderError(w, err)
+	}
+
+	dataset, err = h.datasetService.GetDataset(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, dataset))
+}
+
+/*
+Upload a dataset. Requester needs to be the owner or a co-curator of the dataset.
 */
 // UploadDataset godoc
 // @Summary      Upload a dataset.
-// @Description  Requester needs to be the owner of the dataset.
+// @Description  Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset.
+// @Description  Every cell is checked against the schema. Invalid uploads are rejected with the first invalid cells,
+// @Description  unless invalid=clamp or invalid=drop is given, in which case a report of the upload is returned.
+// @Description  The data can be CSV with a header, JSON Lines or Parquet, chosen by the format parameter or the
+// @Description  content type (CSV by default), and may be gzip compressed. Columns are matched to the schema by name.
+// @Description  Every upload creates a new version of the data which replaces the data or is appended to it.
+// @Description  Consumed budgets are kept unless budget=reset is given with mode=replace, which drops the older versions.
 // @Tags         datasets
 // @Security 	 BearerTokenAuth
+// @Accept       plain
 // @Accept       json
+// @Accept       octet-stream
 // @Produce      json
-// @Param		 csvData 	body	string	true  "CSV Data"
+// @Param		 data 		body	string	true  "CSV, JSON Lines or Parquet data"
 // @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        format     query   string  false "csv, jsonl or parquet, overrides the content type"
+// @Param        mode       query   string  false "replace (default) or append to the latest version"
+// @Param        budget     query   string  false "keep (default) or reset the consumed budgets of the dataset, reset needs mode=replace"
+// @Param        invalid    query   string  false "what to do with cells that do not fit the schema: reject (default), clamp or drop"
+// @Success      200  {object}  entity.UploadReport
 // @Success      204
+// @Failure      400  {object}  response.Error
 // @Failure      403  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/dataset/{datasetId}/upload [post]
 // @Router       /v2/datasets/{datasetId}/upload [post]
 func (h DatasetHandler) UploadData(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateOwnership(r, &h.datasetService); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_UPLOAD); err != nil {
+		return RenderError(w, err)
+	}
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
 		return RenderError(w, err)
 	}
 
 	vars := mux.Vars(r)
-	x, err := io.ReadAll(r.Body)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
 	if err != nil {
 		return RenderError(w, err)
 	}
 
-	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	format, err := entity.UploadFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	opts, err := entity.NewUploadOptions(r.URL.Query().Get("mode"), r.URL.Query().Get("budget"))
 	if err != nil {
 		return RenderError(w, err)
 	}
@@ -267,62 +498,272 @@ func (h DatasetHandler) UploadData(w http.ResponseWriter, r *http.Request) error
 		return RenderError(w, err)
 	}
 
-	reader := csv.NewReader(bytes.NewReader(x))
+	report, err := h.storeUpload(dataset, r.Body, format, r.URL.Query().Get("invalid"), opts)
 
-	records, err := reader.ReadAll()
+	if err != nil {
+		return RenderError(w, err)
+	}
 
+	if r.URL.Query().Get("invalid") != "" {
+		return RenderResponse(w, response.NewSuccess(http.StatusOK, report))
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+/*
+Gets the versions of the data of a dataset.
+Requester needs permission dataset.read, or needs granted access via budget allocation.
+*/
+// GetDataVersions godoc
+// @Summary      Gets the versions of the data of a dataset.
+// @Description  Every upload creates a version. Queries use the latest version unless they pin one.
+// @Description  Requester needs permission dataset.read, or needs granted access via budget allocation.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      200  {object}  []entity.DataVersion
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/versions [get]
+func (h DatasetHandler) GetDataVersions(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_READ); err != nil {
+		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService, &h.datasetService); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
 	if err != nil {
 		return RenderError(w, err)
 	}
 
-	if !validateUploadedDataset(dataset, records) {
-		return RenderError(w, fmt.Errorf("uploaded data does not fit the dataset schema"))
+	if _, err := h.datasetService.GetDataset(id); err != nil {
+		return RenderError(w, err)
 	}
 
-	if err := h.datasetService.UploadData(id, x); err != nil {
+	versions, err := h.datasetService.GetDataVersions(id)
+	if err != nil {
 		return RenderError(w, err)
 	}
 
-	return RenderResponse(w, response.NoContent())
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, versions))
 }
 
-func validateUploadedDataset(dataset entity.DatasetInfo, uploadedData [][]string) bool {
-	colNames := getColNamesFromDataset(dataset)
+/*
+Changes the schema of a dataset. Requester needs permission dataset.update, and to be owner or co-curator of the dataset.
+*/
+// PatchSchema godoc
+// @Summary      Changes the schema of a dataset.
+// @Description  Requester needs permission dataset.update, and to be owner or co-curator of the dataset. Columns are dropped, then updated and then added
+// @Description  after the remaining columns. An update changes bounds or labels but not the type of a column.
+// @Description  If data is loaded, its latest version is rewritten to the new schema as a new version with mode schema;
+// @Description  added columns need a default value and every value is checked against the new schema, invalid values are
+// @Description  handled like in uploads. Queries cannot pin versions from before the change. Consumed budgets are kept.
+// @Description  The change is recorded in the audit trail of the schema.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param        datasetId  	path   	int 				true  "Dataset Id"
+// @Param		 requestBody	body   	entity.SchemaPatch  true  "request body"
+// @Param        invalid    	query   string  			false "what to do with loaded values that do not fit the new schema: reject (default), clamp or drop"
+// @Success      200  {object}  entity.SchemaChange
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/schema [patch]
+func (h DatasetHandler) PatchSchema(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_UPDATE); err != nil {
+		return RenderError(w, err)
+	}
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	var patch entity.SchemaPatch
+	if err := utils.ParseJsonRequestBody[entity.SchemaPatch](r, &patch); err != nil {
+		return RenderError(w, err)
+	}
+	if err := patch.Valid(); err != nil {
+		return RenderError(w, err)
+	}
 
-	if len(uploadedData) < 1 {
-		return false
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
 	}
-	csvColumns := uploadedData[0]
 
-	return compareSchemaWithUploaded(colNames, csvColumns)
+	dataset, err := h.datasetService.GetDataset(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	schema, err := patch.Apply(dataset.Schema)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	change := entity.SchemaChange{Dataset: id, ChangedBy: userToken.Handle, Patch: patch, OldSchema: dataset.Schema, NewSchema: schema}
+	change, err = h.datasetService.ChangeSchema(change, r.URL.Query().Get("invalid"))
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	h.dpClient.RemoveDatasetFromEngineCache(id)
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, change))
 }
 
-func getColNamesFromDataset(dataset entity.DatasetInfo) []string {
-	var cols []string
-	for _, colSchema := range dataset.Schema {
-		cols = append(cols, colSchema.Name)
+/*
+Gets the audit trail of the schema of a dataset.
+Requester needs permission dataset.read, or needs granted access via budget allocation.
+*/
+// GetSchemaChanges godoc
+// @Summary      Gets the audit trail of the schema of a dataset.
+// @Description  Requester needs permission dataset.read, or needs granted access via budget allocation.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      200  {object}  []entity.SchemaChange
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/schema/changes [get]
+func (h DatasetHandler) GetSchemaChanges(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_READ); err != nil {
+		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService, &h.datasetService); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if _, err := h.datasetService.GetDataset(id); err != nil {
+		return RenderError(w, err)
+	}
+
+	changes, err := h.datasetService.GetSchemaChanges(id)
+	if err != nil {
+		return RenderError(w, err)
 	}
 
-	return cols
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, changes))
 }
 
-func compareSchemaWithUploaded(schemaCols []string, uploadedCols []string) bool {
-	if len(schemaCols) != len(uploadedCols) {
-		return false
+/*
+Reads the upload one row at a time, checks every cell against the dataset
+schema and stores the accepted rows as CSV in a new version of the data.
+Nothing is stored if the upload is rejected. Engines drop their cached copy
+of the dataset once the new version is stored.
+*/
+func (h DatasetHandler) storeUpload(dataset entity.DatasetInfo, body io.Reader, format string, invalid string, opts entity.UploadOptions) (entity.UploadReport, error) {
+	reader, err := openUpload(format, body, dataset.Schema)
+	if err != nil {
+		return entity.UploadReport{}, err
 	}
+	defer reader.Close()
 
-	c1 := make([]string, len(schemaCols))
-	c2 := make([]string, len(uploadedCols))
-	copy(c1, schemaCols)
-	copy(c2, uploadedCols)
+	validator, err := entity.NewUploadValidator(dataset.Schema, reader.Header(), invalid)
+	if err != nil {
+		return entity.UploadReport{}, err
+	}
 
-	sort.Strings(c1)
-	sort.Strings(c2)
+	headerLine, err := entity.EncodeCSVLine(validator.Header())
+	if err != nil {
+		return entity.UploadReport{}, err
+	}
 
-	for i := range c1 {
-		if c1[i] != c2[i] {
-			return false
+	next := func() (string, error) {
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				if err := validator.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			if err != nil {
+				return "", err
+			}
+
+			if row, ok := validator.Row(record); ok {
+				return entity.EncodeCSVLine(row)
+			}
 		}
 	}
-	return true
+
+	version, err := h.datasetService.StoreData(dataset.Id, headerLine, opts, next)
+	if err != nil {
+		return entity.UploadReport{}, err
+	}
+
+	h.dpClient.RemoveDatasetFromEngineCache(dataset.Id)
+
+	report := validator.Report()
+	report.Version = version
+	return report, nil
+}
+
+/*
+Opens the records of an upload. Parquet files are read from their footer, so
+they are first decompressed into a temporary file which is removed on close.
+*/
+func openUpload(format string, body io.Reader, schema []entity.ColumnSchema) (entity.RecordReader, error) {
+	if format != entity.FORMAT_PARQUET {
+		return entity.NewRecordReader(format, body, schema)
+	}
+
+	file, err := os.CreateTemp("", "webdp-upload-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
+	}
+	removeFile := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	decompressed, err := entity.Decompress(body)
+	if err != nil {
+		removeFile()
+		return nil, err
+	}
+	_, err = io.Copy(file, decompressed)
+	decompressed.Close()
+	if err != nil {
+		removeFile()
+		return nil, fmt.Errorf("%w: %s", errors.ErrBadFormatting, err.Error())
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		removeFile()
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
+	}
+
+	reader, err := entity.NewRecordReader(format, file, schema)
+	if err != nil {
+		removeFile()
+		return nil, err
+	}
+	return tempFileReader{reader, removeFile}, nil
+}
+
+// also removes the temporary file of the upload
+type tempFileReader struct {
+	entity.RecordReader
+	remove func()
+}
+
+func (t tempFileReader) Close() error {
+	err := t.RecordReader.Close()
+	t.remove()
+	return err
 }
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"bufio"
+	"fmt"
 	"net/http"
 	"strconv"
+	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/services"
 
 	"github.com/gorilla/mux"
@@ -28,12 +31,37 @@ func (dh InternalDatasetHandler) GetDataset(w http.ResponseWriter, r *http.Reque
 		return RenderError(w, err)
 	}
 
-	res, err := dh.dataService.GetTable(id)
+	// the latest version unless the query pins one
+	var version int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return RenderError(w, fmt.Errorf("%w: invalid version", errors.ErrBadInput))
+		}
+	}
 
-	if err != nil {
+	// the response is streamed, so errors can only be rendered before the first line
+	started := false
+	bw := bufio.NewWriterSize(w, 64*1024)
+
+	err = dh.dataService.StreamTable(id, version, func(line string) error {
+		if !started {
+			w.Header().Add(CONTENT_TYPE, TEXT_CSV)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if _, err := bw.WriteString(line); err != nil {
+			return err
+		}
+		return bw.WriteByte('\n')
+	})
+
+	if err != nil && !started {
 		return RenderError(w, err)
 	}
+	if err != nil {
+		// abort the connection so the engine does not take a truncated table for the dataset
+		panic(http.ErrAbortHandler)
+	}
 
-	return RenderCSVResponse(w, http.StatusOK, res, "Content-Type", "text/csv")
-
+	return bw.Flush()
 }
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
-	errors "webdp/internal/api/http"
+	"strconv"
+	"time"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/middlewares"
 	"webdp/internal/api/http/response"
 	"webdp/internal/api/http/services"
 	"webdp/internal/api/http/utils"
+
+	"github.com/gorilla/mux"
 )
 
 type LoginHandler struct {
@@ -15,9 +19,6 @@ type LoginHandler struct {
 	tokenService services.TokenService
 }
 
-type JWTAccessToken = string
-type TokenResponse = map[string]JWTAccessToken
-
 func NewLoginHandler(userService services.UserService, tokenService services.TokenService) LoginHandler {
 	return LoginHandler{userService: userService, tokenService: tokenService}
 }
@@ -26,7 +27,10 @@ func NewLoginHandler(userService services.UserService, tokenService services.Tok
 // we could refactor some parsing for nices error handling
 // Login godoc
 // @Summary      Login User
-// @Description  Login user with user/password credentials.
+// @Description  Login user with user/password credentials. Every login starts a new session, labelled with the optional device.
+// @Description  The access token is used until it expires at expires_at, the refresh token then gets a new pair of tokens.
+// @Description  After too many failed logins the account, or the address the logins come from, is locked for a while.
+// @Description  Logins are then refused with 429 and a Retry-After header, without checking the password.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -34,6 +38,7 @@ func NewLoginHandler(userService services.UserService, tokenService services.Tok
 // @Success      200  {object}  response.Token
 // @Failure      400  {object}  response.Error
 // @Failure      401  {object}  response.Error
+// @Failure      429  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/login [post]
 // @Router       /v2/login [post]
@@ -49,8 +54,12 @@ func (lh LoginHandler) LoginRequestHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// validate credentials
-	if !lh.userService.CompareHashedPlain(loginRequest) {
-		return RenderError(w, errors.ErrUnauthorized)
+	requestId := middlewares.GetRequestId(r)
+	if until, err := lh.userService.Authenticate(loginRequest, clientAddress(r), requestId); err != nil {
+		if !until.IsZero() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+		}
+		return RenderError(w, err)
 	}
 
 	// retrieve user to make token
@@ -60,20 +69,61 @@ func (lh LoginHandler) LoginRequestHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// make token
-	token, err := lh.tokenService.IssueNewTokenFor(user.Handle, user.Roles)
+	actor := entity.Actor{Handle: user.Handle, Organization: user.Organization, RequestId: requestId}
+	tokens, err := lh.tokenService.IssueNewTokenFor(actor, user.Roles, loginRequest.Device)
 	if err != nil {
 		return RenderError(w, err)
 	}
-	tokenResponse := make(map[string]JWTAccessToken)
-	tokenResponse["jwt"] = token
 
 	// all ok - return token
-	return RenderResponse(w, response.NewSuccess(http.StatusOK, tokenResponse))
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, response.Token{Token: tokens.Access, RefreshToken: tokens.Refresh, ExpiresAt: tokens.ExpiresAt}))
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  Gets a new access token and a new refresh token for the session of the refresh token. A refresh token can
+// @Description  only be used once, the session is revoked if it is used again.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param		 refreshRequest 	body	entity.RefreshRequest	true  "Refresh Request"
+// @Success      200  {object}  response.Token
+// @Failure      400  {object}  response.Error
+// @Failure      401  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v1/refresh [post]
+// @Router       /v2/refresh [post]
+func (lh LoginHandler) RefreshRequestHandler(w http.ResponseWriter, r *http.Request) error {
+	var req entity.RefreshRequest
+	if err := utils.ParseJsonRequestBody[entity.RefreshRequest](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := utils.ValidateNonEmptyString(req); err != nil {
+		return RenderError(w, err)
+	}
+
+	session, refresh, err := lh.tokenService.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	// the roles of the user may have changed since the last token
+	user, err := lh.userService.GetUser(session.Handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	access, expires, err := lh.tokenService.IssueAccessToken(session, user.Roles)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, response.Token{Token: access, RefreshToken: refresh, ExpiresAt: expires}))
 }
 
 // logout godoc
 // @Summary      Logout User
-// @Description  Logout user from session.
+// @Description  Logout user from the current session, other sessions of the user stay logged in.
 // @Tags         auth
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -84,12 +134,83 @@ func (lh LoginHandler) LoginRequestHandler(w http.ResponseWriter, r *http.Reques
 // @Router       /v1/logout [post]
 // @Router       /v2/logout [post]
 func (lh LoginHandler) LogoutRequestHandler(w http.ResponseWriter, r *http.Request) error {
-	var userToken services.JWTTokenClaims
-	if _, err := middlewares.ExtracAuthnHeader[*services.JWTTokenClaims](r.Header, &userToken); err != nil {
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
 		return RenderError(w, err)
 	}
-	if err := lh.tokenService.LogOffUser(userToken.Handle); err != nil {
+	if err := lh.tokenService.RevokeSession(userToken.Handle, userToken.Id); err != nil {
 		return RenderError(w, err)
 	}
 	return RenderResponse(w, response.NoContent())
 }
+
+// GetSessions godoc
+// @Summary      Get own sessions
+// @Description  Gets the sessions the requester is logged in with, the session of the request is marked as current.
+// @Tags         auth
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  []entity.Session
+// @Failure      400  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/sessions [get]
+func (lh LoginHandler) GetSessions(w http.ResponseWriter, r *http.Request) error {
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	sessions, err := lh.tokenService.GetSessions(userToken.Handle, userToken.Id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, sessions))
+}
+
+// DeleteSession godoc
+// @Summary      Revoke own session
+// @Description  Revokes a session of the requester, its access and refresh tokens can no longer be used.
+// @Tags         auth
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        sessionId  path    string 	true  "Session Id"
+// @Success      204
+// @Failure      400  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/sessions/{sessionId} [delete]
+func (lh LoginHandler) DeleteSession(w http.ResponseWriter, r *http.Request) error {
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := lh.tokenService.RevokeSession(userToken.Handle, mux.Vars(r)["sessionId"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// GetJWKS godoc
+// @Summary      Get the keys of the access tokens
+// @Description  The public keys that verify the access tokens, as a JSON Web Key Set. The kid in the header of a token
+// @Description  names its key. Keys that are rotated in are published before they sign, keys that are rotated out until
+// @Description  they are removed. Tokens signed with AUTH_SIGN_KEY are verified by Webdp only, its key is secret.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  signing.JWKSet
+// @Router       /v2/.well-known/jwks.json [get]
+func (lh LoginHandler) GetJWKS(w http.ResponseWriter, r *http.Request) error {
+	return RenderJsonResponse(w, http.StatusOK, lh.tokenService.JWKS(), "Cache-Control", "public, max-age=300")
+}
+
+// the address of the client, without the port
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/utils"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Members of a dataset. The owner adds co-curators, who manage the dataset with
+the owner, and viewers, who can read it without a budget. The ownership itself
+moves through a transfer that the new owner has to accept.
+*/
+
+// GetMembers godoc
+// @Summary      Gets the members of a dataset.
+// @Description  Requester needs permission dataset.read, or needs granted access via budget allocation or membership.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      200  {object}  []entity.DatasetMember
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/members [get]
+func (h DatasetHandler) GetMembers(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_READ); err != nil {
+		if err := middlewares.ValidateGrantedAccess(r, &h.budgetService, &h.datasetService); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if _, err := h.datasetService.GetDataset(id); err != nil {
+		return RenderError(w, err)
+	}
+
+	members, err := h.datasetService.GetMembers(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, members))
+}
+
+// PutMember godoc
+// @Summary      Adds a member to a dataset or changes its role.
+// @Description  Requester needs to be the owner of the dataset. The role is co-curator or viewer, the member can have any role.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param        datasetId  	path    int 				true  "Dataset Id"
+// @Param        userHandle 	path    string 				true  "User Handle"
+// @Param		 requestBody	body   	entity.MemberPut  	true  "request body"
+// @Success      204
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/members/{userHandle} [put]
+func (h DatasetHandler) PutMember(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
+		return RenderError(w, err)
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	var put entity.MemberPut
+	if err := utils.ParseJsonRequestBody[entity.MemberPut](r, &put); err != nil {
+		return RenderError(w, err)
+	}
+	if err := put.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	if _, err := h.userService.GetUser(vars["userHandle"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.datasetService.PutMember(middlewares.RequestActor(r), id, vars["userHandle"], put.Role); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// DeleteMember godoc
+// @Summary      Removes a member from a dataset.
+// @Description  Requester needs to be the owner of the dataset, or the member itself. The owner cannot be removed.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        userHandle path    string 	true  "User Handle"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/members/{userHandle} [delete]
+func (h DatasetHandler) DeleteMember(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateSelfRequest(r); err != nil {
+		if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.datasetService.DeleteMember(middlewares.RequestActor(r), id, vars["userHandle"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// PostTransfer godoc
+// @Summary      Proposes a new owner of a dataset.
+// @Description  Requester needs to be the owner of the dataset. The new owner can have any role and becomes the owner
+// @Description  when it accepts the transfer, the previous owner is then no longer a member. A new proposal replaces an earlier one.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param        datasetId  	path    int 					true  "Dataset Id"
+// @Param		 requestBody	body   	entity.TransferRequest  true  "request body"
+// @Success      201  {object}  entity.OwnershipTransfer
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/transfer [post]
+func (h DatasetHandler) PostTransfer(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.TransferRequest
+	if err := utils.ParseJsonRequestBody[entity.TransferRequest](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := utils.ValidateNonEmptyString(req); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.validateTransfer(r, req.To); err != nil {
+		return RenderError(w, err)
+	}
+
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	transfer, err := h.datasetService.ProposeTransfer(id, userToken.Handle, req.To)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusCreated, transfer))
+}
+
+// GetTransfer godoc
+// @Summary      Gets the proposed ownership transfer of a dataset.
+// @Description  Requester needs to be the owner of the dataset or the proposed owner.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      200  {object}  entity.OwnershipTransfer
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/transfer [get]
+func (h DatasetHandler) GetTransfer(w http.ResponseWriter, r *http.Request) error {
+	transfer, err := h.requestedTransfer(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, transfer))
+}
+
+// DeleteTransfer godoc
+// @Summary      Cancels or declines the proposed ownership transfer of a dataset.
+// @Description  Requester needs to be the owner of the dataset or the proposed owner.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/transfer [delete]
+func (h DatasetHandler) DeleteTransfer(w http.ResponseWriter, r *http.Request) error {
+	transfer, err := h.requestedTransfer(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	if err := h.datasetService.DeleteTransfer(transfer.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NoContent())
+}
+
+// AcceptTransfer godoc
+// @Summary      Accepts the ownership of a dataset.
+// @Description  Requester needs to be the proposed owner of the dataset.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      204
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/transfer/accept [post]
+func (h DatasetHandler) AcceptTransfer(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.datasetService.AcceptTransfer(middlewares.RequestActor(r), id); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// checks that the requester owns the dataset and can hand it over to the user, who is in the same organization
+func (h DatasetHandler) validateTransfer(r *http.Request, to string) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
+		return err
+	}
+
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return err
+	}
+	if to == userToken.Handle {
+		return fmt.Errorf("%w: %s already owns the dataset", errors.ErrBadInput, to)
+	}
+
+	user, err := h.userService.GetUser(to)
+	if err != nil {
+		return err
+	}
+	return middlewares.ValidateOrganization(r, user.Organization)
+}
+
+// the transfer of the requested dataset, if the requester is its owner or proposed owner
+func (h DatasetHandler) requestedTransfer(r *http.Request) (entity.OwnershipTransfer, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return entity.OwnershipTransfer{}, err
+	}
+
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return entity.OwnershipTransfer{}, err
+	}
+
+	transfer, err := h.datasetService.GetTransfer(id)
+	if err != nil {
+		if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
+			return entity.OwnershipTransfer{}, err
+		}
+		return entity.OwnershipTransfer{}, err
+	}
+	if userToken.Handle != transfer.To {
+		if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MEMBER_OWNER); err != nil {
+			return entity.OwnershipTransfer{}, err
+		}
+	}
+	return transfer, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/services"
+)
+
+// the cookie that keeps the state of a login at the identity provider, only sent to the oidc routes
+const (
+	OIDC_COOKIE      = "webdp_oidc"
+	OIDC_COOKIE_PATH = "/v2/oidc"
+)
+
+type OidcHandler struct {
+	oidcService *services.OidcService
+}
+
+func NewOidcHandler(oidcService *services.OidcService) OidcHandler {
+	return OidcHandler{oidcService: oidcService}
+}
+
+// OidcLogin godoc
+// @Summary      Login with the identity provider
+// @Description  Redirects to the identity provider, which redirects back to the callback when the user has logged in.
+// @Tags         auth
+// @Success      302
+// @Failure      500  {object}  response.Error
+// @Router       /v2/oidc/login [get]
+func (h OidcHandler) Login(w http.ResponseWriter, r *http.Request) error {
+	url, state, err := h.oidcService.BeginLogin()
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDC_COOKIE,
+		Value:    state,
+		Path:     OIDC_COOKIE_PATH,
+		MaxAge:   int(services.OIDC_LOGIN_LIFETIME.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, url, http.StatusFound)
+	return nil
+}
+
+// OidcCallback godoc
+// @Summary      Complete a login with the identity provider
+// @Description  Starts a session for the user that logged in at the identity provider. The user is created on its first login,
+// @Description  its roles follow its groups at the identity provider.
+// @Tags         auth
+// @Produce      json
+// @Param        code   query   string  true  "code from the identity provider"
+// @Param        state  query   string  true  "state of the login"
+// @Success      200  {object}  response.Token
+// @Failure      401  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/oidc/callback [get]
+func (h OidcHandler) Callback(w http.ResponseWriter, r *http.Request) error {
+	saved, err := r.Cookie(OIDC_COOKIE)
+	if err != nil {
+		return RenderError(w, fmt.Errorf("%w: no login was started", errors.ErrUnauthorized))
+	}
+	http.SetCookie(w, &http.Cookie{Name: OIDC_COOKIE, Path: OIDC_COOKIE_PATH, MaxAge: -1})
+
+	query := r.URL.Query()
+	tokens, err := h.oidcService.CompleteLogin(query.Get("code"), query.Get("state"), saved.Value, middlewares.GetRequestId(r))
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, response.Token{Token: tokens.Access, RefreshToken: tokens.Refresh, ExpiresAt: tokens.ExpiresAt}))
+}
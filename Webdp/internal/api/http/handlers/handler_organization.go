@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/services"
+	"webdp/internal/api/http/utils"
+
+	"github.com/gorilla/mux"
+
+	errors "webdp/internal/api/http"
+)
+
+/*
+Organizations, the tenants of Webdp. Every user can read its own organization,
+platform admins, who have permission org.manage in the default organization,
+read and manage all of them.
+*/
+type OrganizationHandler struct {
+	organizationService services.OrganizationService
+}
+
+func NewOrganizationHandler(orgs services.OrganizationService) OrganizationHandler {
+	return OrganizationHandler{organizationService: orgs}
+}
+
+// GetOrganizations godoc
+// @Summary      Get the organizations.
+// @Description  Platform admins get all organizations, other users their own organization.
+// @Tags         organizations
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  []entity.Organization
+// @Failure      500  {object}  response.Error
+// @Router       /v2/organizations [get]
+func (h OrganizationHandler) GetOrganizations(w http.ResponseWriter, r *http.Request) error {
+	handle := middlewares.RequesterOrganization(r)
+	if middlewares.IsPlatformAdmin(r) {
+		handle = ""
+	}
+	organizations, err := h.organizationService.GetOrganizations(handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, organizations))
+}
+
+// GetOrganization godoc
+// @Summary      Get an organization.
+// @Description  Users get their own organization, platform admins any organization.
+// @Description  Used is the total budget of the datasets of the organization, deleted datasets that can still be restored included.
+// @Tags         organizations
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 orgHandle	path string true "Organization Handle"
+// @Success      200  {object}  entity.Organization
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/organizations/{orgHandle} [get]
+func (h OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) error {
+	handle := mux.Vars(r)["orgHandle"]
+	if !middlewares.IsPlatformAdmin(r) {
+		if err := middlewares.ValidateOrganization(r, handle); err != nil {
+			return RenderError(w, err)
+		}
+	}
+	organization, err := h.organizationService.GetOrganization(handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, organization))
+}
+
+// PostOrganization godoc
+// @Summary      Create an organization.
+// @Description  Requester needs permission org.manage in the default organization.
+// @Description  Without a budget cap the datasets of the organization can have any total budget.
+// @Tags         organizations
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param		 requestBody	body entity.OrganizationCreate true "request body"
+// @Success      201  {object}  entity.Organization
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/organizations [post]
+func (h OrganizationHandler) PostOrganization(w http.ResponseWriter, r *http.Request) error {
+	if err := validatePlatformAdmin(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.OrganizationCreate
+	if err := utils.ParseJsonRequestBody[entity.OrganizationCreate](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := req.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.organizationService.CreateOrganization(middlewares.RequestActor(r), req); err != nil {
+		return RenderError(w, err)
+	}
+	organization, err := h.organizationService.GetOrganization(req.Handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusCreated, organization))
+}
+
+// PatchOrganization godoc
+// @Summary      Update an organization.
+// @Description  Requester needs permission org.manage in the default organization. Replaces the name and the budget cap,
+// @Description  which cannot be lower than the total budget of the datasets of the organization.
+// @Tags         organizations
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param		 orgHandle		path string 					true "Organization Handle"
+// @Param		 requestBody	body entity.OrganizationPatch 	true "request body"
+// @Success      200  {object}  entity.Organization
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/organizations/{orgHandle} [patch]
+func (h OrganizationHandler) PatchOrganization(w http.ResponseWriter, r *http.Request) error {
+	if err := validatePlatformAdmin(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.OrganizationPatch
+	if err := utils.ParseJsonRequestBody[entity.OrganizationPatch](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := req.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	handle := mux.Vars(r)["orgHandle"]
+	if err := h.organizationService.UpdateOrganization(middlewares.RequestActor(r), handle, req); err != nil {
+		return RenderError(w, err)
+	}
+	organization, err := h.organizationService.GetOrganization(handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, organization))
+}
+
+// DeleteOrganization godoc
+// @Summary      Delete an organization.
+// @Description  Requester needs permission org.manage in the default organization.
+// @Description  The organization cannot have users or datasets. The default organization cannot be deleted.
+// @Tags         organizations
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 orgHandle	path string true "Organization Handle"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/organizations/{orgHandle} [delete]
+func (h OrganizationHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) error {
+	if err := validatePlatformAdmin(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.organizationService.DeleteOrganization(middlewares.RequestActor(r), mux.Vars(r)["orgHandle"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+func validatePlatformAdmin(r *http.Request) error {
+	if !middlewares.IsPlatformAdmin(r) {
+		return fmt.Errorf("%w: missing permission %s in the default organization", errors.ErrForbidden, entity.PERM_ORG_MANAGE)
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/client"
 	"webdp/internal/api/http/entity"
@@ -26,7 +28,8 @@ func NewQueryHandler(dataset services.DatasetService, budget services.BudgetServ
 // PostQueryEvaluate godoc
 // @Summary      Do a query evaluation
 // @Description  Request a query evaluation on a specific dataset.
-// @Description  Requester must be curator or analyst.
+// @Description  Requester needs permission query.run.
+// @Description  The query counts against the daily quota of queries of the allocation, see X-Query-Quota-* headers.
 // @Tags         queries
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -36,11 +39,12 @@ func NewQueryHandler(dataset services.DatasetService, budget services.BudgetServ
 // @Success      200  {object}  entity.QueryResult
 // @Failure      400  {object}  response.Error
 // @Failure      403  {object}  response.Error
+// @Failure      429  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v1/query/evaluate [post]
 // @Router       /v2/queries/evaluate [post]
 func (h QueryHandler) PostQueryEvaluate(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR, entity.ANALYST}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_QUERY_RUN); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -59,10 +63,17 @@ func (h QueryHandler) PostQueryEvaluate(w http.ResponseWriter, r *http.Request)
 		return RenderError(w, err)
 	}
 
+	if err := middlewares.ValidateDatasetScope(r, query.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+
 	datainfo, err := h.dataset.GetDataset(query.Dataset)
 	if err != nil {
 		return RenderError(w, err)
 	}
+	if err := middlewares.ValidateOrganization(r, datainfo.Organization); err != nil {
+		return RenderError(w, err)
+	}
 
 	if !h.budget.HasUserEnoughBudget(user, query.Dataset, query.Budget) {
 		return RenderError(w, fmt.Errorf("%w: not have budget for making the query", errors.ErrBadRequest))
@@ -72,12 +83,23 @@ func (h QueryHandler) PostQueryEvaluate(w http.ResponseWriter, r *http.Request)
 		return RenderError(w, fmt.Errorf("%w: cannot make a query without data. dataset %d not loaded", errors.ErrBadRequest, query.Dataset))
 	}
 
+	version, err := h.dataVersion(datainfo, query.Version)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.countQuery(w, user, query.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+
 	req := entity.QueryFromClientEvaluate{
 		Data:          query.Dataset,
+		Version:       version,
 		Budget:        query.Budget,
 		Query:         query.Query,
 		Schema:        datainfo.Schema,
 		PrivacyNotion: datainfo.PrivacyNotion,
+		ValuePolicies: query.ValuePolicies,
 	}
 
 	engine := r.URL.Query().Get("engine")
@@ -92,7 +114,7 @@ func (h QueryHandler) PostQueryEvaluate(w http.ResponseWriter, r *http.Request)
 	}
 
 	// query was ok so we update budget
-	if err := h.budget.AddConsumedBudgetToUser(user, query.Dataset, query.Budget); err != nil {
+	if err := h.budget.AddConsumedBudgetToUser(middlewares.RequestActor(r), user, query.Dataset, query.Budget); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -118,7 +140,8 @@ func (h QueryHandler) PostQueryCustom(w http.ResponseWriter, r *http.Request) er
 // PostQueryValidate godoc
 // @Summary      Validate a query
 // @Description  Validate a query's syntax.
-// @Description  Requester must be curator or analyst.
+// @Description  Requester needs permission query.run.
+// @Description  The query counts against the daily quota of queries of the allocation, see X-Query-Quota-* headers.
 // @Tags         queries
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -129,10 +152,11 @@ func (h QueryHandler) PostQueryCustom(w http.ResponseWriter, r *http.Request) er
 // @Success      200  {object}  []client.ValidateResponse
 // @Failure      400  {object}  response.Error
 // @Failure      403  {object}  response.Error
+// @Failure      429  {object}  response.Error
 // @Failure      500  {object}  response.Error
 // @Router       /v2/queries/validate [post]
 func (h QueryHandler) PostQueryValidate(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR, entity.ANALYST}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_QUERY_RUN); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -151,27 +175,45 @@ func (h QueryHandler) PostQueryValidate(w http.ResponseWriter, r *http.Request)
 		return RenderError(w, err)
 	}
 
+	if err := middlewares.ValidateDatasetScope(r, query.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+
 	datainfo, err := h.dataset.GetDataset(query.Dataset)
 	if err != nil {
 		return RenderError(w, err)
 	}
+	if err := middlewares.ValidateOrganization(r, datainfo.Organization); err != nil {
+		return RenderError(w, err)
+	}
 
 	if !datainfo.Loaded {
 		return RenderError(w, fmt.Errorf("%w: cannot make a query without data. dataset %d not loaded", errors.ErrBadRequest, query.Dataset))
 	}
 
+	version, err := h.dataVersion(datainfo, query.Version)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
 	_, err = h.budget.GetUserDatasetBudget(user, datainfo.Id)
 
 	if err != nil {
 		return RenderError(w, fmt.Errorf("user %s does not have budget allocated on dataset %d", user, query.Dataset))
 	}
 
+	if err := h.countQuery(w, user, query.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+
 	req := entity.QueryFromClientEvaluate{
 		Data:          query.Dataset,
+		Version:       version,
 		Budget:        query.Budget,
 		Query:         query.Query,
 		Schema:        datainfo.Schema,
 		PrivacyNotion: datainfo.PrivacyNotion,
+		ValuePolicies: query.ValuePolicies,
 	}
 
 	engine := r.URL.Query().Get("engine")
@@ -194,7 +236,7 @@ func (h QueryHandler) PostQueryValidate(w http.ResponseWriter, r *http.Request)
 // PostQueryAccuracy godoc
 // @Summary      Check a query's accuracy
 // @Description  Request query accuracy on a specific dataset.
-// @Description  Requester must be curator or analyst.
+// @Description  Requester needs permission query.run.
 // @Tags         queries
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -208,7 +250,7 @@ func (h QueryHandler) PostQueryValidate(w http.ResponseWriter, r *http.Request)
 // @Router       /v1/query/accuracy [post]
 // @Router       /v2/queries/accuracy [post]
 func (h QueryHandler) PostQueryAccuracy(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.CURATOR, entity.ANALYST}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_QUERY_RUN); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -228,10 +270,17 @@ func (h QueryHandler) PostQueryAccuracy(w http.ResponseWriter, r *http.Request)
 		return RenderError(w, err)
 	}
 
+	if err := middlewares.ValidateDatasetScope(r, query.Dataset); err != nil {
+		return RenderError(w, err)
+	}
+
 	datainfo, err := h.dataset.GetDataset(query.Dataset)
 	if err != nil {
 		return RenderError(w, err)
 	}
+	if err := middlewares.ValidateOrganization(r, datainfo.Organization); err != nil {
+		return RenderError(w, err)
+	}
 
 	if _, err = h.budget.GetUserDatasetBudget(user, datainfo.Id); err != nil {
 		return RenderError(w, err)
@@ -241,8 +290,14 @@ func (h QueryHandler) PostQueryAccuracy(w http.ResponseWriter, r *http.Request)
 		return RenderError(w, fmt.Errorf("%w: cannot make a query without data. dataset %d not loaded", errors.ErrBadRequest, query.Dataset))
 	}
 
+	version, err := h.dataVersion(datainfo, query.Version)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
 	req := entity.QueryFromClientAccuracy{
 		Data:          query.Dataset,
+		Version:       version,
 		Budget:        query.Budget,
 		Query:         query.Query,
 		Schema:        datainfo.Schema,
@@ -363,3 +418,52 @@ func (h QueryHandler) GetQueryDocs(w http.ResponseWriter, r *http.Request) error
 
 	return RenderMDResponse(w, http.StatusOK, []byte(combinedDocs.String()))
 }
+
+// the version of the data a query runs on, the latest unless the query pins one
+func (h QueryHandler) dataVersion(datainfo entity.DatasetInfo, version int64) (int64, error) {
+	if version == 0 {
+		return datainfo.Version, nil
+	}
+
+	versions, err := h.dataset.GetDataVersions(datainfo.Id)
+	if err != nil {
+		return 0, err
+	}
+	// versions before the latest schema change do not fit the schema, and the
+	// rows of the versions before a reset of the budgets are dropped
+	found := false
+	for _, v := range versions {
+		if v.Version == version {
+			found = true
+		}
+		if v.Mode == entity.VERSION_SCHEMA && v.Version > version && found {
+			return 0, fmt.Errorf("%w: version %d of dataset %d was loaded before a change of its schema", errors.ErrBadInput, version, datainfo.Id)
+		}
+		if v.BudgetReset && v.Version > version && found {
+			return 0, fmt.Errorf("%w: version %d of dataset %d was loaded before its budgets were reset", errors.ErrBadInput, version, datainfo.Id)
+		}
+	}
+	if found {
+		return version, nil
+	}
+
+	return 0, fmt.Errorf("%w: dataset %d has no version %d", errors.ErrNotFound, datainfo.Id, version)
+}
+
+/*
+Counts the query against the daily quota of the allocation of the user on the
+dataset. The quota and the queries left are returned in the X-Query-Quota-*
+headers, a query over the quota is refused with a Retry-After header.
+*/
+func (h QueryHandler) countQuery(w http.ResponseWriter, user string, dataset int64) error {
+	now := time.Now()
+	quota, err := h.budget.CountQuery(user, dataset, now)
+	if quota.Limit > 0 {
+		w.Header().Set("X-Query-Quota-Limit", strconv.Itoa(quota.Limit))
+		w.Header().Set("X-Query-Quota-Remaining", strconv.Itoa(max(quota.Limit-quota.Used, 0)))
+		if err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(quota.Resets.Sub(now).Seconds())+1))
+		}
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/services"
+	"webdp/internal/api/http/utils"
+
+	"github.com/gorilla/mux"
+
+	errors "webdp/internal/api/http"
+)
+
+/*
+Roles and the permissions they give. Every user can read them, custom roles
+are managed with permission role.manage. Roles are shared by all
+organizations, so only the default organization manages them.
+*/
+type RoleHandler struct {
+	policyService services.PolicyService
+}
+
+func NewRoleHandler(ps services.PolicyService) RoleHandler {
+	return RoleHandler{policyService: ps}
+}
+
+// GetPermissions godoc
+// @Summary      Get all permissions.
+// @Description  The permissions that roles can give.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  []entity.Permission
+// @Failure      500  {object}  response.Error
+// @Router       /v2/permissions [get]
+func (h RoleHandler) GetPermissions(w http.ResponseWriter, r *http.Request) error {
+	permissions, err := h.policyService.GetPermissions()
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, permissions))
+}
+
+// GetRoles godoc
+// @Summary      Get all roles.
+// @Description  The roles and their permissions, the built-in roles first.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Success      200  {object}  []entity.Role
+// @Failure      500  {object}  response.Error
+// @Router       /v2/roles [get]
+func (h RoleHandler) GetRoles(w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.policyService.GetRoles()
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, roles))
+}
+
+// GetRole godoc
+// @Summary      Get a role.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 role	path string true "Role"
+// @Success      200  {object}  entity.Role
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/roles/{role} [get]
+func (h RoleHandler) GetRole(w http.ResponseWriter, r *http.Request) error {
+	role, err := h.policyService.GetRole(mux.Vars(r)["role"])
+	if err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, role))
+}
+
+// PostRole godoc
+// @Summary      Create a custom role.
+// @Description  Requester needs permission role.manage in the default organization. The role can be given to users like the built-in roles.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param		 requestBody	body entity.RoleCreate true "request body"
+// @Success      201  {object}  entity.Role
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/roles [post]
+func (h RoleHandler) PostRole(w http.ResponseWriter, r *http.Request) error {
+	if err := validateRoleManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.RoleCreate
+	if err := utils.ParseJsonRequestBody[entity.RoleCreate](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := req.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.policyService.CreateRole(middlewares.RequestActor(r), req); err != nil {
+		return RenderError(w, err)
+	}
+	role, err := h.policyService.GetRole(req.Name)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusCreated, role))
+}
+
+// PutRole godoc
+// @Summary      Update a custom role.
+// @Description  Requester needs permission role.manage in the default organization. Replaces the description and the permissions of the role,
+// @Description  which apply to the users with the role at once. The built-in roles cannot be changed.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Produce      json
+// @Param		 role			path string 		true "Role"
+// @Param		 requestBody	body entity.RolePut true "request body"
+// @Success      200  {object}  entity.Role
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/roles/{role} [put]
+func (h RoleHandler) PutRole(w http.ResponseWriter, r *http.Request) error {
+	if err := validateRoleManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var req entity.RolePut
+	if err := utils.ParseJsonRequestBody[entity.RolePut](r, &req); err != nil {
+		return RenderError(w, err)
+	}
+	if err := req.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	name := mux.Vars(r)["role"]
+	if err := h.policyService.UpdateRole(middlewares.RequestActor(r), name, req); err != nil {
+		return RenderError(w, err)
+	}
+	role, err := h.policyService.GetRole(name)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, role))
+}
+
+// DeleteRole godoc
+// @Summary      Delete a custom role.
+// @Description  Requester needs permission role.manage in the default organization. The role cannot be given to any user. The built-in roles cannot be deleted.
+// @Tags         roles
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param		 role	path string true "Role"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/roles/{role} [delete]
+func (h RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) error {
+	if err := validateRoleManagement(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.policyService.DeleteRole(middlewares.RequestActor(r), mux.Vars(r)["role"]); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// roles apply to every organization, so they are managed from the default organization
+func validateRoleManagement(r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_ROLE_MANAGE); err != nil {
+		return err
+	}
+	if middlewares.RequesterOrganization(r) != entity.DEFAULT_ORGANIZATION {
+		return fmt.Errorf("%w: roles are shared by all organizations and managed in the default organization", errors.ErrForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/response"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Resumable uploads for large datasets. A session is created, the data is sent in
+chunks at increasing offsets, and completing the session validates and stores
+the data exactly like a single upload. Requester needs to be the owner or a
+co-curator of the dataset, and a session is only reached by the user who
+started it until UPLOAD_SESSION_LIFETIME after its last chunk.
+*/
+
+// PostUploadSession godoc
+// @Summary      Start a resumable upload.
+// @Description  Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Success      201  {object}  entity.UploadSession
+// @Failure      403  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/uploads [post]
+func (h DatasetHandler) PostUploadSession(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_UPLOAD); err != nil {
+		return RenderError(w, err)
+	}
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	us, err := h.datasetService.CreateUploadSession(id, middlewares.RequestActor(r).Handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusCreated, us))
+}
+
+// GetUploadSession godoc
+// @Summary      Get a resumable upload.
+// @Description  Requester needs to be the owner or a co-curator of the dataset. The size is the offset of the next chunk.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        uploadId   path    int 	true  "Upload Id"
+// @Success      200  {object}  entity.UploadSession
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/uploads/{uploadId} [get]
+func (h DatasetHandler) GetUploadSession(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, sid, err := getUploadIds(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	us, err := h.datasetService.GetUploadSession(id, sid, middlewares.RequestActor(r).Handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, us))
+}
+
+// PutUploadChunk godoc
+// @Summary      Append a chunk to a resumable upload.
+// @Description  Requester needs to be the owner or a co-curator of the dataset. The offset must be the current size of the upload,
+// @Description  otherwise 409 is returned and the upload can be resumed from its size.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Accept       octet-stream
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        uploadId   path    int 	true  "Upload Id"
+// @Param        offset     query   int 	true  "offset of the chunk"
+// @Param		 chunk 		body	string	true  "Data"
+// @Success      200  {object}  entity.UploadSession
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      409  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/uploads/{uploadId} [put]
+func (h DatasetHandler) PutUploadChunk(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, sid, err := getUploadIds(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return RenderError(w, fmt.Errorf("%w: offset should be a non negative integer", errors.ErrBadInput))
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, entity.MAX_CHUNK_SIZE))
+	if err != nil {
+		return RenderError(w, fmt.Errorf("%w: chunks can be at most %d bytes", errors.ErrBadInput, entity.MAX_CHUNK_SIZE))
+	}
+
+	if len(chunk) == 0 {
+		return RenderError(w, fmt.Errorf("%w: empty chunk", errors.ErrBadInput))
+	}
+
+	us, err := h.datasetService.AppendUploadChunk(id, sid, middlewares.RequestActor(r).Handle, offset, chunk)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NewSuccess(http.StatusOK, us))
+}
+
+// CompleteUploadSession godoc
+// @Summary      Complete a resumable upload.
+// @Description  Requester needs permission dataset.upload and to be the owner or a co-curator of the dataset. The uploaded chunks are validated and stored
+// @Description  like a single upload, and the upload session is removed.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Produce      json
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        uploadId   path    int 	true  "Upload Id"
+// @Param        invalid    query   string  false "what to do with cells that do not fit the schema: reject (default), clamp or drop"
+// @Param        format     query   string  false "csv (default), jsonl or parquet"
+// @Param        mode       query   string  false "replace (default) or append to the latest version"
+// @Param        budget     query   string  false "keep (default) or reset the consumed budgets of the dataset, reset needs mode=replace"
+// @Success      200  {object}  entity.UploadReport
+// @Success      204
+// @Failure      400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/uploads/{uploadId}/complete [post]
+func (h DatasetHandler) CompleteUploadSession(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_DATASET_UPLOAD); err != nil {
+		return RenderError(w, err)
+	}
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, sid, err := getUploadIds(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	format, err := entity.UploadFormat(r.URL.Query().Get("format"), "")
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	opts, err := entity.NewUploadOptions(r.URL.Query().Get("mode"), r.URL.Query().Get("budget"))
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	dataset, err := h.datasetService.GetDataset(id)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	body, err := h.datasetService.OpenUploadSession(id, sid, middlewares.RequestActor(r).Handle)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	report, err := h.storeUpload(dataset, body, format, r.URL.Query().Get("invalid"), opts)
+	body.Close()
+
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.datasetService.DeleteUploadSession(id, sid, middlewares.RequestActor(r).Handle); err != nil {
+		return RenderError(w, err)
+	}
+
+	if r.URL.Query().Get("invalid") != "" {
+		return RenderResponse(w, response.NewSuccess(http.StatusOK, report))
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+// DeleteUploadSession godoc
+// @Summary      Abort a resumable upload.
+// @Description  Requester needs to be the owner or a co-curator of the dataset.
+// @Tags         datasets
+// @Security 	 BearerTokenAuth
+// @Param        datasetId  path    int 	true  "Dataset Id"
+// @Param        uploadId   path    int 	true  "Upload Id"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Router       /v2/datasets/{datasetId}/uploads/{uploadId} [delete]
+func (h DatasetHandler) DeleteUploadSession(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidateMembership(r, &h.datasetService, entity.MANAGING_MEMBERS...); err != nil {
+		return RenderError(w, err)
+	}
+
+	id, sid, err := getUploadIds(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.datasetService.DeleteUploadSession(id, sid, middlewares.RequestActor(r).Handle); err != nil {
+		return RenderError(w, err)
+	}
+
+	return RenderResponse(w, response.NoContent())
+}
+
+func getUploadIds(r *http.Request) (int64, int64, error) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid dataset id", errors.ErrBadInput)
+	}
+	sid, err := strconv.ParseInt(vars["uploadId"], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid upload id", errors.ErrBadInput)
+	}
+	return id, sid, nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/middlewares"
@@ -25,11 +26,13 @@ func NewUserHandler(us services.UserService, ts services.TokenService) UserHandl
 }
 
 /*
-Get all users. Requester needs admin or curator roles.
+Get the users of the organization of the requester, of all organizations for
+platform admins. Requester needs permission user.read.
 */
 // GetUsers godoc
 // @Summary      Get all users.
-// @Description  Requester needs admin or curator roles.
+// @Description  Requester needs permission user.read. Only the users of the organization of the requester are listed,
+// @Description  platform admins list the users of all organizations.
 // @Tags         users
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -40,11 +43,15 @@ Get all users. Requester needs admin or curator roles.
 // @Router       /v1/users [get]
 // @Router       /v2/users [get]
 func (h UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN, entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_READ); err != nil {
 		return RenderError(w, err)
 	}
 
-	res, err := h.userService.GetAllUsers()
+	organization := middlewares.RequesterOrganization(r)
+	if middlewares.IsPlatformAdmin(r) {
+		organization = ""
+	}
+	res, err := h.userService.GetAllUsers(organization)
 	if err != nil {
 		return RenderError(w, err)
 	}
@@ -57,11 +64,11 @@ func (h UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) error {
 }
 
 /*
-Get a user. Requester needs admin or curator roles.
+Get a user. Requester needs permission user.read.
 */
 // GetUser godoc
 // @Summary      Get a user.
-// @Description  Requester needs admin or curator roles.
+// @Description  Requester needs permission user.read.
 // @Tags         users
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -73,7 +80,7 @@ Get a user. Requester needs admin or curator roles.
 // @Router       /v1/user/{userHandle} [get]
 // @Router       /v2/users/{userHandle} [get]
 func (h UserHandler) GetUser(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN, entity.CURATOR}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_READ); err != nil {
 		if err := middlewares.ValidateSelfRequest(r); err != nil {
 			return RenderError(w, err)
 		}
@@ -96,11 +103,12 @@ func (h UserHandler) GetUser(w http.ResponseWriter, r *http.Request) error {
 }
 
 /*
-Create new user. Requester needs admin role.
+Create new user. Requester needs permission user.manage.
 */
 // PostUser godoc
 // @Summary      Create new user.
-// @Description  Requester needs admin role.
+// @Description  Requester needs permission user.manage. The user is created in the organization of the requester
+// @Description  unless another organization is given, which only platform admins can do.
 // @Tags         users
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -113,7 +121,7 @@ Create new user. Requester needs admin role.
 // @Router       /v1/users [post]
 // @Router       /v2/users [post]
 func (h UserHandler) PostUsers(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_MANAGE); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -124,8 +132,14 @@ func (h UserHandler) PostUsers(w http.ResponseWriter, r *http.Request) error {
 	if err := createUser.Valid(); err != nil {
 		return RenderError(w, err)
 	}
+	if createUser.Organization == "" {
+		createUser.Organization = middlewares.RequesterOrganization(r)
+	}
+	if createUser.Organization != middlewares.RequesterOrganization(r) && !middlewares.IsPlatformAdmin(r) {
+		return RenderError(w, fmt.Errorf("%w: only platform admins create users in other organizations", errors.ErrForbidden))
+	}
 
-	if _, err := h.userService.CreateUser(createUser); err != nil {
+	if _, err := h.userService.CreateUser(middlewares.RequestActor(r), createUser); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -133,12 +147,12 @@ func (h UserHandler) PostUsers(w http.ResponseWriter, r *http.Request) error {
 }
 
 /*
-Update a user. Requester needs admin role.
+Update a user. Requester needs permission user.manage.
 */
 // PatchUser godoc
 // @Summary      Update a user.
 // @Description  Update name, password and roles of a user.
-// @Description  Requester needs admin role.
+// @Description  Requester needs permission user.manage.
 // @Tags         users
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -151,7 +165,7 @@ Update a user. Requester needs admin role.
 // @Router       /v1/user/{userHandle} [patch]
 // @Router       /v2/users/{userHandle} [patch]
 func (h UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_MANAGE); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -170,7 +184,7 @@ func (h UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// Update user data
-	if err := h.userService.UpdateUser(vars["userHandle"], patch); err != nil {
+	if err := h.userService.UpdateUser(middlewares.RequestActor(r), vars["userHandle"], patch); err != nil {
 		return RenderError(w, err)
 	}
 
@@ -178,11 +192,11 @@ func (h UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) error {
 }
 
 /*
-Delete a user. Requester needs admin role.
+Delete a user. Requester needs permission user.manage.
 */
 // DeleteUser godoc
 // @Summary      Delete a user.
-// @Description  Requester needs admin role.
+// @Description  Requester needs permission user.manage.
 // @Tags         users
 // @Security 	 BearerTokenAuth
 // @Accept       json
@@ -195,7 +209,7 @@ Delete a user. Requester needs admin role.
 // @Router       /v1/user/{userHandle} [delete]
 // @Router       /v2/users/{userHandle} [delete]
 func (h UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
-	if err := middlewares.ValidateRoles(r, []string{entity.ADMIN}); err != nil {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_MANAGE); err != nil {
 		return RenderError(w, err)
 	}
 	vars := mux.Vars(r)
@@ -205,7 +219,7 @@ func (h UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
 		if err := h.tokenService.LogOffUser(vars["userHandle"]); err != nil {
 			return RenderError(w, err)
 		}
-		if _, err := h.userService.DeleteUser(vars["userHandle"]); err != nil {
+		if _, err := h.userService.DeleteUser(middlewares.RequestActor(r), vars["userHandle"]); err != nil {
 			return RenderError(w, err)
 		}
 
@@ -226,3 +240,85 @@ func purgeRoot(users []entity.UserResponse) []entity.UserResponse {
 	}
 	return res
 }
+
+// ChangePassword godoc
+// @Summary      Change own password.
+// @Description  Users change their own password with the password they have now, admins set the passwords of others
+// @Description  with PATCH. The new password follows the password policy. The other sessions of the user are logged out.
+// @Description  A wrong old password counts as a failed login. API keys and tokens of the identity provider cannot change passwords.
+// @Tags         users
+// @Security 	 BearerTokenAuth
+// @Accept       json
+// @Param		 userHandle		path string 				true "User Handle"
+// @Param		 passwordChange	body entity.PasswordChange	true "Old and new password"
+// @Success      204
+// @Failure		 400  {object}  response.Error
+// @Failure      403  {object}  response.Error
+// @Failure      429  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/users/{userHandle}/password [put]
+func (h UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	userToken, err := middlewares.RequestClaims(r)
+	if err != nil {
+		return RenderError(w, err)
+	}
+	// the password is changed in a session of the user
+	if userToken.Exchanged() {
+		return RenderError(w, fmt.Errorf("%w: api keys and tokens of the identity provider cannot change passwords", errors.ErrForbidden))
+	}
+	if err := middlewares.ValidateSelfRequest(r); err != nil {
+		return RenderError(w, err)
+	}
+
+	var change entity.PasswordChange
+	if err := utils.ParseJsonRequestBody[entity.PasswordChange](r, &change); err != nil {
+		return RenderError(w, err)
+	}
+	if err := change.Valid(); err != nil {
+		return RenderError(w, err)
+	}
+
+	if err := h.userService.ChangePassword(middlewares.RequestActor(r), userToken.Handle, change); err != nil {
+		return RenderError(w, err)
+	}
+	if err := h.tokenService.LogOffOtherSessions(userToken.Handle, userToken.Id); err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NoContent())
+}
+
+// UnlockUser godoc
+// @Summary      Unlock a user.
+// @Description  Requester needs permission user.manage. Forgets the failed logins of a user in the organization of the
+// @Description  requester, which unlocks its account. Platform admins unlock the users of all organizations.
+// @Tags         users
+// @Security 	 BearerTokenAuth
+// @Param		 userHandle	path string true "User Handle"
+// @Success      204
+// @Failure      403  {object}  response.Error
+// @Failure      404  {object}  response.Error
+// @Failure      500  {object}  response.Error
+// @Router       /v2/users/{userHandle}/lockout [delete]
+func (h UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) error {
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_MANAGE); err != nil {
+		return RenderError(w, err)
+	}
+	handle := mux.Vars(r)["userHandle"]
+	if isRoot(handle) && !middlewares.IsRootRequestor(r) {
+		return RenderError(w, errors.ErrForbidden)
+	}
+	if !middlewares.IsPlatformAdmin(r) {
+		organization, err := h.userService.GetOrganization(handle)
+		if err != nil {
+			return RenderError(w, err)
+		}
+		if err := middlewares.ValidateOrganization(r, organization); err != nil {
+			return RenderError(w, err)
+		}
+	}
+
+	if err := h.userService.Unlock(middlewares.RequestActor(r), handle); err != nil {
+		return RenderError(w, err)
+	}
+	return RenderResponse(w, response.NoContent())
+}
@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
-	"webdp/internal/api/http/repo/postgres"
+	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/services"
+	"webdp/internal/oidc"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
 
 	errors "webdp/internal/api/http"
 )
@@ -20,14 +22,44 @@ type DPContextKey struct {
 }
 
 const (
-	UserContextKey string = "user"
+	UserContextKey   string = "user"
+	ClaimsContextKey string = "claims"
 )
 
-func GetTokenAuthentication(tokenRepo postgres.TokenPostgres) func(http.Handler) http.Handler {
+/*
+Authenticates requests with an access token, an API key or, when idp is not
+nil, a token of the identity provider. API keys and tokens of the provider are
+exchanged for an access token that replaces them in the request, so that the
+handlers only see access tokens. The access token is verified with the key
+ring of tokens once, its claims are kept in the context for RequestClaims.
+Requests with a key that is limited to some datasets are refused for other
+datasets and for routes that are not about a dataset, see CheckDatasetScope.
+*/
+func GetTokenAuthentication(tokens services.TokenService, idp *services.OidcService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var claims services.JWTTokenClaims
-			tokenString, err := ExtracAuthnHeader(r.Header, &claims)
+			auth, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			exchanged := false
+			if strings.HasPrefix(auth, entity.API_KEY_PREFIX) {
+				token, err := tokens.AuthenticateApiKey(auth)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				r.Header.Set("Authorization", "Bearer "+token)
+				exchanged = true
+			} else if idp != nil && oidc.Issuer(auth) == idp.Issuer() {
+				token, err := idp.AuthenticateToken(auth, GetRequestId(r))
+				if err != nil {
+					http.Error(w, err.Error(), errors.ExpandError(err).GetStatusCode())
+					return
+				}
+				r.Header.Set("Authorization", "Bearer "+token)
+				exchanged = true
+			}
+
+			raw, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			claims, err := tokens.ParseAccessToken(raw)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
 				return
@@ -37,49 +69,69 @@ func GetTokenAuthentication(tokenRepo postgres.TokenPostgres) func(http.Handler)
 				return
 			}
 
+			// the session of the token may have been revoked, exchanged tokens have no session
 			user := claims.Handle
-			savedToken, err := tokenRepo.GetUserToken(user)
-			if err != nil {
+			if claims.Exchanged() != exchanged || (!exchanged && !tokens.SessionActive(user, claims.Id)) {
 				http.Error(w, "unauthorized: faulty token", http.StatusUnauthorized)
 				return
 			}
 
-			if tokenString != savedToken {
-				http.Error(w, "unauthorized: faulty token", http.StatusUnauthorized)
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			if err := CheckDatasetScope(claims, r.Method, route, mux.Vars(r)); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
 				return
 			}
 			ctx := context.WithValue(r.Context(), DPContextKey{Key: UserContextKey}, user)
+			ctx = context.WithValue(ctx, DPContextKey{Key: ClaimsContextKey}, claims)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func ExtracAuthnHeader[C jwt.Claims](header http.Header, v C) (string, error) {
-	auth := header.Get("Authorization")
-	if auth == "" {
-		return "", fmt.Errorf("%w: missing header", errors.ErrBadRequest)
+// the prefixes of the routes of queries, whose handlers check the dataset of the query
+var queryRoutes = []string{"/v1/query/", "/v2/queries/"}
+
+// the routes that list the datasets, which only lists the datasets of a limited token
+var datasetListRoutes = []string{"/v1/datasets", "/v2/datasets"}
+
+/*
+Checks whether a token can reach the route with the method, path template and
+variables. A token that is limited to some datasets reaches the routes with
+one of its datasets in the path, the list of datasets and the routes of
+queries, but no other routes, such as the budgets of a user, the sessions or
+the creation of datasets.
+*/
+func CheckDatasetScope(claims services.JWTTokenClaims, method string, route string, vars map[string]string) error {
+	if claims.Datasets == nil {
+		return nil
 	}
-
-	if !strings.HasPrefix(auth, "Bearer ") {
-		return "", fmt.Errorf("%w: expected prefix \"Bearer \"", errors.ErrBadRequest)
+	if id, ok := vars["datasetId"]; ok {
+		dataset, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || !claims.InScope(dataset) {
+			return fmt.Errorf("%w: the api key has no access to the dataset", errors.ErrForbidden)
+		}
+		return nil
 	}
-
-	auth = strings.Replace(auth, "Bearer ", "", 1)
-
-	_, err := jwt.ParseWithClaims(auth, v, func(t *jwt.Token) (interface{}, error) {
-		key := os.Getenv("AUTH_SIGN_KEY")
-		if key == "" {
-			// under development
-			panic("missing key")
+	if method == http.MethodGet && slices.Contains(datasetListRoutes, route) {
+		return nil
+	}
+	for _, prefix := range queryRoutes {
+		if strings.HasPrefix(route, prefix) {
+			return nil
 		}
-		return []byte(key), nil
-	})
-
-	if err != nil {
-		return "", err
 	}
+	return fmt.Errorf("%w: the api key is limited to datasets and their queries", errors.ErrForbidden)
+}
 
-	return auth, v.Valid()
-
+// the claims of the access token of the request, which the token authentication verified
+func RequestClaims(r *http.Request) (services.JWTTokenClaims, error) {
+	claims, ok := r.Context().Value(DPContextKey{Key: ClaimsContextKey}).(services.JWTTokenClaims)
+	if !ok {
+		return services.JWTTokenClaims{}, fmt.Errorf("%w: missing access token", errors.ErrInvalidToken)
+	}
+	return claims, nil
 }
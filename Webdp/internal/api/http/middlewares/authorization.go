@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"slices"
 	"strconv"
+	"strings"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/services"
 	"webdp/internal/api/http/utils"
@@ -15,26 +16,37 @@ import (
 )
 
 /*
-Checks whether the requester has at least one of the provided roles.
+Checks whether the roles of the requester give the permission. The permissions
+are resolved by the policy middleware, requests that did not pass it have none.
 */
-func ValidateRoles(r *http.Request, roles []string) error {
-	var userToken services.JWTTokenClaims
-	if _, err := ExtracAuthnHeader(r.Header, &userToken); err != nil {
+func ValidatePermission(r *http.Request, permission string) error {
+	permissions, _ := r.Context().Value(DPContextKey{Key: PermissionsContextKey}).([]string)
+	if !slices.Contains(permissions, permission) {
+		return fmt.Errorf("%w: missing permission %s", errors.ErrForbidden, permission)
+	}
+	return nil
+}
+
+/*
+Checks whether the requester can access the dataset, which requests with an
+API key that is limited to other datasets cannot.
+*/
+func ValidateDatasetScope(r *http.Request, dataset int64) error {
+	userToken, err := RequestClaims(r)
+	if err != nil {
 		return err
 	}
-	for _, role := range roles {
-		if slices.Contains(userToken.Roles, role) {
-			return nil
-		}
+	if !userToken.InScope(dataset) {
+		return fmt.Errorf("%w: the api key has no access to dataset %d", errors.ErrForbidden, dataset)
 	}
-	return fmt.Errorf("%w: missing role", errors.ErrForbidden)
+	return nil
 }
 
 /*
 Checks whether the requester is the requestee.
 */
 func ValidateSelfRequest(r *http.Request) error {
-	user, err := extractUserHandle(&r.Header)
+	user, err := extractUserHandle(r)
 	if err != nil {
 		return err
 	}
@@ -45,10 +57,10 @@ func ValidateSelfRequest(r *http.Request) error {
 }
 
 /*
-Checks whether the requester is the owner of the requested dataset.
+Checks whether the requester is a member of the requested dataset with one of the provided roles.
 */
-func ValidateOwnership(r *http.Request, ds *services.DatasetService) error {
-	user, err := extractUserHandle(&r.Header)
+func ValidateMembership(r *http.Request, ds *services.DatasetService, roles ...string) error {
+	user, err := extractUserHandle(r)
 	if err != nil {
 		return err
 	}
@@ -56,75 +68,58 @@ func ValidateOwnership(r *http.Request, ds *services.DatasetService) error {
 	if err != nil {
 		return err
 	}
-	owner, err := ds.GetDatasetOwner(id)
-	if err != nil || user != owner {
-		return fmt.Errorf("%w: user is not owner", errors.ErrForbidden)
+	role, err := ds.GetMemberRole(id, user)
+	if err != nil || !slices.Contains(roles, role) {
+		return fmt.Errorf("%w: user is not %s of the dataset", errors.ErrForbidden, strings.Join(roles, " or "))
 	}
 	return nil
 }
 
 /*
-Checks whether owner of a new dataset has a certain role.
+Checks whether the roles of the owner of a new dataset give the permission,
+and whether the owner is in the organization of the requester.
 */
-func ValidateNewOwnerRole(r *http.Request, us *services.UserService, role string) error {
+func ValidateNewOwnerPermission(r *http.Request, us *services.UserService, permission string) error {
 	var d entity.DatasetCreate
 	if err := utils.ParseJsonRequestBody(r, &d); err != nil {
 		return err
 	}
-	roles, err := us.GetUserRoles(d.Owner)
-	if err != nil || !slices.Contains(roles, role) {
-		return fmt.Errorf("%w: missing role", errors.ErrForbidden)
+	has, err := us.HasPermission(d.Owner, permission)
+	if err != nil || !has {
+		return fmt.Errorf("%w: owner is missing permission %s", errors.ErrForbidden, permission)
+	}
+	organization, err := us.GetOrganization(d.Owner)
+	if err != nil || organization != RequesterOrganization(r) {
+		return fmt.Errorf("%w: owner is not in the organization of the requester", errors.ErrForbidden)
 	}
 	return nil
 }
 
 /*
 Checks whether the requester has granted access to the dataset.
-A user has granted access to a dataset to which it has a budget (allocated or consumed).
+A user has granted access to a dataset to which it has a budget (allocated or consumed),
+or of which it is a member.
 */
-func ValidateGrantedAccess(r *http.Request, bs *services.BudgetService) error {
+func ValidateGrantedAccess(r *http.Request, bs *services.BudgetService, ds *services.DatasetService) error {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["datasetId"], 10, 64)
 	if err != nil {
 		return err
 	}
 
-	user, err := extractUserHandle(&r.Header)
+	user, err := extractUserHandle(r)
 	if err != nil {
 		return err
 	}
 
-	return hasAccess(bs, id, user)
-}
-
-/*
-Filters a list of datasets: Removes those where the requester does not have granted access.
-A user has granted access to a dataset to which it has a budget (allocated or consumed).
-*/
-func FilterGrantedAccess(r *http.Request, bs *services.BudgetService, datasets *[]entity.DatasetInfo) error {
-	if datasets == nil {
-		return errors.ErrNotFound
-	}
-
-	user, err := extractUserHandle(&r.Header)
-	if err != nil {
-		return err
-	}
-	var accessed []entity.DatasetInfo
-	for _, set := range *datasets {
-		if err := hasAccess(bs, set.Id, user); err == nil {
-			accessed = append(accessed, set)
-		}
-	}
-	if len(accessed) == 0 {
-		return fmt.Errorf("%w: no access to datasets", errors.ErrForbidden)
+	if _, err := ds.GetMemberRole(id, user); err == nil {
+		return nil
 	}
-	*datasets = accessed
-	return nil
+	return hasAccess(bs, id, user)
 }
 
 func IsRootRequestor(r *http.Request) bool {
-	user, err := extractUserHandle(&r.Header)
+	user, err := extractUserHandle(r)
 	if err != nil {
 		return false
 	}
@@ -137,9 +132,9 @@ func IsRootRequestor(r *http.Request) bool {
 
 }
 
-func extractUserHandle(rh *http.Header) (string, error) {
-	var userToken services.JWTTokenClaims
-	if _, err := ExtracAuthnHeader(*rh, &userToken); err != nil {
+func extractUserHandle(r *http.Request) (string, error) {
+	userToken, err := RequestClaims(r)
+	if err != nil {
 		return "", err
 	}
 	return userToken.Handle, nil
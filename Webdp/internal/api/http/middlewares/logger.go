@@ -1,13 +1,59 @@
 package middlewares
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"regexp"
+	"webdp/internal/api/http/entity"
 )
 
+const (
+	RequestIdContextKey string = "request_id"
+	REQUEST_ID_HEADER   string = "X-Request-Id"
+)
+
+// ids that are taken from the request, others are replaced by a new id
+var requestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+/*
+Gives every request an id, the one in the X-Request-Id header if it has a
+valid one. The id is returned in the same header, and is recorded with the
+actions of the request in the audit log.
+*/
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !requestId.MatchString(id) {
+			bs := make([]byte, 16)
+			if _, err := rand.Read(bs); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			id = hex.EncodeToString(bs)
+		}
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), DPContextKey{Key: RequestIdContextKey}, id)))
+	})
+}
+
+// the id of the request, given by the request id middleware
+func GetRequestId(r *http.Request) string {
+	id, _ := r.Context().Value(DPContextKey{Key: RequestIdContextKey}).(string)
+	return id
+}
+
+// the requester as the actor of what the request does, for the audit log
+func RequestActor(r *http.Request) entity.Actor {
+	user, _ := r.Context().Value(DPContextKey{Key: UserContextKey}).(string)
+	return entity.Actor{Handle: user, Organization: RequesterOrganization(r), RequestId: GetRequestId(r)}
+}
+
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s := fmt.Sprintf("METHOD: %s | REQUEST_URI: %s", r.Method, r.URL.RequestURI())
+		s := fmt.Sprintf("METHOD: %s | REQUEST_URI: %s | REQUEST_ID: %s", r.Method, r.URL.RequestURI(), GetRequestId(r))
 		fmt.Println(s)
 		// Call the next handler, which can be another middleware in the chain, or the final handler.
 		next.ServeHTTP(w, r)
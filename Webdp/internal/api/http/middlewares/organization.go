@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/services"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	OrganizationContextKey string = "organization"
+)
+
+/*
+Resolves the organization of the requester, for RequesterOrganization. The
+datasets and users of other organizations in the path of a request are not
+found, except that platform admins reach the users of every organization.
+Runs after the policy middleware.
+*/
+func GetOrganizationScope(users services.UserService, datasets services.DatasetService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _ := r.Context().Value(DPContextKey{Key: UserContextKey}).(string)
+			organization, err := users.GetOrganization(user)
+			if err != nil {
+				http.Error(w, "unauthorized: unknown user", http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), DPContextKey{Key: OrganizationContextKey}, organization))
+
+			vars := mux.Vars(r)
+			id, onDataset := vars["datasetId"]
+			if dataset, err := strconv.ParseInt(id, 10, 64); onDataset && err == nil {
+				ok, err := inOrganization(organization, func() (string, error) { return datasets.GetDatasetOrganization(dataset) })
+				if err != nil || !ok {
+					renderScopeError(w, err, fmt.Sprintf("dataset %d", dataset))
+					return
+				}
+			}
+			// a user is only reached on a dataset of the same organization
+			if handle, ok := vars["userHandle"]; ok && (onDataset || !IsPlatformAdmin(r)) {
+				ok, err := inOrganization(organization, func() (string, error) { return users.GetOrganization(handle) })
+				if err != nil || !ok {
+					renderScopeError(w, err, handle)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// the organization of the requester, resolved by the organization middleware
+func RequesterOrganization(r *http.Request) string {
+	organization, _ := r.Context().Value(DPContextKey{Key: OrganizationContextKey}).(string)
+	return organization
+}
+
+/*
+Whether the requester manages the organizations, which takes permission
+org.manage in the default organization. Admins of other organizations only
+manage their own organization.
+*/
+func IsPlatformAdmin(r *http.Request) bool {
+	return RequesterOrganization(r) == entity.DEFAULT_ORGANIZATION && ValidatePermission(r, entity.PERM_ORG_MANAGE) == nil
+}
+
+/*
+Checks whether something of the organization is in the organization of the
+requester. Things of other organizations are not found rather than forbidden,
+so that their existence is not revealed.
+*/
+func ValidateOrganization(r *http.Request, organization string) error {
+	if organization == "" || organization != RequesterOrganization(r) {
+		return fmt.Errorf("%w: not found in the organization of the requester", errors.ErrNotFound)
+	}
+	return nil
+}
+
+// whether what the lookup finds is in the organization, what does not exist is left to the handlers
+func inOrganization(organization string, lookup func() (string, error)) (bool, error) {
+	other, err := lookup()
+	if err != nil && errors.ExpandError(err).GetStatusCode() == http.StatusNotFound {
+		return true, nil
+	}
+	return other == organization, err
+}
+
+func renderScopeError(w http.ResponseWriter, err error, what string) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, fmt.Sprintf("not found: %s not found", what), http.StatusNotFound)
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"webdp/internal/api/http/services"
+)
+
+const (
+	PermissionsContextKey string = "permissions"
+)
+
+/*
+Resolves the permissions that the roles of the requester give, for
+ValidatePermission. Runs after the token authentication.
+*/
+func GetPolicyAuthorization(policy services.PolicyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := RequestClaims(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			permissions, err := policy.Permissions(claims.Roles)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), DPContextKey{Key: PermissionsContextKey}, permissions)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/services"
+
+	errors "webdp/internal/api/http"
+)
+
+const (
+	RATE_LIMIT_HEADER     string = "X-RateLimit-Limit"
+	RATE_REMAINING_HEADER string = "X-RateLimit-Remaining"
+	RATE_RESET_HEADER     string = "X-RateLimit-Reset"
+)
+
+/*
+Limits the requests of the requester per class of endpoint, with the limits
+of its handle and roles. The limit, the requests left and the seconds until
+the window resets are returned in the X-RateLimit-* headers; requests over the
+limit are refused with 429 and a Retry-After header. Runs after the token
+authentication.
+*/
+func GetRateLimit(limits services.RateLimitService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := RequestClaims(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			now := time.Now()
+			state, err := limits.Allow(claims.Handle, claims.Roles, RateClass(r), now)
+			if state.Limit > 0 {
+				reset := strconv.Itoa(secondsUntil(now, state.Reset))
+				w.Header().Set(RATE_LIMIT_HEADER, strconv.Itoa(state.Limit))
+				w.Header().Set(RATE_REMAINING_HEADER, strconv.Itoa(state.Remaining))
+				w.Header().Set(RATE_RESET_HEADER, reset)
+				if err != nil {
+					w.Header().Set("Retry-After", reset)
+				}
+			}
+			if err != nil {
+				http.Error(w, err.Error(), errors.ExpandError(err).GetStatusCode())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// the class of the endpoint of the request, queries are posted under /queries, or /query in v1
+func RateClass(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+		return entity.RATE_CLASS_READ
+	case strings.Contains(r.URL.Path, "/queries/") || strings.Contains(r.URL.Path, "/query/"):
+		return entity.RATE_CLASS_QUERY
+	default:
+		return entity.RATE_CLASS_WRITE
+	}
+}
+
+// the whole seconds from now until the time, at least 1
+func secondsUntil(now time.Time, until time.Time) int {
+	return max(int(math.Ceil(until.Sub(now).Seconds())), 1)
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, owner, name, roles, datasets, created_by, created_time, expires_time, last_used_time"
+
+func (d TokenPostgres) CreateApiKey(k entity.ApiKey, keyHash string) error {
+	q := `INSERT INTO ApiKeys (id, owner, name, key_hash, roles, datasets, created_by, created_time, expires_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := d.db.Exec(q, k.Id, k.Owner, k.Name, keyHash, pq.Array(k.Roles), pq.Array(k.Datasets), k.CreatedBy, k.CreatedOn, k.ExpiresOn)
+	return err
+}
+
+// the key with the given id and the hash of the key
+func (d TokenPostgres) GetApiKey(id string) (entity.ApiKey, string, error) {
+	var hash string
+	k, err := scanApiKey(d.db.QueryRow("SELECT "+apiKeyColumns+", key_hash FROM ApiKeys WHERE id = $1", id), &hash)
+	if err != nil {
+		return entity.ApiKey{}, "", err
+	}
+	return k, hash, nil
+}
+
+// the roles the user has now, ErrNotFound if the user does not exist
+func (d TokenPostgres) GetOwnerRoles(owner string) ([]string, error) {
+	var roles []string
+	err := d.db.QueryRow("SELECT roles FROM GetUsers WHERE handle = $1", owner).Scan(pq.Array(&roles))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	return roles, err
+}
+
+// records that the key was used
+func (d TokenPostgres) TouchApiKey(id string, usedOn time.Time) error {
+	_, err := d.db.Exec("UPDATE ApiKeys SET last_used_time = $1 WHERE id = $2", usedOn, id)
+	return err
+}
+
+// the keys of a user, the latest first
+func (d TokenPostgres) GetApiKeys(owner string) ([]entity.ApiKey, error) {
+	rows, err := d.db.Query("SELECT "+apiKeyColumns+" FROM ApiKeys WHERE owner = $1 ORDER BY created_time DESC, id", owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.ApiKey, 0)
+	for rows.Next() {
+		k, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+
+	return out, rows.Err()
+}
+
+func (d TokenPostgres) DeleteApiKey(owner string, id string) error {
+	res, err := d.db.Exec("DELETE FROM ApiKeys WHERE id = $1 AND owner = $2", id, owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+func scanApiKey(row interface{ Scan(...any) error }, extra ...any) (entity.ApiKey, error) {
+	var k entity.ApiKey
+	var used pq.NullTime
+	dest := []any{&k.Id, &k.Owner, &k.Name, pq.Array(&k.Roles), pq.Array(&k.Datasets), &k.CreatedBy, &k.CreatedOn, &k.ExpiresOn, &used}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return entity.ApiKey{}, err
+	}
+	if used.Valid {
+		k.LastUsedOn = &used.Time
+	}
+	if k.Datasets == nil {
+		k.Datasets = []int64{}
+	}
+	return k, nil
+}
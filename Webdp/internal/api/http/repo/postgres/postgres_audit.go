@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"database/sql"
+	"webdp/internal/api/http/entity"
+)
+
+type AuditPostgres struct {
+	db *sql.DB
+}
+
+func NewAuditPostgres(conn *sql.DB) AuditPostgres {
+	return AuditPostgres{db: conn}
+}
+
+const auditColumns = "id, time, actor, organization, action, target, before, after, request_id, prev_hash, hash"
+
+const auditWhere = `($1 = '' OR organization = $1)
+	AND ($2 = '' OR actor = $2)
+	AND ($3 = '' OR action = $3 OR starts_with(action, $3 || '.'))
+	AND ($4 = '' OR target = $4 OR starts_with(target, $4 || '/'))
+	AND ($5::timestamptz IS NULL OR time >= $5)
+	AND ($6::timestamptz IS NULL OR time < $6)`
+
+// the key of the advisory lock that appends to the audit log hold
+const auditLockKey = 0x61756469746c6f67
+
+/*
+Appends the entry to the audit log, chained to the last entry, in a transaction
+of its own. The entry is returned with its id and hashes.
+*/
+func (a AuditPostgres) AppendAuditEntry(e entity.AuditEntry) (_ entity.AuditEntry, err error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return e, err
+	}
+	defer func() { dfun(err, tx) }()
+
+	if e, err = appendAuditEntry(tx, e); err != nil {
+		return e, err
+	}
+	return e, tx.Commit()
+}
+
+/*
+Appends the entry to the audit log in the transaction of the action it
+records, so that the action is undone if it can not be recorded. Appends hold
+an advisory lock until their transaction ends, so that every entry is chained
+to the one before it and the ids follow the chain; reads and other writes do
+not wait for it. The lock serializes the transactions that append, which
+should append as their last statement.
+*/
+func appendAuditEntry(tx *sql.Tx, e entity.AuditEntry) (entity.AuditEntry, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", auditLockKey); err != nil {
+		return e, err
+	}
+	err := tx.QueryRow("SELECT hash FROM AuditLog ORDER BY id DESC LIMIT 1").Scan(&e.PrevHash)
+	if err == sql.ErrNoRows {
+		e.PrevHash, err = "", nil
+	}
+	if err != nil {
+		return e, err
+	}
+	if e.Hash, err = e.ComputeHash(); err != nil {
+		return e, err
+	}
+
+	q := `INSERT INTO AuditLog (time, actor, organization, action, target, before, after, request_id, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	err = tx.QueryRow(q, e.Time, e.Actor, e.Organization, e.Action, e.Target, jsonText(e.Before), jsonText(e.After), e.RequestId, e.PrevHash, e.Hash).Scan(&e.Id)
+	return e, err
+}
+
+// a page of the entries that match the filter, newest first
+func (a AuditPostgres) ListAuditEntries(f entity.AuditFilter) (entity.AuditPage, error) {
+	args := auditArgs(f)
+	q := "SELECT " + auditColumns + ", COUNT(*) OVER () FROM AuditLog WHERE " + auditWhere + " ORDER BY id DESC LIMIT $7 OFFSET $8"
+	rows, err := a.db.Query(q, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return entity.AuditPage{}, err
+	}
+	defer rows.Close()
+
+	page := entity.AuditPage{Entries: make([]entity.AuditEntry, 0)}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows, &page.Total)
+		if err != nil {
+			return entity.AuditPage{}, err
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.AuditPage{}, err
+	}
+
+	// a page past the last entry has no row to count on
+	if len(page.Entries) == 0 && f.Offset > 0 {
+		if err := a.db.QueryRow("SELECT COUNT(*) FROM AuditLog WHERE "+auditWhere, args...).Scan(&page.Total); err != nil {
+			return entity.AuditPage{}, err
+		}
+	}
+	return page, nil
+}
+
+/*
+Calls fn with every entry that matches the filter, oldest first, without
+reading them all into memory. The limit and offset of the filter are ignored.
+The entries are read in one statement, appends meanwhile are not seen.
+*/
+func (a AuditPostgres) EachAuditEntry(f entity.AuditFilter, fn func(entity.AuditEntry) error) error {
+	rows, err := a.db.Query("SELECT "+auditColumns+" FROM AuditLog WHERE "+auditWhere+" ORDER BY id", auditArgs(f)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func auditArgs(f entity.AuditFilter) []any {
+	var from, to sql.NullTime
+	if f.From != nil {
+		from = sql.NullTime{Time: *f.From, Valid: true}
+	}
+	if f.To != nil {
+		to = sql.NullTime{Time: *f.To, Valid: true}
+	}
+	return []any{f.Organization, f.Actor, f.Action, f.Target, from, to}
+}
+
+func scanAuditEntry(row interface{ Scan(...any) error }, extra ...any) (entity.AuditEntry, error) {
+	var e entity.AuditEntry
+	var before, after []byte
+	dest := []any{&e.Id, &e.Time, &e.Actor, &e.Organization, &e.Action, &e.Target, &before, &after, &e.RequestId, &e.PrevHash, &e.Hash}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return entity.AuditEntry{}, err
+	}
+	e.Before, e.After = before, after
+	return e, nil
+}
+
+// the text of a JSON value, NULL if there is none. Bytes would be sent as bytea
+func jsonText(raw []byte) sql.NullString {
+	return sql.NullString{String: string(raw), Valid: raw != nil}
+}
@@ -22,7 +22,10 @@ func (b BudgetPostgres) GetUserBudgets(userHandle string) ([]entity.UserBudgetsR
 		return []entity.UserBudgetsResponse{}, err
 	}
 	defer dfun(err, tx)
-	q := "SELECT dataset, all_epsilon, all_delta, con_epsilon, con_delta FROM UserBudgetAllocation WHERE userid = $1"
+	// budgets on deleted datasets are hidden with the dataset
+	q := `SELECT U.dataset, U.all_epsilon, U.all_delta, U.con_epsilon, U.con_delta
+		FROM UserBudgetAllocation AS U JOIN Dataset AS D ON D.id = U.dataset
+		WHERE U.userid = $1 AND D.deleted_time IS NULL`
 
 	rows, err := tx.Query(q, userHandle)
 	if err != nil {
@@ -145,35 +148,47 @@ func (b BudgetPostgres) budgetHelper(eps string, delta string, userhandle string
 	return entity.Budget{Epsilon: e, Delta: &d.Float64}, nil
 }
 
-func (b BudgetPostgres) CreateUserBudgetAllocation(userhandle string, datasetId int64, allocation entity.Budget) error {
+/*
+The changes of the allocations and the consumed budgets are recorded with the
+audit entry in the same transaction, a change that can not be recorded is not
+made.
+*/
+func (b BudgetPostgres) CreateUserBudgetAllocation(userhandle string, datasetId int64, allocation entity.Budget, audit entity.AuditEntry) (err error) {
 	tx, err := b.db.Begin()
 	if err != nil {
 		return err
 	}
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 	q := "INSERT INTO UserBudgetAllocation (dataset, userid, all_epsilon, all_delta) VALUES ($1, $2, $3, $4)"
 	_, err = tx.Exec(q, datasetId, userhandle, allocation.Epsilon, allocation.Delta)
 	if err != nil {
 		return err
 	}
+	if _, err = appendAuditEntry(tx, audit); err != nil {
+		return err
+	}
 	if err = tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (b BudgetPostgres) UpdateUserBudgetAllocation(userHandle string, datasetId int64, allocation entity.Budget) error {
+func (b BudgetPostgres) UpdateUserBudgetAllocation(userHandle string, datasetId int64, allocation entity.Budget, audit entity.AuditEntry) (err error) {
 	tx, err := b.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
 	q := "UPDATE UserBudgetAllocation SET all_epsilon = $1, all_delta = $2 WHERE dataset = $3 AND userid = $4"
 	_, err = tx.Exec(q, utils.RoundFloat(allocation.Epsilon, 10), allocation.Delta, datasetId, userHandle)
 	if err != nil {
-		return errors.ErrNotFound
+		err = errors.ErrNotFound
+		return err
+	}
+	if _, err = appendAuditEntry(tx, audit); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -182,19 +197,23 @@ func (b BudgetPostgres) UpdateUserBudgetAllocation(userHandle string, datasetId
 	return nil
 }
 
-func (b BudgetPostgres) UpdateUserConsumedBudget(userHandle string, datasetId int64, newConsumed entity.Budget) error {
+func (b BudgetPostgres) UpdateUserConsumedBudget(userHandle string, datasetId int64, newConsumed entity.Budget, audit entity.AuditEntry) (err error) {
 	tx, err := b.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
 	q := "UPDATE UserBudgetAllocation SET con_epsilon = $1, con_delta = $2 WHERE dataset = $3 AND userid = $4"
 
 	_, err = tx.Exec(q, newConsumed.Epsilon, newConsumed.Delta, datasetId, userHandle)
 	if err != nil {
-		return errors.ErrNotFound
+		err = errors.ErrNotFound
+		return err
+	}
+	if _, err = appendAuditEntry(tx, audit); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -204,18 +223,22 @@ func (b BudgetPostgres) UpdateUserConsumedBudget(userHandle string, datasetId in
 	return nil
 }
 
-func (b BudgetPostgres) DeleteUserBudgetAllocation(userHandle string, datasetId int64) error {
+func (b BudgetPostgres) DeleteUserBudgetAllocation(userHandle string, datasetId int64, audit entity.AuditEntry) (err error) {
 	tx, err := b.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
 	q := "DELETE FROM UserBudgetAllocation WHERE userid = $1 AND dataset = $2"
 	_, err = tx.Exec(q, userHandle, datasetId)
 	if err != nil {
-		return errors.ErrNotFound
+		err = errors.ErrNotFound
+		return err
+	}
+	if _, err = appendAuditEntry(tx, audit); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -3,9 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 	"webdp/internal/api/http/entity"
+	"webdp/internal/encryption"
 
 	errors "webdp/internal/api/http"
 
@@ -13,52 +17,45 @@ import (
 )
 
 type DatasetPostgres struct {
-	db *sql.DB
+	db   *sql.DB
+	keys *encryption.KeyRing
 }
 
 type rawtype struct {
 	name   string
-	low    sql.NullInt32
-	high   sql.NullInt32
+	low    sql.NullFloat64
+	high   sql.NullFloat64
 	labels []string
 }
 
-func NewDatasetPostgres(conn *sql.DB) DatasetPostgres {
-	return DatasetPostgres{db: conn}
+// the rows of uploaded data are encrypted with data keys wrapped by the key ring
+func NewDatasetPostgres(conn *sql.DB, keys *encryption.KeyRing) DatasetPostgres {
+	return DatasetPostgres{db: conn, keys: keys}
 }
 
+// a dataset that is not deleted
 func (d DatasetPostgres) GetDataset(datasetId int64) (entity.DatasetInfo, error) {
+	return d.getDataset(datasetId, false)
+}
+
+// a deleted dataset that has not been purged yet
+func (d DatasetPostgres) GetDeletedDataset(datasetId int64) (entity.DatasetInfo, error) {
+	return d.getDataset(datasetId, true)
+}
+
+func (d DatasetPostgres) getDataset(datasetId int64, deleted bool) (entity.DatasetInfo, error) {
 	tx, err := d.db.BeginTx(context.Background(), nil)
 	if err != nil {
 		return entity.DatasetInfo{}, err
 	}
 
 	defer dfun(err, tx)
-	q := "SELECT id, name, owner, privacy_notion, total_epsilon, total_delta, loaded, created_time, updated_time, loaded_time FROM LoadedDatasets WHERE id = $1"
-
-	row := tx.QueryRow(q, datasetId)
-	var id int64
-	var name, owner, privn string
-	var ct, ut time.Time
-	var lt pq.NullTime
-	var loaded bool
-	var eps, del float64
-	err = row.Scan(&id, &name, &owner, &privn, &eps, &del, &loaded, &ct, &ut, &lt)
+	q := "SELECT " + datasetColumns + " FROM LoadedDatasets WHERE id = $1 AND (deleted_time IS NOT NULL) = $2"
 
+	dinfo, err := scanDataset(tx.QueryRow(q, datasetId, deleted))
 	if err != nil {
 		return entity.DatasetInfo{}, errors.ErrNotFound
 	}
-	dinfo := &entity.DatasetInfo{
-		Id:            id,
-		Name:          name,
-		Owner:         owner,
-		PrivacyNotion: privn,
-		TotalBudget:   entity.Budget{Epsilon: eps, Delta: &del},
-		CreatedOn:     ct,
-		UpdatedOn:     ut,
-		Loaded:        loaded,
-		LoadedOn:      lt.Time,
-	}
 
 	cs, err := getColumnSchema(tx, datasetId)
 	if err != nil {
@@ -71,78 +68,158 @@ func (d DatasetPostgres) GetDataset(datasetId int64) (entity.DatasetInfo, error)
 
 }
 
-func (d DatasetPostgres) GetDatasets() ([]entity.DatasetInfo, error) {
-	tx, err := d.db.BeginTx(context.Background(), nil)
-	if err != nil {
-		return []entity.DatasetInfo{}, err
+// the columns read by scanDataset
+const datasetColumns = "id, name, owner, organization, privacy_notion, total_epsilon, total_delta, description, tags, source, contact, loaded, created_time, updated_time, loaded_time, version, deleted_time"
+
+func scanDataset(row interface{ Scan(...any) error }, extra ...any) (*entity.DatasetInfo, error) {
+	var d entity.DatasetInfo
+	var lt, dt pq.NullTime
+	var version sql.NullInt64
+	var del float64
+	dest := []any{&d.Id, &d.Name, &d.Owner, &d.Organization, &d.PrivacyNotion, &d.TotalBudget.Epsilon, &del, &d.Description, pq.Array(&d.Tags), &d.Source, &d.Contact, &d.Loaded, &d.CreatedOn, &d.UpdatedOn, &lt, &version, &dt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	d.TotalBudget.Delta = &del
+	d.LoadedOn = lt.Time
+	if dt.Valid {
+		d.DeletedOn = &dt.Time
 	}
+	d.Version = version.Int64
+	if d.Tags == nil {
+		d.Tags = []string{}
+	}
+	return &d, nil
+}
 
-	defer dfun(err, tx)
-	q := "SELECT id, name, owner, privacy_notion, total_epsilon, total_delta, loaded, created_time, updated_time, loaded_time FROM LoadedDatasets"
-	rs, err := tx.Query(q)
+// sort fields of DatasetFilter as columns of LoadedDatasets
+var datasetSortColumns = map[string]string{
+	entity.SORT_ID:      "id",
+	entity.SORT_NAME:    "name",
+	entity.SORT_CREATED: "created_time",
+	entity.SORT_UPDATED: "updated_time",
+	entity.SORT_LOADED:  "loaded_time",
+}
+
+// the schema of a dataset as a JSON array of ColumnSchema
+const schemaJSON = `COALESCE((
+	SELECT json_agg(json_strip_nulls(json_build_object(
+		'name', C.column_name,
+		'type', json_build_object('name', C.data_type, 'low', C.low, 'high', C.high, 'labels', C.labels)
+	)) ORDER BY C.position)
+	FROM ColumnSchemas AS C WHERE C.dataset = D.id
+), '[]')`
+
+// a percent sign or underscore in a name search matches itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+/*
+A page of the datasets that match the filter, with their schemas, in a single
+query. The total is the number of datasets that match the filter.
+*/
+func (d DatasetPostgres) ListDatasets(f entity.DatasetFilter) (entity.DatasetPage, error) {
+	order := "ASC"
+	if f.Descending {
+		order = "DESC"
+	}
+	sortColumn, ok := datasetSortColumns[f.Sort]
+	if !ok {
+		sortColumn = "id"
+	}
+
+	var loaded sql.NullBool
+	if f.Loaded != nil {
+		loaded = sql.NullBool{Bool: *f.Loaded, Valid: true}
+	}
+
+	where := `($1 = '' OR D.owner = $1)
+		AND ($2::boolean IS NULL OR D.loaded = $2)
+		AND ($3 = '' OR D.privacy_notion::text = $3)
+		AND ($4 = '' OR $4 = ANY(D.tags))
+		AND ($5 = '' OR D.name ILIKE '%' || $5 || '%')
+		AND ($6 = '' OR EXISTS (SELECT 1 FROM UserBudgetAllocation AS U WHERE U.dataset = D.id AND U.userid = $6)
+			OR EXISTS (SELECT 1 FROM DatasetMembers AS M WHERE M.dataset = D.id AND M.userid = $6))
+		AND (D.deleted_time IS NOT NULL) = $7
+		AND ($8::bigint[] IS NULL OR D.id = ANY($8))
+		AND D.organization = $9`
+	args := []any{f.Owner, loaded, f.PrivacyNotion, f.Tag, likeEscaper.Replace(f.Search), f.GrantedTo, f.Deleted, pq.Array(f.Ids), f.Organization}
+
+	q := fmt.Sprintf(`SELECT D.%s, %s, COUNT(*) OVER ()
+		FROM LoadedDatasets AS D
+		WHERE %s
+		ORDER BY D.%s %s NULLS LAST, D.id %s
+		LIMIT $10 OFFSET $11`,
+		strings.ReplaceAll(datasetColumns, ", ", ", D."), schemaJSON, where, sortColumn, order, order)
+
+	rows, err := d.db.Query(q, append(args, f.Limit, f.Offset)...)
 	if err != nil {
-		return []entity.DatasetInfo{}, err
+		return entity.DatasetPage{}, err
 	}
-	temp := make([]*entity.DatasetInfo, 0)
-	for rs.Next() {
-		var id int64
-		var name, owner, privn string
-		var ct, ut time.Time
-		var lt pq.NullTime
-		var loaded bool
-		var eps, del float64
-
-		err = rs.Scan(&id, &name, &owner, &privn, &eps, &del, &loaded, &ct, &ut, &lt)
+	defer rows.Close()
+
+	page := entity.DatasetPage{Datasets: make([]entity.DatasetInfo, 0)}
+	for rows.Next() {
+		var schema []byte
+		dataset, err := scanDataset(rows, &schema, &page.Total)
 		if err != nil {
-			return []entity.DatasetInfo{}, err
+			return entity.DatasetPage{}, err
 		}
-		temp = append(temp, &entity.DatasetInfo{
-			Id:            id,
-			Name:          name,
-			Owner:         owner,
-			PrivacyNotion: privn,
-			TotalBudget:   entity.Budget{Epsilon: eps, Delta: &del},
-			CreatedOn:     ct,
-			UpdatedOn:     ut,
-			Loaded:        loaded,
-			LoadedOn:      lt.Time,
-		})
-	}
-	rs.Close()
-	out := make([]entity.DatasetInfo, 0)
-	for _, di := range temp {
-		cs, err := getColumnSchema(tx, di.Id)
-		if err != nil {
-			return []entity.DatasetInfo{}, err
+		if err := json.Unmarshal(schema, &dataset.Schema); err != nil {
+			return entity.DatasetPage{}, err
 		}
-		di.Schema = cs
-		out = append(out, *di)
+		page.Datasets = append(page.Datasets, *dataset)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.DatasetPage{}, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return []entity.DatasetInfo{}, err
+	// a page past the last dataset has no row to count on
+	if len(page.Datasets) == 0 && f.Offset > 0 {
+		q = "SELECT COUNT(*) FROM LoadedDatasets AS D WHERE " + where
+		if err := d.db.QueryRow(q, args...).Scan(&page.Total); err != nil {
+			return entity.DatasetPage{}, err
+		}
 	}
 
-	return out, nil
+	return page, nil
 }
 
-func (d DatasetPostgres) CreateDataset(dataset entity.DatasetCreate) (int64, error) {
+/*
+Creates a dataset in the organization of its owner. ErrConflict is returned if
+its total budget does not fit within the budget cap of the organization.
+*/
+func (d DatasetPostgres) CreateDataset(dataset entity.DatasetCreate) (id int64, err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
 		return 0, err
 	}
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
-	var id int64
+	var organization string
+	if err = tx.QueryRow("SELECT organization FROM Users WHERE handle = $1", dataset.Owner).Scan(&organization); err != nil {
+		return 0, err
+	}
+	if err = withinBudgetCap(tx, organization, 0, dataset.TotalBudget); err != nil {
+		return 0, err
+	}
 
 	created := time.Now().UTC()
-	q := "INSERT INTO Dataset (name, owner, privacy_notion, total_epsilon, total_delta, created_time, updated_time) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	tags := dataset.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	q := "INSERT INTO Dataset (name, owner, organization, privacy_notion, total_epsilon, total_delta, description, tags, source, contact, created_time, updated_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id"
 	err = tx.QueryRow(q,
 		dataset.Name,
 		dataset.Owner,
+		organization,
 		dataset.PrivacyNotion,
 		dataset.TotalBudget.Epsilon,
 		dataset.TotalBudget.Delta,
+		dataset.Description,
+		pq.Array(tags),
+		dataset.Source,
+		dataset.Contact,
 		created,
 		created).Scan(&id)
 
@@ -150,30 +227,55 @@ func (d DatasetPostgres) CreateDataset(dataset entity.DatasetCreate) (int64, err
 		return 0, err
 	}
 
-	for _, cs := range dataset.Schema {
-		err = insertColumnSchema(tx, id, cs)
+	for i, cs := range dataset.Schema {
+		err = insertColumnSchema(tx, id, i, cs)
 		if err != nil {
 			return 0, err
 		}
 	}
 
+	q = "INSERT INTO DatasetMembers (dataset, userid, role, added_by, added_time) VALUES ($1, $2, $3, $2, $4)"
+	if _, err = tx.Exec(q, id, dataset.Owner, entity.MEMBER_OWNER, created); err != nil {
+		return 0, err
+	}
+
 	tx.Commit()
 	return id, nil
 }
 
-func (d DatasetPostgres) UpdateDataset(dataset int64, patch entity.DatasetPatch) error {
+// ErrConflict is returned if the new total budget does not fit within the budget cap of the organization
+func (d DatasetPostgres) UpdateDataset(dataset int64, patch entity.DatasetPatch) (err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
+
+	var organization string
+	if err = tx.QueryRow("SELECT organization FROM Dataset WHERE id = $1", dataset).Scan(&organization); err != nil {
+		err = errors.ErrNotFound
+		return err
+	}
+	if err = withinBudgetCap(tx, organization, dataset, patch.TotalBudget); err != nil {
+		return err
+	}
+
 	updated := time.Now().UTC()
-	q := "UPDATE Dataset SET name = $1, owner = $2, total_epsilon = $3, total_delta = $4, updated_time = $5 WHERE id = $6"
+	// metadata left out of the patch is NULL and kept
+	var tags any
+	if patch.Tags != nil {
+		tags = pq.Array(*patch.Tags)
+	}
+	// the owner only changes when a transfer is accepted
+	q := `UPDATE Dataset SET name = $1, total_epsilon = $2, total_delta = $3, updated_time = $4,
+		description = COALESCE($6, description), tags = COALESCE($7, tags), source = COALESCE($8, source), contact = COALESCE($9, contact)
+		WHERE id = $5`
 
-	_, err = tx.Exec(q, patch.Name, patch.Owner, patch.TotalBudget.Epsilon, patch.TotalBudget.Delta, updated, dataset)
+	_, err = tx.Exec(q, patch.Name, patch.TotalBudget.Epsilon, patch.TotalBudget.Delta, updated, dataset, patch.Description, tags, patch.Source, patch.Contact)
 	if err != nil {
-		return errors.ErrNotFound
+		err = errors.ErrNotFound
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -184,87 +286,274 @@ func (d DatasetPostgres) UpdateDataset(dataset int64, patch entity.DatasetPatch)
 
 }
 
-func (d DatasetPostgres) UploadData(dataset int64, data []byte) error {
-	ltime := time.Now()
+/*
+Stores the rows returned by next as a new version of the data, one CSV encoded
+line per row, until next returns io.EOF. Any other error from next aborts the
+upload and is returned as is. Everything is written in a single transaction so
+a failed upload stores nothing. The first version is always a replace. A reset
+of the budgets drops the rows of the older versions.
+*/
+func (d DatasetPostgres) StoreData(dataset int64, header string, opts entity.UploadOptions, next func() (string, error)) (version int64, err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
-	q := "INSERT INTO DataUpload (dataset, loaded_time, loaded_data) VALUES ($1, $2, $3)"
-	_, err = tx.Exec(q, dataset, ltime, data)
+	// uploads to the same dataset wait for each other
+	if _, err = tx.Exec("SELECT id FROM Dataset WHERE id = $1 FOR UPDATE", dataset); err != nil {
+		return 0, err
+	}
+
+	var latest, latestRows int64
+	var latestHeader string
+	q := "SELECT version, total_rows, header FROM DataUpload WHERE dataset = $1 ORDER BY version DESC LIMIT 1"
+	err = tx.QueryRow(q, dataset).Scan(&latest, &latestRows, &latestHeader)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	mode := opts.Mode
+	if latest == 0 {
+		mode = entity.VERSION_REPLACE
 	}
-	return nil
-}
+	if mode == entity.VERSION_APPEND && header != latestHeader {
+		err = errors.ErrBadInput
+		return 0, err
+	}
+	version = latest + 1
 
-func (d DatasetPostgres) GetUploadedData(dataset int64) ([]byte, error) {
-	tx, err := d.db.Begin()
+	q = "INSERT INTO DataUpload (dataset, version, mode, loaded_time, header, row_count, total_rows, budget_reset) VALUES ($1, $2, $3, $4, $5, 0, 0, $6)"
+	if _, err = tx.Exec(q, dataset, version, mode, time.Now(), header, opts.ResetBudget); err != nil {
+		return 0, err
+	}
+
+	key, err := d.dataKey(tx, dataset)
 	if err != nil {
-		return []byte{}, err
+		return 0, err
 	}
-	q := "SELECT loaded_data FROM DataUpload WHERE dataset = $1"
-	row := tx.QueryRow(q, dataset)
-	var out []byte
-	err = row.Scan(&out)
 
+	stmt, err := tx.Prepare(pq.CopyIn("datarows", "dataset", "version", "row_number", "line"))
 	if err != nil {
-		return []byte{}, errors.ErrNotFound
+		return 0, err
+	}
+
+	var rows int64
+	for {
+		line, nerr := next()
+		if nerr == io.EOF {
+			break
+		}
+		if nerr != nil {
+			stmt.Close()
+			err = nerr
+			return 0, err
+		}
+		rows++
+		if _, err = stmt.Exec(dataset, version, rows, key.Encrypt([]byte(line))); err != nil {
+			stmt.Close()
+			return 0, err
+		}
+	}
+
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, err
+	}
+	if err = stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	total := rows
+	if mode == entity.VERSION_APPEND {
+		total += latestRows
+	}
+	q = "UPDATE DataUpload SET row_count = $1, total_rows = $2 WHERE dataset = $3 AND version = $4"
+	if _, err = tx.Exec(q, rows, total, dataset, version); err != nil {
+		return 0, err
+	}
+
+	// the rows the budgets were consumed on can no longer be queried after a reset
+	if opts.ResetBudget {
+		q = "UPDATE UserBudgetAllocation SET con_epsilon = NULL, con_delta = NULL WHERE dataset = $1"
+		if _, err = tx.Exec(q, dataset); err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec("DELETE FROM DataRows WHERE dataset = $1 AND version < $2", dataset, version); err != nil {
+			return 0, err
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return []byte{}, err
+		return 0, err
 	}
-	return out, nil
+	return version, nil
 }
 
-func (d DatasetPostgres) DeleteDataset(dataset int64) error {
-	q := "DELETE FROM Dataset WHERE id = $1"
-	tx, err := d.db.Begin()
+// the versions of the data of a dataset, oldest first
+func (d DatasetPostgres) GetDataVersions(dataset int64) ([]entity.DataVersion, error) {
+	q := "SELECT version, mode, row_count, total_rows, loaded_time, budget_reset FROM DataUpload WHERE dataset = $1 ORDER BY version"
+	rows, err := d.db.Query(q, dataset)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.DataVersion, 0)
+	for rows.Next() {
+		var v entity.DataVersion
+		if err := rows.Scan(&v.Version, &v.Mode, &v.Rows, &v.TotalRows, &v.LoadedOn, &v.BudgetReset); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+
+	return out, rows.Err()
+}
+
+/*
+Calls write with the header and then every row of a version of the data in
+upload order, without loading the data into memory. Version 0 is the latest.
+The rows are decrypted one at a time as they are written.
+*/
+func (d DatasetPostgres) StreamData(dataset int64, version int64, write func(line string) error) error {
+	var header string
+	q := "SELECT version, header FROM DataUpload WHERE dataset = $1 AND ($2 = 0 OR version = $2) ORDER BY version DESC LIMIT 1"
+	if err := d.db.QueryRow(q, dataset, version).Scan(&version, &header); err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
+	// the rows of a version start at the last replace or schema change
+	var base int64
+	q = "SELECT MAX(version) FROM DataUpload WHERE dataset = $1 AND version <= $2 AND mode IN ('replace', 'schema')"
+	if err := d.db.QueryRow(q, dataset, version).Scan(&base); err != nil {
+		return err
+	}
 
-	_, err = tx.Exec(q, dataset)
+	key, err := d.dataKey(d.db, dataset)
 	if err != nil {
-		return errors.ErrNotFound
+		return err
 	}
 
-	if err = tx.Commit(); err != nil {
+	if err := write(header); err != nil {
 		return err
 	}
 
+	q = "SELECT line FROM DataRows WHERE dataset = $1 AND version BETWEEN $2 AND $3 ORDER BY version, row_number"
+	rows, err := d.db.Query(q, dataset, base, version)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		line, err := key.Decrypt(data)
+		if err != nil {
+			return err
+		}
+		if err := write(string(line)); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// the organization of a dataset, deleted or not
+func (d DatasetPostgres) GetDatasetOrganization(dataset int64) (string, error) {
+	var organization string
+	err := d.db.QueryRow("SELECT organization FROM Dataset WHERE id = $1", dataset).Scan(&organization)
+	return organization, err
+}
+
+/*
+ErrConflict if the total budgets of the datasets of the organization exceed its
+budget cap when the total budget of the dataset is the given one; dataset 0 is
+a new dataset. Deleted datasets count until they are purged, as they can be
+restored. The organization is locked so that changes to its budgets wait for
+each other.
+*/
+func withinBudgetCap(tx *sql.Tx, organization string, dataset int64, total entity.Budget) error {
+	var epsilonCap, deltaCap sql.NullFloat64
+	q := "SELECT epsilon_cap, delta_cap FROM Organizations WHERE handle = $1 FOR UPDATE"
+	if err := tx.QueryRow(q, organization).Scan(&epsilonCap, &deltaCap); err != nil {
+		return err
+	}
+	if !epsilonCap.Valid && !deltaCap.Valid {
+		return nil
+	}
+
+	var epsilon, delta float64
+	q = "SELECT COALESCE(SUM(total_epsilon), 0), COALESCE(SUM(COALESCE(total_delta, 0)), 0) FROM Dataset WHERE organization = $1 AND id <> $2"
+	if err := tx.QueryRow(q, organization, dataset).Scan(&epsilon, &delta); err != nil {
+		return err
+	}
+	epsilon += total.Epsilon
+	if total.Delta != nil {
+		delta += *total.Delta
+	}
+	if (epsilonCap.Valid && epsilon > epsilonCap.Float64) || (deltaCap.Valid && delta > deltaCap.Float64) {
+		return errors.ErrConflict
+	}
+	return nil
+}
+
+/*
+Deletes a dataset softly. The dataset is hidden until it is restored or purged,
+its data, schema and budgets are kept.
+*/
+func (d DatasetPostgres) DeleteDataset(dataset int64, deletedBy string) error {
+	q := "UPDATE Dataset SET deleted_time = $1, deleted_by = $2 WHERE id = $3 AND deleted_time IS NULL"
+	res, err := d.db.Exec(q, time.Now().UTC(), deletedBy, dataset)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// restores a deleted dataset that has not been purged yet
+func (d DatasetPostgres) RestoreDataset(dataset int64) error {
+	q := "UPDATE Dataset SET deleted_time = NULL, deleted_by = NULL, updated_time = $1 WHERE id = $2 AND deleted_time IS NOT NULL"
+	res, err := d.db.Exec(q, time.Now().UTC(), dataset)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
 	return nil
 }
 
-func insertColumnSchema(tx *sql.Tx, dataset int64, cs entity.ColumnSchema) error {
+func insertColumnSchema(tx *sql.Tx, dataset int64, position int, cs entity.ColumnSchema) error {
 	q := ""
 	var vars []any
 	switch t := cs.Type.Type.(type) {
 	case *entity.IntType:
-		q = "INSERT INTO ColumnSchemas (dataset, column_name, data_type, low, high) VALUES ($1, $2, $3, $4, $5)"
-		vars = []any{dataset, cs.Name, "Int", t.Low, t.High}
+		q = "INSERT INTO ColumnSchemas (dataset, column_name, position, data_type, low, high) VALUES ($1, $2, $3, $4, $5, $6)"
+		vars = []any{dataset, cs.Name, position, "Int", t.Low, t.High}
 	case *entity.DoubleType:
-		q = "INSERT INTO ColumnSchemas (dataset, column_name, data_type, low, high) VALUES ($1, $2, $3, $4, $5)"
-		vars = []any{dataset, cs.Name, "Double", t.Low, t.High}
+		q = "INSERT INTO ColumnSchemas (dataset, column_name, position, data_type, low, high) VALUES ($1, $2, $3, $4, $5, $6)"
+		vars = []any{dataset, cs.Name, position, "Double", t.Low, t.High}
 	case *entity.BoolType:
-		q = "INSERT INTO ColumnSchemas (dataset, column_name, data_type) VALUES ($1, $2, $3)"
-		vars = []any{dataset, cs.Name, "Bool"}
+		q = "INSERT INTO ColumnSchemas (dataset, column_name, position, data_type) VALUES ($1, $2, $3, $4)"
+		vars = []any{dataset, cs.Name, position, "Bool"}
 	case *entity.TextType:
-		q = "INSERT INTO ColumnSchemas (dataset, column_name, data_type) VALUES ($1, $2, $3)"
-		vars = []any{dataset, cs.Name, "Text"}
+		q = "INSERT INTO ColumnSchemas (dataset, column_name, position, data_type) VALUES ($1, $2, $3, $4)"
+		vars = []any{dataset, cs.Name, position, "Text"}
 	case *entity.EnumType:
-		q = "INSERT INTO ColumnSchemas (dataset, column_name, data_type, labels) VALUES ($1, $2, $3, $4)"
-		vars = []any{dataset, cs.Name, "Enum", pq.Array(t.Labels)}
+		q = "INSERT INTO ColumnSchemas (dataset, column_name, position, data_type, labels) VALUES ($1, $2, $3, $4, $5)"
+		vars = []any{dataset, cs.Name, position, "Enum", pq.Array(t.Labels)}
 	default:
 		return fmt.Errorf("%w: invalid data type", errors.ErrBadType)
 	}
@@ -274,7 +563,7 @@ func insertColumnSchema(tx *sql.Tx, dataset int64, cs entity.ColumnSchema) error
 }
 
 func getColumnSchema(tx *sql.Tx, dataset int64) ([]entity.ColumnSchema, error) {
-	q := "SELECT column_name, data_type, low, high, labels FROM ColumnSchemas WHERE dataset = $1"
+	q := "SELECT column_name, data_type, low, high, labels FROM ColumnSchemas WHERE dataset = $1 ORDER BY position"
 	rs, err := tx.Query(q, dataset)
 	if err != nil {
 		return []entity.ColumnSchema{}, err
@@ -301,9 +590,9 @@ func getColumnSchema(tx *sql.Tx, dataset int64) ([]entity.ColumnSchema, error) {
 func fromRaw(r rawtype) (entity.DataType, error) {
 	switch r.name {
 	case "Int":
-		return entity.DataType{Type: &entity.IntType{Low: r.low.Int32, High: r.high.Int32}}, nil
+		return entity.DataType{Type: &entity.IntType{Low: int32(r.low.Float64), High: int32(r.high.Float64)}}, nil
 	case "Double":
-		return entity.DataType{Type: &entity.DoubleType{Low: r.low.Int32, High: r.high.Int32}}, nil
+		return entity.DataType{Type: &entity.DoubleType{Low: r.low.Float64, High: r.high.Float64}}, nil
 	case "Bool":
 		return entity.DataType{Type: &entity.BoolType{}}, nil
 	case "Text":
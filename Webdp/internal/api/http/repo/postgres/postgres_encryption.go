@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/encryption"
+)
+
+// a transaction or the database
+type queryExecer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+/*
+The data key of a dataset, created and stored wrapped by the current master key
+the first time it is needed. Concurrent creations keep the first stored key.
+*/
+func (d DatasetPostgres) dataKey(tx queryExecer, dataset int64) (encryption.DataKey, error) {
+	var masterId string
+	var wrapped []byte
+	q := "SELECT master_key, wrapped_key FROM DataKeys WHERE dataset = $1"
+	err := tx.QueryRow(q, dataset).Scan(&masterId, &wrapped)
+	if err == nil {
+		return d.keys.UnwrapDataKey(dataset, masterId, wrapped)
+	}
+	if err != sql.ErrNoRows {
+		return encryption.DataKey{}, err
+	}
+
+	_, wrapped, err = d.keys.NewDataKey(dataset)
+	if err != nil {
+		return encryption.DataKey{}, err
+	}
+	q = "INSERT INTO DataKeys (dataset, master_key, wrapped_key, created_time) VALUES ($1, $2, $3, $4) ON CONFLICT (dataset) DO NOTHING"
+	if _, err := tx.Exec(q, dataset, d.keys.CurrentId(), wrapped, time.Now()); err != nil {
+		return encryption.DataKey{}, err
+	}
+	return d.dataKey(tx, dataset)
+}
+
+/*
+Re-wraps the data keys that are not wrapped by the current master key and
+returns how many were re-wrapped. The encrypted data is not touched. Every key
+is re-wrapped in its own transaction, so an interrupted rotation can be rerun.
+*/
+func (d DatasetPostgres) RotateDataKeys() (int64, error) {
+	rows, err := d.db.Query("SELECT dataset FROM DataKeys WHERE master_key <> $1 ORDER BY dataset", d.keys.CurrentId())
+	if err != nil {
+		return 0, err
+	}
+	var datasets []int64
+	for rows.Next() {
+		var dataset int64
+		if err := rows.Scan(&dataset); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		datasets = append(datasets, dataset)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var rotated int64
+	for _, dataset := range datasets {
+		ok, err := d.rotateDataKey(dataset)
+		if err != nil {
+			return rotated, err
+		}
+		if ok {
+			rotated++
+		}
+	}
+	return rotated, nil
+}
+
+func (d DatasetPostgres) rotateDataKey(dataset int64) (ok bool, err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	var masterId string
+	var wrapped []byte
+	q := "SELECT master_key, wrapped_key FROM DataKeys WHERE dataset = $1 FOR UPDATE"
+	err = tx.QueryRow(q, dataset).Scan(&masterId, &wrapped)
+	if err == sql.ErrNoRows {
+		// the dataset was deleted since the keys were listed
+		err = nil
+		return false, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+	if masterId == d.keys.CurrentId() {
+		return false, tx.Commit()
+	}
+
+	wrapped, err = d.keys.RewrapDataKey(dataset, masterId, wrapped)
+	if err != nil {
+		return false, err
+	}
+	q = "UPDATE DataKeys SET master_key = $1, wrapped_key = $2, rotated_time = $3 WHERE dataset = $4"
+	if _, err = tx.Exec(q, d.keys.CurrentId(), wrapped, time.Now(), dataset); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+)
+
+// the handle of the user with the subject at the issuer, sql.ErrNoRows if the user has not logged in before
+func (u UserPostgres) GetExternalUser(issuer string, subject string) (string, error) {
+	var handle string
+	q := "SELECT username FROM ExternalIdentities WHERE issuer = $1 AND subject = $2"
+	if err := u.db.QueryRow(q, issuer, subject).Scan(&handle); err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+/*
+Creates a user for the subject at the issuer. ErrConflict is returned if a
+user with the handle exists, the existing user is not taken over, and
+ErrNotFound if the organization of the user does not exist.
+*/
+func (u UserPostgres) CreateExternalUser(issuer string, subject string, post entity.UserPost) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	created := time.Now().UTC()
+	if err = organizationExists(tx, post.Organization); err != nil {
+		return err
+	}
+	q := "INSERT INTO Users (handle, pwd, name, organization, created_time, updated_time) VALUES ($1, $2, $3, $4, $5, $5) ON CONFLICT (handle) DO NOTHING"
+	res, err := tx.Exec(q, post.Handle, post.PWD, post.Name, post.Organization, created)
+	if err != nil {
+		return err
+	}
+	if n, err2 := res.RowsAffected(); err2 == nil && n == 0 {
+		err = errors.ErrConflict
+		return err
+	}
+
+	for _, role := range post.Roles {
+		if _, err = tx.Exec("INSERT INTO UserRoles (username, role) VALUES ($1, $2)", post.Handle, role); err != nil {
+			return err
+		}
+	}
+	q = "INSERT INTO ExternalIdentities (issuer, subject, username, created_time) VALUES ($1, $2, $3, $4)"
+	if _, err = tx.Exec(q, issuer, subject, post.Handle, created); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
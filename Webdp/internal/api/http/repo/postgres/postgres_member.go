@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+)
+
+/*
+The role of a user in a dataset, sql.ErrNoRows if the user is not a member.
+Members of deleted datasets have no role until the dataset is restored.
+*/
+func (d DatasetPostgres) GetMemberRole(dataset int64, handle string) (string, error) {
+	var role string
+	q := `SELECT M.role FROM DatasetMembers AS M JOIN Dataset AS D ON D.id = M.dataset
+		WHERE M.dataset = $1 AND M.userid = $2 AND D.deleted_time IS NULL`
+	if err := d.db.QueryRow(q, dataset, handle).Scan(&role); err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// the members of a dataset, the owner first
+func (d DatasetPostgres) GetMembers(dataset int64) ([]entity.DatasetMember, error) {
+	q := "SELECT userid, role, added_by, added_time FROM DatasetMembers WHERE dataset = $1 ORDER BY role <> 'owner', added_time, userid"
+	rows, err := d.db.Query(q, dataset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.DatasetMember, 0)
+	for rows.Next() {
+		var m entity.DatasetMember
+		if err := rows.Scan(&m.Handle, &m.Role, &m.AddedBy, &m.AddedOn); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, rows.Err()
+}
+
+/*
+Adds a member or changes the role of a member. The owner cannot be changed
+this way, ErrConflict is returned if the user is the owner.
+*/
+func (d DatasetPostgres) PutMember(dataset int64, member entity.DatasetMember) error {
+	q := `INSERT INTO DatasetMembers (dataset, userid, role, added_by, added_time) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dataset, userid) DO UPDATE SET role = EXCLUDED.role WHERE DatasetMembers.role <> 'owner'`
+	res, err := d.db.Exec(q, dataset, member.Handle, member.Role, member.AddedBy, member.AddedOn)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrConflict
+	}
+	return nil
+}
+
+// removes a member other than the owner
+func (d DatasetPostgres) DeleteMember(dataset int64, handle string) error {
+	res, err := d.db.Exec("DELETE FROM DatasetMembers WHERE dataset = $1 AND userid = $2 AND role <> 'owner'", dataset, handle)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// proposes a new owner, replacing an earlier proposal
+func (d DatasetPostgres) ProposeTransfer(t entity.OwnershipTransfer) error {
+	q := `INSERT INTO OwnershipTransfers (dataset, from_user, to_user, created_time) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (dataset) DO UPDATE SET from_user = EXCLUDED.from_user, to_user = EXCLUDED.to_user, created_time = EXCLUDED.created_time`
+	_, err := d.db.Exec(q, t.Dataset, t.From, t.To, t.CreatedOn)
+	return err
+}
+
+func (d DatasetPostgres) GetTransfer(dataset int64) (entity.OwnershipTransfer, error) {
+	var t entity.OwnershipTransfer
+	q := "SELECT dataset, from_user, to_user, created_time FROM OwnershipTransfers WHERE dataset = $1"
+	if err := d.db.QueryRow(q, dataset).Scan(&t.Dataset, &t.From, &t.To, &t.CreatedOn); err != nil {
+		return entity.OwnershipTransfer{}, err
+	}
+	return t, nil
+}
+
+func (d DatasetPostgres) DeleteTransfer(dataset int64) error {
+	res, err := d.db.Exec("DELETE FROM OwnershipTransfers WHERE dataset = $1", dataset)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+/*
+Makes the proposed owner the owner of the dataset. The previous owner is no
+longer a member. ErrNotFound is returned if no transfer to handle is proposed,
+ErrConflict if the owner changed since the transfer was proposed.
+*/
+func (d DatasetPostgres) AcceptTransfer(dataset int64, handle string) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	var owner string
+	if err = tx.QueryRow("SELECT owner FROM Dataset WHERE id = $1 AND deleted_time IS NULL FOR UPDATE", dataset).Scan(&owner); err != nil {
+		return err
+	}
+
+	var t entity.OwnershipTransfer
+	q := "SELECT from_user, to_user FROM OwnershipTransfers WHERE dataset = $1 AND to_user = $2"
+	err = tx.QueryRow(q, dataset, handle).Scan(&t.From, &t.To)
+	if err == sql.ErrNoRows {
+		err = errors.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if t.From != owner {
+		err = errors.ErrConflict
+		return err
+	}
+
+	now := time.Now().UTC()
+	if _, err = tx.Exec("DELETE FROM DatasetMembers WHERE dataset = $1 AND userid IN ($2, $3)", dataset, t.From, t.To); err != nil {
+		return err
+	}
+	q = "INSERT INTO DatasetMembers (dataset, userid, role, added_by, added_time) VALUES ($1, $2, $3, $4, $5)"
+	if _, err = tx.Exec(q, dataset, t.To, entity.MEMBER_OWNER, t.From, now); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("UPDATE Dataset SET owner = $1, updated_time = $2 WHERE id = $3", t.To, now, dataset); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM OwnershipTransfers WHERE dataset = $1", dataset); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
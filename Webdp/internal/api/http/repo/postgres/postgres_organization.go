@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+)
+
+type OrganizationPostgres struct {
+	db *sql.DB
+}
+
+func NewOrganizationPostgres(conn *sql.DB) OrganizationPostgres {
+	return OrganizationPostgres{db: conn}
+}
+
+// the organizations with the total budgets of their datasets, deleted datasets that are not purged included
+const organizationSelect = `SELECT O.handle, O.name, O.epsilon_cap, O.delta_cap, O.created_time,
+	COALESCE(SUM(D.total_epsilon), 0), COALESCE(SUM(COALESCE(D.total_delta, 0)), 0)
+	FROM Organizations AS O LEFT JOIN Dataset AS D ON D.organization = O.handle`
+
+// the organization with the handle, or all organizations if it is empty
+func (d OrganizationPostgres) GetOrganizations(handle string) ([]entity.Organization, error) {
+	rows, err := d.db.Query(organizationSelect+" WHERE $1 = '' OR O.handle = $1 GROUP BY O.handle ORDER BY O.handle", handle)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.Organization, 0)
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+func (d OrganizationPostgres) GetOrganization(handle string) (entity.Organization, error) {
+	return scanOrganization(d.db.QueryRow(organizationSelect+" WHERE O.handle = $1 GROUP BY O.handle", handle))
+}
+
+// ErrConflict is returned if an organization with the handle exists
+func (d OrganizationPostgres) CreateOrganization(c entity.OrganizationCreate) error {
+	epsilonCap, deltaCap := budgetCap(c.BudgetCap)
+	q := "INSERT INTO Organizations (handle, name, epsilon_cap, delta_cap, created_time) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING"
+	res, err := d.db.Exec(q, c.Handle, c.Name, epsilonCap, deltaCap, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrConflict
+	}
+	return nil
+}
+
+/*
+Replaces the name and the budget cap of an organization. ErrNotFound is
+returned if there is no organization with the handle, ErrConflict if the
+total budgets of its datasets exceed the new budget cap.
+*/
+func (d OrganizationPostgres) UpdateOrganization(handle string, p entity.OrganizationPatch) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	epsilonCap, deltaCap := budgetCap(p.BudgetCap)
+	res, err := tx.Exec("UPDATE Organizations SET name = $1, epsilon_cap = $2, delta_cap = $3 WHERE handle = $4", p.Name, epsilonCap, deltaCap, handle)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	// the row is locked by the update, the datasets of the organization cannot change their budgets meanwhile
+	if err = withinBudgetCap(tx, handle, 0, entity.Budget{}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+Deletes an organization. ErrNotFound is returned if there is no organization
+with the handle, ErrConflict if it still has users or datasets.
+*/
+func (d OrganizationPostgres) DeleteOrganization(handle string) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	var used bool
+	q := `SELECT EXISTS (SELECT 1 FROM Users WHERE organization = $1)
+		OR EXISTS (SELECT 1 FROM Dataset WHERE organization = $1)`
+	if err = tx.QueryRow(q, handle).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return errors.ErrConflict
+	}
+
+	res, err := tx.Exec("DELETE FROM Organizations WHERE handle = $1", handle)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func scanOrganization(row interface{ Scan(...any) error }) (entity.Organization, error) {
+	var o entity.Organization
+	var epsilonCap, deltaCap sql.NullFloat64
+	var delta float64
+	if err := row.Scan(&o.Handle, &o.Name, &epsilonCap, &deltaCap, &o.CreatedOn, &o.Used.Epsilon, &delta); err != nil {
+		return entity.Organization{}, err
+	}
+	o.Used.Delta = &delta
+	if epsilonCap.Valid {
+		o.BudgetCap = &entity.Budget{Epsilon: epsilonCap.Float64}
+		if deltaCap.Valid {
+			o.BudgetCap.Delta = &deltaCap.Float64
+		}
+	}
+	return o, nil
+}
+
+// the columns of a budget cap, no budget cap is stored as NULL
+func budgetCap(b *entity.Budget) (sql.NullFloat64, sql.NullFloat64) {
+	if b == nil {
+		return sql.NullFloat64{}, sql.NullFloat64{}
+	}
+	var delta sql.NullFloat64
+	if b.Delta != nil {
+		delta = sql.NullFloat64{Float64: *b.Delta, Valid: true}
+	}
+	return sql.NullFloat64{Float64: b.Epsilon, Valid: true}, delta
+}
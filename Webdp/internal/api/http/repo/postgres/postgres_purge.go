@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"webdp/internal/api/http/entity"
+)
+
+/*
+Purges the datasets that were deleted before the given time and returns the
+budgets recorded for the purged datasets. The budgets of a dataset are recorded in PurgedDatasets
+before the dataset is dropped together with its data, schema and allocations.
+Every dataset is purged in its own transaction.
+*/
+func (d DatasetPostgres) PurgeDatasets(deletedBefore time.Time) ([]entity.PurgedDatasetBudget, error) {
+	rows, err := d.db.Query("SELECT id FROM Dataset WHERE deleted_time < $1 ORDER BY id", deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	var datasets []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		datasets = append(datasets, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	purged := make([]entity.PurgedDatasetBudget, 0)
+	for _, dataset := range datasets {
+		p, ok, err := d.purgeDataset(dataset, deletedBefore)
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			purged = append(purged, p)
+		}
+	}
+	return purged, nil
+}
+
+func (d DatasetPostgres) purgeDataset(dataset int64, deletedBefore time.Time) (p entity.PurgedDatasetBudget, ok bool, err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return p, false, err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	// the dataset may have been restored since it was listed
+	var delta float64
+	var deletedBy sql.NullString
+	q := `SELECT id, name, owner, organization, privacy_notion, total_epsilon, COALESCE(total_delta, 0), deleted_by, deleted_time
+		FROM Dataset WHERE id = $1 AND deleted_time < $2 FOR UPDATE`
+	err = tx.QueryRow(q, dataset, deletedBefore).Scan(&p.Dataset, &p.Name, &p.Owner, &p.Organization, &p.PrivacyNotion, &p.Total.Epsilon, &delta, &deletedBy, &p.DeletedOn)
+	if err == sql.ErrNoRows {
+		err = nil
+		return p, false, tx.Commit()
+	}
+	if err != nil {
+		return p, false, err
+	}
+
+	allocation, err := getUserAllocations(dataset, tx)
+	if err != nil {
+		return p, false, err
+	}
+	bs, err := json.Marshal(allocation)
+	if err != nil {
+		return p, false, err
+	}
+	p.Total.Delta = &delta
+	p.Allocation = allocation
+	p.DeletedBy = deletedBy.String
+	p.PurgedOn = time.Now().UTC()
+
+	q = `INSERT INTO PurgedDatasets (dataset, name, owner, organization, privacy_notion, total_epsilon, total_delta, allocation, deleted_by, deleted_time, purged_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	if _, err = tx.Exec(q, p.Dataset, p.Name, p.Owner, p.Organization, p.PrivacyNotion, p.Total.Epsilon, delta, string(bs), deletedBy, p.DeletedOn, p.PurgedOn); err != nil {
+		return p, false, err
+	}
+	if _, err = tx.Exec("DELETE FROM Dataset WHERE id = $1", dataset); err != nil {
+		return p, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+// the budgets of the purged datasets of the organization, oldest purge first
+func (b BudgetPostgres) GetPurgedBudgets(organization string) ([]entity.PurgedDatasetBudget, error) {
+	q := `SELECT dataset, name, owner, organization, privacy_notion, total_epsilon, total_delta, allocation, deleted_by, deleted_time, purged_time
+		FROM PurgedDatasets WHERE organization = $1 ORDER BY purged_time, dataset`
+	rows, err := b.db.Query(q, organization)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.PurgedDatasetBudget, 0)
+	for rows.Next() {
+		var p entity.PurgedDatasetBudget
+		var delta float64
+		var allocation []byte
+		var deletedBy sql.NullString
+		if err := rows.Scan(&p.Dataset, &p.Name, &p.Owner, &p.Organization, &p.PrivacyNotion, &p.Total.Epsilon, &delta, &allocation, &deletedBy, &p.DeletedOn, &p.PurgedOn); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(allocation, &p.Allocation); err != nil {
+			return nil, err
+		}
+		p.Total.Delta = &delta
+		p.DeletedBy = deletedBy.String
+
+		var conEps, conDel float64
+		for _, a := range p.Allocation {
+			conEps += a.Consumed.Epsilon
+			if a.Consumed.Delta != nil {
+				conDel += *a.Consumed.Delta
+			}
+		}
+		p.Consumed = entity.Budget{Epsilon: conEps, Delta: &conDel}
+		out = append(out, p)
+	}
+
+	return out, rows.Err()
+}
+
+/*
+Drops the versions that were superseded before the given time together with
+their rows and returns the datasets that had such versions. A version is
+superseded by a later replace or schema version, whose rows no longer include
+its rows, so only queries that pin it still read it.
+*/
+func (d DatasetPostgres) PurgeVersions(supersededBefore time.Time) ([]int64, error) {
+	q := `WITH Base AS (
+			SELECT dataset, MAX(version) AS version FROM DataUpload
+			WHERE mode IN ('replace', 'schema') AND loaded_time < $1 GROUP BY dataset
+		), Dropped AS (
+			DELETE FROM DataUpload U USING Base B WHERE U.dataset = B.dataset AND U.version < B.version
+			RETURNING U.dataset
+		)
+		SELECT DISTINCT dataset FROM Dropped ORDER BY dataset`
+	rows, err := d.db.Query(q, supersededBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, id)
+	}
+	return datasets, rows.Err()
+}
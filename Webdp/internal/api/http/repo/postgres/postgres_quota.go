@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+)
+
+// the quota set on the allocation, nil if it has the default quota
+func (b BudgetPostgres) GetQueryQuota(userHandle string, datasetId int64) (*int, error) {
+	var quota sql.NullInt64
+	q := "SELECT daily_queries FROM UserBudgetAllocation WHERE userid = $1 AND dataset = $2"
+	if err := b.db.QueryRow(q, userHandle, datasetId).Scan(&quota); err != nil {
+		return nil, err
+	}
+	if !quota.Valid {
+		return nil, nil
+	}
+	n := int(quota.Int64)
+	return &n, nil
+}
+
+// sets the quota of the allocation, nil gives it the default quota, and records the audit entry with it
+func (b BudgetPostgres) SetQueryQuota(userHandle string, datasetId int64, quota *int, audit entity.AuditEntry) (err error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	q := "UPDATE UserBudgetAllocation SET daily_queries = $1 WHERE userid = $2 AND dataset = $3"
+	res, err := tx.Exec(q, quota, userHandle, datasetId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = errors.ErrNotFound
+		return err
+	}
+	if _, err = appendAuditEntry(tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// the queries made on the allocation on the day
+func (b BudgetPostgres) GetQueryCount(userHandle string, datasetId int64, day time.Time) (int, error) {
+	var queries int
+	q := "SELECT COALESCE(SUM(queries), 0) FROM QueryCounts WHERE userid = $1 AND dataset = $2 AND day = $3"
+	err := b.db.QueryRow(q, userHandle, datasetId, day).Scan(&queries)
+	return queries, err
+}
+
+/*
+Counts a query on the allocation on the day, unless the quota of queries is
+made already; a quota of 0 is no quota. Returns the queries of the day and
+whether the query was counted. Replicas counting at the same time do not go
+over the quota, as the count is checked and increased in one statement.
+*/
+func (b BudgetPostgres) CountQuery(userHandle string, datasetId int64, day time.Time, quota int) (int, bool, error) {
+	q := `INSERT INTO QueryCounts (dataset, userid, day, queries) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (dataset, userid, day) DO UPDATE SET queries = QueryCounts.queries + 1
+		WHERE $4 = 0 OR QueryCounts.queries < $4
+		RETURNING queries`
+	var queries int
+	err := b.db.QueryRow(q, datasetId, userHandle, day, quota).Scan(&queries)
+	if err == sql.ErrNoRows {
+		return quota, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return queries, true, nil
+}
+
+// deletes the counts of the days before the day
+func (b BudgetPostgres) DeleteQueryCounts(before time.Time) error {
+	_, err := b.db.Exec("DELETE FROM QueryCounts WHERE day < $1", before)
+	return err
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RateLimitPostgres struct {
+	db *sql.DB
+}
+
+func NewRateLimitPostgres(conn *sql.DB) RateLimitPostgres {
+	return RateLimitPostgres{db: conn}
+}
+
+// counts a request of the user in the window and returns the requests of the window with it
+func (l RateLimitPostgres) CountRequest(handle string, class string, window time.Time) (int, error) {
+	q := `INSERT INTO RateLimitCounts (username, class, window_start, requests) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (username, class, window_start) DO UPDATE SET requests = RateLimitCounts.requests + 1
+		RETURNING requests`
+	var requests int
+	err := l.db.QueryRow(q, handle, class, window).Scan(&requests)
+	return requests, err
+}
+
+// deletes the counts of the windows that started before the time
+func (l RateLimitPostgres) DeleteRequestCounts(before time.Time) error {
+	_, err := l.db.Exec("DELETE FROM RateLimitCounts WHERE window_start < $1", before)
+	return err
+}
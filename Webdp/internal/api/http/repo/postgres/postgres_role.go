@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"database/sql"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/lib/pq"
+)
+
+type RolePostgres struct {
+	db *sql.DB
+}
+
+func NewRolePostgres(conn *sql.DB) RolePostgres {
+	return RolePostgres{db: conn}
+}
+
+const roleColumns = `role, description, builtin,
+	ARRAY(SELECT P.permission FROM RolePermissions AS P WHERE P.role = R.role ORDER BY P.permission)`
+
+// all roles with their permissions, the built-in roles first
+func (d RolePostgres) GetRoles() ([]entity.Role, error) {
+	rows, err := d.db.Query("SELECT " + roleColumns + " FROM Roles AS R ORDER BY builtin DESC, role")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.Role, 0)
+	for rows.Next() {
+		var r entity.Role
+		if err := rows.Scan(&r.Name, &r.Description, &r.Builtin, pq.Array(&r.Permissions)); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d RolePostgres) GetRole(name string) (entity.Role, error) {
+	var r entity.Role
+	err := d.db.QueryRow("SELECT "+roleColumns+" FROM Roles AS R WHERE role = $1", name).
+		Scan(&r.Name, &r.Description, &r.Builtin, pq.Array(&r.Permissions))
+	return r, err
+}
+
+func (d RolePostgres) GetPermissions() ([]entity.Permission, error) {
+	rows, err := d.db.Query("SELECT permission, description FROM Permissions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.Permission, 0)
+	for rows.Next() {
+		var p entity.Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// ErrConflict is returned if a role with the name exists
+func (d RolePostgres) CreateRole(c entity.RoleCreate) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	res, err := tx.Exec("INSERT INTO Roles (role, description) VALUES ($1, $2) ON CONFLICT DO NOTHING", c.Name, c.Description)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrConflict
+	}
+	if err = setPermissions(tx, c.Name, c.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+Replaces the description and the permissions of a custom role. ErrNotFound is
+returned if there is no custom role with the name.
+*/
+func (d RolePostgres) UpdateRole(name string, p entity.RolePut) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	res, err := tx.Exec("UPDATE Roles SET description = $1 WHERE role = $2 AND NOT builtin", p.Description, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	if _, err = tx.Exec("DELETE FROM RolePermissions WHERE role = $1", name); err != nil {
+		return err
+	}
+	if err = setPermissions(tx, name, p.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+Deletes a custom role. ErrNotFound is returned if there is no custom role with
+the name, ErrConflict if users still have the role.
+*/
+func (d RolePostgres) DeleteRole(name string) (err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { dfun(err, tx) }()
+
+	var used bool
+	if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM UserRoles WHERE role = $1)", name).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return errors.ErrConflict
+	}
+
+	res, err := tx.Exec("DELETE FROM Roles WHERE role = $1 AND NOT builtin", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func setPermissions(tx *sql.Tx, role string, permissions []string) error {
+	q := "INSERT INTO RolePermissions (role, permission) SELECT $1, UNNEST($2::text[])"
+	_, err := tx.Exec(q, role, pq.Array(permissions))
+	return err
+}
@@ -0,0 +1,257 @@
+package postgres
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/encryption"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/lib/pq"
+)
+
+// rows of the loaded data read at a time while they are rewritten
+const rewriteBatchSize = 1000
+
+/*
+Replaces the schema of a dataset and records the change in the audit trail.
+If data is loaded, the rows of the latest version are rewritten by the rewriter
+returned for their header and stored as a new version. ErrConflict is returned
+if the schema is no longer change.OldSchema, errors of the rewriter are returned
+as they are. Everything is written in a single transaction.
+*/
+func (d DatasetPostgres) ChangeSchema(change entity.SchemaChange, rewriter func(header string) (*entity.SchemaRewriter, error)) (_ entity.SchemaChange, err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return change, err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	// waits for uploads and other schema changes
+	if _, err = tx.Exec("SELECT id FROM Dataset WHERE id = $1 FOR UPDATE", change.Dataset); err != nil {
+		return change, err
+	}
+
+	current, err := getColumnSchema(tx, change.Dataset)
+	if err != nil {
+		return change, err
+	}
+	oldSchema, err := json.Marshal(change.OldSchema)
+	if err != nil {
+		return change, err
+	}
+	if currentSchema, _ := json.Marshal(current); !bytes.Equal(currentSchema, oldSchema) {
+		err = errors.ErrConflict
+		return change, err
+	}
+
+	if _, err = tx.Exec("DELETE FROM ColumnSchemas WHERE dataset = $1", change.Dataset); err != nil {
+		return change, err
+	}
+	for i, cs := range change.NewSchema {
+		if err = insertColumnSchema(tx, change.Dataset, i, cs); err != nil {
+			return change, err
+		}
+	}
+
+	var latest int64
+	var header string
+	q := "SELECT version, header FROM DataUpload WHERE dataset = $1 ORDER BY version DESC LIMIT 1"
+	err = tx.QueryRow(q, change.Dataset).Scan(&latest, &header)
+	if err == sql.ErrNoRows {
+		err = nil
+	} else if err == nil {
+		var key encryption.DataKey
+		if key, err = d.dataKey(tx, change.Dataset); err == nil {
+			err = rewriteData(tx, key, &change, latest, header, rewriter)
+		}
+	}
+	if err != nil {
+		return change, err
+	}
+
+	change.ChangedOn = time.Now().UTC()
+	if _, err = tx.Exec("UPDATE Dataset SET updated_time = $1 WHERE id = $2", change.ChangedOn, change.Dataset); err != nil {
+		return change, err
+	}
+
+	patch, err := json.Marshal(change.Patch)
+	if err != nil {
+		return change, err
+	}
+	newSchema, err := json.Marshal(change.NewSchema)
+	if err != nil {
+		return change, err
+	}
+	var version sql.NullInt64
+	var report sql.NullString
+	if change.Version > 0 {
+		version = sql.NullInt64{Int64: change.Version, Valid: true}
+		r, err := json.Marshal(change.Report)
+		if err != nil {
+			return change, err
+		}
+		report = sql.NullString{String: string(r), Valid: true}
+	}
+
+	// JSON is passed as text, byte slices would be sent as bytea
+	q = "INSERT INTO SchemaChanges (dataset, changed_by, changed_time, patch, old_schema, new_schema, version, report) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"
+	if err = tx.QueryRow(q, change.Dataset, change.ChangedBy, change.ChangedOn, string(patch), string(oldSchema), string(newSchema), version, report).Scan(&change.Id); err != nil {
+		return change, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return change, err
+	}
+	return change, nil
+}
+
+// stores the rows of version latest, rewritten to the new schema, as a schema version
+func rewriteData(tx *sql.Tx, key encryption.DataKey, change *entity.SchemaChange, latest int64, header string, rewriter func(header string) (*entity.SchemaRewriter, error)) error {
+	rw, err := rewriter(header)
+	if err != nil {
+		return err
+	}
+	newHeader, err := rw.Header()
+	if err != nil {
+		return err
+	}
+
+	var base int64
+	q := "SELECT MAX(version) FROM DataUpload WHERE dataset = $1 AND version <= $2 AND mode IN ('replace', 'schema')"
+	if err := tx.QueryRow(q, change.Dataset, latest).Scan(&base); err != nil {
+		return err
+	}
+
+	version := latest + 1
+	q = "INSERT INTO DataUpload (dataset, version, mode, loaded_time, header, row_count, total_rows) VALUES ($1, $2, $3, $4, $5, 0, 0)"
+	if _, err := tx.Exec(q, change.Dataset, version, entity.VERSION_SCHEMA, time.Now(), newHeader); err != nil {
+		return err
+	}
+
+	// a cursor, since a connection cannot copy rows in while it reads rows out
+	q = fmt.Sprintf("DECLARE schema_rows NO SCROLL CURSOR FOR SELECT line FROM DataRows WHERE dataset = %d AND version BETWEEN %d AND %d ORDER BY version, row_number", change.Dataset, base, latest)
+	if _, err := tx.Exec(q); err != nil {
+		return err
+	}
+
+	var rows int64
+	for {
+		lines, err := fetchLines(tx, key, fmt.Sprintf("FETCH %d FROM schema_rows", rewriteBatchSize))
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			break
+		}
+
+		stmt, err := tx.Prepare(pq.CopyIn("datarows", "dataset", "version", "row_number", "line"))
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			out, ok, err := rw.Row(line)
+			if err != nil {
+				stmt.Close()
+				return err
+			}
+			if !ok {
+				continue
+			}
+			rows++
+			if _, err := stmt.Exec(change.Dataset, version, rows, key.Encrypt([]byte(out))); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return err
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("CLOSE schema_rows"); err != nil {
+		return err
+	}
+	if err := rw.Err(); err != nil {
+		return err
+	}
+
+	q = "UPDATE DataUpload SET row_count = $1, total_rows = $1 WHERE dataset = $2 AND version = $3"
+	if _, err := tx.Exec(q, rows, change.Dataset, version); err != nil {
+		return err
+	}
+
+	report := rw.Report()
+	change.Version, change.Report = version, &report
+	return nil
+}
+
+// the decrypted lines of the next batch of the cursor
+func fetchLines(tx *sql.Tx, key encryption.DataKey, q string) ([]string, error) {
+	rs, err := tx.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	lines := make([]string, 0, rewriteBatchSize)
+	for rs.Next() {
+		var data []byte
+		if err := rs.Scan(&data); err != nil {
+			return nil, err
+		}
+		line, err := key.Decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, string(line))
+	}
+	return lines, rs.Err()
+}
+
+// the audit trail of the schema of a dataset, oldest first
+func (d DatasetPostgres) GetSchemaChanges(dataset int64) ([]entity.SchemaChange, error) {
+	q := "SELECT id, dataset, changed_by, changed_time, patch, old_schema, new_schema, version, report FROM SchemaChanges WHERE dataset = $1 ORDER BY id"
+	rows, err := d.db.Query(q, dataset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.SchemaChange, 0)
+	for rows.Next() {
+		var c entity.SchemaChange
+		var patch, oldSchema, newSchema, report []byte
+		var version sql.NullInt64
+		if err := rows.Scan(&c.Id, &c.Dataset, &c.ChangedBy, &c.ChangedOn, &patch, &oldSchema, &newSchema, &version, &report); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(patch, &c.Patch); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(oldSchema, &c.OldSchema); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(newSchema, &c.NewSchema); err != nil {
+			return nil, err
+		}
+		if report != nil {
+			if err := json.Unmarshal(report, &c.Report); err != nil {
+				return nil, err
+			}
+		}
+		c.Version = version.Int64
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
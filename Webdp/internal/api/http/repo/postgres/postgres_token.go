@@ -1,6 +1,12 @@
 package postgres
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+	"webdp/internal/api/http/entity"
+
+	errors "webdp/internal/api/http"
+)
 
 type TokenPostgres struct {
 	db *sql.DB
@@ -10,118 +16,136 @@ func NewTokenPostgres(conn *sql.DB) TokenPostgres {
 	return TokenPostgres{db: conn}
 }
 
-func (d TokenPostgres) SaveToken(userHandle string, stringifyedToken string) error {
+/*
+Saves a new session with the hash of its refresh token. The expired sessions
+of the user are removed at the same time.
+*/
+func (d TokenPostgres) CreateSession(s entity.Session, refreshHash string) (err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer dfun(err, tx)
-
-	q := "INSERT INTO UserTokens (username, token) VALUES ($1, $2)"
-
-	_, err = tx.Exec(q, userHandle, stringifyedToken)
+	defer func() { dfun(err, tx) }()
 
-	if err != nil {
+	if _, err = tx.Exec("DELETE FROM UserSessions WHERE username = $1 AND expires_time < $2", s.Handle, s.CreatedOn); err != nil {
 		return err
 	}
 
-	if err = tx.Commit(); err != nil {
+	q := `INSERT INTO UserSessions (id, username, device, refresh_hash, created_time, refreshed_time, expires_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err = tx.Exec(q, s.Id, s.Handle, s.Device, refreshHash, s.CreatedOn, s.RefreshedOn, s.ExpiresOn); err != nil {
 		return err
 	}
 
-	return err
+	return tx.Commit()
 }
 
-func (d TokenPostgres) UserTokenExists(userHandle string) (bool, error) {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return false, err
-	}
-
-	defer dfun(err, tx)
-
-	q := "SELECT 1 FROM UserTokens WHERE username = $1"
-	rows, err := tx.Query(q, userHandle)
-	if err != nil {
-		return false, err
-	}
-
-	ok := rows.Next()
-
-	rows.Close()
-	if err = tx.Commit(); err != nil {
+// whether the session of the user exists and has not expired
+func (d TokenPostgres) SessionActive(userHandle string, id string) (bool, error) {
+	var ok bool
+	q := "SELECT EXISTS (SELECT 1 FROM UserSessions WHERE id = $1 AND username = $2 AND expires_time > $3)"
+	if err := d.db.QueryRow(q, id, userHandle, time.Now().UTC()).Scan(&ok); err != nil {
 		return false, err
 	}
-
 	return ok, nil
-
 }
 
-func (d TokenPostgres) UpdateToken(userHandle string, stringifyedToken string) error {
+/*
+Replaces the refresh token of a session and extends the session by the refresh
+lifetime, but not beyond the max lifetime after it was created, see
+entity.SessionExpiry. ErrNotFound is returned if the session does not exist,
+has expired or reached its max lifetime, or refreshHash is not its refresh token. If refreshHash is the refresh token that was replaced
+last, the token has been used twice and the session is revoked, ErrConflict is
+then returned.
+*/
+func (d TokenPostgres) RotateRefreshToken(id string, refreshHash string, newHash string, refreshLifetime time.Duration, maxLifetime time.Duration) (s entity.Session, err error) {
 	tx, err := d.db.Begin()
 	if err != nil {
-		return err
+		return entity.Session{}, err
 	}
 
-	defer dfun(err, tx)
-
-	q := "UPDATE UserTokens SET token = $1 WHERE username = $2"
+	defer func() { dfun(err, tx) }()
 
-	_, err = tx.Exec(q, stringifyedToken, userHandle)
+	var current string
+	var previous sql.NullString
+	q := `SELECT id, username, device, created_time, refreshed_time, expires_time, refresh_hash, previous_hash
+		FROM UserSessions WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(q, id).Scan(&s.Id, &s.Handle, &s.Device, &s.CreatedOn, &s.RefreshedOn, &s.ExpiresOn, &current, &previous)
+	if err == sql.ErrNoRows {
+		err = errors.ErrNotFound
+	}
 	if err != nil {
-		return err
+		return entity.Session{}, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	now := time.Now().UTC()
+	if previous.Valid && previous.String == refreshHash {
+		if _, err = tx.Exec("DELETE FROM UserSessions WHERE id = $1", id); err != nil {
+			return entity.Session{}, err
+		}
+		if err = tx.Commit(); err != nil {
+			return entity.Session{}, err
+		}
+		return entity.Session{}, errors.ErrConflict
 	}
-
-	return nil
-}
-
-func (d TokenPostgres) DeleteUserToken(userHandle string) error {
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
+	expires := entity.SessionExpiry(s.CreatedOn, now, refreshLifetime, maxLifetime)
+	if current != refreshHash || s.ExpiresOn.Before(now) || !expires.After(now) {
+		err = errors.ErrNotFound
+		return entity.Session{}, err
 	}
 
-	defer dfun(err, tx)
+	q = "UPDATE UserSessions SET refresh_hash = $1, previous_hash = $2, refreshed_time = $3, expires_time = $4 WHERE id = $5"
+	if _, err = tx.Exec(q, newHash, current, now, expires, id); err != nil {
+		return entity.Session{}, err
+	}
+	s.RefreshedOn = now
+	s.ExpiresOn = expires
 
-	q := "DELETE FROM UserTokens WHERE username = $1"
-	_, err = tx.Exec(q, userHandle)
+	return s, tx.Commit()
+}
 
+// the sessions of a user that have not expired, the latest first
+func (d TokenPostgres) GetSessions(userHandle string) ([]entity.Session, error) {
+	q := `SELECT id, username, device, created_time, refreshed_time, expires_time FROM UserSessions
+		WHERE username = $1 AND expires_time > $2 ORDER BY created_time DESC, id`
+	rows, err := d.db.Query(q, userHandle, time.Now().UTC())
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if err = tx.Commit(); err != nil {
-		return err
+	defer rows.Close()
+
+	out := make([]entity.Session, 0)
+	for rows.Next() {
+		var s entity.Session
+		if err := rows.Scan(&s.Id, &s.Handle, &s.Device, &s.CreatedOn, &s.RefreshedOn, &s.ExpiresOn); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
 	}
 
-	return nil
+	return out, rows.Err()
 }
 
-func (d TokenPostgres) GetUserToken(userHandle string) (string, error) {
-	tx, err := d.db.Begin()
+func (d TokenPostgres) DeleteSession(userHandle string, id string) error {
+	res, err := d.db.Exec("DELETE FROM UserSessions WHERE id = $1 AND username = $2", id, userHandle)
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	defer dfun(err, tx)
-	q := "SELECT token FROM UserTokens WHERE username = $1"
-	row := tx.QueryRow(q, userHandle)
-
-	var token string
-	err = row.Scan(&token)
-
-	if err != nil {
-		return "", err
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
 	}
+	return nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return "", err
-	}
+// revokes all sessions of a user
+func (d TokenPostgres) DeleteUserSessions(userHandle string) error {
+	_, err := d.db.Exec("DELETE FROM UserSessions WHERE username = $1", userHandle)
+	return err
+}
 
-	return token, nil
+// revokes the sessions of a user except the one kept
+func (d TokenPostgres) DeleteOtherSessions(userHandle string, keep string) error {
+	_, err := d.db.Exec("DELETE FROM UserSessions WHERE username = $1 AND id <> $2", userHandle, keep)
+	return err
 }
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"database/sql"
+	"io"
+	"time"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/encryption"
+
+	errors "webdp/internal/api/http"
+)
+
+const uploadSessionColumns = "id, dataset, owner, size, created_time, updated_time"
+
+func (d DatasetPostgres) CreateUploadSession(dataset int64, owner string) (entity.UploadSession, error) {
+	now := time.Now()
+	q := "INSERT INTO UploadSessions (dataset, owner, size, created_time, updated_time) VALUES ($1, $2, 0, $3, $3) RETURNING id"
+
+	var id int64
+	if err := d.db.QueryRow(q, dataset, owner, now).Scan(&id); err != nil {
+		return entity.UploadSession{}, err
+	}
+
+	return entity.UploadSession{Id: id, Dataset: dataset, Owner: owner, CreatedOn: now, UpdatedOn: now, ExpiresOn: now.Add(entity.UPLOAD_SESSION_LIFETIME)}, nil
+}
+
+// the session of the owner, sql.ErrNoRows if it is not there or expired
+func (d DatasetPostgres) GetUploadSession(dataset int64, session int64, owner string) (entity.UploadSession, error) {
+	q := "SELECT " + uploadSessionColumns + " FROM UploadSessions WHERE id = $1 AND dataset = $2 AND owner = $3 AND updated_time > $4"
+	return scanUploadSession(d.db.QueryRow(q, session, dataset, owner, time.Now().Add(-entity.UPLOAD_SESSION_LIFETIME)))
+}
+
+/*
+Appends a chunk at offset, which has to be the current size of the session.
+On a mismatch the session is returned unchanged together with ErrConflict
+so that the client can resume from the stored size.
+*/
+func (d DatasetPostgres) AppendUploadChunk(dataset int64, session int64, owner string, offset int64, chunk []byte) (us entity.UploadSession, err error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return entity.UploadSession{}, err
+	}
+
+	defer func() { dfun(err, tx) }()
+
+	q := "SELECT " + uploadSessionColumns + " FROM UploadSessions WHERE id = $1 AND dataset = $2 AND owner = $3 AND updated_time > $4 FOR UPDATE"
+	us, err = scanUploadSession(tx.QueryRow(q, session, dataset, owner, time.Now().Add(-entity.UPLOAD_SESSION_LIFETIME)))
+	if err != nil {
+		return entity.UploadSession{}, err
+	}
+
+	if offset != us.Size {
+		err = errors.ErrConflict
+		return us, err
+	}
+
+	key, err := d.dataKey(tx, dataset)
+	if err != nil {
+		return entity.UploadSession{}, err
+	}
+	if _, err = tx.Exec("INSERT INTO UploadChunks (session, chunk_offset, data) VALUES ($1, $2, $3)", session, offset, key.Encrypt(chunk)); err != nil {
+		return entity.UploadSession{}, err
+	}
+
+	us.Size += int64(len(chunk))
+	us.UpdatedOn = time.Now()
+	us.ExpiresOn = us.UpdatedOn.Add(entity.UPLOAD_SESSION_LIFETIME)
+	if _, err = tx.Exec("UPDATE UploadSessions SET size = $1, updated_time = $2 WHERE id = $3", us.Size, us.UpdatedOn, session); err != nil {
+		return entity.UploadSession{}, err
+	}
+
+	return us, tx.Commit()
+}
+
+// deletes the session of the owner, expired or not
+func (d DatasetPostgres) DeleteUploadSession(dataset int64, session int64, owner string) error {
+	res, err := d.db.Exec("DELETE FROM UploadSessions WHERE id = $1 AND dataset = $2 AND owner = $3", session, dataset, owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// deletes the sessions without chunks since before and returns how many
+func (d DatasetPostgres) PurgeUploadSessions(before time.Time) (int64, error) {
+	res, err := d.db.Exec("DELETE FROM UploadSessions WHERE updated_time <= $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanUploadSession(row *sql.Row) (entity.UploadSession, error) {
+	var us entity.UploadSession
+	if err := row.Scan(&us.Id, &us.Dataset, &us.Owner, &us.Size, &us.CreatedOn, &us.UpdatedOn); err != nil {
+		return entity.UploadSession{}, err
+	}
+	us.ExpiresOn = us.UpdatedOn.Add(entity.UPLOAD_SESSION_LIFETIME)
+	return us, nil
+}
+
+// reads the decrypted chunks of a session in order, one chunk in memory at a time
+type chunkReader struct {
+	rows    *sql.Rows
+	key     encryption.DataKey
+	current []byte
+}
+
+func (d DatasetPostgres) OpenUploadSession(dataset int64, session int64) (io.ReadCloser, error) {
+	key, err := d.dataKey(d.db, dataset)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query("SELECT data FROM UploadChunks WHERE session = $1 ORDER BY chunk_offset", session)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReader{rows: rows, key: key}, nil
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.current) == 0 {
+		if !c.rows.Next() {
+			if err := c.rows.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		var data []byte
+		if err := c.rows.Scan(&data); err != nil {
+			return 0, err
+		}
+		chunk, err := c.key.Decrypt(data)
+		if err != nil {
+			return 0, err
+		}
+		c.current = chunk
+	}
+
+	n := copy(p, c.current)
+	c.current = c.current[n:]
+	return n, nil
+}
+
+func (c *chunkReader) Close() error {
+	return c.rows.Close()
+}
@@ -19,16 +19,17 @@ func NewUserPostgres(conn *sql.DB) UserPostgres {
 	return UserPostgres{db: conn}
 }
 
-func (u UserPostgres) GetUsers() ([]entity.UserResponse, error) {
+// the users of the organization, or of all organizations if it is empty
+func (u UserPostgres) GetUsers(organization string) ([]entity.UserResponse, error) {
 	tx, err := u.db.BeginTx(context.Background(), nil)
 	if err != nil {
 		return []entity.UserResponse{}, err
 	}
 	defer dfun(err, tx)
 
-	q := "SELECT handle, name, roles, created_time, updated_time FROM GetUsers"
+	q := "SELECT handle, name, organization, roles, created_time, updated_time FROM GetUsers WHERE $1 = '' OR organization = $1"
 
-	rows, err := tx.Query(q)
+	rows, err := tx.Query(q, organization)
 	if err != nil {
 		return []entity.UserResponse{}, err
 	}
@@ -36,7 +37,7 @@ func (u UserPostgres) GetUsers() ([]entity.UserResponse, error) {
 	out := make([]entity.UserResponse, 0)
 	for rows.Next() {
 		usr := entity.UserResponse{}
-		err = rows.Scan(&usr.Handle, &usr.Name, pq.Array(&usr.Roles), &usr.CreatedOn, &usr.UpdatedOn)
+		err = rows.Scan(&usr.Handle, &usr.Name, &usr.Organization, pq.Array(&usr.Roles), &usr.CreatedOn, &usr.UpdatedOn)
 		if err != nil {
 			rows.Close()
 			return []entity.UserResponse{}, err
@@ -55,10 +56,10 @@ func (u UserPostgres) GetUser(handle string) (entity.UserResponse, error) {
 	}
 
 	defer dfun(err, tx)
-	q := "SELECT handle, name, roles, created_time, updated_time FROM GetUsers WHERE handle = $1"
+	q := "SELECT handle, name, organization, roles, created_time, updated_time FROM GetUsers WHERE handle = $1"
 	row := tx.QueryRow(q, handle)
 	var user entity.UserResponse
-	err = row.Scan(&user.Handle, &user.Name, pq.Array(&user.Roles), &user.CreatedOn, &user.UpdatedOn)
+	err = row.Scan(&user.Handle, &user.Name, &user.Organization, pq.Array(&user.Roles), &user.CreatedOn, &user.UpdatedOn)
 	if err != nil {
 		return entity.UserResponse{}, errors.ErrNotFound
 	}
@@ -66,23 +67,76 @@ func (u UserPostgres) GetUser(handle string) (entity.UserResponse, error) {
 	return user, nil
 }
 
-func (u UserPostgres) GetByHandle(handle string) (entity.LoginRequest, error) {
-	tx, err := u.db.BeginTx(context.Background(), nil)
+// the stored password of the user and its failed logins
+func (u UserPostgres) GetCredentials(handle string) (entity.Credentials, error) {
+	q := "SELECT handle, pwd, failed_logins, locked_until FROM Users WHERE handle = $1"
+	var c entity.Credentials
+	var locked sql.NullTime
+	if err := u.db.QueryRow(q, handle).Scan(&c.Handle, &c.PWD, &c.FailedLogins, &locked); err != nil {
+		return entity.Credentials{}, errors.ErrNotFound
+	}
+	if locked.Valid {
+		c.LockedUntil = &locked.Time
+	}
+	return c, nil
+}
+
+/*
+Reserves an attempt to log in as the user before its password is checked, by
+counting it as a failed login: a check that succeeds forgets the failed logins
+again. The attempt that reaches max locks the account until the time given and
+starts the count again, so that concurrent attempts can not make more than max
+guesses. Returns the credentials and the time of the lock if this attempt
+locked the account; ErrNotFound if the user does not exist, or the credentials
+and ErrTooManyRequests if the account is locked at now.
+*/
+func (u UserPostgres) ReserveLoginAttempt(handle string, max int, until time.Time, now time.Time) (entity.Credentials, *time.Time, error) {
+	q := `UPDATE Users SET
+			failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+			locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE NULL END
+		WHERE handle = $1 AND (locked_until IS NULL OR locked_until <= $4)
+		RETURNING handle, pwd, failed_logins, locked_until`
+	var c entity.Credentials
+	var locked sql.NullTime
+	err := u.db.QueryRow(q, handle, max, until, now).Scan(&c.Handle, &c.PWD, &c.FailedLogins, &locked)
+	if err == sql.ErrNoRows {
+		// the user does not exist or is locked
+		c, err := u.GetCredentials(handle)
+		if err != nil {
+			return entity.Credentials{}, nil, err
+		}
+		// unlocked meanwhile, the caller tries again
+		if c.LockedUntil == nil {
+			c.LockedUntil = &now
+		}
+		return c, nil, errors.ErrTooManyRequests
+	}
 	if err != nil {
-		return entity.LoginRequest{}, err
+		return entity.Credentials{}, nil, err
 	}
+	if !locked.Valid {
+		return c, nil, nil
+	}
+	c.LockedUntil = &locked.Time
+	return c, &locked.Time, nil
+}
 
-	defer dfun(err, tx)
-	q := "SELECT handle, pwd FROM Users WHERE handle = $1"
-	row := tx.QueryRow(q, handle)
-	var user entity.LoginRequest
-	err = row.Scan(&user.Username, &user.PWD)
+// forgets the failed logins of the user and unlocks it, ErrNotFound if the user does not exist
+func (u UserPostgres) ResetFailedLogins(handle string) error {
+	res, err := u.db.Exec("UPDATE Users SET failed_logins = 0, locked_until = NULL WHERE handle = $1", handle)
 	if err != nil {
-		return entity.LoginRequest{}, errors.ErrNotFound
+		return err
 	}
-	tx.Commit()
-	return user, nil
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
 
+// replaces the hash of the password with one of another cost, unless the password changed meanwhile
+func (u UserPostgres) RehashPassword(handle string, old string, hash string) error {
+	_, err := u.db.Exec("UPDATE Users SET pwd = $3 WHERE handle = $1 AND pwd = $2", handle, old, hash)
+	return err
 }
 
 func (u UserPostgres) CreateUser(post entity.UserPost) (string, error) {
@@ -90,14 +144,20 @@ func (u UserPostgres) CreateUser(post entity.UserPost) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 	created := time.Now().UTC()
-	q1 := "INSERT INTO Users (handle, pwd, name, created_time, updated_time) VALUES ($1, $2, $3, $4, $5)"
+	if err = organizationExists(tx, post.Organization); err != nil {
+		return "", err
+	}
+	q1 := "INSERT INTO Users (handle, pwd, name, organization, created_time, updated_time) VALUES ($1, $2, $3, $4, $5, $6)"
 	q2 := "INSERT INTO UserRoles (username, role) VALUES ($1, $2)"
-	_, err = tx.Exec(q1, post.Handle, post.PWD, post.Name, created, created)
+	_, err = tx.Exec(q1, post.Handle, post.PWD, post.Name, post.Organization, created, created)
 	if err != nil {
 		return "", err
 	}
+	if err = rolesExist(tx, post.Roles); err != nil {
+		return "", err
+	}
 	for _, role := range post.Roles {
 		_, err = tx.Exec(q2, post.Handle, role)
 		if err != nil {
@@ -114,7 +174,7 @@ func (u UserPostgres) UpdateUser(handle string, patch entity.UserPatch) (string,
 	if err != nil {
 		return "", err
 	}
-	defer dfun(err, tx)
+	defer func() { dfun(err, tx) }()
 
 	updated := time.Now().UTC()
 
@@ -138,6 +198,9 @@ func (u UserPostgres) UpdateUser(handle string, patch entity.UserPatch) (string,
 	}
 
 	if patch.Roles != nil {
+		if err = rolesExist(tx, patch.Roles); err != nil {
+			return "", err
+		}
 		d := "DELETE FROM UserRoles WHERE username = $1"
 		_, err = tx.Exec(d, handle)
 		if err != nil {
@@ -172,6 +235,46 @@ func (u UserPostgres) DeleteUser(handle string) error {
 	return nil
 }
 
+// whether one of the roles of the user gives the permission
+func (u UserPostgres) HasPermission(handle string, permission string) (bool, error) {
+	var has bool
+	q := `SELECT EXISTS (SELECT 1 FROM UserRoles AS U JOIN RolePermissions AS P ON P.role = U.role
+		WHERE U.username = $1 AND P.permission = $2)`
+	err := u.db.QueryRow(q, handle, permission).Scan(&has)
+	return has, err
+}
+
+// the organization of the user
+func (u UserPostgres) GetOrganization(handle string) (string, error) {
+	var organization string
+	err := u.db.QueryRow("SELECT organization FROM Users WHERE handle = $1", handle).Scan(&organization)
+	return organization, err
+}
+
+// ErrBadInput if one of the roles does not exist
+func rolesExist(tx *sql.Tx, roles []string) error {
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM Roles WHERE role = ANY($1)", pq.Array(roles)).Scan(&n); err != nil {
+		return err
+	}
+	if n != len(roles) {
+		return errors.ErrBadInput
+	}
+	return nil
+}
+
+// ErrNotFound if the organization does not exist
+func organizationExists(tx *sql.Tx, organization string) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM Organizations WHERE handle = $1)", organization).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
 func dfun(e error, tx *sql.Tx) {
 	if e != nil {
 		tx.Rollback()
@@ -5,7 +5,9 @@ This is mostly for OAS generation.
 */
 
 type Token struct {
-	Token string `json:"jwt"`
+	Token        string `json:"jwt"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
 }
 
 type Error struct {
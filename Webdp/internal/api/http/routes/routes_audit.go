@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"webdp/internal/api/http/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterAudit(router *mux.Router, handler handlers.AuditHandler) {
+	audit := router.PathPrefix("/audit").Subrouter()
+	audit.HandleFunc("", handlers.HandlerDecorator(handler.GetAuditLog)).Methods("GET")
+	audit.HandleFunc("/export", handlers.HandlerDecorator(handler.ExportAuditLog)).Methods("GET")
+	audit.HandleFunc("/verify", handlers.HandlerDecorator(handler.VerifyAuditLog)).Methods("GET")
+}
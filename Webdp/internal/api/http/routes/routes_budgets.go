@@ -20,8 +20,11 @@ func RegisterBudgetsV2(router *mux.Router, handler handlers.BudgetHandler) {
 	budget := router.PathPrefix("/budgets").Subrouter()
 	budget.HandleFunc("/users/{userHandle}", handlers.HandlerDecorator(handler.GetUserBudgets)).Methods("GET")
 	budget.HandleFunc("/datasets/{datasetId}", handlers.HandlerDecorator(handler.GetDatasetBudget)).Methods("GET")
+	budget.HandleFunc("/purged", handlers.HandlerDecorator(handler.GetPurgedBudgets)).Methods("GET")
 	budget.HandleFunc("/allocations/{userHandle}/{datasetId}", handlers.HandlerDecorator(handler.GetUserDatasetBudget)).Methods("GET")
 	budget.HandleFunc("/allocations/{userHandle}/{datasetId}", handlers.HandlerDecorator(handler.PostUserDatasetBudget)).Methods("POST")
 	budget.HandleFunc("/allocations/{userHandle}/{datasetId}", handlers.HandlerDecorator(handler.PatchUserDatasetBudget)).Methods("PATCH")
 	budget.HandleFunc("/allocations/{userHandle}/{datasetId}", handlers.HandlerDecorator(handler.DeleteUserDatasetBudget)).Methods("DELETE")
+	budget.HandleFunc("/allocations/{userHandle}/{datasetId}/quota", handlers.HandlerDecorator(handler.GetQueryQuota)).Methods("GET")
+	budget.HandleFunc("/allocations/{userHandle}/{datasetId}/quota", handlers.HandlerDecorator(handler.PutQueryQuota)).Methods("PUT")
 }
@@ -25,12 +25,35 @@ func RegisterDatasetsV2(router *mux.Router, handler handlers.DatasetHandler) {
 
 	datasets.HandleFunc("", handlers.HandlerDecorator(handler.GetDatasets)).Methods("GET")
 	datasets.HandleFunc("", handlers.HandlerDecorator(handler.PostDataset)).Methods("POST")
+	datasets.HandleFunc("/infer-schema", handlers.HandlerDecorator(handler.InferSchema)).Methods("POST")
 	datasets.HandleFunc("/{datasetId}", handlers.HandlerDecorator(handler.GetDataset)).Methods("GET")
 	datasets.HandleFunc("/{datasetId}", handlers.HandlerDecorator(handler.PatchDataset)).Methods("PATCH")
 	datasets.HandleFunc("/{datasetId}", handlers.HandlerDecorator(handler.DeleteDataset)).Methods("DELETE")
+	datasets.HandleFunc("/{datasetId}/restore", handlers.HandlerDecorator(handler.RestoreDataset)).Methods("POST")
 
 	// upload the csv to the db
 	datasets.HandleFunc("/{datasetId}/upload", handlers.HandlerDecorator(handler.UploadData)).Methods("POST")
+	datasets.HandleFunc("/{datasetId}/versions", handlers.HandlerDecorator(handler.GetDataVersions)).Methods("GET")
+
+	// schema evolution
+	datasets.HandleFunc("/{datasetId}/schema", handlers.HandlerDecorator(handler.PatchSchema)).Methods("PATCH")
+	datasets.HandleFunc("/{datasetId}/schema/changes", handlers.HandlerDecorator(handler.GetSchemaChanges)).Methods("GET")
+
+	// resumable uploads in chunks
+	datasets.HandleFunc("/{datasetId}/uploads", handlers.HandlerDecorator(handler.PostUploadSession)).Methods("POST")
+	datasets.HandleFunc("/{datasetId}/uploads/{uploadId}", handlers.HandlerDecorator(handler.GetUploadSession)).Methods("GET")
+	datasets.HandleFunc("/{datasetId}/uploads/{uploadId}", handlers.HandlerDecorator(handler.PutUploadChunk)).Methods("PUT")
+	datasets.HandleFunc("/{datasetId}/uploads/{uploadId}", handlers.HandlerDecorator(handler.DeleteUploadSession)).Methods("DELETE")
+	datasets.HandleFunc("/{datasetId}/uploads/{uploadId}/complete", handlers.HandlerDecorator(handler.CompleteUploadSession)).Methods("POST")
+
+	// members and ownership transfers
+	datasets.HandleFunc("/{datasetId}/members", handlers.HandlerDecorator(handler.GetMembers)).Methods("GET")
+	datasets.HandleFunc("/{datasetId}/members/{userHandle}", handlers.HandlerDecorator(handler.PutMember)).Methods("PUT")
+	datasets.HandleFunc("/{datasetId}/members/{userHandle}", handlers.HandlerDecorator(handler.DeleteMember)).Methods("DELETE")
+	datasets.HandleFunc("/{datasetId}/transfer", handlers.HandlerDecorator(handler.PostTransfer)).Methods("POST")
+	datasets.HandleFunc("/{datasetId}/transfer", handlers.HandlerDecorator(handler.GetTransfer)).Methods("GET")
+	datasets.HandleFunc("/{datasetId}/transfer", handlers.HandlerDecorator(handler.DeleteTransfer)).Methods("DELETE")
+	datasets.HandleFunc("/{datasetId}/transfer/accept", handlers.HandlerDecorator(handler.AcceptTransfer)).Methods("POST")
 }
 
 func RegisterInternalDatasets(router *mux.Router, handler handlers.InternalDatasetHandler) {
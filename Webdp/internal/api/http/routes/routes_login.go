@@ -8,8 +8,26 @@ import (
 
 func RegisterLogin(router *mux.Router, handler handlers.LoginHandler) {
 	router.HandleFunc("/login", handlers.HandlerDecorator(handler.LoginRequestHandler)).Methods("POST")
+	router.HandleFunc("/refresh", handlers.HandlerDecorator(handler.RefreshRequestHandler)).Methods("POST")
+}
+
+// the keys of the access tokens, for the engines and others that verify them
+func RegisterJwks(router *mux.Router, handler handlers.LoginHandler) {
+	router.HandleFunc("/.well-known/jwks.json", handlers.HandlerDecorator(handler.GetJWKS)).Methods("GET")
 }
 
 func RegisterLogout(router *mux.Router, handler handlers.LoginHandler) {
 	router.HandleFunc("/logout", handlers.HandlerDecorator(handler.LogoutRequestHandler)).Methods("POST")
 }
+
+func RegisterSessions(router *mux.Router, handler handlers.LoginHandler) {
+	sessions := router.PathPrefix("/sessions").Subrouter()
+	sessions.HandleFunc("", handlers.HandlerDecorator(handler.GetSessions)).Methods("GET")
+	sessions.HandleFunc("/{sessionId}", handlers.HandlerDecorator(handler.DeleteSession)).Methods("DELETE")
+}
+
+func RegisterOidc(router *mux.Router, handler handlers.OidcHandler) {
+	oidc := router.PathPrefix("/oidc").Subrouter()
+	oidc.HandleFunc("/login", handlers.HandlerDecorator(handler.Login)).Methods("GET")
+	oidc.HandleFunc("/callback", handlers.HandlerDecorator(handler.Callback)).Methods("GET")
+}
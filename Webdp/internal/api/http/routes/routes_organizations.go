@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"webdp/internal/api/http/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterOrganizations(router *mux.Router, handler handlers.OrganizationHandler) {
+	organizations := router.PathPrefix("/organizations").Subrouter()
+	organizations.HandleFunc("", handlers.HandlerDecorator(handler.GetOrganizations)).Methods("GET")
+	organizations.HandleFunc("", handlers.HandlerDecorator(handler.PostOrganization)).Methods("POST")
+	organizations.HandleFunc("/{orgHandle}", handlers.HandlerDecorator(handler.GetOrganization)).Methods("GET")
+	organizations.HandleFunc("/{orgHandle}", handlers.HandlerDecorator(handler.PatchOrganization)).Methods("PATCH")
+	organizations.HandleFunc("/{orgHandle}", handlers.HandlerDecorator(handler.DeleteOrganization)).Methods("DELETE")
+}
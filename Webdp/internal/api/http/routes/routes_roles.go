@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"webdp/internal/api/http/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterRoles(router *mux.Router, handler handlers.RoleHandler) {
+	router.HandleFunc("/permissions", handlers.HandlerDecorator(handler.GetPermissions)).Methods("GET")
+
+	roles := router.PathPrefix("/roles").Subrouter()
+	roles.HandleFunc("", handlers.HandlerDecorator(handler.GetRoles)).Methods("GET")
+	roles.HandleFunc("", handlers.HandlerDecorator(handler.PostRole)).Methods("POST")
+	roles.HandleFunc("/{role}", handlers.HandlerDecorator(handler.GetRole)).Methods("GET")
+	roles.HandleFunc("/{role}", handlers.HandlerDecorator(handler.PutRole)).Methods("PUT")
+	roles.HandleFunc("/{role}", handlers.HandlerDecorator(handler.DeleteRole)).Methods("DELETE")
+}
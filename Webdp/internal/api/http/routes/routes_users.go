@@ -25,4 +25,13 @@ func RegisterUserV2(router *mux.Router, handler handlers.UserHandler) {
 	users.HandleFunc("/{userHandle}", handlers.HandlerDecorator(handler.GetUser)).Methods("GET")
 	users.HandleFunc("/{userHandle}", handlers.HandlerDecorator(handler.PatchUser)).Methods("PATCH")
 	users.HandleFunc("/{userHandle}", handlers.HandlerDecorator(handler.DeleteUser)).Methods("DELETE")
+
+	// passwords
+	users.HandleFunc("/{userHandle}/password", handlers.HandlerDecorator(handler.ChangePassword)).Methods("PUT")
+	users.HandleFunc("/{userHandle}/lockout", handlers.HandlerDecorator(handler.UnlockUser)).Methods("DELETE")
+
+	// api keys
+	users.HandleFunc("/{userHandle}/keys", handlers.HandlerDecorator(handler.GetApiKeys)).Methods("GET")
+	users.HandleFunc("/{userHandle}/keys", handlers.HandlerDecorator(handler.PostApiKey)).Methods("POST")
+	users.HandleFunc("/{userHandle}/keys/{keyId}", handlers.HandlerDecorator(handler.DeleteApiKey)).Methods("DELETE")
 }
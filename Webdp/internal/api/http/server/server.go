@@ -0,0 +1,166 @@
+package server
+
+import (
+	"database/sql"
+	"webdp/internal/api/http/client"
+	"webdp/internal/api/http/handlers"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/repo/postgres"
+	"webdp/internal/api/http/routes"
+	"webdp/internal/api/http/services"
+	"webdp/internal/config"
+	"webdp/internal/encryption"
+	"webdp/internal/oidc"
+	"webdp/internal/signing"
+
+	"github.com/gorilla/mux"
+)
+
+// api versions
+const (
+	VERSION_1 = "/v1"
+	VERSION_2 = "/v2"
+)
+
+type Repos struct {
+	Users         postgres.UserPostgres
+	Tokens        postgres.TokenPostgres
+	Datasets      postgres.DatasetPostgres
+	Budgets       postgres.BudgetPostgres
+	Roles         postgres.RolePostgres
+	Organizations postgres.OrganizationPostgres
+	Audit         postgres.AuditPostgres
+	Limits        postgres.RateLimitPostgres
+}
+
+type Services struct {
+	Users         services.UserService
+	Tokens        services.TokenService
+	Datasets      services.DatasetService
+	Budgets       services.BudgetService
+	Policy        services.PolicyService
+	Organizations services.OrganizationService
+	Audit         services.AuditService
+	Limits        services.RateLimitService
+	Oidc          *services.OidcService // nil unless an identity provider is configured
+}
+
+type Handlers struct {
+	Users         handlers.UserHandler
+	Login         handlers.LoginHandler
+	Datasets      handlers.DatasetHandler
+	Budgets       handlers.BudgetHandler
+	Queries       handlers.QueryHandler
+	Roles         handlers.RoleHandler
+	Organizations handlers.OrganizationHandler
+	Audit         handlers.AuditHandler
+	Oidc          handlers.OidcHandler
+}
+
+/*
+Initiates repos, services and handlers
+*/
+func New(db *sql.DB, client *client.DPClient, keys *encryption.KeyRing, signKeys *signing.KeyRing, env *config.Envs) (*Repos, *Services, *Handlers) {
+
+	// repos
+	repo := &Repos{
+		Users:         postgres.NewUserPostgres(db),
+		Tokens:        postgres.NewTokenPostgres(db),
+		Datasets:      postgres.NewDatasetPostgres(db, keys),
+		Budgets:       postgres.NewBudgetPostgres(db),
+		Roles:         postgres.NewRolePostgres(db),
+		Organizations: postgres.NewOrganizationPostgres(db),
+		Audit:         postgres.NewAuditPostgres(db),
+		Limits:        postgres.NewRateLimitPostgres(db),
+	}
+
+	// services
+	audit := services.NewAuditService(repo.Audit)
+	service := &Services{
+		Users:         services.NewUserService(repo.Users, audit, env.Password, env.Hash_cost, env.Login),
+		Tokens:        services.NewTokenService(repo.Tokens, signKeys, env.Access_lifetime, env.Refresh_lifetime, env.Session_lifetime, audit),
+		Datasets:      services.NewDatasetService(repo.Datasets, repo.Budgets, audit),
+		Budgets:       services.NewBudgetService(repo.Budgets, audit, env.Daily_quota),
+		Policy:        services.NewPolicyService(repo.Roles, audit),
+		Organizations: services.NewOrganizationService(repo.Organizations, audit),
+		Audit:         audit,
+		Limits:        services.NewRateLimitService(repo.Limits, env.Rate_limits),
+	}
+	if env.Oidc != nil {
+		provider := oidc.NewProvider(*env.Oidc, nil)
+		service.Oidc = services.NewOidcService(provider, service.Users, service.Tokens, signKeys)
+	}
+
+	// handlers
+	handler := &Handlers{
+		Users:         handlers.NewUserHandler(service.Users, service.Tokens),
+		Datasets:      handlers.NewDatasetHandler(service.Datasets, service.Users, service.Budgets, *client),
+		Login:         handlers.NewLoginHandler(service.Users, service.Tokens),
+		Budgets:       handlers.NewBudgetHandler(service.Budgets, service.Datasets),
+		Queries:       handlers.NewQueryHandler(service.Datasets, service.Budgets, *client),
+		Roles:         handlers.NewRoleHandler(service.Policy),
+		Organizations: handlers.NewOrganizationHandler(service.Organizations),
+		Audit:         handlers.NewAuditHandler(service.Audit),
+		Oidc:          handlers.NewOidcHandler(service.Oidc),
+	}
+
+	return repo, service, handler
+}
+
+/*
+The router of the external routes of both versions of the API
+*/
+func NewRouter(service *Services, handler *Handlers) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middlewares.RequestId)
+	router.Use(middlewares.Logger)
+	RegisterExRoutes(router, VERSION_1, service, handler)
+	RegisterExRoutes(router, VERSION_2, service, handler)
+	return router
+}
+
+/*
+Registers external routes
+*/
+func RegisterExRoutes(r *mux.Router, version string, service *Services, handler *Handlers) {
+	router := r.PathPrefix(version).Subrouter()
+	notoken := router.PathPrefix("").Subrouter()
+	token := router.PathPrefix("").Subrouter()
+	token.Use(middlewares.GetTokenAuthentication(service.Tokens, service.Oidc))
+	token.Use(middlewares.GetRateLimit(service.Limits))
+	token.Use(middlewares.GetPolicyAuthorization(service.Policy))
+	token.Use(middlewares.GetOrganizationScope(service.Users, service.Datasets))
+
+	routes.RegisterLogout(token, handler.Login)
+	routes.RegisterLogin(notoken, handler.Login)
+
+	if version == VERSION_1 {
+		routes.RegisterUserV1(token, handler.Users)
+		routes.RegisterDatasetsV1(token, handler.Datasets)
+		routes.RegisterBudgetsV1(token, handler.Budgets)
+		routes.RegisterQueriesV1(token, handler.Queries)
+	} else if version == VERSION_2 {
+		routes.RegisterUserV2(token, handler.Users)
+		routes.RegisterSessions(token, handler.Login)
+		routes.RegisterRoles(token, handler.Roles)
+		routes.RegisterOrganizations(token, handler.Organizations)
+		routes.RegisterAudit(token, handler.Audit)
+		routes.RegisterDatasetsV2(token, handler.Datasets)
+		routes.RegisterBudgetsV2(token, handler.Budgets)
+		routes.RegisterQueriesV2(token, handler.Queries)
+		routes.RegisterSpec(router) // no auth for this one
+		routes.RegisterJwks(notoken, handler.Login)
+		if service.Oidc != nil {
+			routes.RegisterOidc(notoken, handler.Oidc)
+		}
+	}
+}
+
+/*
+Registers internal routes.
+*/
+func RegisterInRoutes(ir *mux.Router, datasetrepo *postgres.DatasetPostgres) {
+	serv := services.NewInternalDatasetService(*datasetrepo)
+	hand := handlers.NewInternalDatasetHandler(*serv)
+	routes.RegisterInternalDatasets(ir, *hand)
+}
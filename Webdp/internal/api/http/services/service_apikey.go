@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+/*
+Creates a key for owner with the roles and datasets of the request. The key is
+only returned here, only its hash is stored.
+*/
+func (t TokenService) CreateApiKey(actor entity.Actor, owner string, req entity.ApiKeyCreate) (entity.ApiKeyCreated, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return entity.ApiKeyCreated{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return entity.ApiKeyCreated{}, err
+	}
+
+	datasets := req.Datasets
+	if datasets == nil {
+		datasets = []int64{}
+	}
+	k := entity.ApiKey{
+		Id:        id,
+		Owner:     owner,
+		Name:      req.Name,
+		Roles:     req.Roles,
+		Datasets:  datasets,
+		CreatedBy: actor.Handle,
+		CreatedOn: time.Now().UTC(),
+		ExpiresOn: req.ExpiresOn.UTC(),
+	}
+	if err := t.postg.CreateApiKey(k, hashToken(secret)); err != nil {
+		return entity.ApiKeyCreated{}, errors.WrapDBError(err, "create api key for", owner)
+	}
+	t.audit.Record(actor, entity.AUDIT_APIKEY_CREATE, auditTarget("user", owner, "apikey", id), nil, k)
+	return entity.ApiKeyCreated{ApiKey: k, Key: entity.API_KEY_PREFIX + id + "." + secret}, nil
+}
+
+func (t TokenService) GetApiKeys(owner string) ([]entity.ApiKey, error) {
+	keys, err := t.postg.GetApiKeys(owner)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get api keys of", owner)
+	}
+	return keys, nil
+}
+
+func (t TokenService) DeleteApiKey(actor entity.Actor, owner string, id string) error {
+	keys, err := t.GetApiKeys(owner)
+	if err != nil {
+		return err
+	}
+	if err := t.postg.DeleteApiKey(owner, id); err != nil {
+		return errors.WrapDBError(err, "delete api key", id)
+	}
+
+	var before any
+	if i := slices.IndexFunc(keys, func(k entity.ApiKey) bool { return k.Id == id }); i >= 0 {
+		before = keys[i]
+	}
+	t.audit.Record(actor, entity.AUDIT_APIKEY_DELETE, auditTarget("user", owner, "apikey", id), before, nil)
+	return nil
+}
+
+/*
+Exchanges an API key for a signed access token that is only valid for the
+current request, and records that the key was used. ErrUnauthorized is
+returned if the key is unknown or expired.
+*/
+func (t TokenService) AuthenticateApiKey(key string) (string, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, entity.API_KEY_PREFIX), ".")
+	if !ok {
+		return "", fmt.Errorf("%w: invalid api key", errors.ErrUnauthorized)
+	}
+
+	k, hash, err := t.postg.GetApiKey(id)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(secret))) != 1 {
+		return "", fmt.Errorf("%w: invalid api key", errors.ErrUnauthorized)
+	}
+	now := time.Now().UTC()
+	if k.ExpiresOn.Before(now) {
+		return "", fmt.Errorf("%w: api key expired", errors.ErrUnauthorized)
+	}
+	if err := t.postg.TouchApiKey(id, now); err != nil {
+		return "", errors.WrapDBError(err, "use api key", id)
+	}
+
+	// the key keeps only the roles its owner still has, and nothing once the owner is deleted
+	roles, err := t.postg.GetOwnerRoles(k.Owner)
+	if err == errors.ErrNotFound {
+		return "", fmt.Errorf("%w: the owner of the api key no longer exists", errors.ErrUnauthorized)
+	}
+	if err != nil {
+		return "", errors.WrapDBError(err, "get roles of", k.Owner)
+	}
+	k.Roles = slices.DeleteFunc(k.Roles, func(role string) bool { return !slices.Contains(roles, role) })
+
+	c := JWTTokenClaims{Handle: k.Owner, Roles: k.Roles, ApiKey: true}
+	if len(k.Datasets) > 0 {
+		c.Datasets = k.Datasets
+	}
+	c.Id = k.Id
+	return t.ExchangeToken(c)
+}
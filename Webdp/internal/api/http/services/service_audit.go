@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+)
+
+/*
+Records the administrative and privacy-relevant actions in the audit log. The
+other services record their actions once they succeeded, a failure to record
+is logged rather than failing an action that is already done. Query releases
+and changes of budgets are recorded in the transaction of the change instead,
+see Entry, so that they are not made unless they are recorded.
+*/
+type AuditService struct {
+	postg postgres.AuditPostgres
+}
+
+func NewAuditService(auditRepo postgres.AuditPostgres) AuditService {
+	return AuditService{postg: auditRepo}
+}
+
+/*
+Records that the actor did the action on the target. Before and after are the
+values of the target before and after the action, nil for what did not exist.
+*/
+func (a AuditService) Record(actor entity.Actor, action string, target string, before any, after any) {
+	e, err := a.Entry(actor, action, target, before, after)
+	if err == nil {
+		_, err = a.postg.AppendAuditEntry(e)
+	}
+	if err != nil {
+		log.Printf("Recording %s of %s by %s in the audit log: %v", action, target, actor.Handle, err)
+	}
+}
+
+// the entry that records the action, for a repository to append in the transaction of the action
+func (a AuditService) Entry(actor entity.Actor, action string, target string, before any, after any) (entity.AuditEntry, error) {
+	e := entity.AuditEntry{
+		Time:         time.Now().UTC().Truncate(time.Microsecond),
+		Actor:        actor.Handle,
+		Organization: actor.Organization,
+		Action:       action,
+		Target:       target,
+		RequestId:    actor.RequestId,
+	}
+	var err error
+	if e.Before, err = auditValue(before); err != nil {
+		return e, err
+	}
+	e.After, err = auditValue(after)
+	return e, err
+}
+
+func (a AuditService) ListAuditEntries(filter entity.AuditFilter) (entity.AuditPage, error) {
+	page, err := a.postg.ListAuditEntries(filter)
+	if err != nil {
+		return entity.AuditPage{}, errors.WrapDBError(err, "get", "audit log")
+	}
+	return page, nil
+}
+
+// calls fn with every entry that matches the filter, oldest first, errors from fn are returned as they are
+func (a AuditService) EachAuditEntry(filter entity.AuditFilter, fn func(entity.AuditEntry) error) error {
+	var fnErr error
+	err := a.postg.EachAuditEntry(filter, func(e entity.AuditEntry) error {
+		fnErr = fn(e)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "get", "audit log")
+	}
+	return nil
+}
+
+/*
+Checks the hash chain of the whole audit log. Broken is the id of the first
+entry that was changed, or that follows an entry that was removed.
+*/
+func (a AuditService) VerifyAuditLog() (entity.AuditVerification, error) {
+	v := entity.AuditVerification{Valid: true}
+	prev := ""
+	err := a.EachAuditEntry(entity.AuditFilter{}, func(e entity.AuditEntry) error {
+		v.Entries++
+		hash, err := e.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if v.Valid && (e.PrevHash != prev || e.Hash != hash) {
+			v.Valid = false
+			v.Broken = &e.Id
+		}
+		prev = e.Hash
+		return nil
+	})
+	if err != nil {
+		return entity.AuditVerification{}, err
+	}
+	return v, nil
+}
+
+// the value of a target as JSON, nil stays nil
+func auditValue(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	bs, err := json.Marshal(v)
+	if err != nil || string(bs) == "null" {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// the target of an action, the parts joined by slashes as in "dataset/3/budget/alice"
+func auditTarget(parts ...any) string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	return strings.Join(s, "/")
+}
@@ -11,10 +11,20 @@ import (
 
 type BudgetService struct {
 	postg postgres.BudgetPostgres
+	audit AuditService
+	// the queries per day on allocations without a quota of their own, 0 is no quota
+	dailyQuota int
 }
 
-func NewBudgetService(budgetRepo postgres.BudgetPostgres) BudgetService {
-	return BudgetService{postg: budgetRepo}
+func NewBudgetService(budgetRepo postgres.BudgetPostgres, audit AuditService, dailyQuota int) BudgetService {
+	return BudgetService{postg: budgetRepo, audit: audit, dailyQuota: dailyQuota}
+}
+
+// a query release in the audit log, the budget it spent of the user and what the user consumed after it
+type auditedRelease struct {
+	User     string        `json:"user"`
+	Spent    entity.Budget `json:"spent"`
+	Consumed entity.Budget `json:"consumed"`
 }
 
 func (b BudgetService) GetUserBudgets(userHandle string) (entity.UserBudgets, error) {
@@ -25,13 +35,30 @@ func (b BudgetService) GetUserBudgets(userHandle string) (entity.UserBudgets, er
 	return budgets, nil
 }
 
-func (b BudgetService) AddConsumedBudgetToUser(user string, dataset int64, spent entity.Budget) error {
+// the budgets recorded for the datasets of the organization that were purged
+func (b BudgetService) GetPurgedBudgets(organization string) ([]entity.PurgedDatasetBudget, error) {
+	budgets, err := b.postg.GetPurgedBudgets(organization)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get", "budgets of purged datasets")
+	}
+	return budgets, nil
+}
+
+/*
+Spends the budget of a query that the engine evaluated, and records that the
+result of the query is released to the actor.
+*/
+func (b BudgetService) AddConsumedBudgetToUser(actor entity.Actor, user string, dataset int64, spent entity.Budget) error {
 	ub, err := b.postg.GetConsumedUserBudgetOnDataset(user, dataset)
 	if err != nil {
 		return errors.WrapDBError(err, "get consumed budget for", user)
 	}
 	newConsumed := budgetAdd(ub, spent)
-	if err := b.postg.UpdateUserConsumedBudget(user, dataset, newConsumed); err != nil {
+	audit, err := b.audit.Entry(actor, entity.AUDIT_QUERY_RELEASE, auditTarget("dataset", dataset, "budget", user), nil, auditedRelease{User: user, Spent: spent, Consumed: newConsumed})
+	if err != nil {
+		return err
+	}
+	if err := b.postg.UpdateUserConsumedBudget(user, dataset, newConsumed, audit); err != nil {
 		return errors.WrapDBError(err, "update consumed budget for", user)
 	}
 	return nil
@@ -69,7 +96,7 @@ func (b BudgetService) GetUserDatasetBudget(userHandle string, datasetId int64)
 	return budget, nil
 }
 
-func (b BudgetService) PostUserDatasetBudget(userHandle string, datasetId int64, budget entity.Budget) error {
+func (b BudgetService) PostUserDatasetBudget(actor entity.Actor, userHandle string, datasetId int64, budget entity.Budget) error {
 	dataset, err := b.GetDatasetBudget(datasetId)
 	if err != nil {
 		return err
@@ -92,15 +119,18 @@ func (b BudgetService) PostUserDatasetBudget(userHandle string, datasetId int64,
 		return fmt.Errorf("%w: not enough delta budget to allocate", errors.ErrBadInput)
 	}
 
-	if err := b.postg.CreateUserBudgetAllocation(userHandle, datasetId, budget); err != nil {
+	audit, err := b.audit.Entry(actor, entity.AUDIT_BUDGET_ALLOCATE, auditTarget("dataset", datasetId, "budget", userHandle), nil, budget)
+	if err != nil {
+		return err
+	}
+	if err := b.postg.CreateUserBudgetAllocation(userHandle, datasetId, budget, audit); err != nil {
 		return errors.WrapDBError(err, "allocate budget for", userHandle+" "+strconv.FormatInt(datasetId, 10))
 	}
-
 	return nil
 
 }
 
-func (b BudgetService) PatchUserDatasetBudget(userHandle string, datasetId int64, budget entity.Budget) error {
+func (b BudgetService) PatchUserDatasetBudget(actor entity.Actor, userHandle string, datasetId int64, budget entity.Budget) error {
 	// Add checks and changes to dataset allocation
 	dataset, err := b.postg.GetDatasetUserAllocations(datasetId)
 
@@ -132,11 +162,24 @@ func (b BudgetService) PatchUserDatasetBudget(userHandle string, datasetId int64
 		return fmt.Errorf("dataset total delta allocation not enough. Total Delta: %f, Allocated Delta: %f, Previous User Delta: %f, New User Delta: %f", coalesce(dataset.Total.Delta), coalesce(dataset.Allocated.Delta), coalesce(uBudget.Delta), coalesce(budget.Delta))
 	}
 
-	return b.postg.UpdateUserBudgetAllocation(userHandle, datasetId, budget)
+	audit, err := b.audit.Entry(actor, entity.AUDIT_BUDGET_UPDATE, auditTarget("dataset", datasetId, "budget", userHandle), uBudget, budget)
+	if err != nil {
+		return err
+	}
+	return b.postg.UpdateUserBudgetAllocation(userHandle, datasetId, budget, audit)
 }
 
-func (b BudgetService) DeleteUserDatasetBudget(userHandle string, datasetId int64) error {
-	if err := b.postg.DeleteUserBudgetAllocation(userHandle, datasetId); err != nil {
+func (b BudgetService) DeleteUserDatasetBudget(actor entity.Actor, userHandle string, datasetId int64) error {
+	// deleting an allocation that does not exist is not an error
+	var before any
+	if allocated, err := b.postg.GetAllocatedUserBudgetOnDataset(userHandle, datasetId); err == nil {
+		before = allocated
+	}
+	audit, err := b.audit.Entry(actor, entity.AUDIT_BUDGET_REVOKE, auditTarget("dataset", datasetId, "budget", userHandle), before, nil)
+	if err != nil {
+		return err
+	}
+	if err := b.postg.DeleteUserBudgetAllocation(userHandle, datasetId, audit); err != nil {
 		return errors.WrapDBError(err, "delete user dataset budget", userHandle+" "+strconv.FormatInt(datasetId, 10))
 	}
 	return nil
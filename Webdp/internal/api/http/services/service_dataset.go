@@ -2,7 +2,9 @@ package services
 
 import (
 	"fmt"
+	"io"
 	"strconv"
+	"time"
 	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/repo/postgres"
@@ -11,18 +13,19 @@ import (
 type DatasetService struct {
 	postg      postgres.DatasetPostgres
 	budgetRepo postgres.BudgetPostgres
+	audit      AuditService
 }
 
-func NewDatasetService(datasetRepo postgres.DatasetPostgres, budgetRepo postgres.BudgetPostgres) DatasetService {
-	return DatasetService{postg: datasetRepo, budgetRepo: budgetRepo}
+func NewDatasetService(datasetRepo postgres.DatasetPostgres, budgetRepo postgres.BudgetPostgres, audit AuditService) DatasetService {
+	return DatasetService{postg: datasetRepo, budgetRepo: budgetRepo, audit: audit}
 }
 
-func (d DatasetService) GetAllDatasets() ([]entity.DatasetInfo, error) {
-	data, err := d.postg.GetDatasets()
+func (d DatasetService) ListDatasets(filter entity.DatasetFilter) (entity.DatasetPage, error) {
+	page, err := d.postg.ListDatasets(filter)
 	if err != nil {
-		return []entity.DatasetInfo{}, errors.WrapDBError(err, "get datasets", "all")
+		return entity.DatasetPage{}, errors.WrapDBError(err, "get datasets", "all")
 	}
-	return data, nil
+	return page, nil
 }
 
 func (d DatasetService) GetDataset(id int64) (entity.DatasetInfo, error) {
@@ -33,23 +36,33 @@ func (d DatasetService) GetDataset(id int64) (entity.DatasetInfo, error) {
 	return dataset, nil
 }
 
-func (d DatasetService) GetDatasetOwner(id int64) (string, error) {
-	dataset, err := d.GetDataset(id)
+// the organization of a dataset, deleted or not
+func (d DatasetService) GetDatasetOrganization(id int64) (string, error) {
+	organization, err := d.postg.GetDatasetOrganization(id)
 	if err != nil {
-		return "", err
+		return "", errors.WrapDBError(err, "get organization of", strconv.FormatInt(id, 10))
 	}
-	return dataset.Owner, nil
+	return organization, nil
 }
 
-func (d DatasetService) CreateDataset(ds entity.DatasetCreate) (int64, error) {
+func (d DatasetService) CreateDataset(actor entity.Actor, ds entity.DatasetCreate) (int64, error) {
 	id, err := d.postg.CreateDataset(ds)
+	if err == errors.ErrConflict {
+		return 0, fmt.Errorf("%w: the total budget exceeds the budget cap of the organization", errors.ErrConflict)
+	}
 	if err != nil {
 		return 0, errors.WrapDBError(err, "create", strconv.FormatInt(id, 10))
 	}
+	d.recordDataset(actor, entity.AUDIT_DATASET_CREATE, id, nil)
 	return id, nil
 }
 
-func (d DatasetService) UpdateDataset(datasetId int64, patch entity.DatasetPatch) error {
+func (d DatasetService) UpdateDataset(actor entity.Actor, datasetId int64, patch entity.DatasetPatch) error {
+	before, err := d.GetDataset(datasetId)
+	if err != nil {
+		return err
+	}
+
 	allocs, err := d.budgetRepo.GetDatasetUserAllocations(datasetId)
 	if err != nil {
 		return errors.WrapDBError(err, "update", strconv.FormatInt(datasetId, 10))
@@ -59,23 +72,203 @@ func (d DatasetService) UpdateDataset(datasetId int64, patch entity.DatasetPatch
 		return fmt.Errorf("%w: you cannot set a lower budget than what has already been allocated", errors.ErrBadRequest)
 	}
 
-	if err := d.postg.UpdateDataset(datasetId, patch); err != nil {
+	err = d.postg.UpdateDataset(datasetId, patch)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: the total budget exceeds the budget cap of the organization", errors.ErrConflict)
+	}
+	if err != nil {
 		return errors.WrapDBError(err, "update", strconv.FormatInt(datasetId, 10))
 	}
+	d.recordDataset(actor, entity.AUDIT_DATASET_UPDATE, datasetId, &before)
 	return nil
 }
 
-func (d DatasetService) DeleteDataset(id int64) error {
-	if err := d.postg.DeleteDataset(id); err != nil {
+// deletes the dataset softly, it can be restored until it is purged
+func (d DatasetService) DeleteDataset(actor entity.Actor, id int64) error {
+	before, err := d.GetDataset(id)
+	if err != nil {
+		return err
+	}
+	if err := d.postg.DeleteDataset(id, actor.Handle); err != nil {
 		return errors.WrapDBError(err, "delete", strconv.FormatInt(id, 10))
 	}
+	d.audit.Record(actor, entity.AUDIT_DATASET_DELETE, auditTarget("dataset", id), before, nil)
 	return nil
 }
 
-func (d DatasetService) UploadData(datasetid int64, data []byte) error {
-	err := d.postg.UploadData(datasetid, data)
+// a deleted dataset that is not purged yet
+func (d DatasetService) GetDeletedDataset(id int64) (entity.DatasetInfo, error) {
+	dataset, err := d.postg.GetDeletedDataset(id)
+	if err != nil {
+		return entity.DatasetInfo{}, errors.WrapDBError(err, "get", "deleted dataset "+strconv.FormatInt(id, 10))
+	}
+	return dataset, nil
+}
+
+func (d DatasetService) RestoreDataset(actor entity.Actor, id int64) error {
+	if err := d.postg.RestoreDataset(id); err != nil {
+		return errors.WrapDBError(err, "restore", strconv.FormatInt(id, 10))
+	}
+	d.recordDataset(actor, entity.AUDIT_DATASET_RESTORE, id, nil)
+	return nil
+}
+
+/*
+Purges the datasets deleted before the given time and returns how many were
+purged, see DatasetPostgres.PurgeDatasets. Every purge is recorded, with the
+budgets kept of the dataset as the value before it.
+*/
+func (d DatasetService) PurgeDatasets(actor entity.Actor, deletedBefore time.Time) (int64, error) {
+	purged, err := d.postg.PurgeDatasets(deletedBefore)
+	for _, p := range purged {
+		d.audit.Record(actor, entity.AUDIT_DATASET_PURGE, auditTarget("dataset", p.Dataset), p, nil)
+	}
+	if err != nil {
+		return int64(len(purged)), errors.WrapDBError(err, "purge", "datasets deleted before "+deletedBefore.Format(time.RFC3339))
+	}
+	return int64(len(purged)), nil
+}
+
+// drops the versions superseded before the given time and returns the datasets they belonged to
+func (d DatasetService) PurgeVersions(supersededBefore time.Time) ([]int64, error) {
+	datasets, err := d.postg.PurgeVersions(supersededBefore)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "purge", "versions superseded before "+supersededBefore.Format(time.RFC3339))
+	}
+	return datasets, nil
+}
+
+// records the action on the dataset, with the dataset as it is now as the value after it
+func (d DatasetService) recordDataset(actor entity.Actor, action string, id int64, before *entity.DatasetInfo) {
+	after, err := d.postg.GetDataset(id)
+	if err != nil {
+		d.audit.Record(actor, action, auditTarget("dataset", id), before, nil)
+		return
+	}
+	d.audit.Record(actor, action, auditTarget("dataset", id), before, after)
+}
+
+/*
+Stores the rows returned by next as a new version, see DatasetPostgres.StoreData.
+Errors from next are returned as they are, errors from the database are wrapped.
+*/
+func (d DatasetService) StoreData(datasetid int64, header string, opts entity.UploadOptions, next func() (string, error)) (int64, error) {
+	var rowErr error
+	version, err := d.postg.StoreData(datasetid, header, opts, func() (string, error) {
+		line, err := next()
+		if err != nil && err != io.EOF {
+			rowErr = err
+		}
+		return line, err
+	})
+
+	if rowErr != nil {
+		return 0, rowErr
+	}
+	if err == errors.ErrBadInput {
+		return 0, fmt.Errorf("%w: appended data has other columns than the latest version", errors.ErrBadInput)
+	}
+	if err != nil {
+		return 0, errors.WrapDBError(err, "upload", strconv.FormatInt(datasetid, 10))
+	}
+	return version, nil
+}
+
+func (d DatasetService) GetDataVersions(datasetid int64) ([]entity.DataVersion, error) {
+	versions, err := d.postg.GetDataVersions(datasetid)
 	if err != nil {
-		return errors.WrapDBError(err, "upload", strconv.FormatInt(datasetid, 10))
+		return nil, errors.WrapDBError(err, "get", "versions of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return versions, nil
+}
+
+/*
+Changes the schema of a dataset and rewrites its loaded data, see
+DatasetPostgres.ChangeSchema. Errors of the rewriter are returned as they are.
+*/
+func (d DatasetService) ChangeSchema(change entity.SchemaChange, invalid string) (entity.SchemaChange, error) {
+	var rw *entity.SchemaRewriter
+	var rwErr error
+	out, err := d.postg.ChangeSchema(change, func(header string) (*entity.SchemaRewriter, error) {
+		rw, rwErr = entity.NewSchemaRewriter(header, change.NewSchema, change.Patch, invalid)
+		return rw, rwErr
+	})
+
+	if rwErr != nil {
+		return entity.SchemaChange{}, rwErr
+	}
+	if rw != nil && rw.Err() != nil {
+		return entity.SchemaChange{}, rw.Err()
+	}
+	if err == errors.ErrConflict {
+		return entity.SchemaChange{}, fmt.Errorf("%w: the schema of dataset %d was changed at the same time", errors.ErrConflict, change.Dataset)
+	}
+	if err != nil {
+		return entity.SchemaChange{}, errors.WrapDBError(err, "change schema of", "dataset "+strconv.FormatInt(change.Dataset, 10))
+	}
+	return out, nil
+}
+
+func (d DatasetService) GetSchemaChanges(datasetid int64) ([]entity.SchemaChange, error) {
+	changes, err := d.postg.GetSchemaChanges(datasetid)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get", "schema changes of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return changes, nil
+}
+
+func (d DatasetService) CreateUploadSession(datasetid int64, owner string) (entity.UploadSession, error) {
+	us, err := d.postg.CreateUploadSession(datasetid, owner)
+	if err != nil {
+		return entity.UploadSession{}, errors.WrapDBError(err, "create", "upload session")
+	}
+	return us, nil
+}
+
+// the session of the owner, which is not found once it expired or for other users
+func (d DatasetService) GetUploadSession(datasetid int64, session int64, owner string) (entity.UploadSession, error) {
+	us, err := d.postg.GetUploadSession(datasetid, session, owner)
+	if err != nil {
+		return entity.UploadSession{}, errors.WrapDBError(err, "get", fmt.Sprintf("upload session %d", session))
+	}
+	return us, nil
+}
+
+func (d DatasetService) AppendUploadChunk(datasetid int64, session int64, owner string, offset int64, chunk []byte) (entity.UploadSession, error) {
+	us, err := d.postg.AppendUploadChunk(datasetid, session, owner, offset, chunk)
+	if err == errors.ErrConflict {
+		return us, fmt.Errorf("%w: upload session %d is at offset %d, not %d", errors.ErrConflict, session, us.Size, offset)
+	}
+	if err != nil {
+		return entity.UploadSession{}, errors.WrapDBError(err, "append to", fmt.Sprintf("upload session %d", session))
+	}
+	return us, nil
+}
+
+// the uploaded chunks of a session of the owner as one stream
+func (d DatasetService) OpenUploadSession(datasetid int64, session int64, owner string) (io.ReadCloser, error) {
+	if _, err := d.GetUploadSession(datasetid, session, owner); err != nil {
+		return nil, err
+	}
+	rc, err := d.postg.OpenUploadSession(datasetid, session)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "read", fmt.Sprintf("upload session %d", session))
+	}
+	return rc, nil
+}
+
+func (d DatasetService) DeleteUploadSession(datasetid int64, session int64, owner string) error {
+	if err := d.postg.DeleteUploadSession(datasetid, session, owner); err != nil {
+		return errors.WrapDBError(err, "delete", fmt.Sprintf("upload session %d", session))
 	}
 	return nil
 }
+
+// deletes the upload sessions that expired before now and returns how many
+func (d DatasetService) PurgeUploadSessions(now time.Time) (int64, error) {
+	purged, err := d.postg.PurgeUploadSessions(now.Add(-entity.UPLOAD_SESSION_LIFETIME))
+	if err != nil {
+		return 0, errors.WrapDBError(err, "purge", "expired upload sessions")
+	}
+	return purged, nil
+}
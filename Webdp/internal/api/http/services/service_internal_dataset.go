@@ -1,6 +1,10 @@
 package services
 
-import "webdp/internal/api/http/repo/postgres"
+import (
+	"strconv"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/repo/postgres"
+)
 
 type InternalDatasetService struct {
 	repo postgres.DatasetPostgres
@@ -10,6 +14,19 @@ func NewInternalDatasetService(repo postgres.DatasetPostgres) *InternalDatasetSe
 	return &InternalDatasetService{repo: repo}
 }
 
-func (ds InternalDatasetService) GetTable(datasetid int64) ([]byte, error) {
-	return ds.repo.GetUploadedData(datasetid)
+// calls write with the CSV header and then every row of a version of the dataset, 0 is the latest
+func (ds InternalDatasetService) StreamTable(datasetid int64, version int64, write func(line string) error) error {
+	var writeErr error
+	err := ds.repo.StreamData(datasetid, version, func(line string) error {
+		writeErr = write(line)
+		return writeErr
+	})
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "read", "data of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return nil
 }
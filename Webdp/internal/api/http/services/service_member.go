@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+// the role of a user in a dataset, ErrNotFound if the user is not a member
+func (d DatasetService) GetMemberRole(datasetid int64, handle string) (string, error) {
+	role, err := d.postg.GetMemberRole(datasetid, handle)
+	if err != nil {
+		return "", errors.WrapDBError(err, "get", fmt.Sprintf("member %s of dataset %d", handle, datasetid))
+	}
+	return role, nil
+}
+
+func (d DatasetService) GetMembers(datasetid int64) ([]entity.DatasetMember, error) {
+	members, err := d.postg.GetMembers(datasetid)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get", "members of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return members, nil
+}
+
+func (d DatasetService) PutMember(actor entity.Actor, datasetid int64, handle string, role string) error {
+	before, err := d.getMember(datasetid, handle)
+	if err != nil {
+		return err
+	}
+	member := entity.DatasetMember{Handle: handle, Role: role, AddedBy: actor.Handle, AddedOn: time.Now().UTC()}
+	err = d.postg.PutMember(datasetid, member)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: %s owns dataset %d, the owner changes through a transfer", errors.ErrConflict, handle, datasetid)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "add", fmt.Sprintf("member %s to dataset %d", handle, datasetid))
+	}
+	d.audit.Record(actor, entity.AUDIT_MEMBER_PUT, auditTarget("dataset", datasetid, "member", handle), before, member)
+	return nil
+}
+
+func (d DatasetService) DeleteMember(actor entity.Actor, datasetid int64, handle string) error {
+	before, err := d.getMember(datasetid, handle)
+	if err != nil {
+		return err
+	}
+	if before != nil && before.Role == entity.MEMBER_OWNER {
+		return fmt.Errorf("%w: %s owns dataset %d, the owner changes through a transfer", errors.ErrConflict, handle, datasetid)
+	}
+	if err := d.postg.DeleteMember(datasetid, handle); err != nil {
+		return errors.WrapDBError(err, "remove", fmt.Sprintf("member %s of dataset %d", handle, datasetid))
+	}
+	d.audit.Record(actor, entity.AUDIT_MEMBER_DELETE, auditTarget("dataset", datasetid, "member", handle), before, nil)
+	return nil
+}
+
+// the member with the handle, nil if the user is not a member of the dataset
+func (d DatasetService) getMember(datasetid int64, handle string) (*entity.DatasetMember, error) {
+	members, err := d.GetMembers(datasetid)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.Handle == handle {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d DatasetService) ProposeTransfer(datasetid int64, from string, to string) (entity.OwnershipTransfer, error) {
+	t := entity.OwnershipTransfer{Dataset: datasetid, From: from, To: to, CreatedOn: time.Now().UTC()}
+	if err := d.postg.ProposeTransfer(t); err != nil {
+		return entity.OwnershipTransfer{}, errors.WrapDBError(err, "propose", "transfer of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return t, nil
+}
+
+func (d DatasetService) GetTransfer(datasetid int64) (entity.OwnershipTransfer, error) {
+	t, err := d.postg.GetTransfer(datasetid)
+	if err != nil {
+		return entity.OwnershipTransfer{}, errors.WrapDBError(err, "get", "transfer of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return t, nil
+}
+
+func (d DatasetService) DeleteTransfer(datasetid int64) error {
+	if err := d.postg.DeleteTransfer(datasetid); err != nil {
+		return errors.WrapDBError(err, "delete", "transfer of dataset "+strconv.FormatInt(datasetid, 10))
+	}
+	return nil
+}
+
+// makes the actor the owner of the dataset, if the ownership was proposed to the actor
+func (d DatasetService) AcceptTransfer(actor entity.Actor, datasetid int64) error {
+	handle := actor.Handle
+	before, err := d.GetDataset(datasetid)
+	if err != nil {
+		return err
+	}
+	err = d.postg.AcceptTransfer(datasetid, handle)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: the owner of dataset %d changed since the transfer was proposed", errors.ErrConflict, datasetid)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "accept", fmt.Sprintf("transfer of dataset %d to %s", datasetid, handle))
+	}
+	d.recordDataset(actor, entity.AUDIT_DATASET_TRANSFER, datasetid, &before)
+	return nil
+}
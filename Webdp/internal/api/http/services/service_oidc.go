@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/oidc"
+	"webdp/internal/signing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// how long a login at the identity provider can take
+const OIDC_LOGIN_LIFETIME = 10 * time.Minute
+
+// the audience of the login state, which tells it apart from access tokens signed with the same keys
+const OIDC_LOGIN_AUDIENCE = "webdp-oidc-login"
+
+/*
+Logins with the identity provider. Users log in at the provider and get a
+Webdp session, or send tokens of the provider that are exchanged for a Webdp
+token on every request.
+*/
+type OidcService struct {
+	provider *oidc.Provider
+	users    UserService
+	tokens   TokenService
+	keys     *signing.KeyRing
+}
+
+// the state of a login at the provider, kept by the browser in a cookie signed by the keys of the access tokens
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+func NewOidcService(provider *oidc.Provider, users UserService, tokens TokenService, keys *signing.KeyRing) *OidcService {
+	return &OidcService{provider: provider, users: users, tokens: tokens, keys: keys}
+}
+
+func (o *OidcService) Issuer() string {
+	return o.provider.Issuer()
+}
+
+// the URL of the provider to log in at, and the login state to keep until the user returns
+func (o *OidcService) BeginLogin() (string, string, error) {
+	state, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	url, err := o.provider.AuthCodeURL(state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	login := oidcLogin{State: state, Nonce: nonce, Verifier: verifier}
+	login.Audience = OIDC_LOGIN_AUDIENCE
+	login.ExpiresAt = time.Now().Add(OIDC_LOGIN_LIFETIME).Unix()
+	signed, err := o.keys.Sign(&login)
+	if err != nil {
+		return "", "", err
+	}
+	return url, signed, nil
+}
+
+/*
+Completes a login when the user returns from the provider with a code, and
+starts a session for the user.
+*/
+func (o *OidcService) CompleteLogin(code string, state string, saved string, requestId string) (SessionTokens, error) {
+	var login oidcLogin
+	if err := o.keys.Parse(saved, &login); err != nil || !login.VerifyAudience(OIDC_LOGIN_AUDIENCE, true) {
+		return SessionTokens{}, fmt.Errorf("%w: login expired or was not started here", errors.ErrUnauthorized)
+	}
+	if login.State == "" || login.State != state {
+		return SessionTokens{}, fmt.Errorf("%w: state does not match the login", errors.ErrUnauthorized)
+	}
+
+	raw, err := o.provider.Exchange(code, login.Verifier)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	id, err := o.provider.VerifyIDToken(raw, login.Nonce)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	handle, roles, err := o.provision(id, requestId)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	organization, err := o.users.GetOrganization(handle)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	return o.tokens.IssueNewTokenFor(entity.Actor{Handle: handle, Organization: organization, RequestId: requestId}, roles, "oidc")
+}
+
+// exchanges a token of the provider for a Webdp token that is valid for the current request
+func (o *OidcService) AuthenticateToken(raw string, requestId string) (string, error) {
+	id, err := o.provider.VerifyToken(raw)
+	if err != nil {
+		return "", err
+	}
+
+	handle, roles, err := o.provision(id, requestId)
+	if err != nil {
+		return "", err
+	}
+
+	c := JWTTokenClaims{Handle: handle, Roles: roles, Idp: true}
+	c.Id = id.Subject
+	return o.tokens.ExchangeToken(c)
+}
+
+// creates or updates the user of the identity, which Webdp does rather than a user
+func (o *OidcService) provision(id oidc.Identity, requestId string) (string, []string, error) {
+	roles := o.provider.Roles(id.Groups)
+	if len(roles) == 0 {
+		return "", nil, fmt.Errorf("%w: %s is in no group that gives a webdp role", errors.ErrForbidden, id.Username)
+	}
+	handle, err := o.users.ProvisionExternalUser(entity.SystemActor(requestId), id, roles, o.provider.Organization())
+	if err != nil {
+		return "", nil, err
+	}
+	return handle, roles, nil
+}
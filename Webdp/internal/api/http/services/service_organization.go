@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+)
+
+type OrganizationService struct {
+	postg postgres.OrganizationPostgres
+	audit AuditService
+}
+
+func NewOrganizationService(organizationRepo postgres.OrganizationPostgres, audit AuditService) OrganizationService {
+	return OrganizationService{postg: organizationRepo, audit: audit}
+}
+
+// the organization with the handle, or all organizations if it is empty
+func (o OrganizationService) GetOrganizations(handle string) ([]entity.Organization, error) {
+	organizations, err := o.postg.GetOrganizations(handle)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get organizations", "all")
+	}
+	return organizations, nil
+}
+
+func (o OrganizationService) GetOrganization(handle string) (entity.Organization, error) {
+	organization, err := o.postg.GetOrganization(handle)
+	if err != nil {
+		return entity.Organization{}, errors.WrapDBError(err, "get organization", handle)
+	}
+	return organization, nil
+}
+
+func (o OrganizationService) CreateOrganization(actor entity.Actor, c entity.OrganizationCreate) error {
+	err := o.postg.CreateOrganization(c)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: organization %s exists", errors.ErrConflict, c.Handle)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "create organization", c.Handle)
+	}
+	o.recordOrganization(actor, entity.AUDIT_ORG_CREATE, c.Handle, nil)
+	return nil
+}
+
+func (o OrganizationService) UpdateOrganization(actor entity.Actor, handle string, patch entity.OrganizationPatch) error {
+	before, err := o.GetOrganization(handle)
+	if err != nil {
+		return err
+	}
+	err = o.postg.UpdateOrganization(handle, patch)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: the datasets of organization %s have more budget than the new budget cap", errors.ErrConflict, handle)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "update organization", handle)
+	}
+	o.recordOrganization(actor, entity.AUDIT_ORG_UPDATE, handle, &before)
+	return nil
+}
+
+// deletes an organization without users or datasets, the default organization is never deleted
+func (o OrganizationService) DeleteOrganization(actor entity.Actor, handle string) error {
+	if handle == entity.DEFAULT_ORGANIZATION {
+		return fmt.Errorf("%w: the default organization cannot be deleted", errors.ErrForbidden)
+	}
+	before, err := o.GetOrganization(handle)
+	if err != nil {
+		return err
+	}
+	err = o.postg.DeleteOrganization(handle)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: organization %s still has users or datasets", errors.ErrConflict, handle)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "delete organization", handle)
+	}
+	o.audit.Record(actor, entity.AUDIT_ORG_DELETE, auditTarget("organization", handle), before, nil)
+	return nil
+}
+
+// records the action on the organization, with the organization as it is now as the value after it
+func (o OrganizationService) recordOrganization(actor entity.Actor, action string, handle string, before *entity.Organization) {
+	after, err := o.postg.GetOrganization(handle)
+	if err != nil {
+		o.audit.Record(actor, action, auditTarget("organization", handle), before, nil)
+		return
+	}
+	o.audit.Record(actor, action, auditTarget("organization", handle), before, after)
+}
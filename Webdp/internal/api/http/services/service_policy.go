@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+)
+
+// how long the roles are cached, changes made by other instances of the api take this long to apply
+const POLICY_CACHE_LIFETIME = 30 * time.Second
+
+/*
+The roles and the permissions they give. The roles are read on every request,
+so they are cached; changes made through this service apply at once.
+*/
+type PolicyService struct {
+	postg postgres.RolePostgres
+	cache *roleCache
+	audit AuditService
+}
+
+type roleCache struct {
+	mu      sync.Mutex
+	roles   []entity.Role
+	fetched time.Time
+}
+
+func NewPolicyService(roleRepo postgres.RolePostgres, audit AuditService) PolicyService {
+	return PolicyService{postg: roleRepo, cache: &roleCache{}, audit: audit}
+}
+
+// the permissions that the roles give
+func (p PolicyService) Permissions(roles []string) ([]string, error) {
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+
+	if p.cache.roles == nil || time.Since(p.cache.fetched) > POLICY_CACHE_LIFETIME {
+		defined, err := p.postg.GetRoles()
+		if err != nil {
+			return nil, errors.WrapDBError(err, "get roles", "all")
+		}
+		p.cache.roles, p.cache.fetched = defined, time.Now()
+	}
+	return entity.PermissionsOf(p.cache.roles, roles), nil
+}
+
+func (p PolicyService) GetRoles() ([]entity.Role, error) {
+	roles, err := p.postg.GetRoles()
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get roles", "all")
+	}
+	return roles, nil
+}
+
+func (p PolicyService) GetRole(name string) (entity.Role, error) {
+	role, err := p.postg.GetRole(name)
+	if err != nil {
+		return entity.Role{}, errors.WrapDBError(err, "get role", name)
+	}
+	return role, nil
+}
+
+func (p PolicyService) GetPermissions() ([]entity.Permission, error) {
+	permissions, err := p.postg.GetPermissions()
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get permissions", "all")
+	}
+	return permissions, nil
+}
+
+func (p PolicyService) CreateRole(actor entity.Actor, c entity.RoleCreate) error {
+	err := p.postg.CreateRole(c)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: role %s exists", errors.ErrConflict, c.Name)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "create role", c.Name)
+	}
+	p.invalidate()
+	p.recordRole(actor, entity.AUDIT_ROLE_CREATE, c.Name, nil)
+	return nil
+}
+
+func (p PolicyService) UpdateRole(actor entity.Actor, name string, put entity.RolePut) error {
+	before, err := p.validateCustom(name)
+	if err != nil {
+		return err
+	}
+	if err := p.postg.UpdateRole(name, put); err != nil {
+		return errors.WrapDBError(err, "update role", name)
+	}
+	p.invalidate()
+	p.recordRole(actor, entity.AUDIT_ROLE_UPDATE, name, &before)
+	return nil
+}
+
+// deletes a custom role that no user has
+func (p PolicyService) DeleteRole(actor entity.Actor, name string) error {
+	before, err := p.validateCustom(name)
+	if err != nil {
+		return err
+	}
+	err = p.postg.DeleteRole(name)
+	if err == errors.ErrConflict {
+		return fmt.Errorf("%w: role %s is given to users, remove it from them first", errors.ErrConflict, name)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "delete role", name)
+	}
+	p.invalidate()
+	p.audit.Record(actor, entity.AUDIT_ROLE_DELETE, auditTarget("role", name), before, nil)
+	return nil
+}
+
+// the custom role with the name, built-in roles are forbidden
+func (p PolicyService) validateCustom(name string) (entity.Role, error) {
+	role, err := p.GetRole(name)
+	if err != nil {
+		return entity.Role{}, err
+	}
+	if role.Builtin {
+		return entity.Role{}, fmt.Errorf("%w: built-in role %s cannot be changed", errors.ErrForbidden, name)
+	}
+	return role, nil
+}
+
+// records the action on the role, with the role as it is now as the value after it
+func (p PolicyService) recordRole(actor entity.Actor, action string, name string, before *entity.Role) {
+	after, err := p.postg.GetRole(name)
+	if err != nil {
+		p.audit.Record(actor, action, auditTarget("role", name), before, nil)
+		return
+	}
+	p.audit.Record(actor, action, auditTarget("role", name), before, after)
+}
+
+func (p PolicyService) invalidate() {
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	p.cache.roles = nil
+}
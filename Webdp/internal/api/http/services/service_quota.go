@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+// the quota of queries per day on the allocation, and the queries made on it today
+func (b BudgetService) GetQueryQuota(userHandle string, datasetId int64, now time.Time) (entity.QueryQuota, error) {
+	quota, err := b.queryQuota(userHandle, datasetId, now)
+	if err != nil {
+		return quota, err
+	}
+	day, _ := entity.QuotaDay(now)
+	used, err := b.postg.GetQueryCount(userHandle, datasetId, day)
+	if err != nil {
+		return quota, errors.WrapDBError(err, "get query count of", allocationName(userHandle, datasetId))
+	}
+	quota.Used = used
+	return quota, nil
+}
+
+// sets the quota of queries per day on the allocation, no quota gives it the default quota
+func (b BudgetService) SetQueryQuota(actor entity.Actor, userHandle string, datasetId int64, put entity.QueryQuotaPut) error {
+	before, err := b.postg.GetQueryQuota(userHandle, datasetId)
+	if err != nil {
+		return errors.WrapDBError(err, "get query quota of", allocationName(userHandle, datasetId))
+	}
+	audit, err := b.audit.Entry(actor, entity.AUDIT_BUDGET_QUOTA, auditTarget("dataset", datasetId, "budget", userHandle), entity.QueryQuotaPut{DailyQueries: before}, put)
+	if err != nil {
+		return err
+	}
+	if err := b.postg.SetQueryQuota(userHandle, datasetId, put.DailyQueries, audit); err != nil {
+		return errors.WrapDBError(err, "set query quota of", allocationName(userHandle, datasetId))
+	}
+	return nil
+}
+
+/*
+Counts a query of the user on the dataset against the quota of the
+allocation. Once the quota of the day is made the query is refused with
+ErrTooManyRequests, until the quota resets at the start of the next day.
+*/
+func (b BudgetService) CountQuery(userHandle string, datasetId int64, now time.Time) (entity.QueryQuota, error) {
+	quota, err := b.queryQuota(userHandle, datasetId, now)
+	if err != nil {
+		return quota, err
+	}
+	day, _ := entity.QuotaDay(now)
+	used, counted, err := b.postg.CountQuery(userHandle, datasetId, day, quota.Limit)
+	if err != nil {
+		return quota, errors.WrapDBError(err, "count query of", allocationName(userHandle, datasetId))
+	}
+	quota.Used = used
+	if !counted {
+		return quota, fmt.Errorf("%w: the quota of %d queries per day on dataset %d is used", errors.ErrTooManyRequests, quota.Limit, datasetId)
+	}
+	return quota, nil
+}
+
+// deletes the query counts of the days before the day of the time
+func (b BudgetService) SweepQueryCounts(now time.Time) error {
+	day, _ := entity.QuotaDay(now)
+	if err := b.postg.DeleteQueryCounts(day); err != nil {
+		return errors.WrapDBError(err, "delete", "query counts")
+	}
+	return nil
+}
+
+func (b BudgetService) queryQuota(userHandle string, datasetId int64, now time.Time) (entity.QueryQuota, error) {
+	daily, err := b.postg.GetQueryQuota(userHandle, datasetId)
+	if err != nil {
+		return entity.QueryQuota{}, errors.WrapDBError(err, "get query quota of", allocationName(userHandle, datasetId))
+	}
+	_, resets := entity.QuotaDay(now)
+	quota := entity.QueryQuota{DailyQueries: daily, Limit: b.dailyQuota, Resets: resets}
+	if daily != nil {
+		quota.Limit = *daily
+	}
+	return quota, nil
+}
+
+func allocationName(userHandle string, datasetId int64) string {
+	return userHandle + " " + strconv.FormatInt(datasetId, 10)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+)
+
+/*
+Limits the requests of every user per window. The requests are counted in the
+database, so that the limits hold across the replicas of Webdp.
+*/
+type RateLimitService struct {
+	postg  postgres.RateLimitPostgres
+	limits entity.RateLimits
+}
+
+func NewRateLimitService(limitRepo postgres.RateLimitPostgres, limits entity.RateLimits) RateLimitService {
+	return RateLimitService{postg: limitRepo, limits: limits}
+}
+
+/*
+Counts a request of the class by the user with the roles. Once the limit of
+the window is reached the request is refused with ErrTooManyRequests, until
+the window resets. Requests without a limit are not counted, and give a
+state with a limit of 0.
+*/
+func (s RateLimitService) Allow(handle string, roles []string, class string, now time.Time) (entity.RateLimitState, error) {
+	limit := s.limits.Limit(handle, roles, class)
+	if limit == 0 {
+		return entity.RateLimitState{}, nil
+	}
+	window := now.UTC().Truncate(entity.RATE_LIMIT_WINDOW)
+	state := entity.RateLimitState{Limit: limit, Reset: window.Add(entity.RATE_LIMIT_WINDOW)}
+	requests, err := s.postg.CountRequest(handle, class, window)
+	if err != nil {
+		return state, errors.WrapDBError(err, "count request of", handle)
+	}
+	state.Remaining = max(limit-requests, 0)
+	if requests > limit {
+		return state, fmt.Errorf("%w: the limit of %d %s requests is reached until %s", errors.ErrTooManyRequests, limit, class, state.Reset.Format(time.RFC3339))
+	}
+	return state, nil
+}
+
+// deletes the request counts of the windows that are over
+func (s RateLimitService) Sweep(now time.Time) error {
+	if err := s.postg.DeleteRequestCounts(now.UTC().Truncate(entity.RATE_LIMIT_WINDOW)); err != nil {
+		return errors.WrapDBError(err, "delete", "request counts")
+	}
+	return nil
+}
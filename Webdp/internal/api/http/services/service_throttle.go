@@ -0,0 +1,71 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// the number of addresses kept before the ones whose failures are over are dropped
+const throttleSweepSize = 10000
+
+/*
+Counts the failed logins from each address, in memory. An address that fails
+max times within the window is refused until the window since its first
+failure is over. The counts are per instance of Webdp and lost on restart,
+the accounts themselves are locked in the database.
+*/
+type LoginThrottle struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*addressFailures
+}
+
+type addressFailures struct {
+	count int
+	since time.Time
+}
+
+func NewLoginThrottle(max int, window time.Duration) *LoginThrottle {
+	return &LoginThrottle{max: max, window: window, failures: make(map[string]*addressFailures)}
+}
+
+// until when logins from the address are refused, false if they are not
+func (t *LoginThrottle) Blocked(address string, now time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.failures[address]
+	if !ok || f.count < t.max {
+		return time.Time{}, false
+	}
+	until := f.since.Add(t.window)
+	if !now.Before(until) {
+		delete(t.failures, address)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// counts a failed login from the address
+func (t *LoginThrottle) Fail(address string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.failures[address]
+	if !ok || !now.Before(f.since.Add(t.window)) {
+		if len(t.failures) >= throttleSweepSize {
+			t.sweep(now)
+		}
+		t.failures[address] = &addressFailures{count: 1, since: now}
+		return
+	}
+	f.count++
+}
+
+// drops the addresses whose window is over
+func (t *LoginThrottle) sweep(now time.Time) {
+	for address, f := range t.failures {
+		if !now.Before(f.since.Add(t.window)) {
+			delete(t.failures, address)
+		}
+	}
+}
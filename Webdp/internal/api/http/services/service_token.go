@@ -1,71 +1,222 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 	errors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/repo/postgres"
+	"webdp/internal/signing"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type TokenService struct {
-	postg         postgres.TokenPostgres
-	signKey       []byte
-	signingMethod jwt.SigningMethod
+	postg           postgres.TokenPostgres
+	keys            *signing.KeyRing
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+	sessionLifetime time.Duration
+	audit           AuditService
 }
 
+// how long a token that an API key or a token of the identity provider is exchanged for is valid, it only lives for one request
+const EXCHANGED_TOKEN_LIFETIME = time.Minute
+
+/*
+The claims of an access token. The id of the session the token belongs to is
+the id of the token. Requests with an API key get a token with the id of the
+key, ApiKey set and, if the key is limited to some datasets, those datasets.
+Requests with a token of the identity provider get a token with Idp set.
+*/
 type JWTTokenClaims struct {
-	Handle string   `json:"handle"`
-	Roles  []string `json:"roles"`
+	Handle   string   `json:"handle"`
+	Roles    []string `json:"roles"`
+	ApiKey   bool     `json:"api_key,omitempty"`
+	Idp      bool     `json:"idp,omitempty"`
+	Datasets []int64  `json:"datasets,omitempty"`
 	jwt.StandardClaims
 }
 
+// whether the token was exchanged for an API key or a token of the identity provider, and has no session
+func (j *JWTTokenClaims) Exchanged() bool {
+	return j.ApiKey || j.Idp
+}
+
 func (j *JWTTokenClaims) Valid() error {
-	if j.ExpiresAt == 0 || j.Handle == "" {
+	if j.ExpiresAt == 0 || j.Handle == "" || j.Id == "" {
 		return errors.ErrInvalidToken
 	}
 	return nil
 }
 
-func NewTokenService(tokenRepo postgres.TokenPostgres, signingKey []byte, signingMethod jwt.SigningMethod) TokenService {
-	return TokenService{postg: tokenRepo, signKey: signingKey, signingMethod: signingMethod}
+// whether the token gives access to the dataset, only tokens of some API keys are limited to some datasets
+func (j *JWTTokenClaims) InScope(dataset int64) bool {
+	return j.Datasets == nil || slices.Contains(j.Datasets, dataset)
 }
 
-func (t TokenService) IssueNewTokenFor(user string, roles []string) (string, error) {
-	c := JWTTokenClaims{}
-	var repoFun func(string, string) error
+// the tokens of a session, the access token expires at ExpiresAt in unix time
+type SessionTokens struct {
+	Access    string
+	Refresh   string
+	ExpiresAt int64
+}
+
+/*
+Sessions are extended by refreshLifetime on every refresh, up to sessionLifetime
+after the login, after which the user has to log in again.
+*/
+func NewTokenService(tokenRepo postgres.TokenPostgres, keys *signing.KeyRing, accessLifetime time.Duration, refreshLifetime time.Duration, sessionLifetime time.Duration, audit AuditService) TokenService {
+	return TokenService{postg: tokenRepo, keys: keys, accessLifetime: accessLifetime, refreshLifetime: refreshLifetime, sessionLifetime: sessionLifetime, audit: audit}
+}
+
+/*
+Starts a new session for the user that logs in, the actor, and records the
+login. The other sessions of the user are kept.
+*/
+func (t TokenService) IssueNewTokenFor(actor entity.Actor, roles []string, device string) (SessionTokens, error) {
+	user := actor.Handle
+	id, err := randomToken(16)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	now := time.Now().UTC()
+	session := entity.Session{Id: id, Handle: user, Device: device, CreatedOn: now, RefreshedOn: now, ExpiresOn: entity.SessionExpiry(now, now, t.refreshLifetime, t.sessionLifetime)}
+	if err := t.postg.CreateSession(session, hashToken(secret)); err != nil {
+		return SessionTokens{}, errors.WrapDBError(err, "create session for", user)
+	}
 
-	exists, err := t.postg.UserTokenExists(user)
+	access, expires, err := t.IssueAccessToken(session, roles)
 	if err != nil {
-		return "", errors.WrapDBError(err, "token exists", user)
+		return SessionTokens{}, err
 	}
+	t.audit.Record(actor, entity.AUDIT_LOGIN, auditTarget("user", user), nil, session)
+	return SessionTokens{Access: access, Refresh: id + "." + secret, ExpiresAt: expires}, nil
+}
 
-	if exists {
-		repoFun = t.postg.UpdateToken
-	} else {
-		repoFun = t.postg.SaveToken
+/*
+Replaces a refresh token with a new one and returns the session it belongs to.
+A refresh token can only be used once, using it again revokes the session.
+*/
+func (t TokenService) RotateRefreshToken(refreshToken string) (entity.Session, string, error) {
+	id, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return entity.Session{}, "", fmt.Errorf("%w: invalid refresh token", errors.ErrUnauthorized)
+	}
+	newSecret, err := randomToken(32)
+	if err != nil {
+		return entity.Session{}, "", err
 	}
 
-	c.ExpiresAt = time.Now().Add(time.Hour).Unix()
-	c.Handle = user
+	session, err := t.postg.RotateRefreshToken(id, hashToken(secret), hashToken(newSecret), t.refreshLifetime, t.sessionLifetime)
+	if err == errors.ErrNotFound {
+		return entity.Session{}, "", fmt.Errorf("%w: invalid or expired refresh token", errors.ErrUnauthorized)
+	}
+	if err == errors.ErrConflict {
+		return entity.Session{}, "", fmt.Errorf("%w: refresh token was already used, the session is revoked", errors.ErrUnauthorized)
+	}
+	if err != nil {
+		return entity.Session{}, "", errors.WrapDBError(err, "refresh session", id)
+	}
+	return session, id + "." + newSecret, nil
+}
+
+// a signed access token for the session, and when it expires in unix time
+func (t TokenService) IssueAccessToken(session entity.Session, roles []string) (string, int64, error) {
+	c := JWTTokenClaims{}
+	c.Id = session.Id
+	c.ExpiresAt = time.Now().Add(t.accessLifetime).Unix()
+	c.Handle = session.Handle
 	c.Roles = roles
 
-	token := jwt.NewWithClaims(t.signingMethod, &c)
-	signed, err := token.SignedString(t.signKey)
+	signed, err := t.keys.Sign(&c)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
+	return signed, c.ExpiresAt, nil
+}
 
-	// maybe move to repo that ensures that only one token per user is saved
-	if err := repoFun(user, signed); err != nil {
-		return "", errors.WrapDBError(err, "token", user)
+// a signed token for a single request, see EXCHANGED_TOKEN_LIFETIME
+func (t TokenService) ExchangeToken(c JWTTokenClaims) (string, error) {
+	c.ExpiresAt = time.Now().Add(EXCHANGED_TOKEN_LIFETIME).Unix()
+	return t.keys.Sign(&c)
+}
+
+// verifies an access token with the key ring and reads its claims
+func (t TokenService) ParseAccessToken(token string) (JWTTokenClaims, error) {
+	var c JWTTokenClaims
+	if err := t.keys.Parse(token, &c); err != nil {
+		return JWTTokenClaims{}, err
 	}
-	return signed, nil
+	return c, nil
+}
+
+// the public keys that verify the access tokens, for the engines and others
+func (t TokenService) JWKS() signing.JWKSet {
+	return t.keys.JWKS()
 }
 
+// whether the session of the user can still be used
+func (t TokenService) SessionActive(userHandle string, id string) bool {
+	active, err := t.postg.SessionActive(userHandle, id)
+	return err == nil && active
+}
+
+// the active sessions of a user, current is marked as the current session
+func (t TokenService) GetSessions(userHandle string, current string) ([]entity.Session, error) {
+	sessions, err := t.postg.GetSessions(userHandle)
+	if err != nil {
+		return nil, errors.WrapDBError(err, "get sessions of", userHandle)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == current
+	}
+	return sessions, nil
+}
+
+func (t TokenService) RevokeSession(userHandle string, id string) error {
+	if err := t.postg.DeleteSession(userHandle, id); err != nil {
+		return errors.WrapDBError(err, "revoke session", id)
+	}
+	return nil
+}
+
+// revokes all sessions of the user
 func (t TokenService) LogOffUser(userHandle string) error {
-	if err := t.postg.DeleteUserToken(userHandle); err != nil {
-		return errors.WrapDBError(err, "delete token for", userHandle)
+	if err := t.postg.DeleteUserSessions(userHandle); err != nil {
+		return errors.WrapDBError(err, "delete sessions for", userHandle)
+	}
+	return nil
+}
+
+// revokes the sessions of the user except the one kept
+func (t TokenService) LogOffOtherSessions(userHandle string, keep string) error {
+	if err := t.postg.DeleteOtherSessions(userHandle, keep); err != nil {
+		return errors.WrapDBError(err, "delete sessions for", userHandle)
 	}
 	return nil
 }
+
+func randomToken(size int) (string, error) {
+	bs := make([]byte, size)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,23 +1,65 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
+	"log"
+	"slices"
+	"time"
 	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/entity"
 	"webdp/internal/api/http/repo/postgres"
 	"webdp/internal/api/http/utils"
+	"webdp/internal/oidc"
 )
 
+/*
+Manages the users and checks their passwords. Passwords are set by the
+policy and hashed with hashCost, logins are limited by the login policy.
+Logins of users that do not exist are checked against dummyHash, which has the
+same cost, so that they take as long as the logins of users that do.
+*/
 type UserService struct {
-	postg postgres.UserPostgres
+	postg     postgres.UserPostgres
+	audit     AuditService
+	policy    entity.PasswordPolicy
+	hashCost  int
+	dummyHash string
+	login     entity.LoginPolicy
+	throttle  *LoginThrottle
 }
 
-func NewUserService(postUser postgres.UserPostgres) UserService {
-	return UserService{postg: postUser}
+func NewUserService(postUser postgres.UserPostgres, audit AuditService, policy entity.PasswordPolicy, hashCost int, login entity.LoginPolicy) UserService {
+	dummyHash, err := utils.HashAndSalt("not the password of any user", hashCost)
+	if err != nil {
+		log.Printf("Hashing the dummy password: %v", err)
+	}
+	return UserService{
+		postg:     postUser,
+		audit:     audit,
+		policy:    policy,
+		hashCost:  hashCost,
+		dummyHash: dummyHash,
+		login:     login,
+		throttle:  NewLoginThrottle(login.MaxIpFailures, login.Lockout),
+	}
+}
+
+// a user in the audit log, which records that the password changed but not the password
+type auditedUser struct {
+	entity.UserResponse
+	PasswordChanged bool `json:"password_changed,omitempty"`
+}
+
+// the failed logins of a user in the audit log
+type auditedLockout struct {
+	FailedLogins int        `json:"failed_logins"`
+	LockedUntil  *time.Time `json:"locked_until"`
 }
 
-func (u UserService) GetAllUsers() ([]entity.UserResponse, error) {
-	users, err := u.postg.GetUsers()
+// the users of the organization, or of all organizations if it is empty
+func (u UserService) GetAllUsers(organization string) ([]entity.UserResponse, error) {
+	users, err := u.postg.GetUsers(organization)
 	if err != nil {
 		return []entity.UserResponse{}, errors.WrapDBError(err, "get users", "all")
 	}
@@ -40,48 +82,290 @@ func (u UserService) GetUserRoles(userHandle string) ([]string, error) {
 	return user.Roles, nil
 }
 
-func (u UserService) CreateUser(user entity.UserPost) (string, error) {
-	pwd, err := utils.HashAndSalt(user.PWD)
+func (u UserService) GetOrganization(userHandle string) (string, error) {
+	organization, err := u.postg.GetOrganization(userHandle)
+	if err != nil {
+		return "", errors.WrapDBError(err, "get organization of", userHandle)
+	}
+	return organization, nil
+}
+
+// whether one of the roles of the user gives the permission
+func (u UserService) HasPermission(userHandle string, permission string) (bool, error) {
+	has, err := u.postg.HasPermission(userHandle, permission)
+	if err != nil {
+		return false, errors.WrapDBError(err, "get permissions of", userHandle)
+	}
+	return has, nil
+}
+
+func (u UserService) CreateUser(actor entity.Actor, user entity.UserPost) (string, error) {
+	if err := u.policy.Check(user.Handle, user.PWD); err != nil {
+		return "", err
+	}
+	pwd, err := utils.HashAndSalt(user.PWD, u.hashCost)
 	if err != nil {
 		return "", fmt.Errorf("%w: could not set password for %s", err, user.Handle)
 	}
 
 	user.PWD = pwd
+	if user.Organization == "" {
+		user.Organization = entity.DEFAULT_ORGANIZATION
+	}
 
 	res, err := u.postg.CreateUser(user)
-
+	if err == errors.ErrBadInput {
+		return "", fmt.Errorf("%w: unrecognized role in %v", errors.ErrBadInput, user.Roles)
+	}
+	if err == errors.ErrNotFound {
+		return "", fmt.Errorf("%w: unrecognized organization %s", errors.ErrBadInput, user.Organization)
+	}
 	if err != nil {
 		return "", errors.WrapDBError(err, "create user", user.Handle)
 	}
 
+	u.recordUser(actor, entity.AUDIT_USER_CREATE, user.Handle, nil, false)
 	return res, nil
 }
 
-func (u UserService) UpdateUser(handle string, patch entity.UserPatch) error {
+func (u UserService) UpdateUser(actor entity.Actor, handle string, patch entity.UserPatch) error {
+	before, err := u.GetUser(handle)
+	if err != nil {
+		return err
+	}
 	if len(patch.PWD) > 0 {
-		pwd, err := utils.HashAndSalt(patch.PWD)
+		if err := u.policy.Check(handle, patch.PWD); err != nil {
+			return err
+		}
+		pwd, err := utils.HashAndSalt(patch.PWD, u.hashCost)
 		if err != nil {
 			return fmt.Errorf("%w: could not hash password for %s", err, handle)
 		}
 		patch.PWD = pwd
 	}
-	if _, err := u.postg.UpdateUser(handle, patch); err != nil {
+	_, err = u.postg.UpdateUser(handle, patch)
+	if err == errors.ErrBadInput {
+		return fmt.Errorf("%w: unrecognized role in %v", errors.ErrBadInput, patch.Roles)
+	}
+	if err != nil {
 		return errors.WrapDBError(err, "update", handle)
 	}
+
+	u.recordUser(actor, entity.AUDIT_USER_UPDATE, handle, &before, len(patch.PWD) > 0)
 	return nil
 }
 
-func (u UserService) DeleteUser(handle string) (string, error) {
+func (u UserService) DeleteUser(actor entity.Actor, handle string) (string, error) {
+	before, err := u.GetUser(handle)
+	if err != nil {
+		return "", err
+	}
 	if err := u.postg.DeleteUser(handle); err != nil {
 		return "", errors.WrapDBError(err, "delete", handle)
 	}
+
+	u.audit.Record(actor, entity.AUDIT_USER_DELETE, auditTarget("user", handle), before, nil)
 	return fmt.Sprintf("user with handle %s has been deleted", handle), nil
 }
 
-func (u UserService) CompareHashedPlain(loginReq entity.LoginRequest) bool {
-	res, err := u.postg.GetByHandle(loginReq.Username)
+/*
+Checks the password of the login request, which comes from the address. A
+failed login is recorded in the audit log, as done by the user it was tried
+for, and counts for the account and the address. When either has failed too
+often, logins are refused with ErrTooManyRequests until the time returned,
+without checking the password. A password hashed with another cost than the
+one configured is hashed again.
+*/
+func (u UserService) Authenticate(loginReq entity.LoginRequest, address string, requestId string) (time.Time, error) {
+	now := time.Now().UTC()
+	if until, blocked := u.throttle.Blocked(address, now); blocked {
+		return until, fmt.Errorf("%w: too many failed logins from this address, try again later", errors.ErrTooManyRequests)
+	}
+
+	creds, locked, err := u.attempt(loginReq.Username, now)
+	if err == errors.ErrTooManyRequests {
+		return *creds.LockedUntil, fmt.Errorf("%w: too many failed logins, the account is locked", errors.ErrTooManyRequests)
+	}
+	if err == nil && utils.ComparePasswords(creds.PWD, loginReq.PWD) {
+		if err := u.postg.ResetFailedLogins(creds.Handle); err != nil {
+			log.Printf("Resetting the failed logins of %s: %v", creds.Handle, err)
+		}
+		u.rehash(creds, loginReq.PWD)
+		return time.Time{}, nil
+	}
+	if err != nil {
+		// as long as a login of a user that exists
+		utils.ComparePasswords(u.dummyHash, loginReq.PWD)
+	}
+
+	u.throttle.Fail(address, now)
+	u.failedLogin(loginReq.Username, requestId, locked)
+	return time.Time{}, errors.ErrUnauthorized
+}
+
+/*
+Counts an attempt to log in as the user as failed before its password is
+checked, see UserPostgres.ReserveLoginAttempt, and returns the credentials of
+the user and the time of the lock if the attempt locked the account. A check
+that succeeds resets the count.
+*/
+func (u UserService) attempt(handle string, now time.Time) (entity.Credentials, *time.Time, error) {
+	creds, locked, err := u.postg.ReserveLoginAttempt(handle, u.login.MaxFailures, now.Add(u.login.Lockout), now)
+	if err != nil && err != errors.ErrTooManyRequests && err != errors.ErrNotFound {
+		log.Printf("Counting a login of %s: %v", handle, err)
+	}
+	return creds, locked, err
+}
+
+/*
+Changes the password of the user to a new one, which takes the password it has
+now. A wrong password counts as a failed login, so that it cannot be guessed
+here instead.
+*/
+func (u UserService) ChangePassword(actor entity.Actor, handle string, change entity.PasswordChange) error {
+	now := time.Now().UTC()
+	creds, locked, err := u.attempt(handle, now)
+	if err == errors.ErrTooManyRequests {
+		return fmt.Errorf("%w: too many failed logins, the account is locked", errors.ErrTooManyRequests)
+	}
+	if err != nil {
+		return errors.WrapDBError(err, "get", handle)
+	}
+	if !utils.ComparePasswords(creds.PWD, change.OldPWD) {
+		u.failedLogin(handle, actor.RequestId, locked)
+		return fmt.Errorf("%w: the old password is wrong", errors.ErrForbidden)
+	}
+	if err := u.postg.ResetFailedLogins(handle); err != nil {
+		log.Printf("Resetting the failed logins of %s: %v", handle, err)
+	}
+	if change.NewPWD == change.OldPWD {
+		return fmt.Errorf("%w: the new password should differ from the old one", errors.ErrBadInput)
+	}
+	if err := u.policy.Check(handle, change.NewPWD); err != nil {
+		return err
+	}
+
+	before, err := u.GetUser(handle)
+	if err != nil {
+		return err
+	}
+	pwd, err := utils.HashAndSalt(change.NewPWD, u.hashCost)
+	if err != nil {
+		return fmt.Errorf("%w: could not hash password for %s", err, handle)
+	}
+	if _, err := u.postg.UpdateUser(handle, entity.UserPatch{PWD: pwd}); err != nil {
+		return errors.WrapDBError(err, "update", handle)
+	}
+
+	u.recordUser(actor, entity.AUDIT_USER_PASSWORD, handle, &before, true)
+	return nil
+}
+
+// forgets the failed logins of the user, which unlocks its account
+func (u UserService) Unlock(actor entity.Actor, handle string) error {
+	creds, err := u.postg.GetCredentials(handle)
+	if err != nil {
+		return errors.WrapDBError(err, "get", handle)
+	}
+	if err := u.postg.ResetFailedLogins(handle); err != nil {
+		return errors.WrapDBError(err, "unlock", handle)
+	}
+
+	before := auditedLockout{FailedLogins: creds.FailedLogins, LockedUntil: creds.LockedUntil}
+	u.audit.Record(actor, entity.AUDIT_USER_UNLOCK, auditTarget("user", handle), before, auditedLockout{})
+	return nil
+}
+
+/*
+Records a failed login for the user, which was counted when it was attempted.
+The failure that locked the account at the time given is recorded too.
+*/
+func (u UserService) failedLogin(handle string, requestId string, locked *time.Time) {
+	organization, err := u.postg.GetOrganization(handle)
+	if err != nil {
+		organization = entity.DEFAULT_ORGANIZATION
+	}
+	actor := entity.Actor{Handle: handle, Organization: organization, RequestId: requestId}
+	u.audit.Record(actor, entity.AUDIT_LOGIN_FAILED, auditTarget("user", handle), nil, nil)
+	if locked != nil {
+		u.audit.Record(actor, entity.AUDIT_LOGIN_LOCKOUT, auditTarget("user", handle), nil, auditedLockout{LockedUntil: locked})
+	}
+}
+
+// hashes the password again if its hash was made with another cost, a failure only keeps the old hash
+func (u UserService) rehash(creds entity.Credentials, plain string) {
+	if !utils.NeedsRehash(creds.PWD, u.hashCost) {
+		return
+	}
+	hash, err := utils.HashAndSalt(plain, u.hashCost)
+	if err == nil {
+		err = u.postg.RehashPassword(creds.Handle, creds.PWD, hash)
+	}
 	if err != nil {
-		return false
+		log.Printf("Rehashing the password of %s: %v", creds.Handle, err)
 	}
-	return utils.ComparePasswords(res.PWD, loginReq.PWD)
+}
+
+// records the action on the user, with the user as it is now as the value after it
+func (u UserService) recordUser(actor entity.Actor, action string, handle string, before *entity.UserResponse, passwordChanged bool) {
+	after, err := u.postg.GetUser(handle)
+	if err != nil {
+		u.audit.Record(actor, action, auditTarget("user", handle), before, nil)
+		return
+	}
+	u.audit.Record(actor, action, auditTarget("user", handle), before, auditedUser{UserResponse: after, PasswordChanged: passwordChanged})
+}
+
+/*
+The handle of the user that logs in with the identity provider. The user is
+created in the organization on its first login, its name and roles follow the
+provider on every login. A user with the same handle that did not come from the provider is
+not taken over, ErrConflict is returned instead.
+*/
+func (u UserService) ProvisionExternalUser(actor entity.Actor, id oidc.Identity, roles []string, organization string) (string, error) {
+	handle, err := u.postg.GetExternalUser(id.Issuer, id.Subject)
+	if err == sql.ErrNoRows {
+		// the password is never used, the user logs in with the provider
+		pwd, err := oidc.RandomString(32)
+		if err != nil {
+			return "", err
+		}
+		hashed, err := utils.HashAndSalt(pwd, u.hashCost)
+		if err != nil {
+			return "", err
+		}
+		post := entity.UserPost{Handle: id.Username, Name: id.Name, Organization: organization, Roles: roles, PWD: hashed}
+		err = u.postg.CreateExternalUser(id.Issuer, id.Subject, post)
+		if err == errors.ErrConflict {
+			return "", fmt.Errorf("%w: user %s exists and does not log in with the identity provider", errors.ErrConflict, id.Username)
+		}
+		if err == errors.ErrNotFound {
+			return "", fmt.Errorf("%w: organization %s of the identity provider does not exist", errors.ErrUnexpected, organization)
+		}
+		if err != nil {
+			return "", errors.WrapDBError(err, "create user", id.Username)
+		}
+		u.recordUser(actor, entity.AUDIT_USER_CREATE, id.Username, nil, false)
+		return id.Username, nil
+	}
+	if err != nil {
+		return "", errors.WrapDBError(err, "get user of", id.Subject)
+	}
+
+	user, err := u.GetUser(handle)
+	if err != nil {
+		return "", err
+	}
+	if user.Name != id.Name || !slices.Equal(sorted(user.Roles), roles) {
+		if err := u.UpdateUser(actor, handle, entity.UserPatch{Name: id.Name, Roles: roles}); err != nil {
+			return "", err
+		}
+	}
+	return handle, nil
+}
+
+func sorted(ss []string) []string {
+	out := slices.Clone(ss)
+	slices.Sort(out)
+	return out
 }
@@ -0,0 +1,246 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webdp/internal/api/http/client"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/server"
+	"webdp/internal/config"
+	"webdp/internal/encryption"
+	"webdp/internal/signing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the connection string of keys and values of the database the tests of the API run on, such as
+// "host=localhost port=5432 user=postgres password=postgres dbname=webdp sslmode=disable"
+const TEST_DB_ENV = "WEBDP_TEST_DB"
+
+// the password of the users of testApi.user
+const TEST_PASSWORD = "Test-password-1"
+
+// how long after its login a session of testApi ends
+const TEST_SESSION_LIFETIME = 7 * 24 * time.Hour
+
+/*
+Webdp with the router of main, its handlers and middlewares on a schema of its
+own in the database of WEBDP_TEST_DB, which is made from deployment/init.sql
+and dropped after the test. Tests that use it are skipped without the
+database. The engine of the API answers queries with the number of rows of the
+dataset, which it reads from the internal routes like a real engine.
+*/
+type testApi struct {
+	server   *httptest.Server
+	repos    *server.Repos
+	services *server.Services
+	db       *sql.DB
+
+	mu       sync.Mutex
+	requests map[string]int
+	queries  []entity.QueryFromClientEvaluate
+}
+
+func newTestApi(t *testing.T) *testApi {
+	dsn := os.Getenv(TEST_DB_ENV)
+	if dsn == "" {
+		t.Skipf("%s is not set", TEST_DB_ENV)
+	}
+
+	root, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("webdp_test_%d", time.Now().UnixNano())
+	if _, err := root.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		root.Exec("DROP SCHEMA " + schema + " CASCADE")
+		root.Close()
+	})
+
+	// lib/pq sends the parameters it does not know to the server
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ddl, err := os.ReadFile("../../../../deployment/init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(ddl)); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := encryption.NewKeyRing(masterKey(1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, _ := newEdKey(t)
+	signKeys, err := signing.NewKeyRing(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &config.Envs{
+		Access_lifetime:  time.Hour,
+		Refresh_lifetime: 24 * time.Hour,
+		Session_lifetime: TEST_SESSION_LIFETIME,
+		Password:         entity.PasswordPolicy{MinLength: entity.DEFAULT_PASSWORD_MIN_LENGTH, MinClasses: entity.DEFAULT_PASSWORD_MIN_CLASSES},
+		Hash_cost:        bcrypt.MinCost,
+		Login:            entity.LoginPolicy{MaxFailures: entity.DEFAULT_LOGIN_MAX_FAILURES, MaxIpFailures: entity.DEFAULT_LOGIN_IP_MAX_FAILURES, Lockout: entity.DEFAULT_LOGIN_LOCKOUT},
+		Rate_limits:      entity.DefaultRateLimits(),
+		Daily_quota:      entity.DEFAULT_QUERY_DAILY_QUOTA,
+	}
+
+	a := &testApi{db: db, requests: make(map[string]int)}
+
+	internalRouter := mux.NewRouter()
+	engine := httptest.NewServer(http.HandlerFunc(a.evaluate))
+	internal := httptest.NewServer(internalRouter)
+	engines := entity.EnginesConfig{Default: "test", Engines: []entity.WebDPClientTarget{{Name: "test", EndpointEvaluate: engine.URL + "/evaluate"}}}
+	repos, services, handlers := server.New(db, client.NewDPClient(engines, internal.URL+"/datasets", nil), keys, signKeys, env)
+	server.RegisterInRoutes(internalRouter, &repos.Datasets)
+	a.repos, a.services = repos, services
+
+	router := server.NewRouter(services, handlers)
+	a.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.requests[r.Method+" "+r.URL.Path]++
+		a.mu.Unlock()
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(a.server.Close)
+	t.Cleanup(internal.Close)
+	t.Cleanup(engine.Close)
+	return a
+}
+
+// the number of requests to the path with the method, such as "POST /v2/refresh"
+func (a *testApi) count(request string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests[request]
+}
+
+// answers with the number of rows of the dataset of the query, which it reads from its callback
+func (a *testApi) evaluate(w http.ResponseWriter, r *http.Request) {
+	var query entity.QueryFromClientEvaluate
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.queries = append(a.queries, query)
+	a.mu.Unlock()
+
+	resp, err := http.Get(query.CallbackUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	rows := -1 // the header
+	for s := bufio.NewScanner(resp.Body); s.Scan(); {
+		rows++
+	}
+	json.NewEncoder(w).Encode(entity.QueryResult{"count": rows})
+}
+
+// creates a user of the default organization with the roles and TEST_PASSWORD
+func (a *testApi) user(t *testing.T, handle string, roles ...string) {
+	user := entity.UserPost{Handle: handle, Name: handle, Roles: roles, PWD: TEST_PASSWORD}
+	if _, err := a.services.Users.CreateUser(entity.SystemActor(""), user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the access token of a login of the user
+func (a *testApi) login(t *testing.T, handle string) string {
+	var token response.Token
+	a.call(t, "", http.MethodPost, "/v2/login", entity.LoginRequest{Username: handle, PWD: TEST_PASSWORD}, http.StatusOK, &token)
+	return token.Token
+}
+
+/*
+Sends a request with the token and returns the response, whose body is read.
+Bodies of []byte are sent as they are and other bodies as JSON.
+*/
+func (a *testApi) request(t *testing.T, token string, method string, path string, body any) (*http.Response, []byte) {
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, a.server.URL+path, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := a.server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, out
+}
+
+// sends the request and fails unless the API answers with the status, the body of the response is decoded into out
+func (a *testApi) call(t *testing.T, token string, method string, path string, body any, status int, out any) {
+	t.Helper()
+	resp, data := a.request(t, token, method, path, body)
+	if resp.StatusCode != status {
+		t.Fatalf("expected %s %s to answer %d, got %d: %s", method, path, status, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+// the status of the request
+func (a *testApi) status(t *testing.T, token string, method string, path string, body any) int {
+	resp, _ := a.request(t, token, method, path, body)
+	return resp.StatusCode
+}
+
+// creates a dataset of the owner with an Int column age, as the owner
+func (a *testApi) dataset(t *testing.T, token string, owner string) int64 {
+	dataset := entity.DatasetCreate{
+		Name:          "dataset of " + owner,
+		Owner:         owner,
+		Schema:        []entity.ColumnSchema{{Name: "age", Type: entity.DataType{Type: &entity.IntType{Low: 0, High: 120}}}},
+		PrivacyNotion: entity.PURE,
+		TotalBudget:   entity.Budget{Epsilon: 1},
+	}
+	var id response.Id
+	a.call(t, token, http.MethodPost, "/v2/datasets", dataset, http.StatusCreated, &id)
+	return id.Id
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+	"webdp/internal/api/http/services"
+)
+
+func TestApiKeyCreate(t *testing.T) {
+	now := time.Now()
+	good := entity.ApiKeyCreate{Name: "nightly report", Roles: []string{entity.ANALYST}, Datasets: []int64{1, 2}, ExpiresOn: now.Add(24 * time.Hour)}
+	if err := good.Valid(now); err != nil {
+		t.Errorf("expected key to be valid, got: %v", err)
+	}
+
+	bad := map[string]entity.ApiKeyCreate{
+		"no name":     {Roles: good.Roles, ExpiresOn: good.ExpiresOn},
+		"no roles":    {Name: good.Name, ExpiresOn: good.ExpiresOn},
+		"bad role":    {Name: good.Name, Roles: []string{"no such role!"}, ExpiresOn: good.ExpiresOn},
+		"expired":     {Name: good.Name, Roles: good.Roles, ExpiresOn: now.Add(-time.Hour)},
+		"no expiry":   {Name: good.Name, Roles: good.Roles},
+		"too long":    {Name: good.Name, Roles: good.Roles, ExpiresOn: now.Add(entity.MAX_API_KEY_LIFETIME + time.Hour)},
+		"bad dataset": {Name: good.Name, Roles: good.Roles, Datasets: []int64{0}, ExpiresOn: good.ExpiresOn},
+	}
+	for name, k := range bad {
+		if err := k.Valid(now); !errors.Is(err, httperrors.ErrBadInput) && !errors.Is(err, httperrors.ErrBadFormatting) {
+			t.Errorf("expected key with %s to be rejected, got: %v", name, err)
+		}
+	}
+}
+
+func TestTokenDatasetScope(t *testing.T) {
+	session := services.JWTTokenClaims{Handle: "curt"}
+	if !session.InScope(1) {
+		t.Error("expected a session token to give access to any dataset")
+	}
+
+	key := services.JWTTokenClaims{Handle: "curt", ApiKey: true, Datasets: []int64{1, 3}}
+	if !key.InScope(3) || key.InScope(2) {
+		t.Errorf("expected a key to give access to datasets %v only", key.Datasets)
+	}
+}
+
+func TestApiKeyRoutes(t *testing.T) {
+	key := services.JWTTokenClaims{Handle: "curt", ApiKey: true, Datasets: []int64{1, 3}}
+	allowed := []struct {
+		method string
+		route  string
+		vars   map[string]string
+	}{
+		{"GET", "/v2/datasets/{datasetId}", map[string]string{"datasetId": "3"}},
+		{"PUT", "/v2/budgets/allocations/{userHandle}/{datasetId}/quota", map[string]string{"userHandle": "curt", "datasetId": "1"}},
+		{"GET", "/v2/datasets", nil},
+		{"POST", "/v2/queries/evaluate", nil},
+		{"POST", "/v1/query/accuracy", nil},
+	}
+	for _, a := range allowed {
+		if err := middlewares.CheckDatasetScope(key, a.method, a.route, a.vars); err != nil {
+			t.Errorf("expected the key to reach %s, got: %v", a.route, err)
+		}
+	}
+
+	refused := []struct {
+		method string
+		route  string
+		vars   map[string]string
+	}{
+		{"GET", "/v2/datasets/{datasetId}", map[string]string{"datasetId": "2"}},
+		{"GET", "/v2/budgets/users/{userHandle}", map[string]string{"userHandle": "curt"}},
+		{"GET", "/v2/sessions", nil},
+		{"POST", "/v2/datasets", nil},
+		{"GET", "/v2/users/{userHandle}/keys", map[string]string{"userHandle": "curt"}},
+	}
+	for _, r := range refused {
+		if err := middlewares.CheckDatasetScope(key, r.method, r.route, r.vars); !errors.Is(err, httperrors.ErrForbidden) {
+			t.Errorf("expected the key to be refused at %s, got: %v", r.route, err)
+		}
+	}
+
+	session := services.JWTTokenClaims{Handle: "curt"}
+	if err := middlewares.CheckDatasetScope(session, "GET", "/v2/sessions", nil); err != nil {
+		t.Errorf("expected a session token to reach every route, got: %v", err)
+	}
+}
+
+func TestApiKeyOwnerRoles(t *testing.T) {
+	a := newTestApi(t)
+	a.user(t, "ada", entity.ADMIN)
+	a.user(t, "carl", entity.CURATOR, entity.ANALYST)
+	admin := a.login(t, "ada")
+
+	var created entity.ApiKeyCreated
+	req := entity.ApiKeyCreate{Name: "report", Roles: []string{entity.CURATOR, entity.ANALYST}, ExpiresOn: time.Now().Add(time.Hour)}
+	a.call(t, a.login(t, "carl"), http.MethodPost, "/v2/users/carl/keys", req, http.StatusCreated, &created)
+	key := created.Key
+
+	roles := func() []string {
+		token, err := a.services.Tokens.AuthenticateApiKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := a.services.Tokens.ParseAccessToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(claims.Roles)
+		return claims.Roles
+	}
+	if got := roles(); !slices.Equal(got, []string{entity.ANALYST, entity.CURATOR}) {
+		t.Errorf("expected the key to have the roles of carl, got %v", got)
+	}
+	a.call(t, key, http.MethodGet, "/v2/users", nil, http.StatusOK, nil)
+
+	// carl is no longer a curator, nor is the key
+	demote := entity.UserPatch{Name: "carl", Roles: []string{entity.ANALYST}, PWD: TEST_PASSWORD}
+	a.call(t, admin, http.MethodPatch, "/v2/users/carl", demote, http.StatusNoContent, nil)
+	if got := roles(); !slices.Equal(got, []string{entity.ANALYST}) {
+		t.Errorf("expected the key to lose the role carl lost, got %v", got)
+	}
+	if s := a.status(t, key, http.MethodGet, "/v2/users", nil); s != http.StatusForbidden {
+		t.Errorf("expected the key of a demoted user to be refused, got %d", s)
+	}
+
+	// the key has no more roles than it was made with
+	promote := entity.UserPatch{Name: "carl", Roles: []string{entity.ADMIN, entity.CURATOR, entity.ANALYST}, PWD: TEST_PASSWORD}
+	a.call(t, admin, http.MethodPatch, "/v2/users/carl", promote, http.StatusNoContent, nil)
+	if got := roles(); !slices.Equal(got, []string{entity.ANALYST, entity.CURATOR}) {
+		t.Errorf("expected the key to keep its own roles, got %v", got)
+	}
+
+	a.call(t, admin, http.MethodDelete, "/v2/users/carl", nil, http.StatusNoContent, nil)
+	if s := a.status(t, key, http.MethodGet, "/v2/users", nil); s != http.StatusUnauthorized {
+		t.Errorf("expected the key of a deleted user to be refused, got %d", s)
+	}
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+func TestAuditHashChain(t *testing.T) {
+	first := entity.AuditEntry{
+		Time:         time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC),
+		Actor:        "root",
+		Organization: entity.DEFAULT_ORGANIZATION,
+		Action:       entity.AUDIT_USER_CREATE,
+		Target:       "user/alice",
+		After:        json.RawMessage(`{"handle":"alice","roles":["Analyst"]}`),
+		RequestId:    "req-1",
+	}
+	hash, err := first.ComputeHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Hash = hash
+
+	// the database keeps microseconds, in its own time zone
+	stored := first
+	stored.Time = first.Time.Truncate(time.Microsecond).In(time.FixedZone("CET", 3600))
+	if again, _ := stored.ComputeHash(); again != hash {
+		t.Errorf("expected the hash of the stored entry to be the same")
+	}
+
+	second := entity.AuditEntry{Time: first.Time.Add(time.Second), Actor: "root", Action: entity.AUDIT_USER_DELETE, Target: "user/alice", PrevHash: first.Hash}
+	chained, _ := second.ComputeHash()
+	second.PrevHash = ""
+	if unchained, _ := second.ComputeHash(); unchained == chained {
+		t.Errorf("expected the hash to depend on the previous hash")
+	}
+
+	changes := map[string]func(e *entity.AuditEntry){
+		"actor":      func(e *entity.AuditEntry) { e.Actor = "mallory" },
+		"action":     func(e *entity.AuditEntry) { e.Action = entity.AUDIT_USER_UPDATE },
+		"target":     func(e *entity.AuditEntry) { e.Target = "user/bob" },
+		"after":      func(e *entity.AuditEntry) { e.After = json.RawMessage(`{"handle":"alice","roles":["Admin"]}`) },
+		"before":     func(e *entity.AuditEntry) { e.Before = json.RawMessage(`{}`) },
+		"time":       func(e *entity.AuditEntry) { e.Time = e.Time.Add(time.Microsecond) },
+		"request id": func(e *entity.AuditEntry) { e.RequestId = "req-2" },
+	}
+	for name, change := range changes {
+		changed := first
+		change(&changed)
+		if h, _ := changed.ComputeHash(); h == hash {
+			t.Errorf("expected a changed %s to change the hash", name)
+		}
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	f, err := entity.NewAuditFilter(url.Values{"actor": {"root"}, "action": {"user"}, "from": {"2024-03-01T00:00:00Z"}, "limit": {"10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Actor != "root" || f.Action != "user" || f.From == nil || f.To != nil || f.Limit != 10 {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+
+	if f, _ := entity.NewAuditFilter(url.Values{}); f.Limit != entity.DEFAULT_PAGE_SIZE {
+		t.Errorf("expected the default page size, got %d", f.Limit)
+	}
+
+	bad := map[string]url.Values{
+		"bad from":      {"from": {"yesterday"}},
+		"from after to": {"from": {"2024-03-02T00:00:00Z"}, "to": {"2024-03-01T00:00:00Z"}},
+		"big limit":     {"limit": {"100000"}},
+		"bad offset":    {"offset": {"-1"}},
+	}
+	for name, query := range bad {
+		if _, err := entity.NewAuditFilter(query); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected %s to be rejected, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+func TestDatasetFilter(t *testing.T) {
+	f, err := entity.NewDatasetFilter(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Sort != entity.SORT_ID || f.Descending || f.Limit != entity.DEFAULT_PAGE_SIZE || f.Offset != 0 || f.Loaded != nil || f.Deleted || f.Filters() {
+		t.Errorf("unexpected default filter: %+v", f)
+	}
+
+	query, _ := url.ParseQuery("owner=curt&loaded=true&privacy_notion=ApproxDP&tag=health&search=sal&sort=name&order=desc&limit=10&offset=20")
+	f, err = entity.NewDatasetFilter(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Owner != "curt" || f.Loaded == nil || !*f.Loaded || f.PrivacyNotion != entity.APPROX || f.Tag != "health" || f.Search != "sal" ||
+		f.Sort != entity.SORT_NAME || !f.Descending || f.Limit != 10 || f.Offset != 20 || !f.Filters() {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+
+	query, _ = url.ParseQuery("deleted=true")
+	f, err = entity.NewDatasetFilter(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Deleted || !f.Filters() {
+		t.Errorf("expected a filter of deleted datasets, got: %+v", f)
+	}
+
+	for _, bad := range []string{"loaded=yes", "privacy_notion=DP", "sort=owner", "order=up", "limit=0", "limit=1001", "offset=-1", "limit=ten", "deleted=maybe"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := entity.NewDatasetFilter(query); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected %s to be rejected, got: %v", bad, err)
+		}
+	}
+}
+
+func TestDatasetFilterQuery(t *testing.T) {
+	if query := (entity.DatasetFilter{Sort: entity.SORT_ID}).Query(); len(query) != 0 {
+		t.Errorf("expected no parameters for the default filter, got %v", query)
+	}
+
+	query, _ := url.ParseQuery("owner=curt&loaded=false&privacy_notion=ApproxDP&tag=health&search=sal&sort=name&order=desc&limit=10&offset=20&deleted=true")
+	f, err := entity.NewDatasetFilter(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Query().Encode(); got != query.Encode() {
+		t.Errorf("expected the parameters the filter was read from, got %s", got)
+	}
+	f.Organization, f.GrantedTo, f.Ids = "org", "curt", []int64{1}
+	if got := f.Query().Encode(); got != query.Encode() {
+		t.Errorf("expected the filters of the server to be left out, got %s", got)
+	}
+}
+
+func TestDatasetTags(t *testing.T) {
+	create := entity.DatasetCreate{Name: "name", Owner: "myowner", Schema: validColumnSchemas(), PrivacyNotion: entity.PURE, TotalBudget: validBudgets()[0], Tags: []string{"health", "survey"}}
+	if err := create.Valid(); err != nil {
+		t.Errorf("expected tags to be valid, got: %v", err)
+	}
+
+	for _, tags := range [][]string{{"health", "health"}, {""}, make([]string, 51)} {
+		create.Tags = tags
+		if err := create.Valid(); err == nil {
+			t.Errorf("expected tags %q to be rejected", tags)
+		}
+	}
+
+	tags := []string{"a", "a"}
+	patch := entity.DatasetPatch{Name: "name", Owner: "myowner", TotalBudget: validBudgets()[0], Tags: &tags}
+	if err := patch.Valid(); err == nil {
+		t.Errorf("expected duplicate tags in a patch to be rejected")
+	}
+	patch.Tags = nil
+	if err := patch.Valid(); err != nil {
+		t.Errorf("expected a patch without metadata to be valid, got: %v", err)
+	}
+}
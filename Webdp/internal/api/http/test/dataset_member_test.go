@@ -0,0 +1,203 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+func TestMemberRoles(t *testing.T) {
+	for _, role := range []string{entity.MEMBER_CO_CURATOR, entity.MEMBER_VIEWER} {
+		if err := (entity.MemberPut{Role: role}).Valid(); err != nil {
+			t.Errorf("expected role %s to be valid, got: %v", role, err)
+		}
+	}
+
+	// the owner changes through a transfer
+	for _, role := range []string{entity.MEMBER_OWNER, "", "Curator"} {
+		if err := (entity.MemberPut{Role: role}).Valid(); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected role %q to be rejected, got: %v", role, err)
+		}
+	}
+}
+
+/*
+A dataset of ann, a curator, with cody as co-curator and vera, an analyst, as
+viewer. Curt is a curator and nora an analyst, neither of them is a member.
+Returns the dataset and the tokens of the users.
+*/
+func memberDataset(t *testing.T, a *testApi) (int64, map[string]string) {
+	users := map[string]string{"ann": entity.CURATOR, "cody": entity.CURATOR, "vera": entity.ANALYST, "curt": entity.CURATOR, "nora": entity.ANALYST}
+	tokens := make(map[string]string)
+	for handle, role := range users {
+		a.user(t, handle, role)
+		tokens[handle] = a.login(t, handle)
+	}
+	id := a.dataset(t, tokens["ann"], "ann")
+	members := fmt.Sprintf("/v2/datasets/%d/members/", id)
+	a.call(t, tokens["ann"], http.MethodPut, members+"cody", entity.MemberPut{Role: entity.MEMBER_CO_CURATOR}, http.StatusNoContent, nil)
+	a.call(t, tokens["ann"], http.MethodPut, members+"vera", entity.MemberPut{Role: entity.MEMBER_VIEWER}, http.StatusNoContent, nil)
+	return id, tokens
+}
+
+func TestMemberRoutes(t *testing.T) {
+	a := newTestApi(t)
+	id, tokens := memberDataset(t, a)
+	dataset := fmt.Sprintf("/v2/datasets/%d", id)
+	patch := entity.DatasetPatch{Name: "renamed", Owner: "ann", TotalBudget: entity.Budget{Epsilon: 1}}
+	handOver := entity.DatasetPatch{Name: "renamed", Owner: "cody", TotalBudget: entity.Budget{Epsilon: 1}}
+
+	// the statuses of the co-curator, the viewer and the users that are not members
+	users := []string{"cody", "vera", "curt", "nora"}
+	routes := []struct {
+		method   string
+		path     string
+		body     any
+		statuses []int
+	}{
+		{http.MethodGet, dataset, nil, []int{200, 200, 200, 403}},
+		{http.MethodGet, dataset + "/members", nil, []int{200, 200, 200, 403}},
+		{http.MethodGet, dataset + "/versions", nil, []int{200, 200, 200, 403}},
+		{http.MethodPatch, dataset, patch, []int{204, 403, 403, 403}},
+		{http.MethodPatch, dataset, handOver, []int{403, 403, 403, 403}},
+		{http.MethodPut, dataset + "/members/vera", entity.MemberPut{Role: entity.MEMBER_VIEWER}, []int{403, 403, 403, 403}},
+		{http.MethodDelete, dataset + "/members/ann", nil, []int{403, 403, 403, 403}},
+		{http.MethodGet, dataset + "/transfer", nil, []int{403, 403, 403, 403}},
+		{http.MethodPost, dataset + "/transfer", entity.TransferRequest{To: "curt"}, []int{403, 403, 403, 403}},
+		{http.MethodPost, dataset + "/uploads", nil, []int{201, 403, 403, 403}},
+		{http.MethodDelete, dataset, nil, []int{403, 403, 403, 403}},
+	}
+	for _, route := range routes {
+		for i, user := range users {
+			if s := a.status(t, tokens[user], route.method, route.path, route.body); s != route.statuses[i] {
+				t.Errorf("expected %s %s of %s to answer %d, got %d", route.method, route.path, user, route.statuses[i], s)
+			}
+		}
+	}
+
+	// the owner hands the dataset over by patch or transfer, but not to itself
+	owner := []struct {
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{http.MethodGet, dataset, nil, 200},
+		{http.MethodGet, dataset + "/members", nil, 200},
+		{http.MethodGet, dataset + "/versions", nil, 200},
+		{http.MethodPatch, dataset, patch, 204},
+		{http.MethodPut, dataset + "/members/vera", entity.MemberPut{Role: entity.MEMBER_VIEWER}, 204},
+		{http.MethodDelete, dataset + "/members/ann", nil, 409},
+		{http.MethodGet, dataset + "/transfer", nil, 404},
+		{http.MethodPost, dataset + "/transfer", entity.TransferRequest{To: "ann"}, 400},
+		{http.MethodPost, dataset + "/uploads", nil, 201},
+		{http.MethodPatch, dataset, handOver, 204},
+		{http.MethodGet, dataset + "/transfer", nil, 200},
+	}
+	for _, route := range owner {
+		if s := a.status(t, tokens["ann"], route.method, route.path, route.body); s != route.status {
+			t.Errorf("expected %s %s of ann to answer %d, got %d", route.method, route.path, route.status, s)
+		}
+	}
+	a.call(t, tokens["ann"], http.MethodDelete, dataset, nil, http.StatusNoContent, nil)
+}
+
+func TestOwnershipTransfer(t *testing.T) {
+	a := newTestApi(t)
+	id, tokens := memberDataset(t, a)
+	dataset := fmt.Sprintf("/v2/datasets/%d", id)
+	owner := func() string {
+		var d entity.DatasetInfo
+		a.call(t, tokens["ann"], http.MethodGet, dataset, nil, http.StatusOK, &d)
+		return d.Owner
+	}
+
+	var transfer entity.OwnershipTransfer
+	a.call(t, tokens["ann"], http.MethodPost, dataset+"/transfer", entity.TransferRequest{To: "curt"}, http.StatusCreated, &transfer)
+	if transfer.From != "ann" || transfer.To != "curt" {
+		t.Errorf("expected a transfer from ann to curt, got %+v", transfer)
+	}
+	a.call(t, tokens["curt"], http.MethodGet, dataset+"/transfer", nil, http.StatusOK, &transfer)
+	if s := a.status(t, tokens["cody"], http.MethodPost, dataset+"/transfer/accept", nil); s != http.StatusNotFound {
+		t.Errorf("expected cody not to accept a transfer to curt, got %d", s)
+	}
+	if owner() != "ann" {
+		t.Error("expected ann to own the dataset until the transfer is accepted")
+	}
+
+	// a new proposal replaces the one to curt, who can no longer accept
+	a.call(t, tokens["ann"], http.MethodPost, dataset+"/transfer", entity.TransferRequest{To: "cody"}, http.StatusCreated, nil)
+	if s := a.status(t, tokens["curt"], http.MethodPost, dataset+"/transfer/accept", nil); s != http.StatusNotFound {
+		t.Errorf("expected the replaced transfer to curt to be gone, got %d", s)
+	}
+	a.call(t, tokens["cody"], http.MethodPost, dataset+"/transfer/accept", nil, http.StatusNoContent, nil)
+	if o := owner(); o != "cody" {
+		t.Fatalf("expected cody to own the dataset, got %s", o)
+	}
+	var members []entity.DatasetMember
+	a.call(t, tokens["cody"], http.MethodGet, dataset+"/members", nil, http.StatusOK, &members)
+	if len(members) != 2 || members[0].Handle != "cody" || members[0].Role != entity.MEMBER_OWNER || members[1].Handle != "vera" {
+		t.Errorf("expected cody to own the dataset with vera as viewer and without ann, got %+v", members)
+	}
+	if s := a.status(t, tokens["ann"], http.MethodPost, dataset+"/transfer", entity.TransferRequest{To: "ann"}); s != http.StatusForbidden {
+		t.Errorf("expected ann to no longer hand over the dataset, got %d", s)
+	}
+	if s := a.status(t, tokens["ann"], http.MethodGet, dataset+"/transfer", nil); s != http.StatusForbidden {
+		t.Errorf("expected ann to no longer see the transfers of the dataset, got %d", s)
+	}
+
+	// a transfer left over from when ann owned the dataset is not accepted once cody owns it
+	if _, err := a.db.Exec("INSERT INTO OwnershipTransfers (dataset, from_user, to_user, created_time) VALUES ($1, 'ann', 'curt', NOW())", id); err != nil {
+		t.Fatal(err)
+	}
+	if s := a.status(t, tokens["curt"], http.MethodPost, dataset+"/transfer/accept", nil); s != http.StatusConflict {
+		t.Errorf("expected a transfer of the previous owner to be refused with 409, got %d", s)
+	}
+	if o := owner(); o != "cody" {
+		t.Errorf("expected cody to still own the dataset, got %s", o)
+	}
+
+	// the owner cancels a proposal and the proposed owner declines one
+	a.call(t, tokens["cody"], http.MethodPost, dataset+"/transfer", entity.TransferRequest{To: "curt"}, http.StatusCreated, nil)
+	a.call(t, tokens["cody"], http.MethodDelete, dataset+"/transfer", nil, http.StatusNoContent, nil)
+	a.call(t, tokens["cody"], http.MethodPost, dataset+"/transfer", entity.TransferRequest{To: "curt"}, http.StatusCreated, nil)
+	a.call(t, tokens["curt"], http.MethodDelete, dataset+"/transfer", nil, http.StatusNoContent, nil)
+	if s := a.status(t, tokens["curt"], http.MethodPost, dataset+"/transfer/accept", nil); s != http.StatusNotFound {
+		t.Errorf("expected a declined transfer to be gone, got %d", s)
+	}
+}
+
+func TestRemoveOwner(t *testing.T) {
+	a := newTestApi(t)
+	id, tokens := memberDataset(t, a)
+	members := fmt.Sprintf("/v2/datasets/%d/members/", id)
+
+	// the only owner stays until another accepts the dataset
+	if s := a.status(t, tokens["ann"], http.MethodDelete, members+"ann", nil); s != http.StatusConflict {
+		t.Errorf("expected the owner not to leave the dataset, got %d", s)
+	}
+	if s := a.status(t, tokens["ann"], http.MethodPut, members+"ann", entity.MemberPut{Role: entity.MEMBER_CO_CURATOR}); s != http.StatusConflict {
+		t.Errorf("expected the owner not to step down to co-curator, got %d", s)
+	}
+	if s := a.status(t, tokens["cody"], http.MethodDelete, members+"ann", nil); s != http.StatusForbidden {
+		t.Errorf("expected a co-curator not to remove the owner, got %d", s)
+	}
+	var list []entity.DatasetMember
+	a.call(t, tokens["ann"], http.MethodGet, fmt.Sprintf("/v2/datasets/%d/members", id), nil, http.StatusOK, &list)
+	if len(list) != 3 || list[0].Handle != "ann" || list[0].Role != entity.MEMBER_OWNER {
+		t.Errorf("expected ann to still own the dataset, got %+v", list)
+	}
+
+	// members leave on their own and are removed by the owner
+	a.call(t, tokens["vera"], http.MethodDelete, members+"vera", nil, http.StatusNoContent, nil)
+	if s := a.status(t, tokens["vera"], http.MethodGet, fmt.Sprintf("/v2/datasets/%d", id), nil); s != http.StatusForbidden {
+		t.Errorf("expected vera to no longer read the dataset, got %d", s)
+	}
+	a.call(t, tokens["ann"], http.MethodDelete, members+"cody", nil, http.StatusNoContent, nil)
+	if s := a.status(t, tokens["ann"], http.MethodDelete, members+"cody", nil); s != http.StatusNotFound {
+		t.Errorf("expected a removed member to be gone, got %d", s)
+	}
+}
@@ -2,9 +2,13 @@ package test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+	httperrors "webdp/internal/api/http"
 	"webdp/internal/api/http/entity"
 )
 
@@ -75,3 +79,164 @@ func TestUnmDataType(t *testing.T) {
 	fmt.Println(string(back))
 
 }
+
+func TestDataTypeFractionalBounds(t *testing.T) {
+	var dt entity.DataType
+	err := json.Unmarshal([]byte(`{"name": "Double", "low": 0, "high": 0.5}`), &dt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	double, ok := dt.Type.(*entity.DoubleType)
+	if !ok {
+		t.Fatalf("expected a Double type but got: %v", dt.Type)
+	}
+	if double.Low != 0 || double.High != 0.5 {
+		t.Errorf("unexpected bounds: [%g, %g]", double.Low, double.High)
+	}
+
+	// integer bounded columns are still accepted
+	err = json.Unmarshal([]byte(`{"name": "Int", "low": 1, "high": 10}`), &dt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it, ok := dt.Type.(*entity.IntType); !ok || it.Low != 1 || it.High != 10 {
+		t.Errorf("unexpected Int type: %v", dt.Type)
+	}
+
+	err = json.Unmarshal([]byte(`{"name": "Int", "low": 0, "high": 0.5}`), &dt)
+	if err == nil {
+		t.Error("expected error for fractional Int bound")
+	}
+}
+
+func uploadTestSchema() []entity.ColumnSchema {
+	return []entity.ColumnSchema{
+		{Name: "name", Type: entity.DataType{Type: &entity.TextType{}}},
+		{Name: "age", Type: entity.DataType{Type: &entity.IntType{Low: 18, High: 100}}},
+		{Name: "job", Type: entity.DataType{Type: &entity.EnumType{Labels: []string{"Dentist", "Accountant"}}}},
+		{Name: "score", Type: entity.DataType{Type: &entity.DoubleType{Low: 0, High: 1}}},
+		{Name: "member", Type: entity.DataType{Type: &entity.BoolType{}}},
+	}
+}
+
+func TestUploadValidatorReject(t *testing.T) {
+	v, err := entity.NewUploadValidator(uploadTestSchema(), []string{"age", "name", "job", "score", "member"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := v.Row([]string{"20", "Anna", "Dentist", "0.5", "true"}); !ok {
+		t.Errorf("expected a valid row, got: %s", v.Err())
+	}
+
+	for _, row := range [][]string{
+		{"abc", "Bo", "Dentist", "0.5", "false"},
+		{"200", "Bo", "Dentist", "0.5", "false"},
+		{"20", "Bo", "Plumber", "0.5", "false"},
+		{"20", "Bo", "Dentist", "NaN", "false"},
+		{"20", "Bo", "Dentist", "0.5", "maybe"},
+	} {
+		if _, ok := v.Row(row); ok {
+			t.Errorf("expected row %v to be invalid", row)
+		}
+	}
+
+	err = v.Err()
+	if err == nil || !strings.Contains(err.Error(), "5 invalid cells") || !strings.Contains(err.Error(), "row 3, column 1 (age)") {
+		t.Errorf("unexpected report: %v", err)
+	}
+}
+
+func TestUploadValidatorCapped(t *testing.T) {
+	v, err := entity.NewUploadValidator(uploadTestSchema(), []string{"name", "age", "job", "score", "member"}, entity.UPLOAD_REJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		v.Row([]string{"Bo", "x", "y", "z", "w"})
+	}
+
+	err = v.Err()
+	if err == nil || !strings.Contains(err.Error(), "400 invalid cells, showing the first 20") || strings.Count(err.Error(), "; row") != 20 {
+		t.Errorf("unexpected report: %v", err)
+	}
+}
+
+func TestUploadValidatorClampAndDrop(t *testing.T) {
+	header := []string{"name", "age", "job", "score", "member"}
+
+	v, err := entity.NewUploadValidator(uploadTestSchema(), header, entity.UPLOAD_CLAMP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, ok := v.Row([]string{"Bo", "150", "Dentist", "-0.5", "1"})
+	if !ok || row[1] != "100" || row[3] != "0" {
+		t.Errorf("expected clamped row, got: %v", row)
+	}
+	if _, ok := v.Row([]string{"Bo", "abc", "Dentist", "0.5", "1"}); ok || v.Err() == nil {
+		t.Errorf("expected clamp to still reject cells that are not numbers")
+	}
+	if v.Report().ClampedCells != 2 {
+		t.Errorf("expected 2 clamped cells, got: %+v", v.Report())
+	}
+
+	v, err = entity.NewUploadValidator(uploadTestSchema(), header, entity.UPLOAD_DROP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Row([]string{"Bo", "150", "Dentist", "0.5", "1"})
+	v.Row([]string{"Bo", "50", "Dentist", "0.5", "1"})
+	if v.Err() != nil || v.Report() != (entity.UploadReport{Rows: 2, DroppedRows: 1}) {
+		t.Errorf("unexpected report: %+v, %v", v.Report(), v.Err())
+	}
+
+	if _, err := entity.NewUploadValidator(uploadTestSchema(), header, "fix"); err == nil {
+		t.Errorf("expected unknown mode to be rejected")
+	}
+	if _, err := entity.NewUploadValidator(uploadTestSchema(), []string{"name", "name", "job", "score", "member"}, ""); err == nil {
+		t.Errorf("expected header not matching the schema to be rejected")
+	}
+}
+
+func TestUploadValidatorSchemaOrder(t *testing.T) {
+	v, err := entity.NewUploadValidator(uploadTestSchema(), []string{"member", "score", "job", "age", "name"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row, ok := v.Row([]string{"true", "0.5", "Dentist", "20", "Anna"})
+	if !ok || !reflect.DeepEqual(row, []string{"Anna", "20", "Dentist", "0.5", "true"}) {
+		t.Errorf("expected the row in schema order, got: %v", row)
+	}
+	if !reflect.DeepEqual(v.Header(), []string{"name", "age", "job", "score", "member"}) {
+		t.Errorf("expected the header of the schema, got: %v", v.Header())
+	}
+}
+
+func TestUploadOptions(t *testing.T) {
+	opts, err := entity.NewUploadOptions("", "")
+	if err != nil || opts != (entity.UploadOptions{Mode: entity.VERSION_REPLACE}) {
+		t.Errorf("expected replace and keep by default, got: %+v, %v", opts, err)
+	}
+
+	opts, err = entity.NewUploadOptions(entity.VERSION_REPLACE, entity.BUDGET_RESET)
+	if err != nil || opts != (entity.UploadOptions{Mode: entity.VERSION_REPLACE, ResetBudget: true}) {
+		t.Errorf("unexpected options: %+v, %v", opts, err)
+	}
+	opts, err = entity.NewUploadOptions(entity.VERSION_APPEND, "")
+	if err != nil || opts != (entity.UploadOptions{Mode: entity.VERSION_APPEND}) {
+		t.Errorf("unexpected options: %+v, %v", opts, err)
+	}
+
+	if _, err := entity.NewUploadOptions(entity.VERSION_APPEND, entity.BUDGET_RESET); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected a reset of the budget with appended rows to be rejected, got: %v", err)
+	}
+
+	if _, err := entity.NewUploadOptions("merge", ""); err == nil {
+		t.Errorf("expected unknown mode to be rejected")
+	}
+	if _, err := entity.NewUploadOptions("", "refund"); err == nil {
+		t.Errorf("expected unknown budget handling to be rejected")
+	}
+}
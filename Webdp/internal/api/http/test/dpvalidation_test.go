@@ -34,6 +34,24 @@ func TestDpValidation(t *testing.T) {
 	}
 }
 
+func TestDpValidationTagged(t *testing.T) {
+
+	type temp struct {
+		Field1 string `dpvalidation:"non-empty-string"`
+		Field2 string
+	}
+
+	if err := utils.ValidateNonEmptyTagged(temp{Field2: "hello"}); err == nil {
+		t.Error("expected error")
+	}
+	if err := utils.ValidateNonEmptyTagged(temp{Field1: "hello"}); err != nil {
+		t.Error(err)
+	}
+	if err := utils.ValidateNonEmptyString(temp{Field1: "hello"}); err == nil {
+		t.Error("expected untagged strings to be checked")
+	}
+}
+
 func TestBudgets(t *testing.T) {
 	bsV := validBudgets()
 	for _, b := range bsV {
@@ -168,6 +186,7 @@ func validDataTypes() []entity.DataType {
 		{Type: &entity.TextType{}},
 		{Type: &entity.BoolType{}},
 		{Type: &entity.DoubleType{Low: -1, High: 0}},
+		{Type: &entity.DoubleType{Low: 0, High: 0.5}},
 		{Type: &entity.EnumType{Labels: []string{"a", "b", "c"}}},
 	}
 }
@@ -176,6 +195,7 @@ func invalidDataTypes() []entity.DataType {
 	return []entity.DataType{
 		{Type: &entity.DoubleType{Low: 10}},
 		{Type: &entity.DoubleType{Low: -10, High: -11}},
+		{Type: &entity.DoubleType{Low: 0.75, High: 0.5}},
 		{Type: &entity.IntType{Low: 1}},
 		{Type: &entity.IntType{Low: 10, High: 5}},
 		{Type: &entity.EnumType{Labels: []string{"a", "a"}}},
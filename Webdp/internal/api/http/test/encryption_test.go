@@ -0,0 +1,90 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"webdp/internal/encryption"
+)
+
+func masterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, encryption.KEY_SIZE))
+}
+
+func TestDataKeyEncryption(t *testing.T) {
+	keys, err := encryption.NewKeyRing(masterKey(1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dk, wrapped, err := keys.NewDataKey(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`"Bo, Jr",30,Dentist,0.5,true`)
+	data := dk.Encrypt(line)
+	if bytes.Contains(data, []byte("Dentist")) {
+		t.Errorf("expected the line to be encrypted")
+	}
+
+	unwrapped, err := keys.UnwrapDataKey(7, keys.CurrentId(), wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := unwrapped.Decrypt(data); err != nil || !bytes.Equal(plain, line) {
+		t.Errorf("expected the unwrapped key to decrypt the line, got: %q, %v", plain, err)
+	}
+
+	// keys and data are bound to their dataset
+	if _, err := keys.UnwrapDataKey(8, keys.CurrentId(), wrapped); err == nil {
+		t.Errorf("expected a data key of another dataset to be rejected")
+	}
+	other, _, _ := keys.NewDataKey(8)
+	if _, err := other.Decrypt(data); err == nil {
+		t.Errorf("expected data of another dataset to be rejected")
+	}
+
+	for _, bad := range []string{"", "short", base64.StdEncoding.EncodeToString([]byte("sixteen byte key"))} {
+		if _, err := encryption.NewKeyRing(bad, ""); err == nil {
+			t.Errorf("expected master key %q to be rejected", bad)
+		}
+	}
+}
+
+func TestMasterKeyRotation(t *testing.T) {
+	old, _ := encryption.NewKeyRing(masterKey(1), "")
+	dk, wrapped, err := old.NewDataKey(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := dk.Encrypt([]byte("Anna,70,Accountant,1,false"))
+
+	keys, err := encryption.NewKeyRing(masterKey(2), masterKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.CurrentId() == old.CurrentId() {
+		t.Fatalf("expected master keys to have different ids")
+	}
+
+	// data keys of the old master key can be read until they are rotated
+	if _, err := keys.UnwrapDataKey(7, old.CurrentId(), wrapped); err != nil {
+		t.Errorf("expected the old master key to unwrap, got: %v", err)
+	}
+
+	rewrapped, err := keys.RewrapDataKey(7, old.CurrentId(), wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := encryption.NewKeyRing(masterKey(2), "")
+	dk, err = rotated.UnwrapDataKey(7, rotated.CurrentId(), rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := dk.Decrypt(data); err != nil || string(plain) != "Anna,70,Accountant,1,false" {
+		t.Errorf("expected the rotated key to decrypt the data, got: %q, %v", plain, err)
+	}
+	if _, err := rotated.UnwrapDataKey(7, old.CurrentId(), wrapped); err == nil {
+		t.Errorf("expected a key of a removed master key to be rejected")
+	}
+}
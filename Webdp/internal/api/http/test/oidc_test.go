@@ -0,0 +1,233 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+	"webdp/internal/api/http/services"
+	"webdp/internal/oidc"
+	"webdp/internal/signing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// a local identity provider that logs in everyone who asks
+type mockIdp struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdp{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.token(t, jwt.MapClaims{"aud": "webdp", "nonce": idp.nonce})})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// a token for alice in group data-team, claims replace the defaults
+func (idp *mockIdp) token(t *testing.T, claims jwt.MapClaims) string {
+	c := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"sub":                "0001",
+		"aud":                "webdp-api",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "alice",
+		"groups":             []string{"data-team"},
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (idp *mockIdp) provider() *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Issuer:      idp.server.URL,
+		ClientId:    "webdp",
+		RedirectURL: "http://localhost:8000/v2/oidc/callback",
+		Audience:    "webdp-api",
+		RoleGroups: map[string][]string{
+			entity.ADMIN:   {"it-admins"},
+			entity.ANALYST: {"data-team", "interns"},
+		},
+	}, idp.server.Client())
+}
+
+func TestOidcLogin(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := idp.provider()
+
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.challenge, idp.nonce = challenge, "nonce-1"
+
+	login, err := provider.AuthCodeURL("state-1", idp.nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("state") != "state-1" || q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("unexpected login url %s", login)
+	}
+
+	if _, err := provider.Exchange("good-code", "wrong verifier"); !errors.Is(err, httperrors.ErrUnauthorized) {
+		t.Errorf("expected a wrong code verifier to be refused, got: %v", err)
+	}
+	raw, err := provider.Exchange("good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(raw, "nonce-2"); !errors.Is(err, httperrors.ErrUnauthorized) {
+		t.Errorf("expected an id token of another login to be refused, got: %v", err)
+	}
+	id, err := provider.VerifyIDToken(raw, idp.nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "0001" || id.Username != "alice" || id.Name != "alice" || !slices.Equal(id.Groups, []string{"data-team"}) {
+		t.Errorf("unexpected identity %+v", id)
+	}
+}
+
+func TestOidcLoginState(t *testing.T) {
+	idp := newMockIdp(t)
+	k, _ := newEdKey(t)
+	ring, _ := signing.NewKeyRing(k)
+	tokens := services.NewTokenService(postgres.TokenPostgres{}, ring, time.Hour, time.Hour, time.Hour, services.AuditService{})
+	o := services.NewOidcService(idp.provider(), services.UserService{}, tokens, ring)
+
+	_, state, err := o.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := new(jwt.Parser).ParseUnverified(state, &jwt.MapClaims{})
+	if header.Header["kid"] != ring.CurrentId() {
+		t.Errorf("expected the login state to be signed by the key of the access tokens, got kid %v", header.Header["kid"])
+	}
+	if _, err := tokens.ParseAccessToken(state); err == nil {
+		t.Errorf("expected the login state not to be an access token")
+	}
+
+	// the state is read, and only the state of the callback is wrong
+	if _, err := o.CompleteLogin("good-code", "other-state", state, ""); err == nil || !strings.Contains(err.Error(), "state does not match") {
+		t.Errorf("expected the login state to verify, got: %v", err)
+	}
+
+	// an access token, or a state signed with the HMAC secret of the access tokens, is not a login state
+	access, _, _ := tokens.IssueAccessToken(entity.Session{Id: "s1", Handle: "alice"}, []string{entity.ANALYST})
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"state": "state-1", "exp": time.Now().Add(time.Hour).Unix()}).SignedString([]byte("hubbabubbajordgubb"))
+	for name, saved := range map[string]string{"access token": access, "legacy state": legacy} {
+		if _, err := o.CompleteLogin("good-code", "state-1", saved, ""); !errors.Is(err, httperrors.ErrUnauthorized) || !strings.Contains(err.Error(), "not started here") {
+			t.Errorf("expected the %s to be refused as login state, got: %v", name, err)
+		}
+	}
+}
+
+func TestOidcVerifyToken(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := idp.provider()
+
+	raw := idp.token(t, nil)
+	if oidc.Issuer(raw) != idp.server.URL {
+		t.Errorf("expected issuer %s, got %s", idp.server.URL, oidc.Issuer(raw))
+	}
+	if _, err := provider.VerifyToken(raw); err != nil {
+		t.Errorf("expected token to be accepted, got: %v", err)
+	}
+
+	bad := map[string]string{
+		"wrong audience": idp.token(t, jwt.MapClaims{"aud": "other-api"}),
+		"expired":        idp.token(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+		"wrong issuer":   idp.token(t, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"no username":    idp.token(t, jwt.MapClaims{"preferred_username": ""}),
+	}
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": idp.server.URL, "sub": "0001", "aud": "webdp-api", "exp": time.Now().Add(time.Hour).Unix(), "preferred_username": "alice"}).SignedString([]byte("secret"))
+	bad["hmac signed"] = hs
+
+	for name, raw := range bad {
+		if _, err := provider.VerifyToken(raw); !errors.Is(err, httperrors.ErrInvalidToken) {
+			t.Errorf("expected %s token to be refused, got: %v", name, err)
+		}
+	}
+}
+
+func TestOidcRoles(t *testing.T) {
+	provider := newMockIdp(t).provider()
+
+	cases := map[string]struct {
+		groups []string
+		roles  []string
+	}{
+		"no groups":     {nil, []string{}},
+		"unknown group": {[]string{"sales"}, []string{}},
+		"one group":     {[]string{"interns"}, []string{entity.ANALYST}},
+		"both groups":   {[]string{"data-team", "interns"}, []string{entity.ANALYST}},
+		"two roles":     {[]string{"it-admins", "data-team"}, []string{entity.ADMIN, entity.ANALYST}},
+	}
+	for name, c := range cases {
+		if roles := provider.Roles(c.groups); !slices.Equal(roles, c.roles) {
+			t.Errorf("%s: expected roles %v, got %v", name, c.roles, roles)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+)
+
+func TestOrganizationCreate(t *testing.T) {
+	delta := 1e-6
+	good := entity.OrganizationCreate{Handle: "dept-42", Name: "Department 42", BudgetCap: &entity.Budget{Epsilon: 10, Delta: &delta}}
+	if err := good.Valid(); err != nil {
+		t.Errorf("expected organization to be valid, got: %v", err)
+	}
+	good.BudgetCap = nil
+	if err := good.Valid(); err != nil {
+		t.Errorf("expected organization without budget cap to be valid, got: %v", err)
+	}
+
+	negative := -1.0
+	bad := map[string]entity.OrganizationCreate{
+		"no handle":      {Name: good.Name},
+		"no name":        {Handle: good.Handle},
+		"bad handle":     {Handle: "Dept 42", Name: good.Name},
+		"negative cap":   {Handle: good.Handle, Name: good.Name, BudgetCap: &entity.Budget{Epsilon: -1}},
+		"negative delta": {Handle: good.Handle, Name: good.Name, BudgetCap: &entity.Budget{Epsilon: 1, Delta: &negative}},
+	}
+	for name, o := range bad {
+		if err := o.Valid(); !errors.Is(err, httperrors.ErrBadInput) && !errors.Is(err, httperrors.ErrBadFormatting) {
+			t.Errorf("expected organization with %s to be rejected, got: %v", name, err)
+		}
+	}
+}
+
+func TestOrganizationScope(t *testing.T) {
+	key := func(k string) middlewares.DPContextKey { return middlewares.DPContextKey{Key: k} }
+	cases := map[string]struct {
+		organization string
+		permissions  []string
+		admin        bool
+	}{
+		"platform admin":    {entity.DEFAULT_ORGANIZATION, []string{entity.PERM_ORG_MANAGE}, true},
+		"tenant admin":      {"acme", []string{entity.PERM_ORG_MANAGE}, false},
+		"default user":      {entity.DEFAULT_ORGANIZATION, []string{entity.PERM_USER_MANAGE}, false},
+		"no middleware ran": {"", nil, false},
+	}
+	for name, c := range cases {
+		r := httptest.NewRequest("GET", "/v2/organizations", nil)
+		ctx := context.WithValue(r.Context(), key(middlewares.OrganizationContextKey), c.organization)
+		r = r.WithContext(context.WithValue(ctx, key(middlewares.PermissionsContextKey), c.permissions))
+
+		if admin := middlewares.IsPlatformAdmin(r); admin != c.admin {
+			t.Errorf("%s: expected platform admin to be %v", name, c.admin)
+		}
+		if err := middlewares.ValidateOrganization(r, "acme"); (err == nil) != (c.organization == "acme") {
+			t.Errorf("%s: unexpected result validating organization acme: %v", name, err)
+		}
+		if err := middlewares.ValidateOrganization(r, "other"); !errors.Is(err, httperrors.ErrNotFound) {
+			t.Errorf("%s: expected another organization not to be found, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+func readParquetFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join("testdata", "parquet", name))
+}
+
+/*
+Reads files that arrow-go wrote: dictionary pages with snappy, and delta
+encodings with zstd, data pages v2 and two row groups. Nested columns are not
+supported.
+*/
+func TestParquetFiles(t *testing.T) {
+	for _, tc := range []struct {
+		file   string
+		header []string
+		rows   [][]string
+	}{
+		{
+			"people_dictionary_snappy.parquet",
+			[]string{"age", "name", "job", "score", "member"},
+			[][]string{
+				{"20", "Anna", "Dentist", "0.5", "true"},
+				{"35", "", "Accountant", "0.25", "false"},
+				{"50", "Bo", "Dentist", "1", "true"},
+			},
+		},
+		{
+			"delta_zstd_v2.parquet",
+			[]string{"id", "name", "price", "ratio"},
+			[][]string{
+				{"1", "apple", "19.99", "0.1"},
+				{"2", "apricot", "-0.05", "2.5"},
+				{"3", "banana", "", "-1"},
+				{"1000", "band", "1.00", "3"},
+			},
+		},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			file, err := readParquetFile(tc.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, rows, err := readAllRecords(t, entity.FORMAT_PARQUET, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(header, tc.header) {
+				t.Errorf("unexpected header: %v", header)
+			}
+			if !reflect.DeepEqual(rows, tc.rows) {
+				t.Errorf("unexpected rows: %v", rows)
+			}
+		})
+	}
+
+	file, err := readParquetFile("nested_list.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readAllRecords(t, entity.FORMAT_PARQUET, file); !errors.Is(err, httperrors.ErrBadFormatting) {
+		t.Errorf("expected a list column to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/services"
+	"webdp/internal/api/http/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := entity.PasswordPolicy{MinLength: entity.DEFAULT_PASSWORD_MIN_LENGTH, MinClasses: entity.DEFAULT_PASSWORD_MIN_CLASSES}
+
+	for _, good := range []string{"Analyst-pass-1", "correct horse Battery", "Ünïcödé-Wörd1", strings.Repeat("aB1", 24)} {
+		if err := policy.Check("anna", good); err != nil {
+			t.Errorf("expected %q to be accepted, got: %v", good, err)
+		}
+	}
+
+	bad := map[string]string{
+		"too short":           "Ab1-xyz",
+		"too few classes":     "alllowercase1",
+		"contains the handle": "My-Anna-pass-1",
+		"longer than bcrypt":  strings.Repeat("aB1", 24) + "c",
+		"empty":               "",
+	}
+	for name, pwd := range bad {
+		if err := policy.Check("anna", pwd); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected a password that is %s to be rejected, got: %v", name, err)
+		}
+	}
+
+	// a loose policy still takes the other rules
+	loose := entity.PasswordPolicy{MinLength: 1, MinClasses: 1}
+	if err := loose.Check("anna", "x"); err != nil {
+		t.Errorf("expected a loose policy to accept a short password, got: %v", err)
+	}
+	if err := loose.Check("anna", "anna"); err == nil {
+		t.Errorf("expected the handle to be rejected by every policy")
+	}
+}
+
+func TestPasswordRehash(t *testing.T) {
+	hash, err := utils.HashAndSalt("Analyst-pass-1", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.ComparePasswords(hash, "Analyst-pass-1") {
+		t.Errorf("expected the password to match its hash")
+	}
+	if utils.NeedsRehash(hash, bcrypt.MinCost) {
+		t.Errorf("expected a hash of the configured cost to be kept")
+	}
+	if !utils.NeedsRehash(hash, bcrypt.MinCost+1) {
+		t.Errorf("expected a hash of another cost to be rehashed")
+	}
+	if utils.NeedsRehash("not a hash", bcrypt.MinCost) {
+		t.Errorf("expected what is not a bcrypt hash to be left alone")
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	throttle := services.NewLoginThrottle(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		throttle.Fail("10.0.0.1", now.Add(time.Duration(i)*time.Second))
+	}
+	if _, blocked := throttle.Blocked("10.0.0.1", now.Add(2*time.Second)); blocked {
+		t.Errorf("expected an address below the limit to be let through")
+	}
+
+	throttle.Fail("10.0.0.1", now.Add(2*time.Second))
+	until, blocked := throttle.Blocked("10.0.0.1", now.Add(3*time.Second))
+	if !blocked || !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the address to be blocked until a minute after its first failure, got %v %v", until, blocked)
+	}
+	if _, blocked := throttle.Blocked("10.0.0.2", now.Add(3*time.Second)); blocked {
+		t.Errorf("expected other addresses to be let through")
+	}
+	if _, blocked := throttle.Blocked("10.0.0.1", now.Add(time.Minute)); blocked {
+		t.Errorf("expected the address to be let through after the window")
+	}
+
+	// failures in an earlier window do not count
+	throttle.Fail("10.0.0.3", now)
+	throttle.Fail("10.0.0.3", now.Add(30*time.Second))
+	throttle.Fail("10.0.0.3", now.Add(90*time.Second))
+	if _, blocked := throttle.Blocked("10.0.0.3", now.Add(91*time.Second)); blocked {
+		t.Errorf("expected failures of an earlier window to be forgotten")
+	}
+}
+
+func TestCredentialsLocked(t *testing.T) {
+	now := time.Now()
+	until := now.Add(time.Minute)
+	if (entity.Credentials{}).Locked(now) {
+		t.Errorf("expected an account without a lock to be open")
+	}
+	if !(entity.Credentials{LockedUntil: &until}).Locked(now) {
+		t.Errorf("expected the account to be locked until the time")
+	}
+	if (entity.Credentials{LockedUntil: &until}).Locked(until) {
+		t.Errorf("expected the account to be open at the end of the lock")
+	}
+}
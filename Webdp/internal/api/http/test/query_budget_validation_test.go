@@ -52,3 +52,53 @@ func readQs(path string) []entity.QueryEvaluate {
 	}
 	return qs
 }
+
+func TestQuantileRanksRoundTrip(t *testing.T) {
+	var q entity.Query
+	err := json.Unmarshal([]byte(`[{"quantile": {"column": "age", "mech": "Laplace", "ranks": [0.1, 0.9]}}]`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back []map[string]map[string]interface{}
+	if err := json.Unmarshal(js, &back); err != nil {
+		t.Fatal(err)
+	}
+
+	ranks, ok := back[0]["quantile"]["ranks"].([]interface{})
+	if !ok || len(ranks) != 2 {
+		t.Errorf("expected ranks to be passed on to the engines, got: %s", string(js))
+	}
+}
+
+func TestValuePolicyValidation(t *testing.T) {
+	var q entity.QueryEvaluate
+	err := json.Unmarshal([]byte(`{
+		"dataset": 1,
+		"budget": {"epsilon": 1},
+		"query": [{"count": {"column": "age", "mech": "Laplace"}}],
+		"value_policies": {"age": {"null": "impute", "invalid": "drop", "impute": 30}}
+	}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Valid(); err != nil {
+		t.Errorf("expected valid value policies, got: %s", err)
+	}
+
+	q.ValuePolicies["age"] = entity.ValuePolicy{Null: "impute", Invalid: "drop"}
+	if err := q.Valid(); err == nil {
+		t.Errorf("expected impute without value to be rejected")
+	}
+
+	q.ValuePolicies["age"] = entity.ValuePolicy{Null: "skip", Invalid: "drop"}
+	if err := q.Valid(); err == nil {
+		t.Errorf("expected unknown policy to be rejected")
+	}
+}
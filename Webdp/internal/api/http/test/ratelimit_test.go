@@ -0,0 +1,100 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := entity.ParseRateLimits("")
+	if err != nil || limits.Default[entity.RATE_CLASS_QUERY] != entity.DEFAULT_RATE_LIMIT_QUERY {
+		t.Errorf("expected the default limits without a spec, got %v %v", limits, err)
+	}
+
+	limits, err = entity.ParseRateLimits(" query=10, role:Curator:query=40,role:Admin:write=0,user:anna:read=5 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Default[entity.RATE_CLASS_QUERY] != 10 || limits.Default[entity.RATE_CLASS_READ] != entity.DEFAULT_RATE_LIMIT_READ {
+		t.Errorf("expected the query limit to be set and the others to keep their default, got %v", limits.Default)
+	}
+	if limits.Roles[entity.CURATOR][entity.RATE_CLASS_QUERY] != 40 || limits.Users["anna"][entity.RATE_CLASS_READ] != 5 {
+		t.Errorf("expected the overrides of the role and the user, got %v %v", limits.Roles, limits.Users)
+	}
+
+	for _, bad := range []string{"query", "query=-1", "query=many", "upload=10", "role::query=1", "group:x:query=1", "role:Analyst:x:query=1"} {
+		if _, err := entity.ParseRateLimits(bad); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestRateLimitsLimit(t *testing.T) {
+	limits, err := entity.ParseRateLimits("query=10,role:Curator:query=40,role:Analyst:query=20,role:Admin:write=0,user:anna:query=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		handle string
+		roles  []string
+		class  string
+		limit  int
+	}{
+		{"the default", "bob", []string{"Custom"}, entity.RATE_CLASS_QUERY, 10},
+		{"the limit of the role", "bob", []string{entity.ANALYST}, entity.RATE_CLASS_QUERY, 20},
+		{"the highest limit of the roles", "bob", []string{entity.ANALYST, entity.CURATOR}, entity.RATE_CLASS_QUERY, 40},
+		{"the limit of the user over its roles", "anna", []string{entity.CURATOR}, entity.RATE_CLASS_QUERY, 5},
+		{"no limit of a role", "bob", []string{entity.ANALYST, entity.ADMIN}, entity.RATE_CLASS_WRITE, 0},
+		{"the default of other classes", "anna", []string{entity.ANALYST}, entity.RATE_CLASS_READ, entity.DEFAULT_RATE_LIMIT_READ},
+	}
+	for _, c := range cases {
+		if limit := limits.Limit(c.handle, c.roles, c.class); limit != c.limit {
+			t.Errorf("expected %s, %d, got %d", c.name, c.limit, limit)
+		}
+	}
+}
+
+func TestRateClass(t *testing.T) {
+	cases := map[string]string{
+		"POST /v2/queries/evaluate":   entity.RATE_CLASS_QUERY,
+		"POST /v2/queries/validate":   entity.RATE_CLASS_QUERY,
+		"POST /v1/query/accuracy":     entity.RATE_CLASS_QUERY,
+		"GET /v2/queries/functions":   entity.RATE_CLASS_READ,
+		"GET /v2/datasets/1":          entity.RATE_CLASS_READ,
+		"POST /v2/datasets":           entity.RATE_CLASS_WRITE,
+		"DELETE /v2/sessions/s1":      entity.RATE_CLASS_WRITE,
+		"PATCH /v2/datasets/1/schema": entity.RATE_CLASS_WRITE,
+	}
+	for request, class := range cases {
+		method, path, _ := strings.Cut(request, " ")
+		if got := middlewares.RateClass(httptest.NewRequest(method, path, nil)); got != class {
+			t.Errorf("expected %s to be of class %s, got %s", request, class, got)
+		}
+	}
+}
+
+func TestQueryQuota(t *testing.T) {
+	day, next := entity.QuotaDay(time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("CET", 3600)))
+	if !day.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !next.Equal(day.Add(24*time.Hour)) {
+		t.Errorf("expected the quota day in UTC, got %v %v", day, next)
+	}
+
+	none, negative := 0, -1
+	if err := (entity.QueryQuotaPut{DailyQueries: &none}).Valid(); err != nil {
+		t.Errorf("expected a quota of 0 to be no quota, got %v", err)
+	}
+	if err := (entity.QueryQuotaPut{}).Valid(); err != nil {
+		t.Errorf("expected no quota to be the default quota, got %v", err)
+	}
+	if err := (entity.QueryQuotaPut{DailyQueries: &negative}).Valid(); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected a negative quota to be rejected, got %v", err)
+	}
+}
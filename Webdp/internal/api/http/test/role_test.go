@@ -0,0 +1,73 @@
+package test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/middlewares"
+)
+
+func TestRoleCreate(t *testing.T) {
+	good := entity.RoleCreate{Name: "Data-steward", Description: "Reads everything", Permissions: []string{entity.PERM_DATASET_READ, entity.PERM_BUDGET_READ}}
+	if err := good.Valid(); err != nil {
+		t.Errorf("expected role to be valid, got: %v", err)
+	}
+
+	bad := map[string]entity.RoleCreate{
+		"no name":            {Permissions: good.Permissions},
+		"bad name":           {Name: "data steward", Permissions: good.Permissions},
+		"no permissions":     {Name: good.Name},
+		"unknown permission": {Name: good.Name, Permissions: []string{"dataset.everything"}},
+		"twice":              {Name: good.Name, Permissions: []string{entity.PERM_QUERY_RUN, entity.PERM_QUERY_RUN}},
+	}
+	for name, r := range bad {
+		if err := r.Valid(); !errors.Is(err, httperrors.ErrBadInput) && !errors.Is(err, httperrors.ErrBadFormatting) {
+			t.Errorf("expected role with %s to be rejected, got: %v", name, err)
+		}
+	}
+}
+
+func TestUserCustomRoles(t *testing.T) {
+	user := entity.UserPost{Handle: "sam", Name: "Sam", PWD: "pass", Roles: []string{entity.ANALYST, "Data-steward"}}
+	if err := user.Valid(); err != nil {
+		t.Errorf("expected custom role to be accepted, got: %v", err)
+	}
+
+	user.Roles = []string{entity.ANALYST, entity.ANALYST}
+	if err := user.Valid(); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected a role given twice to be rejected, got: %v", err)
+	}
+}
+
+func TestPermissionsOf(t *testing.T) {
+	defined := []entity.Role{
+		{Name: entity.ANALYST, Permissions: []string{entity.PERM_QUERY_RUN, entity.PERM_BUDGET_ALLOCATE}},
+		{Name: "Steward", Permissions: []string{entity.PERM_DATASET_READ, entity.PERM_QUERY_RUN}},
+	}
+
+	cases := map[string]struct {
+		roles       []string
+		permissions []string
+	}{
+		"no roles":     {nil, []string{}},
+		"unknown role": {[]string{"Gone"}, []string{}},
+		"one role":     {[]string{"Steward"}, []string{entity.PERM_DATASET_READ, entity.PERM_QUERY_RUN}},
+		"union":        {[]string{entity.ANALYST, "Steward"}, []string{entity.PERM_DATASET_READ, entity.PERM_BUDGET_ALLOCATE, entity.PERM_QUERY_RUN}},
+	}
+	for name, c := range cases {
+		if ps := entity.PermissionsOf(defined, c.roles); !slices.Equal(ps, c.permissions) {
+			t.Errorf("%s: expected permissions %v, got %v", name, c.permissions, ps)
+		}
+	}
+}
+
+func TestValidatePermission(t *testing.T) {
+	// requests that did not pass the policy middleware have no permissions
+	r := httptest.NewRequest("GET", "/v2/users", nil)
+	if err := middlewares.ValidatePermission(r, entity.PERM_USER_READ); !errors.Is(err, httperrors.ErrForbidden) {
+		t.Errorf("expected request without permissions to be forbidden, got: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+func parseSchemaPatch(t *testing.T, body string) entity.SchemaPatch {
+	var patch entity.SchemaPatch
+	if err := json.Unmarshal([]byte(body), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+func TestSchemaPatchApply(t *testing.T) {
+	schema := uploadTestSchema()
+
+	patch := parseSchemaPatch(t, `{
+		"drop": ["job"],
+		"update": [{"name": "age", "type": {"name": "Int", "low": 18, "high": 65}}],
+		"add": [{"name": "city", "type": {"name": "Enum", "labels": ["Gothenburg", "Stockholm"]}, "default": "Gothenburg"}]
+	}`)
+	out, err := patch.Apply(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, col := range out {
+		names = append(names, col.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"name", "age", "score", "member", "city"}) {
+		t.Errorf("unexpected columns: %v", names)
+	}
+	if !reflect.DeepEqual(out[1].Type.Type, &entity.IntType{Low: 18, High: 65}) {
+		t.Errorf("expected the bounds of age to be updated, got: %v", out[1].Type.Type)
+	}
+	if len(schema) != 5 || schema[2].Name != "job" {
+		t.Errorf("expected the old schema to be left as is, got: %v", schema)
+	}
+
+	for _, bad := range []string{
+		`{"drop": ["height"]}`,
+		`{"update": [{"name": "height", "type": {"name": "Text"}}]}`,
+		`{"update": [{"name": "age", "type": {"name": "Double", "low": 0, "high": 1}}]}`,
+		`{"add": [{"name": "age", "type": {"name": "Text"}}]}`,
+		`{"drop": ["name", "age", "job", "score", "member"]}`,
+	} {
+		if _, err := parseSchemaPatch(t, bad).Apply(schema); !errors.Is(err, httperrors.ErrBadInput) {
+			t.Errorf("expected %s to be rejected, got: %v", bad, err)
+		}
+	}
+
+	if err := (entity.SchemaPatch{}).Valid(); err == nil {
+		t.Errorf("expected an empty patch to be rejected")
+	}
+}
+
+func TestSchemaRewriter(t *testing.T) {
+	patch := parseSchemaPatch(t, `{
+		"drop": ["job"],
+		"update": [{"name": "age", "type": {"name": "Int", "low": 18, "high": 65}}],
+		"add": [{"name": "city", "type": {"name": "Text"}, "default": "Gothenburg"}]
+	}`)
+	schema, err := patch.Apply(uploadTestSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewrite := func(invalid string) ([]string, *entity.SchemaRewriter) {
+		rw, err := entity.NewSchemaRewriter("name,age,job,score,member", schema, patch, invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := rw.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := []string{header}
+		for _, line := range []string{`"Bo, Jr",30,Dentist,0.5,true`, `Anna,70,Accountant,1,false`} {
+			out, ok, err := rw.Row(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				lines = append(lines, out)
+			}
+		}
+		return lines, rw
+	}
+
+	_, rw := rewrite("")
+	if rw.Err() == nil || !errors.Is(rw.Err(), httperrors.ErrBadInput) {
+		t.Errorf("expected the narrowed bounds to reject the loaded data, got: %v", rw.Err())
+	}
+
+	lines, rw := rewrite(entity.UPLOAD_CLAMP)
+	expected := []string{"name,age,score,member,city", `"Bo, Jr",30,0.5,true,Gothenburg`, "Anna,65,1,false,Gothenburg"}
+	if rw.Err() != nil || !reflect.DeepEqual(lines, expected) {
+		t.Errorf("unexpected rewritten rows: %v, %v", lines, rw.Err())
+	}
+	if report := rw.Report(); report.Rows != 2 || report.ClampedCells != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// added columns need a default for the loaded rows
+	patch = parseSchemaPatch(t, `{"add": [{"name": "city", "type": {"name": "Text"}}]}`)
+	schema, _ = patch.Apply(uploadTestSchema())
+	if _, err := entity.NewSchemaRewriter("name,age,job,score,member", schema, patch, ""); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected a missing default to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"webdp/internal/api/http/entity"
+)
+
+func inferSchema(t *testing.T, csv string, rows int) ([]entity.InferredColumn, int) {
+	reader, err := entity.NewRecordReader(entity.FORMAT_CSV, strings.NewReader(csv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	schema, n, err := entity.InferSchema(reader, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, n
+}
+
+func TestInferSchema(t *testing.T) {
+	csv := "age,name,job,score,member,empty\n" +
+		"18,Anna,Dentist,0.13,true,\n" +
+		"93,Bo,Accountant,0.97,FALSE,\n" +
+		"42,Cy,Dentist,0.5,true,\n" +
+		"57,Di,Accountant,0.25,false,\n"
+
+	schema, rows := inferSchema(t, csv, entity.DEFAULT_SAMPLE_ROWS)
+	if rows != 4 {
+		t.Errorf("expected 4 sample rows, got: %d", rows)
+	}
+
+	expected := []entity.InferredColumn{
+		{ColumnSchema: entity.ColumnSchema{Name: "age", Type: entity.DataType{Type: &entity.IntType{Low: 0, High: 100}}}, ConfirmBounds: true},
+		{ColumnSchema: entity.ColumnSchema{Name: "name", Type: entity.DataType{Type: &entity.TextType{}}}},
+		// labels that occur less than 5 times are not proposed
+		{ColumnSchema: entity.ColumnSchema{Name: "job", Type: entity.DataType{Type: &entity.TextType{}}}},
+		{ColumnSchema: entity.ColumnSchema{Name: "score", Type: entity.DataType{Type: &entity.DoubleType{Low: 0, High: 1}}}, ConfirmBounds: true},
+		{ColumnSchema: entity.ColumnSchema{Name: "member", Type: entity.DataType{Type: &entity.BoolType{}}}},
+		{ColumnSchema: entity.ColumnSchema{Name: "empty", Type: entity.DataType{Type: &entity.TextType{}}}},
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		got, _ := json.Marshal(schema)
+		t.Errorf("unexpected schema: %s", got)
+	}
+
+	// only the first rows are read
+	schema, rows = inferSchema(t, "n\n1\n2\nx\n", 2)
+	if _, ok := schema[0].Type.Type.(*entity.IntType); !ok || rows != 2 {
+		t.Errorf("expected an Int column from 2 rows, got: %v from %d rows", schema[0].Type.Type, rows)
+	}
+}
+
+func TestInferSchemaEnumLabels(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("job,city\n")
+	for i := 0; i < 15; i++ {
+		csv.WriteString([]string{"Dentist,Lund\n", "Accountant,Lund\n", "Nurse,Malmo\n"}[i%3])
+	}
+	csv.WriteString("Astronaut,Kiruna\nAstronaut,Lund\n")
+
+	schema, _ := inferSchema(t, csv.String(), entity.DEFAULT_SAMPLE_ROWS)
+	expected := []entity.InferredColumn{
+		{ColumnSchema: entity.ColumnSchema{Name: "job", Type: entity.DataType{Type: &entity.EnumType{Labels: []string{"Accountant", "Dentist", "Nurse"}}}}, ConfirmBounds: true, RareLabels: 1},
+		{ColumnSchema: entity.ColumnSchema{Name: "city", Type: entity.DataType{Type: &entity.EnumType{Labels: []string{"Lund", "Malmo"}}}}, ConfirmBounds: true, RareLabels: 1},
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		got, _ := json.Marshal(schema)
+		t.Errorf("unexpected schema: %s", got)
+	}
+
+	// labels that each occur a few times are not categories, however few they are
+	schema, _ = inferSchema(t, "job\nDentist\nDentist\nNurse\nNurse\nNurse\nAstronaut\n", entity.DEFAULT_SAMPLE_ROWS)
+	if _, ok := schema[0].Type.Type.(*entity.TextType); !ok {
+		t.Errorf("expected a Text column, got: %v", schema[0].Type.Type)
+	}
+}
+
+func TestInferSchemaProposalIsDatasetCreate(t *testing.T) {
+	schema, rows := inferSchema(t, "height,weight\n-3,1250\n7,980\n", entity.DEFAULT_SAMPLE_ROWS)
+
+	delta := 1e-6
+	proposal := entity.SchemaProposal{Name: "sample", Owner: "curator", Schema: schema, PrivacyNotion: entity.APPROX, TotalBudget: &entity.Budget{Epsilon: 1, Delta: &delta}, SampleRows: rows}
+	body, err := json.Marshal(proposal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var create entity.DatasetCreate
+	if err := json.Unmarshal(body, &create); err != nil {
+		t.Fatal(err)
+	}
+	if err := create.Valid(); err != nil {
+		t.Errorf("expected the proposal to be a valid dataset, got: %v", err)
+	}
+	if !reflect.DeepEqual(create.Schema[0].Type.Type, &entity.IntType{Low: -5, High: 10}) || !reflect.DeepEqual(create.Schema[1].Type.Type, &entity.IntType{Low: 0, High: 5000}) {
+		t.Errorf("unexpected bounds: %s", body)
+	}
+}
+
+func TestInferSchemaRejectsDuplicateColumns(t *testing.T) {
+	reader, err := entity.NewRecordReader(entity.FORMAT_CSV, strings.NewReader("a,a\n1,2\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := entity.InferSchema(reader, 10); err == nil {
+		t.Errorf("expected duplicate column names to be rejected")
+	}
+}
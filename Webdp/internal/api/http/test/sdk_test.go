@@ -0,0 +1,290 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"webdp/internal/api/http/entity"
+	"webdp/sdk"
+)
+
+// the API with anna and bob, who are curators, and a client of anna
+func sdkLogin(t *testing.T) (*testApi, *sdk.Client) {
+	a := newTestApi(t)
+	a.user(t, "anna", entity.CURATOR)
+	a.user(t, "bob", entity.CURATOR)
+	c := sdk.NewClient(a.server.URL+"/", sdk.WithHTTPClient(a.server.Client()))
+	if err := c.Login(context.Background(), sdk.LoginRequest{Username: "anna", PWD: TEST_PASSWORD}); err != nil {
+		t.Fatal(err)
+	}
+	return a, c
+}
+
+// a dataset of the client with a column age
+func sdkDataset(t *testing.T, c *sdk.Client, name string, owner string) int64 {
+	schema := []sdk.ColumnSchema{{Name: "age", Type: sdk.IntType(0, 120)}}
+	id, err := c.CreateDataset(context.Background(), sdk.DatasetCreate{Name: name, Owner: owner, Schema: schema, PrivacyNotion: sdk.PURE, TotalBudget: sdk.Budget{Epsilon: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSdkLogin(t *testing.T) {
+	a, _ := sdkLogin(t)
+	ctx := context.Background()
+
+	c := sdk.NewClient(a.server.URL, sdk.WithHTTPClient(a.server.Client()))
+	if _, err := c.User(ctx, "anna"); !errors.Is(err, sdk.ErrUnauthorized) {
+		t.Errorf("expected a client that did not log in to be unauthorized, got %v", err)
+	}
+	err := c.Login(ctx, sdk.LoginRequest{Username: "anna", PWD: "wrong"})
+	var apiErr *sdk.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Title != "Unauthorized" {
+		t.Errorf("expected the error of the login, got %v", err)
+	}
+
+	if err := c.Login(ctx, sdk.LoginRequest{Username: "anna", PWD: TEST_PASSWORD}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.User(ctx, "anna")
+	if err != nil || user.Handle != "anna" || !reflect.DeepEqual(user.Roles, []string{entity.CURATOR}) {
+		t.Errorf("expected the user with the token of the login, got %v %v", user, err)
+	}
+}
+
+func TestSdkRefresh(t *testing.T) {
+	a, c := sdkLogin(t)
+	ctx := context.Background()
+
+	// an access token about to expire is refreshed before the request
+	expiring := c.Tokens()
+	expiring.ExpiresAt = time.Now().Add(sdk.REFRESH_MARGIN / 2).Unix()
+	c.SetTokens(expiring)
+	if _, err := c.User(ctx, "anna"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Tokens().RefreshToken == expiring.RefreshToken || a.count("POST /v2/refresh") != 1 {
+		t.Errorf("expected the tokens to be refreshed once, got %d refreshes", a.count("POST /v2/refresh"))
+	}
+
+	// an access token the API refuses is refreshed and the request sent again
+	refused := c.Tokens()
+	refused.Token = "not a token"
+	c.SetTokens(refused)
+	if _, err := c.User(ctx, "anna"); err != nil {
+		t.Errorf("expected the request to succeed after a refresh, got %v", err)
+	}
+	if a.count("POST /v2/refresh") != 2 || a.count("GET /v2/users/anna") != 3 {
+		t.Errorf("expected a refresh after the token was refused, got %d refreshes", a.count("POST /v2/refresh"))
+	}
+
+	// a refresh token that was used is refused, and so is the request
+	used := c.Tokens()
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	used.ExpiresAt = 0
+	c.SetTokens(used)
+	if _, err := c.User(ctx, "anna"); !errors.Is(err, sdk.ErrUnauthorized) {
+		t.Errorf("expected a used refresh token to be refused, got %v", err)
+	}
+}
+
+func TestSdkErrors(t *testing.T) {
+	_, c := sdkLogin(t)
+	ctx := context.Background()
+
+	if _, err := c.User(ctx, "carol"); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("expected an unknown user to be not found, got %v", err)
+	}
+
+	others := sdkDataset(t, c, "others", "bob")
+	if _, err := c.Upload(ctx, others, strings.NewReader("age\n30\n"), sdk.UploadOptions{}); !errors.Is(err, sdk.ErrForbidden) {
+		t.Errorf("expected an upload to the dataset of another user to be forbidden, got %v", err)
+	}
+
+	id := sdkDataset(t, c, "own", "anna")
+	if err := c.Allocate(ctx, "anna", id, sdk.Budget{Epsilon: -1}); !errors.Is(err, sdk.ErrBadRequest) {
+		t.Errorf("expected a negative budget to be a bad request, got %v", err)
+	}
+
+	if _, err := c.Upload(ctx, id, strings.NewReader("age\n30\n"), sdk.UploadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Allocate(ctx, "anna", id, sdk.Budget{Epsilon: 1}); err != nil {
+		t.Fatal(err)
+	}
+	daily := 1
+	if err := c.SetQueryQuota(ctx, "anna", id, sdk.QueryQuotaPut{DailyQueries: &daily}); err != nil {
+		t.Fatal(err)
+	}
+	query := sdk.QueryEvaluate{Dataset: id, Budget: sdk.Budget{Epsilon: 0.1}, Query: sdk.NewQuery().Count("age").Query()}
+	if _, err := c.Evaluate(ctx, query, ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Evaluate(ctx, query, "")
+	var apiErr *sdk.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, sdk.ErrTooManyRequests) || apiErr.RetryAfter <= 0 || apiErr.RetryAfter > 25*time.Hour {
+		t.Errorf("expected the query over the quota to be retried after the quota resets, got %v", err)
+	}
+	if apiErr != nil && (apiErr.Title != "Too Many Requests" || !strings.Contains(apiErr.Detail, "queries per day")) {
+		t.Errorf("expected the title and detail of the response, got %q %q", apiErr.Title, apiErr.Detail)
+	}
+}
+
+// the client shares the types of the API, but must not pull in the services and the database of the server
+func TestSdkDependencies(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", "webdp/sdk").Output()
+	if err != nil {
+		t.Skipf("go list: %v", err)
+	}
+	types := map[string]bool{
+		"webdp/sdk":                        true,
+		"webdp/internal/api/http":          true,
+		"webdp/internal/api/http/entity":   true,
+		"webdp/internal/api/http/response": true,
+		"webdp/internal/api/http/client":   true,
+		"webdp/internal/api/http/utils":    true,
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		if (strings.HasPrefix(pkg, "webdp/") && !types[pkg]) || pkg == "github.com/lib/pq" {
+			t.Errorf("expected the sdk not to depend on %s", pkg)
+		}
+	}
+}
+
+func TestSdkQueryBuilder(t *testing.T) {
+	delta := 1e-6
+	q := sdk.NewQuery().
+		Select("age", "income").
+		Filter("age > 18", "income < 100000").
+		Rename(map[string]string{"income": "salary"}).
+		Bin(map[string][]any{"age": {18, 40, 65}}).
+		GroupBy(map[string][]any{"age": {18, 40}}).
+		Count("age").
+		Mean("salary", sdk.Mech("Laplace"), sdk.WithBudget(sdk.Budget{Epsilon: 0.5, Delta: &delta})).
+		Quantile("salary", []float64{0.25, 0.75}).
+		Query()
+
+	bs, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"select":["age","income"]},{"filter":["age > 18","income < 100000"]},{"rename":{"income":"salary"}},` +
+		`{"bin":{"age":[18,40,65]}},{"groupby":{"age":[18,40]}},{"count":{"column":"age"}},` +
+		`{"mean":{"column":"salary","mech":"Laplace","budget":{"epsilon":0.5,"delta":0.000001}}},` +
+		`{"quantile":{"column":"salary","ranks":[0.25,0.75]}}]`
+	var got, want any
+	if err := json.Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the steps of the query in order\n%s\ngot\n%s", expected, bs)
+	}
+
+	var read entity.Query
+	if err := json.Unmarshal(bs, &read); err != nil || len(read.QuerySteps) != len(q.QuerySteps) {
+		t.Errorf("expected the API to read the query back, got %v %v", read, err)
+	}
+}
+
+func TestSdkRoundTrip(t *testing.T) {
+	a, c := sdkLogin(t)
+	ctx := context.Background()
+
+	ids := []int64{}
+	for _, name := range []string{"first", "second", "third"} {
+		ids = append(ids, sdkDataset(t, c, name, "anna"))
+	}
+	sdkDataset(t, c, "others", "bob")
+	page, err := c.Datasets(ctx, sdk.DatasetFilter{Owner: "anna", Limit: 2, Offset: 1})
+	if err != nil || page.Total != 3 || len(page.Datasets) != 2 || page.Datasets[0].Name != "second" {
+		t.Errorf("expected the page of the filter and the total, got %v %v", page, err)
+	}
+
+	data := "age\n30\n141\n"
+	report, err := c.Upload(ctx, ids[0], strings.NewReader(data), sdk.UploadOptions{Format: sdk.FORMAT_CSV, Invalid: sdk.UPLOAD_CLAMP})
+	if err != nil || report == nil || report.Rows != 2 || report.ClampedCells != 1 {
+		t.Errorf("expected the report of the upload, got %v %v", report, err)
+	}
+	if report, err := c.Upload(ctx, ids[0], strings.NewReader("age\n41\n"), sdk.UploadOptions{Mode: sdk.VERSION_APPEND}); err != nil || report != nil {
+		t.Errorf("expected no report without invalid, got %v %v", report, err)
+	}
+	if _, err := c.Upload(ctx, ids[0], strings.NewReader(data), sdk.UploadOptions{Mode: "merge"}); !errors.Is(err, sdk.ErrBadRequest) {
+		t.Errorf("expected an unknown mode to be a bad request, got %v", err)
+	}
+
+	large := "age\n" + strings.Repeat("42\n", 1000)
+	if _, err := c.UploadResumable(ctx, ids[1], strings.NewReader(large), sdk.UploadOptions{ChunkSize: 512}); err != nil {
+		t.Fatal(err)
+	}
+	if n := a.count(fmt.Sprintf("PUT /v2/datasets/%d/uploads/1", ids[1])); n != (len(large)+511)/512 {
+		t.Errorf("expected the data to be sent in chunks of 512 bytes, got %d chunks", n)
+	}
+
+	dataset, err := c.Dataset(ctx, ids[1])
+	schema := []sdk.ColumnSchema{{Name: "age", Type: sdk.IntType(0, 120)}}
+	if err != nil || !dataset.Loaded || !reflect.DeepEqual(dataset.Schema, schema) {
+		t.Errorf("expected the loaded dataset, got %v %v", dataset, err)
+	}
+
+	if err := c.Allocate(ctx, "anna", ids[1], sdk.Budget{Epsilon: 1}); err != nil {
+		t.Fatal(err)
+	}
+	query := sdk.QueryEvaluate{Dataset: ids[1], Budget: sdk.Budget{Epsilon: 0.1}, Query: sdk.NewQuery().Count("age").Query()}
+	result, err := c.Evaluate(ctx, query, "")
+	if err != nil || result["count"] != float64(1000) {
+		t.Errorf("expected the engine to count the rows of the upload, got %v %v", result, err)
+	}
+	a.mu.Lock()
+	evaluated := a.queries
+	a.mu.Unlock()
+	if len(evaluated) != 1 || evaluated[0].Data != ids[1] || len(evaluated[0].Query.QuerySteps) != 1 {
+		t.Errorf("expected the engine to get the query, got %v", evaluated)
+	}
+	budget, err := c.Allocation(ctx, "anna", ids[1])
+	if err != nil || budget.Epsilon != 1 {
+		t.Errorf("expected the allocation, got %v %v", budget, err)
+	}
+}
+
+func TestSdkResumeUpload(t *testing.T) {
+	a, c := sdkLogin(t)
+	ctx := context.Background()
+	id := sdkDataset(t, c, "first", "anna")
+
+	data := []byte("age\n" + strings.Repeat("42\n", 100))
+	_, err := c.UploadResumable(ctx, id, io.MultiReader(bytes.NewReader(data[:100]), iotestErrReader{}), sdk.UploadOptions{ChunkSize: 64})
+	var uploadErr *sdk.UploadError
+	if !errors.As(err, &uploadErr) || uploadErr.Session.Size != 100 {
+		t.Fatalf("expected the upload to stop after the data that was read, got %v", err)
+	}
+
+	if _, err := c.ResumeUpload(ctx, id, uploadErr.Session.Id, bytes.NewReader(data), sdk.UploadOptions{ChunkSize: 64}); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := a.services.Datasets.GetDataVersions(id)
+	if err != nil || len(versions) != 1 || versions[0].Rows != 100 {
+		t.Errorf("expected the resumed upload to make up the data, got %v %v", versions, err)
+	}
+}
+
+// fails reading, as a connection that drops
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/response"
+	"webdp/internal/api/http/services"
+)
+
+func TestLoginDevice(t *testing.T) {
+	for _, device := range []string{"", "notebook", strings.Repeat("d", entity.MAX_DEVICE_LENGTH)} {
+		if err := (entity.LoginRequest{Username: "curt", PWD: "123", Device: device}).Valid(); err != nil {
+			t.Errorf("expected device %q to be valid, got: %v", device, err)
+		}
+	}
+
+	login := entity.LoginRequest{Username: "curt", PWD: "123", Device: strings.Repeat("d", entity.MAX_DEVICE_LENGTH+1)}
+	if err := login.Valid(); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected a long device to be rejected, got: %v", err)
+	}
+}
+
+func TestTokenClaimsSession(t *testing.T) {
+	c := services.JWTTokenClaims{Handle: "curt"}
+	c.ExpiresAt = 1
+	if err := c.Valid(); !errors.Is(err, httperrors.ErrInvalidToken) {
+		t.Errorf("expected a token without session to be invalid, got: %v", err)
+	}
+
+	c.Id = "session"
+	if err := c.Valid(); err != nil {
+		t.Errorf("expected a token with session to be valid, got: %v", err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	refresh, max := 24*time.Hour, 7*24*time.Hour
+
+	if e := entity.SessionExpiry(created, created, refresh, max); !e.Equal(created.Add(refresh)) {
+		t.Errorf("expected a new session to last the refresh lifetime, got %v", e)
+	}
+	if e := entity.SessionExpiry(created, created.Add(3*24*time.Hour), refresh, max); !e.Equal(created.Add(4 * 24 * time.Hour)) {
+		t.Errorf("expected a refresh to extend the session by the refresh lifetime, got %v", e)
+	}
+	if e := entity.SessionExpiry(created, created.Add(6*24*time.Hour+time.Hour), refresh, max); !e.Equal(created.Add(max)) {
+		t.Errorf("expected a refresh not to extend the session beyond the max lifetime, got %v", e)
+	}
+	if e := entity.SessionExpiry(created, created, refresh, time.Hour); !e.Equal(created.Add(time.Hour)) {
+		t.Errorf("expected a max lifetime below the refresh lifetime to end the session first, got %v", e)
+	}
+}
+
+func TestSessionMaxLifetime(t *testing.T) {
+	a := newTestApi(t)
+	a.user(t, "ann", entity.CURATOR)
+
+	var token response.Token
+	a.call(t, "", http.MethodPost, "/v2/login", entity.LoginRequest{Username: "ann", PWD: TEST_PASSWORD}, http.StatusOK, &token)
+	age := func(d time.Duration) time.Time {
+		created := time.Now().UTC().Add(-d)
+		if _, err := a.db.Exec("UPDATE UserSessions SET created_time = $1 WHERE username = 'ann'", created); err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+
+	// the login was an hour short of the max lifetime ago, the refresh only extends the session by that hour
+	created := age(TEST_SESSION_LIFETIME - time.Hour)
+	a.call(t, "", http.MethodPost, "/v2/refresh", entity.RefreshRequest{RefreshToken: token.RefreshToken}, http.StatusOK, &token)
+	var sessions []entity.Session
+	a.call(t, token.Token, http.MethodGet, "/v2/sessions", nil, http.StatusOK, &sessions)
+	if len(sessions) != 1 || sessions[0].ExpiresOn.Sub(created.Add(TEST_SESSION_LIFETIME)).Abs() > time.Second {
+		t.Errorf("expected the session to end at the max lifetime after the login, got %+v", sessions)
+	}
+
+	// refreshing keeps a session alive up to the max lifetime only
+	age(TEST_SESSION_LIFETIME + time.Minute)
+	if s := a.status(t, "", http.MethodPost, "/v2/refresh", entity.RefreshRequest{RefreshToken: token.RefreshToken}); s != http.StatusUnauthorized {
+		t.Errorf("expected a session past its max lifetime not to be refreshed, got %d", s)
+	}
+}
@@ -0,0 +1,293 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/repo/postgres"
+	"webdp/internal/api/http/services"
+	"webdp/internal/signing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func pemKey(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func pemPublicKey(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func newEdKey(t *testing.T) (signing.Key, ed25519.PrivateKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := signing.ParseKey(pemKey(t, priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv
+}
+
+func testClaims(handle string) *services.JWTTokenClaims {
+	c := &services.JWTTokenClaims{Handle: handle, Roles: []string{entity.ANALYST}}
+	c.Id = "session"
+	c.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	return c
+}
+
+func TestSigningKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for alg, key := range map[string]any{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey} {
+		k, err := signing.ParseKey(pemKey(t, key))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		ring, err := signing.NewKeyRing(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := ring.Sign(testClaims("anna"))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		parsed, _ := jwt.Parse(token, nil)
+		if parsed.Header["alg"] != alg || parsed.Header["kid"] != k.Id() {
+			t.Errorf("expected a token of %s with kid %s, got header %v", alg, k.Id(), parsed.Header)
+		}
+
+		var c services.JWTTokenClaims
+		if err := ring.Parse(token, &c); err != nil || c.Handle != "anna" {
+			t.Errorf("%s: expected the token to verify, got %v", alg, err)
+		}
+		jwks := ring.JWKS()
+		if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != k.Id() || jwks.Keys[0].Alg != alg {
+			t.Errorf("%s: expected the key in the JWKS, got %+v", alg, jwks)
+		}
+	}
+
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := signing.ParseKey(pemKey(t, small)); !errors.Is(err, httperrors.ErrMissingEnv) {
+		t.Errorf("expected a small RSA key to be rejected, got %v", err)
+	}
+	if _, err := signing.ParseKey([]byte("not a key")); !errors.Is(err, httperrors.ErrMissingEnv) {
+		t.Errorf("expected what is not PEM to be rejected, got %v", err)
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	oldKey, _ := newEdKey(t)
+	newKey, newPriv := newEdKey(t)
+	c := testClaims("anna")
+
+	// the new key is added as a verifying key before it signs
+	before, _ := signing.NewKeyRing(oldKey, newKey)
+	oldToken, _ := before.Sign(c)
+	during, _ := signing.NewKeyRing(newKey, oldKey)
+	newToken, _ := during.Sign(c)
+	for name, ring := range map[string]*signing.KeyRing{"before": before, "during": during} {
+		for _, token := range []string{oldToken, newToken} {
+			if err := ring.Parse(token, &services.JWTTokenClaims{}); err != nil {
+				t.Errorf("expected the ring %s the rotation to verify both keys, got %v", name, err)
+			}
+		}
+	}
+	if n := len(during.JWKS().Keys); n != 2 {
+		t.Errorf("expected both keys to be published during the rotation, got %d", n)
+	}
+
+	// once the old key is removed, its tokens are refused
+	after, _ := signing.NewKeyRing(newKey)
+	if err := after.Parse(oldToken, &services.JWTTokenClaims{}); !errors.Is(err, httperrors.ErrInvalidToken) {
+		t.Errorf("expected a token of a removed key to be refused, got %v", err)
+	}
+
+	// a public key verifies but does not sign
+	public, err := signing.ParseKey(pemPublicKey(t, newPriv.Public()))
+	if err != nil || public.Id() != newKey.Id() || public.CanSign() {
+		t.Errorf("expected the public key to have the id of its private key and not sign, got %v", err)
+	}
+	if _, err := signing.NewKeyRing(public); err == nil {
+		t.Errorf("expected a public key not to be the signing key")
+	}
+	verifier, _ := signing.NewKeyRing(oldKey, public)
+	if err := verifier.Parse(newToken, &services.JWTTokenClaims{}); err != nil {
+		t.Errorf("expected the public key to verify, got %v", err)
+	}
+}
+
+func TestSigningLegacyKey(t *testing.T) {
+	secret := "hubbabubbajordgubb"
+	legacy := signing.HMACKey(secret)
+
+	// tokens from before the key ids have no kid
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("anna")).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, _ := signing.NewKeyRing(legacy)
+	if err := ring.Parse(unnamed, &services.JWTTokenClaims{}); err != nil {
+		t.Errorf("expected a token without kid to verify with the secret, got %v", err)
+	}
+	if n := len(ring.JWKS().Keys); n != 0 {
+		t.Errorf("expected the secret not to be published, got %d keys", n)
+	}
+
+	// after switching to key files the secret is accepted for a while
+	edKey, _ := newEdKey(t)
+	switched, _ := signing.NewKeyRing(edKey)
+	switched.AcceptUntil(legacy, time.Now().Add(time.Hour))
+	if err := switched.Parse(unnamed, &services.JWTTokenClaims{}); err != nil {
+		t.Errorf("expected the secret to be accepted until the time, got %v", err)
+	}
+	expired, _ := signing.NewKeyRing(edKey)
+	expired.AcceptUntil(legacy, time.Now().Add(-time.Second))
+	if err := expired.Parse(unnamed, &services.JWTTokenClaims{}); err == nil {
+		t.Errorf("expected the secret to be refused after the time")
+	}
+}
+
+func TestSigningAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k, _ := signing.ParseKey(pemKey(t, rsaKey))
+	ring, _ := signing.NewKeyRing(k)
+
+	// a token signed with HS256 and the public key as the secret, under the kid of the RSA key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("root"))
+	forged.Header["kid"] = k.Id()
+	token, _ := forged.SignedString(pemPublicKey(t, &rsaKey.PublicKey))
+	if err := ring.Parse(token, &services.JWTTokenClaims{}); !errors.Is(err, httperrors.ErrInvalidToken) {
+		t.Errorf("expected a token with another algorithm than its key to be refused, got %v", err)
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims("root"))
+	none.Header["kid"] = k.Id()
+	token, _ = none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err := ring.Parse(token, &services.JWTTokenClaims{}); !errors.Is(err, httperrors.ErrInvalidToken) {
+		t.Errorf("expected an unsigned token to be refused, got %v", err)
+	}
+}
+
+func TestSigningLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	_, currentPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, nextPriv, _ := ed25519.GenerateKey(rand.Reader)
+	files := []string{filepath.Join(dir, "current.pem"), filepath.Join(dir, "next.pem")}
+	for i, key := range []ed25519.PrivateKey{currentPriv, nextPriv} {
+		if err := os.WriteFile(files[i], pemKey(t, key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ring, err := signing.LoadKeyRing("secret", strings.Join(files, ", "), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := signing.ParseKey(pemKey(t, currentPriv))
+	if ring.CurrentId() != first.Id() || len(ring.JWKS().Keys) != 2 {
+		t.Errorf("expected the first file to sign and both to be published")
+	}
+
+	legacy, _ := signing.LoadKeyRing("secret", "", time.Time{})
+	if legacy.CurrentId() != signing.HMACKey("secret").Id() {
+		t.Errorf("expected the secret to sign without key files")
+	}
+	if _, err := signing.LoadKeyRing("secret", filepath.Join(dir, "missing.pem"), time.Time{}); !errors.Is(err, httperrors.ErrMissingEnv) {
+		t.Errorf("expected a missing key file to be reported, got %v", err)
+	}
+	if _, err := signing.LoadKeyRing("", "", time.Time{}); !errors.Is(err, httperrors.ErrMissingEnv) {
+		t.Errorf("expected a secret or key files to be required, got %v", err)
+	}
+	if _, err := signing.LoadKeyRing("", files[0], time.Time{}); err != nil {
+		t.Errorf("expected the secret not to be needed with key files, got %v", err)
+	}
+}
+
+func TestSigningLegacyCutover(t *testing.T) {
+	dir := t.TempDir()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	file := filepath.Join(dir, "current.pem")
+	if err := os.WriteFile(file, pemKey(t, priv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("anna")).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the secret is accepted until the configured time, however often the ring is loaded
+	until := time.Now().Add(time.Hour)
+	for restart := 0; restart < 2; restart++ {
+		ring, err := signing.LoadKeyRing("secret", file, until)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ring.Parse(unnamed, &services.JWTTokenClaims{}); err != nil {
+			t.Errorf("expected the secret to be accepted before the cutover, got %v", err)
+		}
+	}
+
+	// a ring loaded after the time refuses the secret, as does one without a time
+	for _, cutover := range []time.Time{time.Now().Add(-time.Second), {}} {
+		ring, err := signing.LoadKeyRing("secret", file, cutover)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ring.Parse(unnamed, &services.JWTTokenClaims{}); !errors.Is(err, httperrors.ErrInvalidToken) {
+			t.Errorf("expected the secret to be refused after the cutover at %v, got %v", cutover, err)
+		}
+	}
+}
+
+func TestTokenServiceKeyRing(t *testing.T) {
+	k, _ := newEdKey(t)
+	ring, _ := signing.NewKeyRing(k)
+	tokens := services.NewTokenService(postgres.TokenPostgres{}, ring, time.Hour, time.Hour, time.Hour, services.AuditService{})
+
+	access, _, err := tokens.IssueAccessToken(entity.Session{Id: "s1", Handle: "anna"}, []string{entity.ANALYST})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := tokens.ParseAccessToken(access)
+	if err != nil || c.Handle != "anna" || c.Id != "s1" {
+		t.Errorf("expected the access token to verify, got %+v %v", c, err)
+	}
+
+	other, _ := newEdKey(t)
+	otherRing, _ := signing.NewKeyRing(other)
+	foreign, _ := otherRing.Sign(testClaims("anna"))
+	if _, err := tokens.ParseAccessToken(foreign); !errors.Is(err, httperrors.ErrInvalidToken) {
+		t.Errorf("expected a token of an unknown key to be refused, got %v", err)
+	}
+}
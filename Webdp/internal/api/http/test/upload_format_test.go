@@ -0,0 +1,161 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	httperrors "webdp/internal/api/http"
+	"webdp/internal/api/http/entity"
+)
+
+// parquet uploads are decompressed into a file first, as the handlers do
+func readAllRecords(t *testing.T, format string, body []byte) ([]string, [][]string, error) {
+	if format == entity.FORMAT_PARQUET {
+		decompressed, err := entity.Decompress(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		if body, err = io.ReadAll(decompressed); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	reader, err := entity.NewRecordReader(format, bytes.NewReader(body), uploadTestSchema())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return reader.Header(), records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, append([]string(nil), record...))
+	}
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestUploadFormat(t *testing.T) {
+	for _, tc := range []struct {
+		param, contentType, format string
+	}{
+		{"", "", entity.FORMAT_CSV},
+		{"", "application/json", entity.FORMAT_CSV},
+		{"", "text/csv; charset=utf-8", entity.FORMAT_CSV},
+		{"", "application/x-ndjson", entity.FORMAT_JSONL},
+		{"", "application/vnd.apache.parquet", entity.FORMAT_PARQUET},
+		{"jsonl", "text/csv", entity.FORMAT_JSONL},
+	} {
+		format, err := entity.UploadFormat(tc.param, tc.contentType)
+		if err != nil || format != tc.format {
+			t.Errorf("expected %s for %q and %q, got: %s, %v", tc.format, tc.param, tc.contentType, format, err)
+		}
+	}
+
+	if _, err := entity.UploadFormat("xlsx", ""); !errors.Is(err, httperrors.ErrBadInput) {
+		t.Errorf("expected unknown format to be rejected, got: %v", err)
+	}
+}
+
+func TestUploadGzipCSV(t *testing.T) {
+	csv := "age,name,job,score,member\n20,Anna,Dentist,0.5,true\n30,\"Bo, Jr\",Accountant,1,false\n"
+
+	header, records, err := readAllRecords(t, entity.FORMAT_CSV, gzipBytes([]byte(csv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(header, []string{"age", "name", "job", "score", "member"}) || len(records) != 2 || records[1][1] != "Bo, Jr" {
+		t.Errorf("unexpected records: %v %v", header, records)
+	}
+}
+
+func TestUploadJsonLines(t *testing.T) {
+	jsonl := `{"name": "Anna", "age": 20, "job": "Dentist", "score": 0.5, "member": true}
+{"age": 30, "job": "Accountant", "score": 1, "member": false, "name": null}
+`
+	for _, body := range [][]byte{[]byte(jsonl), gzipBytes([]byte(jsonl))} {
+		header, records, err := readAllRecords(t, entity.FORMAT_JSONL, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(header, []string{"name", "age", "job", "score", "member"}) {
+			t.Errorf("expected the header of the schema, got: %v", header)
+		}
+		expected := [][]string{{"Anna", "20", "Dentist", "0.5", "true"}, {"", "30", "Accountant", "1", "false"}}
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("unexpected records: %v", records)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"name": "Anna", "height": 180}`,
+		`{"name": ["Anna"]}`,
+		`["Anna", 20]`,
+		`{"name": "Anna"`,
+	} {
+		if _, _, err := readAllRecords(t, entity.FORMAT_JSONL, []byte(bad)); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestUploadParquet(t *testing.T) {
+	file, err := readParquetFile("people_dictionary_snappy.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range [][]byte{file, gzipBytes(file)} {
+		header, records, err := readAllRecords(t, entity.FORMAT_PARQUET, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(header, []string{"age", "name", "job", "score", "member"}) {
+			t.Errorf("unexpected header: %v", header)
+		}
+		expected := [][]string{
+			{"20", "Anna", "Dentist", "0.5", "true"},
+			{"35", "", "Accountant", "0.25", "false"},
+			{"50", "Bo", "Dentist", "1", "true"},
+		}
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("unexpected records: %v", records)
+		}
+
+		v, err := entity.NewUploadValidator(uploadTestSchema(), header, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			v.Row(record)
+		}
+		if v.Err() != nil {
+			t.Errorf("expected the parquet rows to fit the schema, got: %v", v.Err())
+		}
+	}
+
+	for _, bad := range [][]byte{file[:len(file)-20], file[:100], []byte("age,name\n")} {
+		if _, _, err := readAllRecords(t, entity.FORMAT_PARQUET, bad); !errors.Is(err, httperrors.ErrBadFormatting) {
+			t.Errorf("expected a broken parquet file to be rejected, got: %v", err)
+		}
+	}
+
+	if _, err := entity.NewRecordReader(entity.FORMAT_PARQUET, io.LimitReader(bytes.NewReader(file), int64(len(file))), nil); !errors.Is(err, httperrors.ErrUnexpected) {
+		t.Errorf("expected parquet to be read from a file only, got: %v", err)
+	}
+}
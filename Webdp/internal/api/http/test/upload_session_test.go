@@ -0,0 +1,132 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"webdp/internal/api/http/entity"
+)
+
+// a dataset of ann with cody as co-curator, and the tokens of both
+func uploadDataset(t *testing.T, a *testApi) (int64, string, string) {
+	a.user(t, "ann", entity.CURATOR)
+	a.user(t, "cody", entity.CURATOR)
+	ann := a.login(t, "ann")
+	id := a.dataset(t, ann, "ann")
+	a.call(t, ann, http.MethodPut, fmt.Sprintf("/v2/datasets/%d/members/cody", id), entity.MemberPut{Role: entity.MEMBER_CO_CURATOR}, http.StatusNoContent, nil)
+	return id, ann, a.login(t, "cody")
+}
+
+func TestUploadSessionChunks(t *testing.T) {
+	a := newTestApi(t)
+	id, ann, cody := uploadDataset(t, a)
+
+	var us entity.UploadSession
+	a.call(t, ann, http.MethodPost, fmt.Sprintf("/v2/datasets/%d/uploads", id), nil, http.StatusCreated, &us)
+	if us.Owner != "ann" || us.Size != 0 || !us.ExpiresOn.After(time.Now()) {
+		t.Errorf("expected a new session of ann, got %+v", us)
+	}
+	session := fmt.Sprintf("/v2/datasets/%d/uploads/%d", id, us.Id)
+	chunk := func(token string, offset int, data string) int {
+		return a.status(t, token, http.MethodPut, fmt.Sprintf("%s?offset=%d", session, offset), []byte(data))
+	}
+
+	// chunks only go at the size of the session
+	if s := chunk(ann, 0, "ag"); s != http.StatusOK {
+		t.Fatalf("expected the first chunk to be taken, got %d", s)
+	}
+	refused := map[string]struct {
+		offset int
+		data   string
+	}{
+		"a repeated chunk":    {0, "ag"},
+		"an overlap":          {1, "ge\n"},
+		"a chunk after a gap": {5, "30\n"},
+	}
+	for name, c := range refused {
+		if s := chunk(ann, c.offset, c.data); s != http.StatusConflict {
+			t.Errorf("expected %s to be refused with 409, got %d", name, s)
+		}
+	}
+	a.call(t, ann, http.MethodGet, session, nil, http.StatusOK, &us)
+	if us.Size != 2 {
+		t.Errorf("expected the refused chunks to leave the session at 2 bytes, got %d", us.Size)
+	}
+
+	// the data so far is not a dataset, and the session is kept to resume
+	if s := a.status(t, ann, http.MethodPost, session+"/complete", nil); s != http.StatusBadRequest {
+		t.Errorf("expected an incomplete upload to be refused with 400, got %d", s)
+	}
+	a.call(t, ann, http.MethodGet, session, nil, http.StatusOK, &us)
+
+	// only ann reaches the session, a co-curator of the dataset does not
+	if s := chunk(cody, 2, "e\n"); s != http.StatusNotFound {
+		t.Errorf("expected a chunk of cody to be refused with 404, got %d", s)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if s := a.status(t, cody, method, session, nil); s != http.StatusNotFound {
+			t.Errorf("expected %s of cody to be refused with 404, got %d", method, s)
+		}
+	}
+	if s := a.status(t, cody, http.MethodPost, session+"/complete", nil); s != http.StatusNotFound {
+		t.Errorf("expected cody not to complete the session of ann, got %d", s)
+	}
+
+	if s := chunk(ann, 2, "e\n30\n41\n"); s != http.StatusOK {
+		t.Fatalf("expected the rest of the upload to be taken, got %d", s)
+	}
+	a.call(t, ann, http.MethodPost, session+"/complete", nil, http.StatusNoContent, nil)
+	var versions []entity.DataVersion
+	a.call(t, ann, http.MethodGet, fmt.Sprintf("/v2/datasets/%d/versions", id), nil, http.StatusOK, &versions)
+	if len(versions) != 1 || versions[0].Rows != 2 {
+		t.Errorf("expected one version of 2 rows, got %+v", versions)
+	}
+	if s := a.status(t, ann, http.MethodGet, session, nil); s != http.StatusNotFound {
+		t.Errorf("expected the completed session to be removed, got %d", s)
+	}
+
+	// a session without chunks has nothing to store
+	a.call(t, ann, http.MethodPost, fmt.Sprintf("/v2/datasets/%d/uploads", id), nil, http.StatusCreated, &us)
+	if s := a.status(t, ann, http.MethodPost, fmt.Sprintf("/v2/datasets/%d/uploads/%d/complete", id, us.Id), nil); s != http.StatusBadRequest {
+		t.Errorf("expected an empty upload to be refused with 400, got %d", s)
+	}
+}
+
+func TestUploadSessionExpiry(t *testing.T) {
+	a := newTestApi(t)
+	id, ann, _ := uploadDataset(t, a)
+
+	var expired, live entity.UploadSession
+	a.call(t, ann, http.MethodPost, fmt.Sprintf("/v2/datasets/%d/uploads", id), nil, http.StatusCreated, &expired)
+	a.call(t, ann, http.MethodPost, fmt.Sprintf("/v2/datasets/%d/uploads", id), nil, http.StatusCreated, &live)
+	session := fmt.Sprintf("/v2/datasets/%d/uploads/%d", id, expired.Id)
+	a.call(t, ann, http.MethodPut, session+"?offset=0", []byte("age\n30\n"), http.StatusOK, nil)
+
+	// the last chunk was a lifetime ago
+	if _, err := a.db.Exec("UPDATE UploadSessions SET updated_time = $1 WHERE id = $2", time.Now().Add(-entity.UPLOAD_SESSION_LIFETIME-time.Minute), expired.Id); err != nil {
+		t.Fatal(err)
+	}
+	if s := a.status(t, ann, http.MethodGet, session, nil); s != http.StatusNotFound {
+		t.Errorf("expected an expired session to be gone, got %d", s)
+	}
+	if s := a.status(t, ann, http.MethodPut, session+"?offset=7", []byte("41\n")); s != http.StatusNotFound {
+		t.Errorf("expected no chunks to go to an expired session, got %d", s)
+	}
+	if s := a.status(t, ann, http.MethodPost, session+"/complete", nil); s != http.StatusNotFound {
+		t.Errorf("expected an expired session not to be completed, got %d", s)
+	}
+
+	purged, err := a.services.Datasets.PurgeUploadSessions(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("expected the expired session to be purged only, got %d", purged)
+	}
+	var chunks int
+	if err := a.db.QueryRow("SELECT COUNT(*) FROM UploadChunks WHERE session = $1", expired.Id).Scan(&chunks); err != nil || chunks != 0 {
+		t.Errorf("expected the chunks of the expired session to be purged, got %d: %v", chunks, err)
+	}
+	a.call(t, ann, http.MethodGet, fmt.Sprintf("/v2/datasets/%d/uploads/%d", id, live.Id), nil, http.StatusOK, nil)
+}
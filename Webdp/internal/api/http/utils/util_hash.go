@@ -8,8 +8,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashAndSalt(pwd string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.MinCost)
+// the bcrypt cost of the password hashes, unless PASSWORD_HASH_COST is set
+const DEFAULT_HASH_COST = 12
+
+func HashAndSalt(pwd string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), cost)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
 	}
@@ -23,3 +26,9 @@ func ComparePasswords(hashedPwd string, plainPwd string) bool {
 	err := bcrypt.CompareHashAndPassword(byteSavedHash, bytePlain)
 	return err == nil
 }
+
+// whether the hash was made with another cost than the one passwords are hashed with now
+func NeedsRehash(hashedPwd string, cost int) bool {
+	c, err := bcrypt.Cost([]byte(hashedPwd))
+	return err == nil && c != cost
+}
@@ -18,6 +18,15 @@ const (
 */
 
 func ValidateNonEmptyString(obj any) error {
+	return validateStrings(obj, false)
+}
+
+// like ValidateNonEmptyString, but only the fields with the tag are checked and other strings may be empty
+func ValidateNonEmptyTagged(obj any) error {
+	return validateStrings(obj, true)
+}
+
+func validateStrings(obj any, taggedOnly bool) error {
 
 	t := reflect.TypeOf(obj)
 	v := reflect.ValueOf(obj)
@@ -27,6 +36,9 @@ func ValidateNonEmptyString(obj any) error {
 		if tag != "" && tag != NON_EMPTY {
 			panic("WRONG TAG")
 		}
+		if taggedOnly && tag != NON_EMPTY {
+			continue
+		}
 		val := v.FieldByName(field.Name)
 		if val.Interface() == reflect.ValueOf("").Interface() {
 			return fmt.Errorf("%w: field \"%s\" in struct \"%s\" should not be empty", errors.ErrBadFormatting, field.Name, t.Name())
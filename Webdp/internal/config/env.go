@@ -4,8 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 	errors "webdp/internal/api/http"
 	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/utils"
+	"webdp/internal/oidc"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Envs struct {
@@ -18,9 +25,38 @@ type Envs struct {
 	Db_name     string
 	Root_pw     string
 	Auth_key    string
+	Auth_until  time.Time
+	Sign_keys   string
 	Config_path string
+	Master_key  string
+	Old_keys    string
+	Retention   time.Duration
+
+	Access_lifetime  time.Duration
+	Refresh_lifetime time.Duration
+	Session_lifetime time.Duration
+
+	Password  entity.PasswordPolicy
+	Hash_cost int
+	Login     entity.LoginPolicy
+
+	Rate_limits entity.RateLimits
+	Daily_quota int
+
+	// nil unless OIDC_ISSUER is set
+	Oidc *oidc.Config
 }
 
+// the number of days deleted datasets are kept before they are purged, unless DATASET_RETENTION_DAYS is set
+const DEFAULT_RETENTION_DAYS = 30
+
+// how long tokens and sessions are valid, unless ACCESS_TOKEN_LIFETIME, REFRESH_TOKEN_LIFETIME and SESSION_MAX_LIFETIME are set
+const (
+	DEFAULT_ACCESS_LIFETIME  = time.Hour
+	DEFAULT_REFRESH_LIFETIME = 30 * 24 * time.Hour
+	DEFAULT_SESSION_LIFETIME = 90 * 24 * time.Hour
+)
+
 /*
 Collects environment variables from container.
 */
@@ -56,10 +92,73 @@ func Getenvars() (*Envs, error) {
 		return nil, fmt.Errorf("%w: %s", errors.ErrMissingEnv, "config path")
 	}
 
+	// key files of the access tokens, the first signs and the others verify, AUTH_SIGN_KEY signs without them
+	signKeys := os.Getenv("AUTH_SIGNING_KEYS")
 	skey := os.Getenv("AUTH_SIGN_KEY")
-	if skey == "" {
+	if skey == "" && strings.TrimSpace(signKeys) == "" {
 		return nil, fmt.Errorf("%w: %s", errors.ErrMissingEnv, "auth key")
 	}
+	// tokens of AUTH_SIGN_KEY are accepted until this time after switching to key files, and not at all without it
+	var skeyUntil time.Time
+	if v := os.Getenv("AUTH_SIGN_KEY_UNTIL"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: AUTH_SIGN_KEY_UNTIL should be a time such as 2024-05-01T12:00:00Z, got %s", errors.ErrBadInput, v)
+		}
+		skeyUntil = t
+	}
+
+	// master key of the data keys, the old keys are only needed until they are rotated
+	mkey := os.Getenv("DATA_MASTER_KEY")
+	if mkey == "" {
+		return nil, fmt.Errorf("%w: %s", errors.ErrMissingEnv, "data master key")
+	}
+	oldKeys := os.Getenv("DATA_MASTER_KEY_OLD")
+
+	days := DEFAULT_RETENTION_DAYS
+	if v := os.Getenv("DATASET_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: DATASET_RETENTION_DAYS should be a number of days, got %s", errors.ErrBadInput, v)
+		}
+		days = n
+	}
+
+	access, err := lifetime("ACCESS_TOKEN_LIFETIME", DEFAULT_ACCESS_LIFETIME)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := lifetime("REFRESH_TOKEN_LIFETIME", DEFAULT_REFRESH_LIFETIME)
+	if err != nil {
+		return nil, err
+	}
+	session, err := lifetime("SESSION_MAX_LIFETIME", DEFAULT_SESSION_LIFETIME)
+	if err != nil {
+		return nil, err
+	}
+
+	password, cost, login, err := passwordConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := password.Check("root", pass); err != nil {
+		return nil, fmt.Errorf("ROOT_PASSWORD does not follow the password policy: %w", err)
+	}
+
+	// requests per minute of each class of endpoint, and queries per day on each allocation
+	limits, err := entity.ParseRateLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+	quota, err := number("QUERY_DAILY_QUOTA", entity.DEFAULT_QUERY_DAILY_QUOTA, 0, 1000000)
+	if err != nil {
+		return nil, err
+	}
+
+	idp, err := oidcConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Envs{
 		Port_ext:    apiPort,
@@ -71,10 +170,134 @@ func Getenvars() (*Envs, error) {
 		Db_name:     dbName,
 		Root_pw:     pass,
 		Auth_key:    skey,
+		Auth_until:  skeyUntil,
+		Sign_keys:   signKeys,
 		Config_path: path,
+		Master_key:  mkey,
+		Old_keys:    oldKeys,
+		Retention:   time.Duration(days) * 24 * time.Hour,
+
+		Access_lifetime:  access,
+		Refresh_lifetime: refresh,
+		Session_lifetime: session,
+
+		Password:  password,
+		Hash_cost: cost,
+		Login:     login,
+
+		Rate_limits: limits,
+		Daily_quota: quota,
+
+		Oidc: idp,
 	}, nil
 }
 
+/*
+The password policy, the bcrypt cost of the password hashes and the limits of
+failed logins. Passwords hashed with another cost are hashed again when their
+users log in.
+*/
+func passwordConfig() (entity.PasswordPolicy, int, entity.LoginPolicy, error) {
+	var policy entity.PasswordPolicy
+	var login entity.LoginPolicy
+	var cost int
+	for _, v := range []struct {
+		name     string
+		to       *int
+		def      int
+		min, max int
+	}{
+		{"PASSWORD_MIN_LENGTH", &policy.MinLength, entity.DEFAULT_PASSWORD_MIN_LENGTH, 1, entity.MAX_PASSWORD_BYTES},
+		{"PASSWORD_MIN_CLASSES", &policy.MinClasses, entity.DEFAULT_PASSWORD_MIN_CLASSES, 1, 4},
+		{"PASSWORD_HASH_COST", &cost, utils.DEFAULT_HASH_COST, bcrypt.MinCost, bcrypt.MaxCost},
+		{"LOGIN_MAX_FAILURES", &login.MaxFailures, entity.DEFAULT_LOGIN_MAX_FAILURES, 1, 1000},
+		{"LOGIN_IP_MAX_FAILURES", &login.MaxIpFailures, entity.DEFAULT_LOGIN_IP_MAX_FAILURES, 1, 100000},
+	} {
+		n, err := number(v.name, v.def, v.min, v.max)
+		if err != nil {
+			return policy, 0, login, err
+		}
+		*v.to = n
+	}
+	lockout, err := lifetime("LOGIN_LOCKOUT", entity.DEFAULT_LOGIN_LOCKOUT)
+	if err != nil {
+		return policy, 0, login, err
+	}
+	login.Lockout = lockout
+	return policy, cost, login, nil
+}
+
+// a number between min and max from the environment variable, def if it is not set
+func number(name string, def int, min int, max int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: %s should be a number between %d and %d, got %s", errors.ErrBadInput, name, min, max, v)
+	}
+	return n, nil
+}
+
+/*
+The identity provider to log in with, nil when OIDC_ISSUER is not set. The
+OIDC_*_GROUPS variables are comma separated lists of the groups at the
+provider that give each role. The users of the provider are created in
+OIDC_ORGANIZATION, the default organization if it is not set.
+*/
+func oidcConfig() (*oidc.Config, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	cfg := oidc.Config{
+		Issuer:        issuer,
+		ClientId:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		Audience:      os.Getenv("OIDC_AUDIENCE"),
+		UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+		GroupsClaim:   os.Getenv("OIDC_GROUPS_CLAIM"),
+		Organization:  os.Getenv("OIDC_ORGANIZATION"),
+		RoleGroups: map[string][]string{
+			entity.ADMIN:   groups("OIDC_ADMIN_GROUPS"),
+			entity.CURATOR: groups("OIDC_CURATOR_GROUPS"),
+			entity.ANALYST: groups("OIDC_ANALYST_GROUPS"),
+		},
+	}
+	if cfg.Organization == "" {
+		cfg.Organization = entity.DEFAULT_ORGANIZATION
+	}
+	if cfg.ClientId == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("%w: %s", errors.ErrMissingEnv, "oidc client id and redirect url")
+	}
+	return &cfg, nil
+}
+
+func groups(name string) []string {
+	out := make([]string, 0)
+	for _, g := range strings.Split(os.Getenv(name), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// a positive duration such as 15m or 720h from the environment variable, def if it is not set
+func lifetime(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: %s should be a positive duration such as 15m or 720h, got %s", errors.ErrBadInput, name, v)
+	}
+	return d, nil
+}
+
 /*
 Collects default engine and list of DP engines from config file.
 */
@@ -0,0 +1,195 @@
+/*
+Envelope encryption of uploaded data. Every dataset has its own data key that
+encrypts its rows, the data key is stored wrapped (encrypted) by a master key
+from the configuration. Rotating the master key only re-wraps the data keys,
+the rows are left as they are.
+*/
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	errors "webdp/internal/api/http"
+)
+
+// length in bytes of master and data keys, AES-256
+const KEY_SIZE = 32
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+/*
+The current master key, which wraps new data keys, and the previous master
+keys, which are only used to unwrap data keys that have not been rotated yet.
+*/
+type KeyRing struct {
+	current  masterKey
+	previous map[string]masterKey
+}
+
+// a data key ready to encrypt and decrypt the rows of one dataset
+type DataKey struct {
+	dataset []byte
+	aead    cipher.AEAD
+}
+
+/*
+Creates a key ring from base64 encoded 32 byte keys. previous is a comma
+separated list of master keys that have been replaced by current, it may be
+empty.
+*/
+func NewKeyRing(current string, previous string) (*KeyRing, error) {
+	cur, err := parseMasterKey(current)
+	if err != nil {
+		return nil, err
+	}
+
+	kr := &KeyRing{current: cur, previous: make(map[string]masterKey)}
+	for _, s := range strings.Split(previous, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		mk, err := parseMasterKey(s)
+		if err != nil {
+			return nil, err
+		}
+		if mk.id != cur.id {
+			kr.previous[mk.id] = mk
+		}
+	}
+	return kr, nil
+}
+
+func parseMasterKey(s string) (masterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != KEY_SIZE {
+		return masterKey{}, fmt.Errorf("%w: master keys should be %d bytes encoded in base64", errors.ErrMissingEnv, KEY_SIZE)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return masterKey{}, err
+	}
+	// the id is stored next to every wrapped data key, it does not reveal the key
+	sum := sha256.Sum256(key)
+	return masterKey{id: hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// the id of the master key that wraps new data keys
+func (kr *KeyRing) CurrentId() string {
+	return kr.current.id
+}
+
+// generates a data key for a dataset and returns it together with its wrapped form
+func (kr *KeyRing) NewDataKey(dataset int64) (DataKey, []byte, error) {
+	key := make([]byte, KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return DataKey{}, nil, fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
+	}
+	dk, err := newDataKey(dataset, key)
+	if err != nil {
+		return DataKey{}, nil, err
+	}
+	return dk, seal(kr.current.aead, key, dk.dataset), nil
+}
+
+/*
+Unwraps a data key of a dataset that was wrapped by the master key with id
+masterId, which has to be in the ring.
+*/
+func (kr *KeyRing) UnwrapDataKey(dataset int64, masterId string, wrapped []byte) (DataKey, error) {
+	key, err := kr.unwrap(dataset, masterId, wrapped)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return newDataKey(dataset, key)
+}
+
+// unwraps a data key with a previous master key and wraps it with the current one
+func (kr *KeyRing) RewrapDataKey(dataset int64, masterId string, wrapped []byte) ([]byte, error) {
+	key, err := kr.unwrap(dataset, masterId, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return seal(kr.current.aead, key, datasetId(dataset)), nil
+}
+
+func (kr *KeyRing) unwrap(dataset int64, masterId string, wrapped []byte) ([]byte, error) {
+	mk, ok := kr.previous[masterId]
+	if masterId == kr.current.id {
+		mk, ok = kr.current, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: the data key of dataset %d is wrapped by unknown master key %s", errors.ErrUnexpected, dataset, masterId)
+	}
+
+	key, err := open(mk.aead, wrapped, datasetId(dataset))
+	if err != nil || len(key) != KEY_SIZE {
+		return nil, fmt.Errorf("%w: could not unwrap the data key of dataset %d", errors.ErrUnexpected, dataset)
+	}
+	return key, nil
+}
+
+// encrypts a row or chunk of data
+func (dk DataKey) Encrypt(plain []byte) []byte {
+	return seal(dk.aead, plain, dk.dataset)
+}
+
+// decrypts data encrypted by Encrypt with the same key
+func (dk DataKey) Decrypt(data []byte) ([]byte, error) {
+	plain, err := open(dk.aead, data, dk.dataset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decrypt data", errors.ErrUnexpected)
+	}
+	return plain, nil
+}
+
+func newDataKey(dataset int64, key []byte) (DataKey, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return DataKey{dataset: datasetId(dataset), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnexpected, err.Error())
+	}
+	return aead, nil
+}
+
+// the dataset is authenticated with the data so that it cannot be moved to another dataset
+func datasetId(dataset int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(dataset))
+}
+
+// a random nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plain []byte, ad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plain, ad)
+}
+
+func open(aead cipher.AEAD, data []byte, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", errors.ErrUnexpected)
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+}
@@ -0,0 +1,360 @@
+/*
+Login with an external OpenID Connect identity provider. The provider is found
+through its discovery document, its tokens are verified against the keys it
+publishes (JWKS). Users log in with the authorization code flow with PKCE, or
+present a token issued by the provider directly. The groups of a user at the
+provider decide its Webdp roles.
+*/
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// how often the keys of the provider are fetched again at most, when a token is signed with an unknown key
+const KEYS_REFRESH_INTERVAL = time.Minute
+
+// the signing algorithms accepted in tokens of the provider
+var SIGNING_METHODS = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512"}
+
+/*
+The client registration at the provider. Tokens presented directly must be
+issued for Audience, or for ClientId when Audience is empty. RoleGroups maps
+the Webdp roles to the groups at the provider that give them. Users are
+created in Organization on their first login.
+*/
+type Config struct {
+	Issuer        string
+	ClientId      string
+	ClientSecret  string
+	RedirectURL   string
+	Audience      string
+	UsernameClaim string
+	GroupsClaim   string
+	RoleGroups    map[string][]string
+	Organization  string
+}
+
+// a user as the provider knows it
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Name     string
+	Groups   []string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+/*
+A provider that is discovered on first use, so that Webdp starts while the
+provider is unavailable. Its keys are cached and fetched again when a token is
+signed with a key that is not known yet.
+*/
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.Mutex
+	meta    *metadata
+	keys    map[string]any
+	fetched time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = cfg.ClientId
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+func (p *Provider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// the Webdp organization of the users of the provider
+func (p *Provider) Organization() string {
+	return p.cfg.Organization
+}
+
+// the URL of the provider that the user logs in at
+func (p *Provider) AuthCodeURL(state string, nonce string, challenge string) (string, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientId},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {"openid profile email"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// exchanges the code of a login for the ID token of the user
+func (p *Provider) Exchange(code string, verifier string) (string, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientId},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	resp, err := p.client.PostForm(meta.TokenEndpoint, form)
+	if err != nil {
+		return "", fmt.Errorf("%w: identity provider: %s", errors.ErrUnexpected, err.Error())
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IdToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("%w: identity provider: %s", errors.ErrUnexpected, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK || body.IdToken == "" {
+		return "", fmt.Errorf("%w: identity provider refused the login: %s", errors.ErrUnauthorized, body.Error)
+	}
+	return body.IdToken, nil
+}
+
+// verifies an ID token from a login, which is issued for the client and carries the nonce of the login
+func (p *Provider) VerifyIDToken(raw string, nonce string) (Identity, error) {
+	claims, err := p.verify(raw, p.cfg.ClientId)
+	if err != nil {
+		return Identity{}, err
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce of the id token does not match the login", errors.ErrUnauthorized)
+	}
+	return p.identity(claims)
+}
+
+// verifies a token that is presented directly as a bearer token
+func (p *Provider) VerifyToken(raw string) (Identity, error) {
+	claims, err := p.verify(raw, p.cfg.Audience)
+	if err != nil {
+		return Identity{}, err
+	}
+	return p.identity(claims)
+}
+
+// the Webdp roles that the groups give, sorted
+func (p *Provider) Roles(groups []string) []string {
+	roles := make([]string, 0)
+	for role, rgs := range p.cfg.RoleGroups {
+		for _, g := range rgs {
+			if slices.Contains(groups, g) {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+/*
+The issuer a token claims to be from, without verifying it. Used to tell the
+tokens of the provider apart from Webdp tokens.
+*/
+func Issuer(raw string) string {
+	var claims jwt.MapClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(raw, &claims); err != nil {
+		return ""
+	}
+	iss, _ := claims["iss"].(string)
+	return iss
+}
+
+// a PKCE code verifier and its S256 challenge
+func NewPKCE() (string, string, error) {
+	verifier, err := RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// size random bytes, base64url encoded
+func RandomString(size int) (string, error) {
+	bs := make([]byte, size)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func (p *Provider) verify(raw string, audience string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(SIGNING_METHODS))
+	if _, err := parser.ParseWithClaims(raw, claims, p.key); err != nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrInvalidToken, err.Error())
+	}
+	now := time.Now().Unix()
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) || !claims.VerifyAudience(audience, true) || !claims.VerifyExpiresAt(now, true) {
+		return nil, fmt.Errorf("%w: token is not issued for webdp or has expired", errors.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (p *Provider) identity(claims jwt.MapClaims) (Identity, error) {
+	id := Identity{Issuer: p.cfg.Issuer}
+	id.Subject, _ = claims["sub"].(string)
+	id.Username, _ = claims[p.cfg.UsernameClaim].(string)
+	id.Name, _ = claims["name"].(string)
+	if id.Subject == "" || id.Username == "" {
+		return Identity{}, fmt.Errorf("%w: token has no sub or %s", errors.ErrInvalidToken, p.cfg.UsernameClaim)
+	}
+	if id.Name == "" {
+		id.Name = id.Username
+	}
+
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	case string:
+		id.Groups = []string{groups}
+	}
+	return id, nil
+}
+
+// the key a token is signed with, the keys are fetched again if it is not known
+func (p *Provider) key(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.fetched) < KEYS_REFRESH_INTERVAL {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := p.fetchKeys(); err != nil {
+		return nil, err
+	}
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// a token without kid is accepted if the provider has a single key
+func (p *Provider) lookup(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// must be called with the lock held
+func (p *Provider) fetchKeys() error {
+	meta, err := p.discoverLocked()
+	if err != nil {
+		return err
+	}
+	p.fetched = time.Now()
+
+	var set jwks
+	if err := p.getJSON(meta.JwksURI, &set); err != nil {
+		return err
+	}
+	keys, err := set.publicKeys()
+	if err != nil {
+		return err
+	}
+	p.keys = keys
+	return nil
+}
+
+func (p *Provider) discover() (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.discoverLocked()
+}
+
+func (p *Provider) discoverLocked() (*metadata, error) {
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta metadata
+	if err := p.getJSON(strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, err
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: identity provider has issuer %s, expected %s", errors.ErrUnexpected, meta.Issuer, p.cfg.Issuer)
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+func (p *Provider) getJSON(url string, v any) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("%w: identity provider: %s", errors.ErrUnexpected, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: identity provider: %s returned %d", errors.ErrUnexpected, url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: identity provider: %s", errors.ErrUnexpected, err.Error())
+	}
+	return nil
+}
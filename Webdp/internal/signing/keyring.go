@@ -0,0 +1,366 @@
+/*
+Signing of the access tokens. The key ring holds the key that signs new tokens
+and the keys that only verify tokens, every key is identified by the kid in
+the header of the tokens it signs. A key is rotated without logging anyone out
+by adding the new key as a verifying key first, then making it the signing key
+and removing the old key once the tokens it signed have expired.
+*/
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	errors "webdp/internal/api/http"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// the smallest RSA keys that sign tokens
+const MIN_RSA_BITS = 2048
+
+/*
+A key that signs or verifies tokens. Keys of RSA, Ed25519 and ECDSA are
+published in the JWKS, so that others verify the tokens with the public key.
+HMAC keys are secret and verify only the tokens of Webdp itself.
+*/
+type Key struct {
+	id      string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+/*
+The key that signs new tokens and the keys that verify tokens. A verifying key
+can be accepted until a time, after which its tokens are refused.
+*/
+type KeyRing struct {
+	current Key
+	keys    map[string]Key
+	until   map[string]time.Time
+	// the HMAC key of the tokens without kid
+	legacy string
+}
+
+// the public keys of the ring, RFC 7517
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// an HS256 key with the secret, its id does not reveal the secret
+func HMACKey(secret string) Key {
+	sum := sha256.Sum256([]byte(secret))
+	return Key{id: "hs256-" + hex.EncodeToString(sum[:8]), method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+}
+
+/*
+Reads a key from PEM. A private key of RSA, Ed25519 or ECDSA signs and
+verifies, a public key only verifies. RSA keys sign with RS256, Ed25519 keys
+with EdDSA and ECDSA keys with ES256, ES384 or ES512 by their curve. The id of
+the key is its JWK thumbprint, RFC 7638.
+*/
+func ParseKey(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("%w: signing keys should be in PEM format", errors.ErrMissingEnv)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("%w: unsupported PEM block %s of signing key", errors.ErrMissingEnv, block.Type)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: could not parse signing key: %s", errors.ErrMissingEnv, err.Error())
+	}
+
+	k := Key{}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.private, k.public = key, &key.PublicKey
+	case ed25519.PrivateKey:
+		k.private, k.public = key, key.Public()
+	case *ecdsa.PrivateKey:
+		k.private, k.public = key, &key.PublicKey
+	default:
+		k.public = parsed
+	}
+
+	switch key := k.public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < MIN_RSA_BITS {
+			return Key{}, fmt.Errorf("%w: RSA signing keys should have at least %d bits", errors.ErrMissingEnv, MIN_RSA_BITS)
+		}
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			k.method = jwt.SigningMethodES256
+		case elliptic.P384():
+			k.method = jwt.SigningMethodES384
+		case elliptic.P521():
+			k.method = jwt.SigningMethodES512
+		default:
+			return Key{}, fmt.Errorf("%w: unsupported curve of ECDSA signing key", errors.ErrMissingEnv)
+		}
+	default:
+		return Key{}, fmt.Errorf("%w: signing keys should be RSA, Ed25519 or ECDSA keys", errors.ErrMissingEnv)
+	}
+
+	k.id, err = thumbprint(k.jwk())
+	if err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// the id of the key, the kid of the tokens it signs
+func (k Key) Id() string {
+	return k.id
+}
+
+// whether the key is private and can sign tokens
+func (k Key) CanSign() bool {
+	return k.private != nil
+}
+
+func (k Key) isHMAC() bool {
+	_, ok := k.public.([]byte)
+	return ok
+}
+
+// the public key as a JWK, the zero JWK for HMAC keys
+func (k Key) jwk() JWK {
+	j := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+	switch key := k.public.(type) {
+	case *rsa.PublicKey:
+		j.Kty, j.N, j.E = "RSA", b64(key.N.Bytes()), b64(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		j.Kty, j.Crv, j.X = "OKP", "Ed25519", b64(key)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		j.Kty, j.Crv, j.X, j.Y = "EC", key.Curve.Params().Name, b64(key.X.FillBytes(make([]byte, size))), b64(key.Y.FillBytes(make([]byte, size)))
+	default:
+		return JWK{}
+	}
+	return j
+}
+
+// the SHA-256 thumbprint of the required members of the JWK, in lexicographic order
+func thumbprint(j JWK) (string, error) {
+	var members any
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	}
+	bs, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+	return b64(sum[:]), nil
+}
+
+func b64(bs []byte) string {
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// a key ring that signs with current and verifies with current and the others
+func NewKeyRing(current Key, others ...Key) (*KeyRing, error) {
+	if !current.CanSign() {
+		return nil, fmt.Errorf("%w: the signing key %s is a public key", errors.ErrMissingEnv, current.id)
+	}
+	kr := &KeyRing{keys: make(map[string]Key), until: make(map[string]time.Time)}
+	kr.add(current)
+	kr.current = current
+	for _, k := range others {
+		kr.add(k)
+	}
+	return kr, nil
+}
+
+func (kr *KeyRing) add(k Key) {
+	kr.keys[k.id] = k
+	if k.isHMAC() {
+		kr.legacy = k.id
+	}
+}
+
+/*
+Creates the key ring of the configuration. keyFiles is a comma separated list
+of PEM files, the first signs and the others verify. Without key files the
+HMAC secret signs, as it did before there were key files. With key files the
+tokens of the secret are still accepted until legacyUntil, a fixed time after
+the switch, so that switching to key files does not log anyone out. The secret
+is not needed with key files once that time has passed.
+*/
+func LoadKeyRing(secret string, keyFiles string, legacyUntil time.Time) (*KeyRing, error) {
+	keys := make([]Key, 0)
+	for _, path := range strings.Split(keyFiles, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not read signing key %s", errors.ErrMissingEnv, path)
+		}
+		k, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		if secret == "" {
+			return nil, fmt.Errorf("%w: a secret or key files are needed to sign tokens", errors.ErrMissingEnv)
+		}
+		return NewKeyRing(HMACKey(secret))
+	}
+	kr, err := NewKeyRing(keys[0], keys[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" && time.Now().Before(legacyUntil) {
+		kr.AcceptUntil(HMACKey(secret), legacyUntil)
+	}
+	return kr, nil
+}
+
+// adds a key that verifies tokens until the time, and not after it
+func (kr *KeyRing) AcceptUntil(k Key, until time.Time) {
+	if k.id == kr.current.id {
+		return
+	}
+	kr.add(k)
+	kr.until[k.id] = until
+}
+
+// the id of the key that signs new tokens
+func (kr *KeyRing) CurrentId() string {
+	return kr.current.id
+}
+
+// signs the claims with the current key, with its id as kid
+func (kr *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.current.method, claims)
+	token.Header["kid"] = kr.current.id
+	return token.SignedString(kr.current.private)
+}
+
+/*
+Verifies the token with the key of its kid and reads its claims. The algorithm
+of the token has to be the one of the key. Tokens without kid were signed
+before there were key ids, with the HMAC secret.
+*/
+func (kr *KeyRing) Parse(raw string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		k, err := kr.key(t.Header["kid"])
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("token of key %s is signed with %s", k.id, t.Method.Alg())
+		}
+		return k.public, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidToken, err.Error())
+	}
+	return nil
+}
+
+func (kr *KeyRing) key(kid any) (Key, error) {
+	if kid == nil {
+		kid = kr.legacy
+	}
+	id, _ := kid.(string)
+	k, ok := kr.keys[id]
+	if !ok {
+		return Key{}, fmt.Errorf("unknown key %v", kid)
+	}
+	if until, ok := kr.until[id]; ok && !time.Now().Before(until) {
+		return Key{}, fmt.Errorf("key %s is no longer accepted", id)
+	}
+	return k, nil
+}
+
+// the public keys of the ring, HMAC keys are secret and left out
+func (kr *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0)}
+	if j := kr.current.jwk(); j.Kty != "" {
+		set.Keys = append(set.Keys, j)
+	}
+	ids := make([]string, 0, len(kr.keys))
+	for id := range kr.keys {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if id == kr.current.id {
+			continue
+		}
+		if until, ok := kr.until[id]; ok && !time.Now().Before(until) {
+			continue
+		}
+		if j := kr.keys[id].jwk(); j.Kty != "" {
+			set.Keys = append(set.Keys, j)
+		}
+	}
+	return set
+}
@@ -5,50 +5,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 	"webdp/internal/api/http/client"
 	"webdp/internal/api/http/entity"
-	"webdp/internal/api/http/handlers"
-	"webdp/internal/api/http/middlewares"
 	"webdp/internal/api/http/repo/postgres"
-	"webdp/internal/api/http/routes"
+	"webdp/internal/api/http/server"
 	"webdp/internal/api/http/services"
 	"webdp/internal/config"
 	"webdp/internal/config/dbconnection"
+	"webdp/internal/encryption"
+	"webdp/internal/signing"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/mux"
 )
 
-// api versions
-const (
-	VERSION_1 = "/v1"
-	VERSION_2 = "/v2"
-)
-
-type repo struct {
-	users    postgres.UserPostgres
-	tokens   postgres.TokenPostgres
-	datasets postgres.DatasetPostgres
-	budgets  postgres.BudgetPostgres
-}
-
-type service struct {
-	users      services.UserService
-	mockTokens services.TokenService
-	realTokens services.TokenService
-	datasets   services.DatasetService
-	budgets    services.BudgetService
-}
-
-type handler struct {
-	users    handlers.UserHandler
-	login    handlers.LoginHandler
-	datasets handlers.DatasetHandler
-	budgets  handlers.BudgetHandler
-	queries  handlers.QueryHandler
-}
-
 // @title Webdp API - Reworked
 // @version 2.0
 // @description Welcome to the official OpenAPI documentation for WebDP, our versatile API designed to provide transparent interoperability with a range of differentially private frameworks.
@@ -70,25 +41,37 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	keys, err := encryption.NewKeyRing(env.Master_key, env.Old_keys)
+	if err != nil {
+		panic(err)
+	}
+	// tokens of AUTH_SIGN_KEY are accepted until AUTH_SIGN_KEY_UNTIL after switching to key files
+	signKeys, err := signing.LoadKeyRing(env.Auth_key, env.Sign_keys, env.Auth_until)
+	if err != nil {
+		panic(err)
+	}
 
 	// initiate database and client
 	pg, err := dbconnection.ConnectPostgresDB(env.Db_name, env.Db_user, env.Db_pw, env.Db_host, env.Dp_port)
 	if err != nil {
 		panic(err)
 	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotateKeys(pg, keys)
+		return
+	}
+
 	durl := fmt.Sprintf("http://webdp-api:%s/datasets", env.Port_int)
 	client := client.NewDPClient(*engines, durl, nil)
-	repos, services, handlers := initRSH(pg, client, env.Auth_key)
+	repos, services, handlers := server.New(pg, client, keys, signKeys, env)
 
 	// external routes
-	router := mux.NewRouter()
-	router.Use(middlewares.Logger)
-	registerExRoutes(router, VERSION_1, &repos.tokens, handlers)
-	registerExRoutes(router, VERSION_2, &repos.tokens, handlers)
+	router := server.NewRouter(services, handlers)
 
 	// internal routes
 	internalRouter := mux.NewRouter()
-	registerInRoutes(internalRouter, &repos.datasets)
+	server.RegisterInRoutes(internalRouter, &repos.Datasets)
 
 	// start internal server
 	intServ := fmt.Sprintf(":%s", env.Port_int)
@@ -97,83 +80,20 @@ func main() {
 	// create root user
 	go func() {
 		defer fmt.Printf("\n==============\nLogin with username: %s, password: %s\n==============\n", "root", env.Root_pw)
-		createRootUser(pg, services.users, env.Root_pw)
+		createRootUser(pg, services.Users, env.Root_pw)
 	}()
 
+	// purge deleted datasets and superseded versions after the retention period, and expired upload sessions
+	go purgeDatasets(services.Datasets, *client, env.Retention)
+
+	// drop the request and query counts that no longer count
+	go sweepCounts(services.Limits, services.Budgets)
+
 	// start server
 	serv := fmt.Sprintf(":%s", env.Port_ext)
 	log.Fatal(http.ListenAndServe(serv, router))
 }
 
-/*
-Initiates repos, services and handlers
-*/
-func initRSH(db *sql.DB, client *client.DPClient, skey string) (*repo, *service, *handler) {
-
-	// repos
-	repo := &repo{
-		users:    postgres.NewUserPostgres(db),
-		tokens:   postgres.NewTokenPostgres(db),
-		datasets: postgres.NewDatasetPostgres(db),
-		budgets:  postgres.NewBudgetPostgres(db),
-	}
-
-	// services
-	service := &service{
-		users:      services.NewUserService(repo.users),
-		mockTokens: services.NewTokenService(repo.tokens, []byte(""), jwt.SigningMethodNone),
-		realTokens: services.NewTokenService(repo.tokens, []byte(skey), jwt.SigningMethodHS256),
-		datasets:   services.NewDatasetService(repo.datasets, repo.budgets),
-		budgets:    services.NewBudgetService(repo.budgets),
-	}
-
-	// handlers
-	handler := &handler{
-		users:    handlers.NewUserHandler(service.users, service.mockTokens),
-		datasets: handlers.NewDatasetHandler(service.datasets, service.users, service.budgets, *client),
-		login:    handlers.NewLoginHandler(service.users, service.realTokens),
-		budgets:  handlers.NewBudgetHandler(service.budgets, service.datasets),
-		queries:  handlers.NewQueryHandler(service.datasets, service.budgets, *client),
-	}
-
-	return repo, service, handler
-}
-
-/*
-Registers external routes
-*/
-func registerExRoutes(r *mux.Router, version string, tokenrepo *postgres.TokenPostgres, handler *handler) {
-	router := r.PathPrefix(version).Subrouter()
-	notoken := router.PathPrefix("").Subrouter()
-	token := router.PathPrefix("").Subrouter()
-	token.Use(middlewares.GetTokenAuthentication(*tokenrepo))
-
-	routes.RegisterLogout(token, handler.login)
-	routes.RegisterLogin(notoken, handler.login)
-
-	if version == VERSION_1 {
-		routes.RegisterUserV1(token, handler.users)
-		routes.RegisterDatasetsV1(token, handler.datasets)
-		routes.RegisterBudgetsV1(token, handler.budgets)
-		routes.RegisterQueriesV1(token, handler.queries)
-	} else if version == VERSION_2 {
-		routes.RegisterUserV2(token, handler.users)
-		routes.RegisterDatasetsV2(token, handler.datasets)
-		routes.RegisterBudgetsV2(token, handler.budgets)
-		routes.RegisterQueriesV2(token, handler.queries)
-		routes.RegisterSpec(router) // no auth for this one
-	}
-}
-
-/*
-Registers internal routes.
-*/
-func registerInRoutes(ir *mux.Router, datasetrepo *postgres.DatasetPostgres) {
-	serv := services.NewInternalDatasetService(*datasetrepo)
-	hand := handlers.NewInternalDatasetHandler(*serv)
-	routes.RegisterInternalDatasets(ir, *hand)
-}
-
 /*
 Creates new root user.
 First deletes any old root user.
@@ -188,12 +108,84 @@ func createRootUser(pg *sql.DB, s services.UserService, pw string) {
 			break
 		}
 	}
-	s.DeleteUser("root")
+	s.DeleteUser(entity.SystemActor(""), "root")
 	root := entity.UserPost{
 		Handle: "root",
 		PWD:    pw,
 		Name:   "root",
 		Roles:  []string{entity.ADMIN, entity.CURATOR, entity.ANALYST},
 	}
-	s.CreateUser(root)
+	s.CreateUser(entity.SystemActor(""), root)
+}
+
+/*
+Re-wraps the data keys that are wrapped by an old master key with the current
+master key. Run as "main rotate-keys" with the new key in DATA_MASTER_KEY and
+the replaced keys in DATA_MASTER_KEY_OLD.
+*/
+func rotateKeys(pg *sql.DB, keys *encryption.KeyRing) {
+	rotated, err := postgres.NewDatasetPostgres(pg, keys).RotateDataKeys()
+	fmt.Printf("Re-wrapped %d data keys with master key %s.\n", rotated, keys.CurrentId())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// how often deleted datasets, superseded versions past the retention period and expired upload sessions are purged
+const PURGE_INTERVAL = time.Hour
+
+/*
+Purges the datasets that were deleted and the versions of the data that were
+superseded longer than the retention period ago, and the upload sessions that
+expired, once every PURGE_INTERVAL. The engines drop their cached versions of
+the datasets.
+*/
+func purgeDatasets(s services.DatasetService, client client.DPClient, retention time.Duration) {
+	for {
+		purged, err := s.PurgeDatasets(entity.SystemActor(""), time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Purging deleted datasets: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted datasets.", purged)
+		}
+
+		datasets, err := s.PurgeVersions(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Purging superseded versions: %v", err)
+		}
+		for _, dataset := range datasets {
+			client.RemoveDatasetFromEngineCache(dataset)
+		}
+		if len(datasets) > 0 {
+			log.Printf("Purged superseded versions of %d datasets.", len(datasets))
+		}
+
+		sessions, err := s.PurgeUploadSessions(time.Now())
+		if err != nil {
+			log.Printf("Purging expired upload sessions: %v", err)
+		} else if sessions > 0 {
+			log.Printf("Purged %d expired upload sessions.", sessions)
+		}
+		time.Sleep(PURGE_INTERVAL)
+	}
+}
+
+// how often the request counts of the rate limits and the query counts of past days are dropped
+const SWEEP_INTERVAL = 10 * time.Minute
+
+/*
+Deletes the request counts of the windows of the rate limits that are over and
+the query counts of the days before today, once every SWEEP_INTERVAL.
+*/
+func sweepCounts(limits services.RateLimitService, budgets services.BudgetService) {
+	for {
+		now := time.Now()
+		if err := limits.Sweep(now); err != nil {
+			log.Printf("Sweeping request counts: %v", err)
+		}
+		if err := budgets.SweepQueryCounts(now); err != nil {
+			log.Printf("Sweeping query counts: %v", err)
+		}
+		time.Sleep(SWEEP_INTERVAL)
+	}
 }
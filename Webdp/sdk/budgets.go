@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+)
+
+// the budgets the user has on datasets
+func (c *Client) UserBudgets(ctx context.Context, handle string) ([]UserBudget, error) {
+	var budgets []UserBudget
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/budgets/users" + path(handle)}, &budgets)
+	return budgets, err
+}
+
+// the total budget of the dataset and how it is allocated to users
+func (c *Client) DatasetBudget(ctx context.Context, dataset int64) (DatasetBudget, error) {
+	var budget DatasetBudget
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/budgets/datasets" + path(dataset)}, &budget)
+	return budget, err
+}
+
+// the budget allocated to the user on the dataset
+func (c *Client) Allocation(ctx context.Context, handle string, dataset int64) (Budget, error) {
+	var budget Budget
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/budgets/allocations" + path(handle, dataset)}, &budget)
+	return budget, err
+}
+
+func (c *Client) Allocate(ctx context.Context, handle string, dataset int64, budget Budget) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/budgets/allocations" + path(handle, dataset), body: budget}, nil)
+	return err
+}
+
+func (c *Client) UpdateAllocation(ctx context.Context, handle string, dataset int64, budget Budget) error {
+	_, err := c.do(ctx, request{method: http.MethodPatch, path: "/budgets/allocations" + path(handle, dataset), body: budget}, nil)
+	return err
+}
+
+func (c *Client) DeleteAllocation(ctx context.Context, handle string, dataset int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/budgets/allocations" + path(handle, dataset)}, nil)
+	return err
+}
+
+// the queries per day the user can make on the dataset, and how many it made today
+func (c *Client) QueryQuota(ctx context.Context, handle string, dataset int64) (QueryQuota, error) {
+	var quota QueryQuota
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/budgets/allocations" + path(handle, dataset, "quota")}, &quota)
+	return quota, err
+}
+
+func (c *Client) SetQueryQuota(ctx context.Context, handle string, dataset int64, quota QueryQuotaPut) error {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/budgets/allocations" + path(handle, dataset, "quota"), body: quota}, nil)
+	return err
+}
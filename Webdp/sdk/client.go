@@ -0,0 +1,265 @@
+/*
+A client of the v2 API of Webdp. The client logs in once and keeps its tokens,
+the access token is refreshed before it expires and again when the API refuses
+it. Requests and responses are the types of the API of Webdp, such as Query and
+Budget. Errors of the API are returned as *Error, which wraps the error of its
+status:
+
+	c := sdk.NewClient("http://localhost:8080")
+	if err := c.Login(ctx, sdk.LoginRequest{Username: "anna", PWD: "..."}); err != nil {
+		return err
+	}
+	q := sdk.NewQuery().Filter("age > 18").Count("age", sdk.Mech("Laplace")).Query()
+	result, err := c.Evaluate(ctx, sdk.QueryEvaluate{Dataset: 1, Budget: sdk.Budget{Epsilon: 0.1}, Query: q}, "")
+*/
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the path of the API below the address of Webdp
+const API_PATH = "/v2"
+
+// access tokens that expire within this time are refreshed before a request
+const REFRESH_MARGIN = 30 * time.Second
+
+type Client struct {
+	base string
+	http *http.Client
+
+	// guards the tokens, a refresh holds it so that a refresh token is used once
+	mu     sync.Mutex
+	token  Token
+	apiKey string
+}
+
+type Option func(*Client)
+
+// sends the requests with the HTTP client, http.DefaultClient otherwise
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// authenticates with the API key instead of logging in, API keys are not refreshed
+func WithApiKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// a client of the Webdp at the address, such as http://localhost:8080
+func NewClient(address string, opts ...Option) *Client {
+	c := &Client{base: strings.TrimSuffix(address, "/") + API_PATH, http: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// logs in and keeps the tokens of the new session
+func (c *Client) Login(ctx context.Context, login LoginRequest) error {
+	var token Token
+	if _, err := c.send(ctx, request{method: http.MethodPost, path: "/login", body: login}, "", &token); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	return nil
+}
+
+// refreshes the tokens of the session now, which the client otherwise does when needed
+func (c *Client) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refresh(ctx)
+}
+
+// ends the session of the client
+func (c *Client) Logout(ctx context.Context) error {
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/logout"}, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = Token{}
+	return nil
+}
+
+// the tokens of the session, to keep them between runs
+func (c *Client) Tokens() Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// continues a session with tokens that Tokens returned
+func (c *Client) SetTokens(token Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// the refresh token is used once, so the caller holds the lock
+func (c *Client) refresh(ctx context.Context) error {
+	if c.token.RefreshToken == "" {
+		return fmt.Errorf("%w: no session to refresh, log in first", ErrUnauthorized)
+	}
+	var token Token
+	req := request{method: http.MethodPost, path: "/refresh", body: refreshRequest{RefreshToken: c.token.RefreshToken}}
+	if _, err := c.send(ctx, req, "", &token); err != nil {
+		return err
+	}
+	c.token = token
+	return nil
+}
+
+// the token of the requests, the access token is refreshed if it is about to expire
+func (c *Client) bearer(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.apiKey != "" {
+		return c.apiKey, nil
+	}
+	if c.token.Token == "" {
+		return "", fmt.Errorf("%w: not logged in", ErrUnauthorized)
+	}
+	if time.Until(time.Unix(c.token.ExpiresAt, 0)) < REFRESH_MARGIN {
+		if err := c.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token.Token, nil
+}
+
+// refreshes the tokens after the API refused the access token, unless another request did already
+func (c *Client) refused(ctx context.Context, refused string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.apiKey != "" || c.token.Token != refused {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+/*
+A request to the API. The body is sent as JSON, unless data is set, which is
+sent as it is with the content type. Data that can not seek is not sent again
+after a refresh.
+*/
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	data        io.Reader
+	contentType string
+}
+
+/*
+Sends an authenticated request and reads the JSON of the response into out,
+unless out is nil or the response has no content. A request refused for its
+access token is sent again once after the tokens are refreshed.
+*/
+func (c *Client) do(ctx context.Context, req request, out any) (http.Header, error) {
+	token, err := c.bearer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seeker, replay := req.data.(io.Seeker)
+	var start int64
+	if replay {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	header, err := c.send(ctx, req, token, out)
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusUnauthorized || (req.data != nil && !replay) {
+		return header, err
+	}
+	if replay {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return header, err
+		}
+	}
+	if err := c.refused(ctx, token); err != nil {
+		return header, err
+	}
+	if token, err = c.bearer(ctx); err != nil {
+		return header, err
+	}
+	return c.send(ctx, req, token, out)
+}
+
+func (c *Client) send(ctx context.Context, req request, token string, out any) (http.Header, error) {
+	// the transport closes a body that can be closed, such as a file of the caller
+	body := req.data
+	if _, ok := body.(io.Closer); ok {
+		body = io.NopCloser(body)
+	}
+	contentType := req.contentType
+	if req.body != nil {
+		bs, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = bytes.NewReader(bs), "application/json"
+	}
+
+	u := c.base + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.Header, newError(resp, bs)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(bs)) == 0 {
+		return resp.Header, nil
+	}
+	if err := json.Unmarshal(bs, out); err != nil {
+		return resp.Header, fmt.Errorf("webdp: could not read the response of %s %s: %w", req.method, req.path, err)
+	}
+	return resp.Header, nil
+}
+
+// the path with the escaped parts
+func path(parts ...any) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(fmt.Sprint(p)))
+	}
+	return b.String()
+}
@@ -0,0 +1,243 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// the size of the chunks of resumable uploads, unless UploadOptions.ChunkSize is set
+const DEFAULT_CHUNK_SIZE = 8 << 20
+
+/*
+How data is uploaded. The empty options are the defaults of the API: the
+format is taken from the content type, the data replaces the latest version,
+the consumed budgets are kept and cells that do not fit the schema reject the
+upload. Only uploads with Invalid set answer with an UploadReport.
+*/
+type UploadOptions struct {
+	// FORMAT_CSV, FORMAT_JSONL or FORMAT_PARQUET
+	Format string
+	// VERSION_REPLACE or VERSION_APPEND
+	Mode string
+	// BUDGET_KEEP or BUDGET_RESET
+	Budget string
+	// UPLOAD_REJECT, UPLOAD_CLAMP or UPLOAD_DROP
+	Invalid string
+	// the size of the chunks of a resumable upload, at most MAX_CHUNK_SIZE
+	ChunkSize int
+}
+
+func (o UploadOptions) query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{"format": o.Format, "mode": o.Mode, "budget": o.Budget, "invalid": o.Invalid} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
+
+// which datasets to list, the empty filter lists the first page of all of them by id
+type DatasetFilter struct {
+	Owner         string
+	Loaded        *bool
+	Deleted       bool
+	PrivacyNotion string
+	Tag           string
+	Search        string
+	// SORT_ID, SORT_NAME, SORT_CREATED, SORT_UPDATED or SORT_LOADED
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+func (f DatasetFilter) query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{"owner": f.Owner, "privacy_notion": f.PrivacyNotion, "tag": f.Tag, "search": f.Search} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if f.Loaded != nil {
+		query.Set("loaded", strconv.FormatBool(*f.Loaded))
+	}
+	if f.Deleted {
+		query.Set("deleted", "true")
+	}
+	if f.Sort != "" && f.Sort != SORT_ID {
+		query.Set("sort", f.Sort)
+	}
+	if f.Descending {
+		query.Set("order", "desc")
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		query.Set("offset", strconv.Itoa(f.Offset))
+	}
+	return query
+}
+
+// a page of the datasets the client has access to and the number of datasets that match the filter
+func (c *Client) Datasets(ctx context.Context, filter DatasetFilter) (DatasetPage, error) {
+	var page DatasetPage
+	header, err := c.do(ctx, request{method: http.MethodGet, path: "/datasets", query: filter.query()}, &page.Datasets)
+	if err != nil {
+		return page, err
+	}
+	page.Total, err = strconv.ParseInt(header.Get("X-Total-Count"), 10, 64)
+	if err != nil {
+		page.Total = int64(len(page.Datasets))
+	}
+	return page, nil
+}
+
+func (c *Client) Dataset(ctx context.Context, id int64) (Dataset, error) {
+	var dataset Dataset
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/datasets" + path(id)}, &dataset)
+	return dataset, err
+}
+
+// creates the dataset and returns its id
+func (c *Client) CreateDataset(ctx context.Context, dataset DatasetCreate) (int64, error) {
+	var id idResponse
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/datasets", body: dataset}, &id)
+	return id.Id, err
+}
+
+func (c *Client) UpdateDataset(ctx context.Context, id int64, patch DatasetPatch) error {
+	_, err := c.do(ctx, request{method: http.MethodPatch, path: "/datasets" + path(id), body: patch}, nil)
+	return err
+}
+
+// deletes the dataset, which its owner can restore until it is purged
+func (c *Client) DeleteDataset(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/datasets" + path(id)}, nil)
+	return err
+}
+
+func (c *Client) RestoreDataset(ctx context.Context, id int64) (Dataset, error) {
+	var dataset Dataset
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/datasets" + path(id, "restore")}, &dataset)
+	return dataset, err
+}
+
+// the versions of the data of the dataset
+func (c *Client) Versions(ctx context.Context, id int64) ([]DataVersion, error) {
+	var versions []DataVersion
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/datasets" + path(id, "versions")}, &versions)
+	return versions, err
+}
+
+/*
+Uploads the data in one request. The report is nil unless opts.Invalid is set.
+A data reader that can not seek is not sent again if the access token is
+refused, see UploadResumable for large data.
+*/
+func (c *Client) Upload(ctx context.Context, id int64, data io.Reader, opts UploadOptions) (*UploadReport, error) {
+	var report UploadReport
+	req := request{method: http.MethodPost, path: "/datasets" + path(id, "upload"), query: opts.query(), data: data, contentType: "application/octet-stream"}
+	if _, err := c.do(ctx, req, &report); err != nil {
+		return nil, err
+	}
+	if opts.Invalid == "" {
+		return nil, nil
+	}
+	return &report, nil
+}
+
+/*
+Uploads the data in chunks of a resumable upload. If the upload fails, the
+upload is kept and the error is an *UploadError with its session, which
+ResumeUpload continues from where it stopped.
+*/
+func (c *Client) UploadResumable(ctx context.Context, id int64, data io.Reader, opts UploadOptions) (*UploadReport, error) {
+	var session UploadSession
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/datasets" + path(id, "uploads")}, &session); err != nil {
+		return nil, err
+	}
+	return c.upload(ctx, session, data, opts)
+}
+
+/*
+Continues a resumable upload with the same data, which is read from the size
+the API has of the upload.
+*/
+func (c *Client) ResumeUpload(ctx context.Context, id int64, upload int64, data io.ReadSeeker, opts UploadOptions) (*UploadReport, error) {
+	var session UploadSession
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/datasets" + path(id, "uploads", upload)}, &session); err != nil {
+		return nil, err
+	}
+	if _, err := data.Seek(session.Size, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return c.upload(ctx, session, data, opts)
+}
+
+// drops a resumable upload and its chunks
+func (c *Client) CancelUpload(ctx context.Context, id int64, upload int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/datasets" + path(id, "uploads", upload)}, nil)
+	return err
+}
+
+// a resumable upload that failed, Session is where it stopped
+type UploadError struct {
+	Session UploadSession
+	Err     error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("webdp: upload %d of dataset %d stopped at %d bytes: %v", e.Session.Id, e.Session.Dataset, e.Session.Size, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Client) upload(ctx context.Context, session UploadSession, data io.Reader, opts UploadOptions) (*UploadReport, error) {
+	size := opts.ChunkSize
+	if size <= 0 || size > MAX_CHUNK_SIZE {
+		size = DEFAULT_CHUNK_SIZE
+	}
+	sessionPath := "/datasets" + path(session.Dataset, "uploads", session.Id)
+
+	chunk := make([]byte, size)
+	for {
+		n, err := io.ReadFull(data, chunk)
+		if n > 0 {
+			req := request{
+				method:      http.MethodPut,
+				path:        sessionPath,
+				query:       url.Values{"offset": {strconv.FormatInt(session.Size, 10)}},
+				data:        bytes.NewReader(chunk[:n]),
+				contentType: "application/octet-stream",
+			}
+			if _, err := c.do(ctx, req, &session); err != nil {
+				return nil, &UploadError{Session: session, Err: err}
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, &UploadError{Session: session, Err: err}
+		}
+	}
+
+	var report UploadReport
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: sessionPath + "/complete", query: opts.query()}, &report); err != nil {
+		return nil, &UploadError{Session: session, Err: err}
+	}
+	if opts.Invalid == "" {
+		return nil, nil
+	}
+	return &report, nil
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the errors of the statuses of the API, which the errors of the client wrap
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnexpected      = errors.New("unexpected error")
+	ErrNotImplemented  = errors.New("not implemented")
+)
+
+/*
+An error response of the API. It wraps the error of its status, so that
+errors.Is(err, sdk.ErrNotFound) tells a missing resource apart. RetryAfter is
+how long to wait before trying again, for requests refused by the rate limits,
+quotas and login lockouts.
+*/
+type Error struct {
+	Status     int
+	Title      string
+	Detail     string
+	Type       string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webdp: %d %s: %s", e.Status, e.Title, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.Status {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	case http.StatusNotImplemented:
+		return ErrNotImplemented
+	default:
+		return ErrUnexpected
+	}
+}
+
+/*
+Reads the error of a response. Handlers answer with an error object,
+the middlewares with a line of text, which becomes the detail.
+*/
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	var r errorResponse
+	if err := json.Unmarshal(body, &r); err == nil && r.Status != 0 {
+		e.Title, e.Detail, e.Type = r.Title, r.Detail, r.Type
+	} else {
+		e.Detail = strings.TrimSpace(string(body))
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return e
+}
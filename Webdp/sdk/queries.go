@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// evaluates the query with the engine, the default engine if it is empty
+func (c *Client) Evaluate(ctx context.Context, query QueryEvaluate, engine string) (QueryResult, error) {
+	var result QueryResult
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/queries/evaluate", query: engineQuery(engine), body: query}, &result)
+	return result, err
+}
+
+// validates the query with the engine, without spending budget
+func (c *Client) Validate(ctx context.Context, query QueryEvaluate, engine string) (ValidateResponse, error) {
+	var result ValidateResponse
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/queries/validate", query: engineQuery(engine), body: query}, &result)
+	return result, err
+}
+
+// validates the query with each engine, by the name of the engine
+func (c *Client) ValidateAll(ctx context.Context, query QueryEvaluate) (map[string]ValidateResponse, error) {
+	var result map[string]ValidateResponse
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/queries/validate", body: query}, &result)
+	return result, err
+}
+
+// the accuracy of each measurement of the query at the confidence
+func (c *Client) Accuracy(ctx context.Context, query QueryAccuracy, engine string) ([]float64, error) {
+	var accuracy []float64
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/queries/accuracy", query: engineQuery(engine), body: query}, &accuracy)
+	return accuracy, err
+}
+
+// the names of the engines
+func (c *Client) Engines(ctx context.Context) ([]string, error) {
+	var engines []string
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/queries/engines"}, &engines)
+	return engines, err
+}
+
+func engineQuery(engine string) url.Values {
+	if engine == "" {
+		return nil
+	}
+	return url.Values{"engine": {engine}}
+}
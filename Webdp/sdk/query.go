@@ -0,0 +1,114 @@
+package sdk
+
+import "webdp/internal/api/http/entity"
+
+/*
+Builds the steps of a query in order, transformations first and the
+measurements last:
+
+	q := sdk.NewQuery().
+		Select("age", "income").
+		Filter("age > 18").
+		Mean("income", sdk.Mech("Laplace"), sdk.WithBudget(sdk.Budget{Epsilon: 0.5})).
+		Query()
+*/
+type QueryBuilder struct {
+	steps []QueryStep
+}
+
+func NewQuery() *QueryBuilder {
+	return &QueryBuilder{steps: make([]QueryStep, 0)}
+}
+
+// the query with the steps so far
+func (b *QueryBuilder) Query() Query {
+	return Query{QuerySteps: append([]QueryStep(nil), b.steps...)}
+}
+
+func (b *QueryBuilder) add(step QueryStep) *QueryBuilder {
+	b.steps = append(b.steps, step)
+	return b
+}
+
+func (b *QueryBuilder) Select(columns ...string) *QueryBuilder {
+	return b.add(entity.SelectTransformation{Columns: columns})
+}
+
+// keeps the rows that match all the filters, such as "age > 18"
+func (b *QueryBuilder) Filter(filters ...string) *QueryBuilder {
+	return b.add(entity.FilterTransformation{Filters: filters})
+}
+
+// renames the columns, from the old name to the new one
+func (b *QueryBuilder) Rename(mapping map[string]string) *QueryBuilder {
+	return b.add(entity.RenameTransformation{Mapping: mapping})
+}
+
+// maps the rows with the function of the engine to the columns of the schema
+func (b *QueryBuilder) Map(fun string, schema []ColumnSchema) *QueryBuilder {
+	return b.add(entity.MapTransformation{Mapping: entity.ColumnMapping{Fun: fun, Schema: schema}})
+}
+
+// bins the values of the columns by the bin edges
+func (b *QueryBuilder) Bin(bins map[string][]any) *QueryBuilder {
+	return b.add(entity.BinTransformation{Bins: bins})
+}
+
+// partitions the rows by the values of the columns, the measurements after it are per group
+func (b *QueryBuilder) GroupBy(groups map[string][]any) *QueryBuilder {
+	return b.add(entity.GroupByPartition{Grouping: groups})
+}
+
+// an option of a measurement
+type MeasurementOption func(*MeasurementParams)
+
+// the mechanism of the measurement, the default of the engine otherwise
+func Mech(mech string) MeasurementOption {
+	return func(p *MeasurementParams) {
+		p.Mech = &mech
+	}
+}
+
+// the budget of the measurement, the measurements without one share what is left of the budget of the query
+func WithBudget(budget Budget) MeasurementOption {
+	return func(p *MeasurementParams) {
+		p.Budget = &budget
+	}
+}
+
+func measurement(column string, opts []MeasurementOption) MeasurementParams {
+	p := MeasurementParams{Column: &column}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+func (b *QueryBuilder) Count(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.CountMeasurement{Params: measurement(column, opts)})
+}
+
+func (b *QueryBuilder) Min(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.MinMeasurement{Params: measurement(column, opts)})
+}
+
+func (b *QueryBuilder) Max(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.MaxMeasurement{Params: measurement(column, opts)})
+}
+
+func (b *QueryBuilder) Mean(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.MeanMeasurement{Params: measurement(column, opts)})
+}
+
+func (b *QueryBuilder) Sum(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.SumMeasurement{Params: measurement(column, opts)})
+}
+
+func (b *QueryBuilder) Median(column string, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.MedianMeasurement{Params: measurement(column, opts)})
+}
+
+// the quantiles of the column at the ranks, which are between 0 and 1
+func (b *QueryBuilder) Quantile(column string, ranks []float64, opts ...MeasurementOption) *QueryBuilder {
+	return b.add(entity.QuantileMeasurement{Params: entity.QuantileParams{MeasurementParams: measurement(column, opts), Ranks: ranks}})
+}
@@ -0,0 +1,117 @@
+package sdk
+
+import (
+	"webdp/internal/api/http/client"
+	"webdp/internal/api/http/entity"
+	"webdp/internal/api/http/response"
+)
+
+/*
+The requests and responses of the API are the types of Webdp, so that the
+client writes and reads the same JSON as the server.
+*/
+
+type (
+	LoginRequest   = entity.LoginRequest
+	refreshRequest = entity.RefreshRequest
+	Token          = response.Token
+	idResponse     = response.Id
+	errorResponse  = response.Error
+)
+
+type (
+	User           = entity.UserResponse
+	UserPost       = entity.UserPost
+	UserPatch      = entity.UserPatch
+	PasswordChange = entity.PasswordChange
+)
+
+type (
+	Dataset       = entity.DatasetInfo
+	DatasetCreate = entity.DatasetCreate
+	// the fields left out are not changed, the name, owner and total budget are always set
+	DatasetPatch  = entity.DatasetPatch
+	DatasetPage   = entity.DatasetPage
+	ColumnSchema  = entity.ColumnSchema
+	DataType      = entity.DataType
+	DataVersion   = entity.DataVersion
+	UploadReport  = entity.UploadReport
+	UploadSession = entity.UploadSession
+)
+
+// the type of a column of a schema, with the bounds of the column
+func IntType(low, high int32) DataType {
+	return DataType{Type: &entity.IntType{Low: low, High: high}}
+}
+
+func DoubleType(low, high float64) DataType {
+	return DataType{Type: &entity.DoubleType{Low: low, High: high}}
+}
+
+func EnumType(labels ...string) DataType {
+	return DataType{Type: &entity.EnumType{Labels: labels}}
+}
+
+func TextType() DataType {
+	return DataType{Type: &entity.TextType{}}
+}
+
+func BoolType() DataType {
+	return DataType{Type: &entity.BoolType{}}
+}
+
+type (
+	Budget           = entity.Budget
+	UserBudget       = entity.UserBudgetsResponse
+	DatasetBudget    = entity.DatasetBudgetAllocationResponse
+	BudgetAllocation = entity.UserBudgetModel
+	QueryQuota       = entity.QueryQuota
+	QueryQuotaPut    = entity.QueryQuotaPut
+)
+
+type (
+	// the steps of a query, see QueryBuilder
+	Query             = entity.Query
+	QueryStep         = entity.QueryStep
+	QueryEvaluate     = entity.QueryEvaluate
+	QueryAccuracy     = entity.QueryAccuracy
+	QueryResult       = entity.QueryResult
+	ValuePolicy       = entity.ValuePolicy
+	MeasurementParams = entity.MeasurementParams
+	ValidateResponse  = client.ValidateResponse
+)
+
+// the privacy notions of datasets
+const (
+	PURE   = entity.PURE
+	APPROX = entity.APPROX
+)
+
+// the options of uploads, see UploadOptions
+const (
+	FORMAT_CSV     = entity.FORMAT_CSV
+	FORMAT_JSONL   = entity.FORMAT_JSONL
+	FORMAT_PARQUET = entity.FORMAT_PARQUET
+
+	VERSION_REPLACE = entity.VERSION_REPLACE
+	VERSION_APPEND  = entity.VERSION_APPEND
+
+	BUDGET_KEEP  = entity.BUDGET_KEEP
+	BUDGET_RESET = entity.BUDGET_RESET
+
+	UPLOAD_REJECT = entity.UPLOAD_REJECT
+	UPLOAD_CLAMP  = entity.UPLOAD_CLAMP
+	UPLOAD_DROP   = entity.UPLOAD_DROP
+)
+
+// the largest chunk of a resumable upload the API takes
+const MAX_CHUNK_SIZE = entity.MAX_CHUNK_SIZE
+
+// the sort orders of datasets
+const (
+	SORT_ID      = entity.SORT_ID
+	SORT_NAME    = entity.SORT_NAME
+	SORT_CREATED = entity.SORT_CREATED
+	SORT_UPDATED = entity.SORT_UPDATED
+	SORT_LOADED  = entity.SORT_LOADED
+)
@@ -0,0 +1,39 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) Users(ctx context.Context) ([]User, error) {
+	var users []User
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/users"}, &users)
+	return users, err
+}
+
+func (c *Client) User(ctx context.Context, handle string) (User, error) {
+	var user User
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/users" + path(handle)}, &user)
+	return user, err
+}
+
+func (c *Client) CreateUser(ctx context.Context, user UserPost) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/users", body: user}, nil)
+	return err
+}
+
+func (c *Client) UpdateUser(ctx context.Context, handle string, patch UserPatch) error {
+	_, err := c.do(ctx, request{method: http.MethodPatch, path: "/users" + path(handle), body: patch}, nil)
+	return err
+}
+
+func (c *Client) DeleteUser(ctx context.Context, handle string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/users" + path(handle)}, nil)
+	return err
+}
+
+// changes the password of the user, which is the user of the client unless it may manage users
+func (c *Client) ChangePassword(ctx context.Context, handle string, change PasswordChange) error {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/users" + path(handle, "password"), body: change}, nil)
+	return err
+}
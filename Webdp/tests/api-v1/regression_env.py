@@ -29,7 +29,7 @@ ApproxDP  = lambda epsilon, delta : {"epsilon": epsilon, "delta": delta}
 
 @pytest.fixture
 def roottoken():
-    root = loginjson("root", "123")
+    root = loginjson("root", "Root-Secret-1")
     response = requests.post(URL("login"), json=root)
     assert response.status_code == 200
     return response.json()["jwt"]
@@ -87,25 +87,25 @@ def users():
         {
             "handle": "analystUser",
             "name": "Mr. analyst",
-            "password": "foobar1",
+            "password": "Foobar-pass-1",
             "roles": ["Analyst"],
         },
         {
             "handle": "analystUserNoBudget",
             "name": "Mr. analystNoBudget",
-            "password": "foobar1",
+            "password": "Foobar-pass-1",
             "roles": ["Analyst"],
         },
         {
             "handle": "curatorUser",
             "name": "Mr. curator",
-            "password": "foobar2",
+            "password": "Foobar-pass-2",
             "roles": ["Curator"],
         },
         {
             "handle": "adminUser",
             "name": "Mr. admin",
-            "password": "foobar3",
+            "password": "Foobar-pass-3",
             "roles": ["Admin"],
         },
     ]
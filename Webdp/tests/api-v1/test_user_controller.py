@@ -10,7 +10,7 @@ def test_create_user(make_test_env, headers_default):
     new_user = {
         "handle": "foo",
         "name": "Mr. foo",
-        "password": "foo123",
+        "password": "Test-pass-123",
         "roles": ["Curator"],
     }
 
@@ -43,6 +43,7 @@ def clean_users():
   response = requests.delete(URL_USER(analyst["handle"]), headers=head)
   response = requests.delete(URL_USER(curana["handle"]), headers=head)
   response = requests.delete(URL_USER("curatorUser"), headers=head)
+  response = requests.delete(URL_USER(tenant["handle"]), headers=head)
   do_logout(head)
 
 #######################################
@@ -57,6 +58,13 @@ def root_dataset():
   do_logout(head)
   return str(response.json()["id"])
 
+# the proposed owner accepts the ownership of a dataset
+def accept_transfer(did, login):
+  head = do_login(login)
+  response = requests.post(URL_DATASET(did)+"/transfer/accept", headers=head)
+  assert response.status_code in SUCCESS
+  do_logout(head)
+
 @pytest.fixture
 def curator_dataset():
   head = do_login(curator_login)
@@ -84,6 +92,7 @@ def analyst_dataset():
   response = requests.patch(URL_DATASET(did), json=data_patch_analyst, headers=head)
   assert response.status_code in SUCCESS
   do_logout(head)
+  accept_transfer(did, analyst_login)
   return did
 
 @pytest.fixture
@@ -101,10 +110,11 @@ def admin_dataset():
   response = requests.post(URL_DATASETS, json=data_root, headers=head)
   assert response.status_code in SUCCESS
   did = str(response.json()["id"])
-  # patch to analyst owner
+  # patch to admin owner
   response = requests.patch(URL_DATASET(did), json=data_patch_admin, headers=head)
   assert response.status_code in SUCCESS
   do_logout(head)
+  accept_transfer(did, admin_login)
   return did
 
 @pytest.fixture
@@ -125,7 +135,7 @@ def granted_access(curator_dataset, analyst_dataset):
 def clean_datasets():
   h = do_login(root_login)
   print("deleting root datasets (if present)")
-  response = requests.get(URL_DATASETS, headers=h)
+  response = requests.get(URL_DATASETS, params={"limit": 1000}, headers=h)
   if response.status_code == 200:
     data = response.json()
     print("- number of datasets:", len(data))
@@ -3,74 +3,90 @@
 # USERS
 #######################################
 
-root_login = { "username" : "root", "password" : "123" }
+root_login = { "username" : "root", "password" : "Root-Secret-1" }
 
 root_patch = {
   "name": "Ruth the Root",
-  # "password": "123",
+  # "password": "Root-Secret-1",
   "roles": ["Admin", "Curator", "Analyst"]
 }
 
-admin_login = { "username" : "adde", "password" : "add123" }
+admin_login = { "username" : "adde", "password" : "Admin-pass-1" }
 
 admin = {
   "handle": "adde",
   "name": "Adrian the Admin",
-  "password": "add123",
+  "password": "Admin-pass-1",
   "roles": ["Admin"]
 }
 
-curator_login = { "username" : "curt", "password" : "cur123" }
+curator_login = { "username" : "curt", "password" : "Curator-pass-1" }
 
 curator = {
   "handle": "curt",
   "name": "Curt the Curator",
-  "password": "cur123",
+  "password": "Curator-pass-1",
   "roles": ["Curator"]
 }
 
 curator_patch = {
   "name": "Curt the Creative Curator",
-  "password": "cur123",
+  "password": "Curator-pass-1",
   "roles": ["Curator"]
 }
 
-analyst_login = { "username" : "anna", "password" : "ana123" }
+analyst_login = { "username" : "anna", "password" : "Analyst-pass-1" }
 
 analyst = {
   "handle": "anna",
   "name": "Anna the Analyst",
-  "password": "ana123",
+  "password": "Analyst-pass-1",
   "roles": ["Analyst"]
 }
 
 analyst_patch = {
   "name": "Anna the Amazing Analyst",
-  "password": "ana123",
+  "password": "Analyst-pass-1",
   "roles": ["Analyst"]
 }
 
-curana_login = { "username" : "curtarne", "password" : "curry" }
+curana_login = { "username" : "curtarne", "password" : "Curana-pass-1" }
 
 curana = {
   "handle": "curtarne",
   "name": "Curt-Arne the Analyst",
-  "password": "curry",
+  "password": "Curana-pass-1",
   "roles": ["Analyst"]
 }
 
 curana_patch = {
   "name": "Curt-Arne the Curator and Analyst",
-  "password": "curry",
+  "password": "Curana-pass-1",
   "roles": ["Curator", "Analyst"]
 }
 
-tester_login = { "username" : "timmy", "password" : "pass" }
+# an admin of another organization than root
+tenant_login = { "username" : "tina", "password" : "Tenant-pass-1" }
+
+tenant = {
+  "handle": "tina",
+  "name": "Tina the Tenant Admin",
+  "password": "Tenant-pass-1",
+  "organization": "acme",
+  "roles": ["Admin", "Curator"]
+}
+
+organization = {
+  "handle": "acme",
+  "name": "Acme"
+}
+
+tester_login = { "username" : "timmy", "password" : "Tester-pass-1" }
 
 tester = {
   "handle": "timmy",
   "name": "Timmy the Tester",
-  "password": "pass",
+  "password": "Tester-pass-1",
   "roles": ["root"] # invalid; bad request
 }
 
@@ -6,9 +6,26 @@ URL = "http://localhost:8080/v2/"
 
 URL_LOGIN               =                  URL + "login"
 URL_LOGOUT              =                  URL + "logout"
+URL_REFRESH             =                  URL + "refresh"
+URL_SESSIONS            =                  URL + "sessions"
+URL_SESSION             = lambda id:       URL_SESSIONS + f"/{id}"
+URL_JWKS                =                  URL + ".well-known/jwks.json"
 
 URL_USERS               =                  URL + "users"
 URL_USER                = lambda user:     URL_USERS + f"/{user}"
+URL_USER_PASSWORD       = lambda user:     URL_USER(user) + "/password"
+URL_USER_LOCKOUT        = lambda user:     URL_USER(user) + "/lockout"
+
+URL_ROLES               =                  URL + "roles"
+URL_ROLE                = lambda role:     URL_ROLES + f"/{role}"
+URL_PERMISSIONS         =                  URL + "permissions"
+
+URL_ORGANIZATIONS       =                  URL + "organizations"
+URL_ORGANIZATION        = lambda org:      URL_ORGANIZATIONS + f"/{org}"
+
+URL_AUDIT               =                  URL + "audit"
+URL_AUDIT_EXPORT        =                  URL_AUDIT + "/export"
+URL_AUDIT_VERIFY        =                  URL_AUDIT + "/verify"
 
 URL_DATASETS            =                  URL + "datasets"
 URL_DATASET             = lambda id:       URL_DATASETS + f"/{id}"
@@ -17,6 +34,7 @@ URL_BUDGETS             =                  URL + "budgets"
 URL_USER_BUDGET         = lambda user:     URL_BUDGETS + f"/users/{user}"
 URL_DATASET_BUDGET      = lambda id:       URL_BUDGETS + f"/datasets/{id}"
 URL_USER_DATASET_BUDGET = lambda user, id: URL_BUDGETS + f"/allocations/{user}/{id}"
+URL_QUERY_QUOTA         = lambda user, id: URL_USER_DATASET_BUDGET(user, id) + "/quota"
 
 URL_Q                   =                  URL + "queries"
 URL_Q_ENGINES           =                  URL_Q + "/engines"
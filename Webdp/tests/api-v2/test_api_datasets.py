@@ -72,6 +72,72 @@ PU2                       ¬ owner           and   exists
 PU3                                             ¬ exists
 PU4 badly formatted schema
 PU5 badly formatted data
+PU6                         owner, gzip compressed CSV
+PU7                         owner, JSON Lines
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+RESUMABLE UPLOAD (req: is owner)
+---------------------------------------------------------------
+RU1                         owner, chunks in order, complete
+RU2                         owner, chunk at wrong offset
+RU3                       ¬ owner
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+DATA VERSIONS (req: is owner)
+---------------------------------------------------------------
+VE1                         owner, replace twice
+VE2                         owner, append
+VE3                         owner, unknown mode
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+INFER SCHEMA (req: curator)
+---------------------------------------------------------------
+IS1     curator, proposal is posted as a dataset
+IS2   ¬ curator
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+SCHEMA PATCH (req: curator and is owner)
+---------------------------------------------------------------
+SC1     owner, loaded data rewritten and change recorded
+SC2     owner, added column without default on loaded data
+SC3   ¬ owner
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+METADATA AND LISTS (req: admin/curator or granted access)
+---------------------------------------------------------------
+ML1     curator, metadata, filters and pages
+ML2     curator, patch keeps left out metadata
+ML3     curator, invalid filter
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+MEMBERS AND TRANSFERS (req: owner manages members and transfers)
+---------------------------------------------------------------
+ME1     owner, viewer reads without budget
+ME2     owner, co-curator uploads but cannot delete or add members
+ME3     owner, transfer takes effect when accepted
+ME4     owner, transfer accepted by someone else (fail) and cancelled
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+DELETE AND RESTORE (req: owner, or admin to restore)
+---------------------------------------------------------------
+DR1     owner, deleted dataset is hidden until it is restored
+DR2   ¬ owner and ¬ admin, restore (fail)
+DR3     owner, restore of a dataset that is not deleted (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+API KEYS (req: admin or self, logged in)
+---------------------------------------------------------------
+AK1     self, key limited to one dataset
+AK2     self, key use is recorded and revoked key (fail)
+AK3   ¬ admin and ¬ self, role of other user, key creating key (fail)
 ---------------------------------------------------------------
 """
 
@@ -79,6 +145,12 @@ import requests
 from server_env import *
 from models import *
 from fixtures import *
+# after the star imports, server_env binds csv to its data file
+import csv
+import gzip
+import io
+import json
+from datetime import datetime, timedelta, timezone
 
 @pytest.fixture(autouse=True)
 def setup(clean_datasets, clean_users, setup_users):
@@ -221,6 +293,27 @@ class Test_DatasetCurator():
         assert response.status_code in SUCCESS
         do_logout(head)
 
+    # Upload gzip compressed CSV
+    def test_PU6(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=gzip.compress(FILE), headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.json()["loaded"]
+        do_logout(head)
+
+    # Upload JSON Lines, columns matched by name
+    def test_PU7(self, curator_dataset):
+        head = do_login(curator_login)
+        rows = list(csv.DictReader(io.StringIO(FILE.decode())))
+        jsonl = "\n".join(json.dumps(row) for row in rows)
+        head_jsonl = dict(head, **{"Content-Type": "application/x-ndjson"})
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=jsonl, headers=head_jsonl)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.json()["loaded"]
+        do_logout(head)
+
     # Delete dataset
     def test_DE1_DE3(self, curator_dataset):
         head = do_login(curator_login)
@@ -274,6 +367,374 @@ class Test_DatasetCurator():
         do_logout(head)
 
 
+class Test_DatasetResumableUpload():
+
+    # upload in chunks and complete
+    def test_RU1(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/uploads", headers=head)
+        assert response.status_code in SUCCESS
+        upload = response.json()["id"]
+        url = URL_DATASET(curator_dataset)+f"/uploads/{upload}"
+
+        offset = 0
+        for start in range(0, len(FILE), 4096):
+            chunk = FILE[start:start+4096]
+            response = requests.put(url, params={"offset": offset}, data=chunk, headers=head)
+            assert response.status_code in SUCCESS
+            offset = response.json()["size"]
+        assert offset == len(FILE)
+
+        response = requests.post(url+"/complete", headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.json()["loaded"]
+        do_logout(head)
+
+    # chunk at the wrong offset (fail) and resume from the stored size
+    def test_RU2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/uploads", headers=head)
+        upload = response.json()["id"]
+        url = URL_DATASET(curator_dataset)+f"/uploads/{upload}"
+
+        response = requests.put(url, params={"offset": 10}, data=FILE[:100], headers=head)
+        assert response.status_code == 409
+        response = requests.get(url, headers=head)
+        assert response.json()["size"] == 0
+        do_logout(head)
+
+    # not owner (fail)
+    def test_RU3(self, curator_dataset):
+        head = do_login(root_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/uploads", headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetVersions():
+
+    # every upload creates a version, replace by default
+    def test_VE1(self, curator_dataset):
+        head = do_login(curator_login)
+        for _ in range(2):
+            response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+            assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset)+"/versions", headers=head)
+        assert response.status_code in SUCCESS
+        versions = response.json()
+        assert [v["version"] for v in versions] == [1, 2]
+        assert versions[1]["mode"] == "replace"
+        assert versions[1]["total_rows"] == versions[0]["total_rows"]
+        assert requests.get(URL_DATASET(curator_dataset), headers=head).json()["version"] == 2
+        do_logout(head)
+
+    # append keeps the rows of the previous version
+    def test_VE2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", params={"mode": "append", "invalid": "reject"}, data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        assert response.json()["version"] == 2
+        versions = requests.get(URL_DATASET(curator_dataset)+"/versions", headers=head).json()
+        assert versions[1]["mode"] == "append"
+        assert versions[1]["total_rows"] == 2 * versions[0]["rows"]
+        do_logout(head)
+
+    # unknown mode (fail)
+    def test_VE3(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", params={"mode": "merge"}, data=FILE, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetInferSchema():
+
+    # the proposal is the body of a new dataset, and the data fits it
+    def test_IS1(self):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASETS+"/infer-schema", params={"name": "inferred", "epsilon": 10}, data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        proposal = response.json()
+        assert proposal["owner"] == curator_login["username"]
+        assert all(col["confirm_bounds"] for col in proposal["schema"] if col["type"]["name"] in ["Int", "Double", "Enum"])
+        response = requests.post(URL_DATASETS, json=proposal, headers=head)
+        assert response.status_code in SUCCESS
+        did = response.json()["id"]
+        response = requests.post(URL_DATASET(did)+"/upload", data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        requests.delete(URL_DATASET(did), headers=head)
+        do_logout(head)
+
+    # not a curator (fail)
+    def test_IS2(self):
+        head = do_login(analyst_login)
+        response = requests.post(URL_DATASETS+"/infer-schema", data=FILE, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetSchemaPatch():
+
+    def test_SC1(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        patch = {
+            "update": [{ "name": "salary", "type": { "name": "Int", "low": 0, "high": 200000 } }],
+            "add": [{ "name": "remote", "type": { "name": "Bool" }, "default": "false" }]
+        }
+        response = requests.patch(URL_DATASET(curator_dataset)+"/schema", json=patch, headers=head)
+        assert response.status_code in SUCCESS
+        assert response.json()["version"] == 2
+        dataset = requests.get(URL_DATASET(curator_dataset), headers=head).json()
+        assert [c["name"] for c in dataset["schema"]] == ["name", "age", "job", "salary", "remote"]
+        versions = requests.get(URL_DATASET(curator_dataset)+"/versions", headers=head).json()
+        assert versions[1]["mode"] == "schema" and versions[1]["total_rows"] == versions[0]["total_rows"]
+        changes = requests.get(URL_DATASET(curator_dataset)+"/schema/changes", headers=head).json()
+        assert len(changes) == 1 and changes[0]["changed_by"] == curator_login["username"]
+        do_logout(head)
+
+    # loaded rows need a value for the added column (fail)
+    def test_SC2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        patch = { "add": [{ "name": "remote", "type": { "name": "Bool" } }] }
+        response = requests.patch(URL_DATASET(curator_dataset)+"/schema", json=patch, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+    # not the owner (fail)
+    def test_SC3(self, root_dataset):
+        head = do_login(curator_login)
+        response = requests.patch(URL_DATASET(root_dataset)+"/schema", json={ "drop": ["name"] }, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetMetadata():
+
+    def test_ML1(self, curator_dataset):
+        head = do_login(curator_login)
+        meta = dict(data_curator, name="Salaries 2024", description="yearly salaries", tags=["jobs", "salaries"], source="survey", contact="curt@example.com")
+        response = requests.post(URL_DATASETS, json=meta, headers=head)
+        assert response.status_code in SUCCESS
+        did = response.json()["id"]
+        dataset = requests.get(URL_DATASET(did), headers=head).json()
+        assert dataset["tags"] == ["jobs", "salaries"] and dataset["source"] == "survey"
+
+        response = requests.get(URL_DATASETS, params={"tag": "jobs", "search": "ries 20"}, headers=head)
+        assert response.status_code in SUCCESS
+        assert [d["id"] for d in response.json()] == [did]
+        assert response.json()[0]["schema"] == dataset["schema"]
+
+        response = requests.get(URL_DATASETS, params={"owner": curator_login["username"], "sort": "name", "order": "desc", "limit": 1}, headers=head)
+        assert response.status_code in SUCCESS
+        assert len(response.json()) == 1 and int(response.headers["X-Total-Count"]) >= 2
+        requests.delete(URL_DATASET(did), headers=head)
+        do_logout(head)
+
+    def test_ML2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.patch(URL_DATASET(curator_dataset), json=dict(data_patch_curator, tags=["jobs"]), headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.patch(URL_DATASET(curator_dataset), json=dict(data_patch_curator, description="salaries"), headers=head)
+        assert response.status_code in SUCCESS
+        dataset = requests.get(URL_DATASET(curator_dataset), headers=head).json()
+        assert dataset["tags"] == ["jobs"] and dataset["description"] == "salaries"
+        do_logout(head)
+
+    # invalid filter (fail)
+    def test_ML3(self):
+        head = do_login(curator_login)
+        response = requests.get(URL_DATASETS, params={"limit": 0}, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetMembers():
+
+    def test_ME1(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.put(URL_DATASET(curator_dataset)+"/members/"+analyst_login["username"], json={"role": "viewer"}, headers=head)
+        assert response.status_code in SUCCESS
+        members = requests.get(URL_DATASET(curator_dataset)+"/members", headers=head).json()
+        assert [(m["handle"], m["role"]) for m in members] == [(curator_login["username"], "owner"), (analyst_login["username"], "viewer")]
+        do_logout(head)
+
+        head = do_login(analyst_login)
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASETS, headers=head)
+        assert [d["id"] for d in response.json()] == [int(curator_dataset)]
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+    def test_ME2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.put(URL_DATASET(curator_dataset)+"/members/root", json={"role": "co-curator"}, headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+        head = do_login(root_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.put(URL_DATASET(curator_dataset)+"/members/"+analyst_login["username"], json={"role": "viewer"}, headers=head)
+        assert response.status_code in FAIL
+        response = requests.delete(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+    def test_ME3(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/transfer", json={"to": "root"}, headers=head)
+        assert response.status_code in SUCCESS
+        assert requests.get(URL_DATASET(curator_dataset), headers=head).json()["owner"] == curator_login["username"]
+        do_logout(head)
+
+        head = do_login(root_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/transfer/accept", headers=head)
+        assert response.status_code in SUCCESS
+        assert requests.get(URL_DATASET(curator_dataset), headers=head).json()["owner"] == "root"
+        members = requests.get(URL_DATASET(curator_dataset)+"/members", headers=head).json()
+        assert [m["handle"] for m in members] == ["root"]
+        do_logout(head)
+
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/upload", data=FILE, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+    def test_ME4(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/transfer", json={"to": "root"}, headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+        head = do_login(analyst_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/transfer/accept", headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+        head = do_login(curator_login)
+        response = requests.delete(URL_DATASET(curator_dataset)+"/transfer", headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset)+"/transfer", headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+class Test_DatasetDeleteRestore():
+
+    def test_DR1(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.delete(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code == 404
+        assert int(curator_dataset) not in [d["id"] for d in requests.get(URL_DATASETS, headers=head).json()]
+        deleted = requests.get(URL_DATASETS, params={"deleted": "true"}, headers=head).json()
+        assert int(curator_dataset) in [d["id"] for d in deleted]
+        assert all("deleted_time" in d for d in deleted)
+
+        response = requests.post(URL_DATASET(curator_dataset)+"/restore", headers=head)
+        assert response.status_code in SUCCESS
+        assert "deleted_time" not in response.json()
+        response = requests.get(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+    def test_DR2(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.delete(URL_DATASET(curator_dataset), headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+        head = do_login(analyst_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/restore", headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+        head = do_login(root_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/restore", headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+    def test_DR3(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASET(curator_dataset)+"/restore", headers=head)
+        assert response.status_code == 404
+        do_logout(head)
+
+# an expiry time of an api key, days from now
+def expires_in(days):
+    return (datetime.now(timezone.utc) + timedelta(days=days)).isoformat()
+
+class Test_DatasetApiKeys():
+
+    def test_AK1(self, curator_dataset):
+        head = do_login(curator_login)
+        response = requests.post(URL_DATASETS, json=data_curator, headers=head)
+        assert response.status_code in SUCCESS
+        other = str(response.json()["id"])
+        key = {"name": "nightly report", "roles": ["Curator"], "datasets": [int(curator_dataset)], "expires_time": expires_in(30)}
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json=key, headers=head)
+        assert response.status_code == 201
+        keyhead = {"Authorization": "Bearer " + response.json()["key"]}
+        do_logout(head)
+
+        response = requests.get(URL_DATASET(curator_dataset), headers=keyhead)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(other), headers=keyhead)
+        assert response.status_code == 403
+        response = requests.get(URL_DATASETS, headers=keyhead)
+        assert [d["id"] for d in response.json()] == [int(curator_dataset)]
+        response = requests.post(URL_DATASETS, json=data_curator, headers=keyhead)
+        assert response.status_code == 403
+        response = requests.get(URL_USER(curator["handle"])+"/keys", headers=keyhead)
+        assert response.status_code == 403
+
+        # at most a year ahead
+        head = do_login(curator_login)
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json={**key, "expires_time": expires_in(400)}, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+    def test_AK2(self, curator_dataset):
+        head = do_login(curator_login)
+        key = {"name": "nightly report", "roles": ["Curator"], "datasets": [int(curator_dataset)], "expires_time": expires_in(30)}
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json=key, headers=head)
+        assert response.status_code == 201
+        created = response.json()
+        keyhead = {"Authorization": "Bearer " + created["key"]}
+
+        response = requests.get(URL_DATASET(curator_dataset), headers=keyhead)
+        assert response.status_code in SUCCESS
+        keys = requests.get(URL_USER(curator["handle"])+"/keys", headers=head).json()
+        assert [k["id"] for k in keys] == [created["id"]]
+        assert "key" not in keys[0] and "last_used_time" in keys[0]
+
+        response = requests.delete(URL_USER(curator["handle"])+"/keys/"+created["id"], headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.get(URL_DATASET(curator_dataset), headers=keyhead)
+        assert response.status_code == 401
+        do_logout(head)
+
+    def test_AK3(self, curator_dataset):
+        key = {"name": "nightly report", "roles": ["Curator"], "expires_time": expires_in(30)}
+        head = do_login(analyst_login)
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json=key, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+        head = do_login(curator_login)
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json={**key, "roles": ["Admin"]}, headers=head)
+        assert response.status_code in FAIL
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json=key, headers=head)
+        assert response.status_code == 201
+        keyhead = {"Authorization": "Bearer " + response.json()["key"]}
+        response = requests.post(URL_USER(curator["handle"])+"/keys", json=key, headers=keyhead)
+        assert response.status_code in FAIL
+        do_logout(head)
+
 class Test_DatasetAnalyst():
 
     # Get all datasets (fail)
@@ -8,6 +8,7 @@ Tests covered:
 - Supported queries on Tumult
 - Supported queries on OpenDP
 - Supported queries on GoogleDP
+- Daily query quota of an allocation
 
 Tests not covered: 
 
@@ -627,3 +628,40 @@ class Test_QueryGoogleDP():
         response = requests.post(URL_Q_EVAL_E("googledp"), json=query, headers=head)
         assert response.status_code in SUCCESS
         do_logout(head)
+
+class Test_QueryQuota():
+
+    def test_daily_quota(self, did):
+        head = do_login(root_login)
+        response = requests.put(URL_QUERY_QUOTA("root", did), json={"daily_queries": 1}, headers=head)
+        assert response.status_code in SUCCESS
+
+        query = QUERY(did, COUNT)
+        response = requests.post(URL_Q_VAL, json=query, headers=head)
+        assert response.status_code in SUCCESS
+        assert response.headers["X-Query-Quota-Remaining"] == "0"
+
+        response = requests.post(URL_Q_EVAL, json=query, headers=head)
+        assert response.status_code == 429
+        assert int(response.headers["Retry-After"]) > 0
+
+        response = requests.get(URL_QUERY_QUOTA("root", did), headers=head)
+        assert response.status_code in SUCCESS
+        assert response.json()["daily_queries"] == 1
+        assert response.json()["used"] == 1
+
+        # back to the default quota, which is no quota
+        response = requests.put(URL_QUERY_QUOTA("root", did), json={"daily_queries": None}, headers=head)
+        assert response.status_code in SUCCESS
+        response = requests.post(URL_Q_EVAL, json=query, headers=head)
+        assert response.status_code in SUCCESS
+        do_logout(head)
+
+    def test_quota_not_allocated(self, did):
+        head = do_login(root_login)
+        response = requests.put(URL_QUERY_QUOTA("nobody", did), json={"daily_queries": 1}, headers=head)
+        assert response.status_code in FAIL
+        response = requests.put(URL_QUERY_QUOTA("root", did), json={"daily_queries": -1}, headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
@@ -54,8 +54,58 @@ DE2   ¬ admin
 ---------------------------------------------------------------
 DER Delete root
 ---------------------------------------------------------------
+
+---------------------------------------------------------------
+SESSIONS (req: own sessions)
+---------------------------------------------------------------
+SE1     two logins are both valid, logout ends one
+SE2     refresh rotates the tokens, reused refresh token revokes the session
+SE3     list and revoke own sessions, other user's session (fail)
+SE4     tokens name their key, which the JWKS publishes unless it is the secret, unknown key (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+ROLES (req: role.manage to change)
+---------------------------------------------------------------
+RO1     built-in roles and permissions are listed
+RO2     custom role gives its permissions, changes apply at once
+RO3   ¬ role.manage, built-in role, role in use, unknown permission (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+ORGANIZATIONS (req: org.manage in the default organization to change)
+---------------------------------------------------------------
+OR1     tenant admin only sees the users and organization of its own organization
+OR2     budget cap of the organization limits its datasets, which other organizations do not see
+OR3     tenant admin manages organizations, roles or other organizations' users,
+        organization with users or default organization is deleted (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+AUDIT (req: audit.read)
+---------------------------------------------------------------
+AU1     user changes and failed logins are recorded with the request id, and exported as CSV
+AU2     tenant admin only reads the audit log of its own organization, ¬ audit.read (fail)
+AU3     hash chain of the audit log is valid, bad filter (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+PASSWORDS (req: self to change, user.manage to unlock)
+---------------------------------------------------------------
+PW1     weak passwords are rejected when users are created or updated (fail)
+PW2     own password is changed with the old one, wrong old password or other user (fail)
+PW3     failed logins lock the account, admin unlocks it, ¬ user.manage (fail)
+---------------------------------------------------------------
+
+---------------------------------------------------------------
+RATE LIMITS
+---------------------------------------------------------------
+RL1     requests return the limit and the requests left of their class, root has no limit
+---------------------------------------------------------------
 """
 
+import base64
+import json
 import requests
 from server_env import *
 from models import *
@@ -289,6 +339,332 @@ class Test_UserOldTokens():
         assert response.status_code in FAIL
     
 
+class Test_UserSessions():
+
+    def test_SE1(self, setup_users):
+        notebook = do_login(curator_login)
+        frontdp = do_login(curator_login)
+        assert requests.get(URL_USER(curator["handle"]), headers=notebook).status_code in SUCCESS
+        assert requests.get(URL_USER(curator["handle"]), headers=frontdp).status_code in SUCCESS
+
+        do_logout(frontdp)
+        assert requests.get(URL_USER(curator["handle"]), headers=frontdp).status_code in FAIL
+        assert requests.get(URL_USER(curator["handle"]), headers=notebook).status_code in SUCCESS
+        do_logout(notebook)
+
+    def test_SE2(self, setup_users):
+        response = requests.post(URL_LOGIN, json=curator_login)
+        assert response.status_code == 200
+        first = response.json()["refresh_token"]
+
+        response = requests.post(URL_REFRESH, json={"refresh_token": first})
+        assert response.status_code == 200
+        tokens = response.json()
+        assert tokens["refresh_token"] != first
+        head = {"Authorization": "Bearer " + tokens["jwt"]}
+        assert requests.get(URL_USER(curator["handle"]), headers=head).status_code in SUCCESS
+
+        # the first refresh token was already used, the session is revoked
+        response = requests.post(URL_REFRESH, json={"refresh_token": first})
+        assert response.status_code == 401
+        assert requests.get(URL_USER(curator["handle"]), headers=head).status_code in FAIL
+        response = requests.post(URL_REFRESH, json={"refresh_token": tokens["refresh_token"]})
+        assert response.status_code == 401
+
+    def test_SE3(self, setup_users):
+        notebook = do_login({**curator_login, "device": "notebook"})
+        frontdp = do_login({**curator_login, "device": "FrontDP"})
+        response = requests.get(URL_SESSIONS, headers=frontdp)
+        assert response.status_code in SUCCESS
+        sessions = {s["device"]: s for s in response.json()}
+        assert sessions["FrontDP"]["current"] and not sessions["notebook"]["current"]
+
+        head = do_login(analyst_login)
+        response = requests.delete(URL_SESSION(sessions["notebook"]["id"]), headers=head)
+        assert response.status_code in FAIL
+        do_logout(head)
+
+        response = requests.delete(URL_SESSION(sessions["notebook"]["id"]), headers=frontdp)
+        assert response.status_code in SUCCESS
+        assert requests.get(URL_USER(curator["handle"]), headers=notebook).status_code in FAIL
+        do_logout(frontdp)
+
+    def test_SE4(self, setup_users):
+        token = requests.post(URL_LOGIN, json=curator_login).json()["jwt"]
+        header = json.loads(base64.urlsafe_b64decode(token.split(".")[0] + "=="))
+        assert header["kid"]
+
+        response = requests.get(URL_JWKS)
+        assert response.status_code in SUCCESS
+        keys = {k["kid"]: k for k in response.json()["keys"]}
+        if header["alg"] == "HS256":
+            assert header["kid"] not in keys
+        else:
+            assert keys[header["kid"]]["alg"] == header["alg"]
+
+        forged = {**header, "kid": "unknown"}
+        encoded = base64.urlsafe_b64encode(json.dumps(forged).encode()).decode().rstrip("=")
+        head = {"Authorization": "Bearer " + ".".join([encoded] + token.split(".")[1:])}
+        assert requests.get(URL_USER(curator["handle"]), headers=head).status_code == 401
+        do_logout({"Authorization": "Bearer " + token})
+
+# a patch of the analyst that only changes its roles
+def with_roles(roles):
+    return {"name": analyst["name"], "password": analyst["password"], "roles": roles}
+
+class Test_UserRoles():
+
+    auditor = {"name": "Auditor", "description": "Reads users", "permissions": ["user.read"]}
+
+    def test_RO1(self, setup_users):
+        head = do_login(analyst_login)
+        response = requests.get(URL_ROLES, headers=head)
+        assert response.status_code in SUCCESS
+        roles = {r["name"]: r for r in response.json()}
+        assert roles["Admin"]["builtin"] and "role.manage" in roles["Admin"]["permissions"]
+        assert "query.run" in roles["Analyst"]["permissions"]
+        response = requests.get(URL_PERMISSIONS, headers=head)
+        assert response.status_code in SUCCESS
+        assert "dataset.upload" in [p["name"] for p in response.json()]
+        do_logout(head)
+
+    def test_RO2(self, setup_users):
+        root = do_login(root_login)
+        response = requests.post(URL_ROLES, json=self.auditor, headers=root)
+        assert response.status_code in SUCCESS
+        response = requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Analyst", "Auditor"]), headers=root)
+        assert response.status_code in SUCCESS
+
+        head = do_login(analyst_login)
+        assert requests.get(URL_USERS, headers=head).status_code in SUCCESS
+        assert requests.post(URL_USERS, json=tester, headers=head).status_code in FAIL
+
+        response = requests.put(URL_ROLE("Auditor"), json={"description": "", "permissions": ["budget.read"]}, headers=root)
+        assert response.status_code in SUCCESS
+        assert response.json()["permissions"] == ["budget.read"]
+        assert requests.get(URL_USERS, headers=head).status_code in FAIL
+        do_logout(head)
+
+        response = requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Analyst"]), headers=root)
+        assert response.status_code in SUCCESS
+        response = requests.delete(URL_ROLE("Auditor"), headers=root)
+        assert response.status_code in SUCCESS
+        do_logout(root)
+
+    def test_RO3(self, setup_users):
+        head = do_login(curator_login)
+        assert requests.post(URL_ROLES, json=self.auditor, headers=head).status_code == 403
+        do_logout(head)
+
+        root = do_login(root_login)
+        response = requests.put(URL_ROLE("Admin"), json={"permissions": ["user.read"]}, headers=root)
+        assert response.status_code == 403
+        response = requests.post(URL_ROLES, json={**self.auditor, "permissions": ["user.everything"]}, headers=root)
+        assert response.status_code == 400
+        response = requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Auditor"]), headers=root)
+        assert response.status_code == 400
+
+        assert requests.post(URL_ROLES, json=self.auditor, headers=root).status_code in SUCCESS
+        assert requests.post(URL_ROLES, json=self.auditor, headers=root).status_code == 409
+        requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Auditor"]), headers=root)
+        assert requests.delete(URL_ROLE("Auditor"), headers=root).status_code == 409
+        requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Analyst"]), headers=root)
+        assert requests.delete(URL_ROLE("Auditor"), headers=root).status_code in SUCCESS
+        do_logout(root)
+
+# the organization of the tenant with a budget cap that leaves room for the given budget
+def with_tenant(root, room):
+    requests.post(URL_ORGANIZATIONS, json=organization, headers=root)
+    used = requests.get(URL_ORGANIZATION(organization["handle"]), headers=root).json()["used"]["epsilon"]
+    response = requests.patch(URL_ORGANIZATION(organization["handle"]), json={**organization, "budget_cap": PureDP(used + room)}, headers=root)
+    assert response.status_code in SUCCESS
+    response = requests.post(URL_USERS, json=tenant, headers=root)
+    assert response.status_code in SUCCESS
+
+class Test_UserOrganizations():
+
+    def test_OR1(self, setup_users):
+        root = do_login(root_login)
+        with_tenant(root, 10)
+        response = requests.get(URL_USERS, headers=root)
+        assert tenant["handle"] in [u["handle"] for u in response.json()]
+
+        head = do_login(tenant_login)
+        response = requests.get(URL_USERS, headers=head)
+        assert response.status_code in SUCCESS
+        assert [u["handle"] for u in response.json()] == [tenant["handle"]]
+        assert requests.get(URL_USER(curator["handle"]), headers=head).status_code == 404
+        response = requests.get(URL_ORGANIZATIONS, headers=head)
+        assert [o["handle"] for o in response.json()] == [organization["handle"]]
+        assert requests.get(URL_ORGANIZATION("default"), headers=head).status_code == 404
+        do_logout(head)
+        do_logout(root)
+
+    def test_OR2(self, setup_users):
+        root = do_login(root_login)
+        with_tenant(root, 3)
+        head = do_login(tenant_login)
+        dataset = {**data_curator, "owner": tenant["handle"]}
+        assert requests.post(URL_DATASETS, json=dataset, headers=head).status_code == 409
+        assert requests.post(URL_DATASETS, json={**dataset, "owner": curator["handle"]}, headers=head).status_code == 403
+
+        current = requests.get(URL_ORGANIZATION(organization["handle"]), headers=root).json()
+        cap = {**organization, "budget_cap": PureDP(current["used"]["epsilon"] + 5)}
+        assert requests.patch(URL_ORGANIZATION(organization["handle"]), json=cap, headers=root).status_code in SUCCESS
+        response = requests.post(URL_DATASETS, json=dataset, headers=head)
+        assert response.status_code in SUCCESS
+        id = str(response.json()["id"])
+        assert requests.get(URL_DATASET(id), headers=head).json()["organization"] == organization["handle"]
+
+        assert requests.get(URL_DATASET(id), headers=root).status_code == 404
+        assert int(id) not in [d["id"] for d in requests.get(URL_DATASETS, headers=root).json()]
+        cap = {**organization, "budget_cap": PureDP(1)}
+        assert requests.patch(URL_ORGANIZATION(organization["handle"]), json=cap, headers=root).status_code == 409
+
+        assert requests.delete(URL_DATASET(id), headers=head).status_code in SUCCESS
+        do_logout(head)
+        do_logout(root)
+
+    def test_OR3(self, setup_users):
+        root = do_login(root_login)
+        with_tenant(root, 10)
+        head = do_login(tenant_login)
+        assert requests.post(URL_ORGANIZATIONS, json={"handle": "other", "name": "Other"}, headers=head).status_code == 403
+        assert requests.post(URL_ROLES, json={"name": "Auditor", "permissions": ["user.read"]}, headers=head).status_code == 403
+        assert requests.post(URL_USERS, json={**tester, "roles": ["Analyst"], "organization": "default"}, headers=head).status_code == 403
+        assert requests.delete(URL_USER(analyst["handle"]), headers=head).status_code == 404
+        do_logout(head)
+
+        assert requests.delete(URL_ORGANIZATION(organization["handle"]), headers=root).status_code == 409
+        assert requests.delete(URL_ORGANIZATION("default"), headers=root).status_code == 403
+        assert requests.post(URL_USERS, json={**tester, "roles": ["Analyst"], "organization": "nowhere"}, headers=root).status_code == 400
+        do_logout(root)
+
+class Test_UserAudit():
+
+    def test_AU1(self, setup_users):
+        root = do_login(root_login)
+        requests.post(URL_LOGIN, json={**analyst_login, "password": "wrong"})
+        response = requests.patch(URL_USER(analyst["handle"]), json=with_roles(["Analyst", "Curator"]), headers={**root, "X-Request-Id": "au1-patch"})
+        assert response.status_code in SUCCESS
+        assert response.headers["X-Request-Id"] == "au1-patch"
+
+        response = requests.get(URL_AUDIT, params={"target": "user/" + analyst["handle"], "limit": 10}, headers=root)
+        assert response.status_code in SUCCESS
+        entries = response.json()
+        assert int(response.headers["X-Total-Count"]) >= 2
+        update = entries[0]
+        assert update["action"] == "user.update" and update["actor"] == "root" and update["request_id"] == "au1-patch"
+        assert update["before"]["roles"] == ["Analyst"] and sorted(update["after"]["roles"]) == ["Analyst", "Curator"]
+        assert "password" not in update["after"]
+        assert "auth.login_failed" in [e["action"] for e in entries]
+
+        response = requests.get(URL_AUDIT_EXPORT, params={"action": "user", "actor": "root"}, headers=root)
+        assert response.status_code in SUCCESS
+        assert response.headers["Content-Type"].startswith("text/csv")
+        lines = response.text.splitlines()
+        assert lines[0].startswith("id,time,actor,organization,action,target")
+        assert "au1-patch" in response.text
+        do_logout(root)
+
+    def test_AU2(self, setup_users):
+        root = do_login(root_login)
+        with_tenant(root, 10)
+        head = do_login(tenant_login)
+        response = requests.get(URL_AUDIT, headers=head)
+        assert response.status_code in SUCCESS
+        assert {e["organization"] for e in response.json()} == {organization["handle"]}
+        assert "auth.login" in [e["action"] for e in response.json()]
+        response = requests.get(URL_AUDIT, params={"organization": "default"}, headers=head)
+        assert {e["organization"] for e in response.json()} == {organization["handle"]}
+        assert requests.get(URL_AUDIT_VERIFY, headers=head).status_code == 403
+        do_logout(head)
+
+        response = requests.get(URL_AUDIT, params={"organization": organization["handle"]}, headers=root)
+        assert {e["organization"] for e in response.json()} == {organization["handle"]}
+
+        head = do_login(analyst_login)
+        assert requests.get(URL_AUDIT, headers=head).status_code == 403
+        assert requests.get(URL_AUDIT_EXPORT, headers=head).status_code == 403
+        do_logout(head)
+        do_logout(root)
+
+    def test_AU3(self, setup_users):
+        root = do_login(root_login)
+        response = requests.get(URL_AUDIT_VERIFY, headers=root)
+        assert response.status_code in SUCCESS
+        assert response.json()["valid"] and response.json()["entries"] > 0
+        assert requests.get(URL_AUDIT, params={"from": "yesterday"}, headers=root).status_code == 400
+        do_logout(root)
+
+class Test_UserPasswords():
+
+    def test_PW1(self, setup_users):
+        root = do_login(root_login)
+        for weak in ["short-1A", "alllowercaseletters", "Curtarne-1234", "a" * 80 + "B1"]:
+            response = requests.post(URL_USERS, json={**curana, "password": weak}, headers=root)
+            assert response.status_code == 400
+        response = requests.patch(URL_USER(analyst["handle"]), json={**with_roles(["Analyst"]), "password": "123123"}, headers=root)
+        assert response.status_code == 400
+        do_logout(root)
+
+    def test_PW2(self, setup_users):
+        head = do_login(analyst_login)
+        other = do_login(analyst_login)
+        changed = "Analyst-pass-2"
+        response = requests.put(URL_USER_PASSWORD(analyst["handle"]), json={"old_password": "Wrong-pass-1", "new_password": changed}, headers=head)
+        assert response.status_code == 403
+        response = requests.put(URL_USER_PASSWORD(curator["handle"]), json={"old_password": curator["password"], "new_password": changed}, headers=head)
+        assert response.status_code == 403
+        response = requests.put(URL_USER_PASSWORD(analyst["handle"]), json={"old_password": analyst["password"], "new_password": "weak"}, headers=head)
+        assert response.status_code == 400
+
+        response = requests.put(URL_USER_PASSWORD(analyst["handle"]), json={"old_password": analyst["password"], "new_password": changed}, headers=head)
+        assert response.status_code == 204
+        # the other session is logged out, the one that changed the password is not
+        assert requests.get(URL_USER(analyst["handle"]), headers=other).status_code == 401
+        assert requests.get(URL_USER(analyst["handle"]), headers=head).status_code in SUCCESS
+        assert requests.post(URL_LOGIN, json=analyst_login).status_code == 401
+        do_logout(do_login({**analyst_login, "password": changed}))
+        do_logout(head)
+
+    def test_PW3(self, setup_users):
+        for _ in range(5):
+            response = requests.post(URL_LOGIN, json={**curator_login, "password": "Wrong-pass-1"})
+            assert response.status_code == 401
+        response = requests.post(URL_LOGIN, json=curator_login)
+        assert response.status_code == 429
+        assert int(response.headers["Retry-After"]) > 0
+
+        head = do_login(analyst_login)
+        assert requests.delete(URL_USER_LOCKOUT(curator["handle"]), headers=head).status_code == 403
+        do_logout(head)
+
+        root = do_login(root_login)
+        assert requests.delete(URL_USER_LOCKOUT(curator["handle"]), headers=root).status_code == 204
+        response = requests.get(URL_AUDIT, params={"target": "user/" + curator["handle"], "action": "auth"}, headers=root)
+        assert "auth.lockout" in [e["action"] for e in response.json()]
+        do_logout(root)
+        do_logout(do_login(curator_login))
+
+class Test_UserRateLimits():
+
+    def test_RL1(self, setup_users):
+        head = do_login(analyst_login)
+        first = requests.get(URL_USER(analyst["handle"]), headers=head)
+        second = requests.get(URL_USER(analyst["handle"]), headers=head)
+        assert first.status_code in SUCCESS and second.status_code in SUCCESS
+        limit = int(first.headers["X-RateLimit-Limit"])
+        assert 0 <= int(second.headers["X-RateLimit-Remaining"]) < limit
+        assert 0 < int(second.headers["X-RateLimit-Reset"]) <= 60
+        do_logout(head)
+
+        head = do_login(root_login)
+        response = requests.get(URL_USER("root"), headers=head)
+        assert response.status_code in SUCCESS
+        assert "X-RateLimit-Limit" not in response.headers
+        do_logout(head)
+
 class Test_UserPostClean():
 
     def test_GO5_GAD(self):